@@ -0,0 +1,531 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: eph/provider/v1/provider.proto
+
+// Package eph.provider.v1 is the protocol between ephd and out-of-process
+// provider plugins. ephd launches a plugin binary, which serves Provider on
+// the Unix socket named by EPH_PLUGIN_SOCKET, and calls Handshake before
+// anything else.
+
+package providerv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Provider_Handshake_FullMethodName             = "/eph.provider.v1.Provider/Handshake"
+	Provider_CheckAccess_FullMethodName           = "/eph.provider.v1.Provider/CheckAccess"
+	Provider_CreateEnvironment_FullMethodName     = "/eph.provider.v1.Provider/CreateEnvironment"
+	Provider_DestroyEnvironment_FullMethodName    = "/eph.provider.v1.Provider/DestroyEnvironment"
+	Provider_ScaleEnvironment_FullMethodName      = "/eph.provider.v1.Provider/ScaleEnvironment"
+	Provider_ListEnvironments_FullMethodName      = "/eph.provider.v1.Provider/ListEnvironments"
+	Provider_GetEnvironmentStatus_FullMethodName  = "/eph.provider.v1.Provider/GetEnvironmentStatus"
+	Provider_StreamLogs_FullMethodName            = "/eph.provider.v1.Provider/StreamLogs"
+	Provider_GetMetrics_FullMethodName            = "/eph.provider.v1.Provider/GetMetrics"
+	Provider_GetCapabilities_FullMethodName       = "/eph.provider.v1.Provider/GetCapabilities"
+	Provider_ValidateConfiguration_FullMethodName = "/eph.provider.v1.Provider/ValidateConfiguration"
+)
+
+// ProviderClient is the client API for Provider service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ProviderClient interface {
+	// Plugin lifecycle. Handshake negotiates the protocol version and hands
+	// the plugin the project's configuration; it is called again whenever the
+	// plugin is restarted.
+	Handshake(ctx context.Context, in *HandshakeRequest, opts ...grpc.CallOption) (*HandshakeResponse, error)
+	CheckAccess(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Empty, error)
+	// Lifecycle Management
+	CreateEnvironment(ctx context.Context, in *CreateEnvironmentRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[OperationUpdate], error)
+	DestroyEnvironment(ctx context.Context, in *DestroyEnvironmentRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[OperationUpdate], error)
+	ScaleEnvironment(ctx context.Context, in *ScaleEnvironmentRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[OperationUpdate], error)
+	// Status & Monitoring
+	ListEnvironments(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*ListEnvironmentsResponse, error)
+	GetEnvironmentStatus(ctx context.Context, in *GetEnvironmentStatusRequest, opts ...grpc.CallOption) (*EnvironmentStatus, error)
+	StreamLogs(ctx context.Context, in *StreamLogsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[LogEntry], error)
+	GetMetrics(ctx context.Context, in *GetMetricsRequest, opts ...grpc.CallOption) (*EnvironmentMetrics, error)
+	// Provider Capabilities
+	GetCapabilities(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*ProviderCapabilities, error)
+	ValidateConfiguration(ctx context.Context, in *ValidateConfigurationRequest, opts ...grpc.CallOption) (*ValidationResult, error)
+}
+
+type providerClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewProviderClient(cc grpc.ClientConnInterface) ProviderClient {
+	return &providerClient{cc}
+}
+
+func (c *providerClient) Handshake(ctx context.Context, in *HandshakeRequest, opts ...grpc.CallOption) (*HandshakeResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(HandshakeResponse)
+	err := c.cc.Invoke(ctx, Provider_Handshake_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *providerClient) CheckAccess(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, Provider_CheckAccess_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *providerClient) CreateEnvironment(ctx context.Context, in *CreateEnvironmentRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[OperationUpdate], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Provider_ServiceDesc.Streams[0], Provider_CreateEnvironment_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[CreateEnvironmentRequest, OperationUpdate]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Provider_CreateEnvironmentClient = grpc.ServerStreamingClient[OperationUpdate]
+
+func (c *providerClient) DestroyEnvironment(ctx context.Context, in *DestroyEnvironmentRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[OperationUpdate], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Provider_ServiceDesc.Streams[1], Provider_DestroyEnvironment_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[DestroyEnvironmentRequest, OperationUpdate]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Provider_DestroyEnvironmentClient = grpc.ServerStreamingClient[OperationUpdate]
+
+func (c *providerClient) ScaleEnvironment(ctx context.Context, in *ScaleEnvironmentRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[OperationUpdate], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Provider_ServiceDesc.Streams[2], Provider_ScaleEnvironment_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ScaleEnvironmentRequest, OperationUpdate]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Provider_ScaleEnvironmentClient = grpc.ServerStreamingClient[OperationUpdate]
+
+func (c *providerClient) ListEnvironments(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*ListEnvironmentsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListEnvironmentsResponse)
+	err := c.cc.Invoke(ctx, Provider_ListEnvironments_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *providerClient) GetEnvironmentStatus(ctx context.Context, in *GetEnvironmentStatusRequest, opts ...grpc.CallOption) (*EnvironmentStatus, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(EnvironmentStatus)
+	err := c.cc.Invoke(ctx, Provider_GetEnvironmentStatus_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *providerClient) StreamLogs(ctx context.Context, in *StreamLogsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[LogEntry], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Provider_ServiceDesc.Streams[3], Provider_StreamLogs_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[StreamLogsRequest, LogEntry]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Provider_StreamLogsClient = grpc.ServerStreamingClient[LogEntry]
+
+func (c *providerClient) GetMetrics(ctx context.Context, in *GetMetricsRequest, opts ...grpc.CallOption) (*EnvironmentMetrics, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(EnvironmentMetrics)
+	err := c.cc.Invoke(ctx, Provider_GetMetrics_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *providerClient) GetCapabilities(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*ProviderCapabilities, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ProviderCapabilities)
+	err := c.cc.Invoke(ctx, Provider_GetCapabilities_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *providerClient) ValidateConfiguration(ctx context.Context, in *ValidateConfigurationRequest, opts ...grpc.CallOption) (*ValidationResult, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ValidationResult)
+	err := c.cc.Invoke(ctx, Provider_ValidateConfiguration_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ProviderServer is the server API for Provider service.
+// All implementations must embed UnimplementedProviderServer
+// for forward compatibility.
+type ProviderServer interface {
+	// Plugin lifecycle. Handshake negotiates the protocol version and hands
+	// the plugin the project's configuration; it is called again whenever the
+	// plugin is restarted.
+	Handshake(context.Context, *HandshakeRequest) (*HandshakeResponse, error)
+	CheckAccess(context.Context, *Empty) (*Empty, error)
+	// Lifecycle Management
+	CreateEnvironment(*CreateEnvironmentRequest, grpc.ServerStreamingServer[OperationUpdate]) error
+	DestroyEnvironment(*DestroyEnvironmentRequest, grpc.ServerStreamingServer[OperationUpdate]) error
+	ScaleEnvironment(*ScaleEnvironmentRequest, grpc.ServerStreamingServer[OperationUpdate]) error
+	// Status & Monitoring
+	ListEnvironments(context.Context, *Empty) (*ListEnvironmentsResponse, error)
+	GetEnvironmentStatus(context.Context, *GetEnvironmentStatusRequest) (*EnvironmentStatus, error)
+	StreamLogs(*StreamLogsRequest, grpc.ServerStreamingServer[LogEntry]) error
+	GetMetrics(context.Context, *GetMetricsRequest) (*EnvironmentMetrics, error)
+	// Provider Capabilities
+	GetCapabilities(context.Context, *Empty) (*ProviderCapabilities, error)
+	ValidateConfiguration(context.Context, *ValidateConfigurationRequest) (*ValidationResult, error)
+	mustEmbedUnimplementedProviderServer()
+}
+
+// UnimplementedProviderServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedProviderServer struct{}
+
+func (UnimplementedProviderServer) Handshake(context.Context, *HandshakeRequest) (*HandshakeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Handshake not implemented")
+}
+func (UnimplementedProviderServer) CheckAccess(context.Context, *Empty) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CheckAccess not implemented")
+}
+func (UnimplementedProviderServer) CreateEnvironment(*CreateEnvironmentRequest, grpc.ServerStreamingServer[OperationUpdate]) error {
+	return status.Errorf(codes.Unimplemented, "method CreateEnvironment not implemented")
+}
+func (UnimplementedProviderServer) DestroyEnvironment(*DestroyEnvironmentRequest, grpc.ServerStreamingServer[OperationUpdate]) error {
+	return status.Errorf(codes.Unimplemented, "method DestroyEnvironment not implemented")
+}
+func (UnimplementedProviderServer) ScaleEnvironment(*ScaleEnvironmentRequest, grpc.ServerStreamingServer[OperationUpdate]) error {
+	return status.Errorf(codes.Unimplemented, "method ScaleEnvironment not implemented")
+}
+func (UnimplementedProviderServer) ListEnvironments(context.Context, *Empty) (*ListEnvironmentsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListEnvironments not implemented")
+}
+func (UnimplementedProviderServer) GetEnvironmentStatus(context.Context, *GetEnvironmentStatusRequest) (*EnvironmentStatus, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetEnvironmentStatus not implemented")
+}
+func (UnimplementedProviderServer) StreamLogs(*StreamLogsRequest, grpc.ServerStreamingServer[LogEntry]) error {
+	return status.Errorf(codes.Unimplemented, "method StreamLogs not implemented")
+}
+func (UnimplementedProviderServer) GetMetrics(context.Context, *GetMetricsRequest) (*EnvironmentMetrics, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetMetrics not implemented")
+}
+func (UnimplementedProviderServer) GetCapabilities(context.Context, *Empty) (*ProviderCapabilities, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetCapabilities not implemented")
+}
+func (UnimplementedProviderServer) ValidateConfiguration(context.Context, *ValidateConfigurationRequest) (*ValidationResult, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ValidateConfiguration not implemented")
+}
+func (UnimplementedProviderServer) mustEmbedUnimplementedProviderServer() {}
+func (UnimplementedProviderServer) testEmbeddedByValue()                  {}
+
+// UnsafeProviderServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ProviderServer will
+// result in compilation errors.
+type UnsafeProviderServer interface {
+	mustEmbedUnimplementedProviderServer()
+}
+
+func RegisterProviderServer(s grpc.ServiceRegistrar, srv ProviderServer) {
+	// If the following call pancis, it indicates UnimplementedProviderServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Provider_ServiceDesc, srv)
+}
+
+func _Provider_Handshake_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HandshakeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProviderServer).Handshake(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Provider_Handshake_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProviderServer).Handshake(ctx, req.(*HandshakeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Provider_CheckAccess_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProviderServer).CheckAccess(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Provider_CheckAccess_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProviderServer).CheckAccess(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Provider_CreateEnvironment_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(CreateEnvironmentRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ProviderServer).CreateEnvironment(m, &grpc.GenericServerStream[CreateEnvironmentRequest, OperationUpdate]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Provider_CreateEnvironmentServer = grpc.ServerStreamingServer[OperationUpdate]
+
+func _Provider_DestroyEnvironment_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(DestroyEnvironmentRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ProviderServer).DestroyEnvironment(m, &grpc.GenericServerStream[DestroyEnvironmentRequest, OperationUpdate]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Provider_DestroyEnvironmentServer = grpc.ServerStreamingServer[OperationUpdate]
+
+func _Provider_ScaleEnvironment_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ScaleEnvironmentRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ProviderServer).ScaleEnvironment(m, &grpc.GenericServerStream[ScaleEnvironmentRequest, OperationUpdate]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Provider_ScaleEnvironmentServer = grpc.ServerStreamingServer[OperationUpdate]
+
+func _Provider_ListEnvironments_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProviderServer).ListEnvironments(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Provider_ListEnvironments_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProviderServer).ListEnvironments(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Provider_GetEnvironmentStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetEnvironmentStatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProviderServer).GetEnvironmentStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Provider_GetEnvironmentStatus_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProviderServer).GetEnvironmentStatus(ctx, req.(*GetEnvironmentStatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Provider_StreamLogs_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamLogsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ProviderServer).StreamLogs(m, &grpc.GenericServerStream[StreamLogsRequest, LogEntry]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Provider_StreamLogsServer = grpc.ServerStreamingServer[LogEntry]
+
+func _Provider_GetMetrics_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetMetricsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProviderServer).GetMetrics(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Provider_GetMetrics_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProviderServer).GetMetrics(ctx, req.(*GetMetricsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Provider_GetCapabilities_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProviderServer).GetCapabilities(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Provider_GetCapabilities_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProviderServer).GetCapabilities(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Provider_ValidateConfiguration_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ValidateConfigurationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProviderServer).ValidateConfiguration(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Provider_ValidateConfiguration_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProviderServer).ValidateConfiguration(ctx, req.(*ValidateConfigurationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Provider_ServiceDesc is the grpc.ServiceDesc for Provider service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Provider_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "eph.provider.v1.Provider",
+	HandlerType: (*ProviderServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Handshake",
+			Handler:    _Provider_Handshake_Handler,
+		},
+		{
+			MethodName: "CheckAccess",
+			Handler:    _Provider_CheckAccess_Handler,
+		},
+		{
+			MethodName: "ListEnvironments",
+			Handler:    _Provider_ListEnvironments_Handler,
+		},
+		{
+			MethodName: "GetEnvironmentStatus",
+			Handler:    _Provider_GetEnvironmentStatus_Handler,
+		},
+		{
+			MethodName: "GetMetrics",
+			Handler:    _Provider_GetMetrics_Handler,
+		},
+		{
+			MethodName: "GetCapabilities",
+			Handler:    _Provider_GetCapabilities_Handler,
+		},
+		{
+			MethodName: "ValidateConfiguration",
+			Handler:    _Provider_ValidateConfiguration_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "CreateEnvironment",
+			Handler:       _Provider_CreateEnvironment_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "DestroyEnvironment",
+			Handler:       _Provider_DestroyEnvironment_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "ScaleEnvironment",
+			Handler:       _Provider_ScaleEnvironment_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "StreamLogs",
+			Handler:       _Provider_StreamLogs_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "eph/provider/v1/provider.proto",
+}
@@ -4,7 +4,13 @@ import (
 	"context"
 	"os"
 
+	_ "github.com/ephlabs/eph/internal/informers/gitea"
+	_ "github.com/ephlabs/eph/internal/informers/github"
+	_ "github.com/ephlabs/eph/internal/informers/gitlab"
 	"github.com/ephlabs/eph/internal/log"
+	_ "github.com/ephlabs/eph/internal/providers/compose"
+	_ "github.com/ephlabs/eph/internal/providers/fake"
+	_ "github.com/ephlabs/eph/internal/providers/kubernetes"
 	"github.com/ephlabs/eph/internal/server"
 )
 
@@ -15,7 +21,7 @@ func main() {
 	log.Info(context.Background(), "Starting Eph Daemon - What the eph?")
 
 	if err := server.Run(); err != nil {
-		log.Error(context.Background(), "Server failed to start", "error", err)
+		log.Error(context.Background(), "ephd failed", "error", err)
 		os.Exit(1)
 	}
 }
@@ -28,8 +28,6 @@ func TestCLIIntegration(t *testing.T) {
 		{"wtf", []string{"wtf"}, "diagnostic"},
 		{"up", []string{"up"}, "Environment creation coming soon"},
 		{"down", []string{"down"}, "Environment destruction coming soon"},
-		{"list", []string{"list"}, "Environment listing coming soon"},
-		{"logs", []string{"logs"}, "Log streaming coming soon"},
 		{"auth", []string{"auth", "login"}, "Authentication coming soon"},
 		{"completion", []string{"completion", "bash"}, "# bash completion for eph"},
 	}
@@ -0,0 +1,47 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// defaultServerURL is $EPH_SERVER, or an ephd on this machine.
+func defaultServerURL() string {
+	if u := os.Getenv("EPH_SERVER"); u != "" {
+		return u
+	}
+	return "http://localhost:8080"
+}
+
+// apiGet requests path from ephd's API. A response other than 200 is
+// returned as an error carrying the API's message.
+func apiGet(ctx context.Context, path string, query url.Values) (*http.Response, error) {
+	u := strings.TrimSuffix(serverURL, "/") + "/api/v1/" + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("contacting ephd: %w", err)
+	}
+	if resp.StatusCode == http.StatusOK {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	var body struct {
+		Message string `json:"message"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil || body.Message == "" {
+		return nil, fmt.Errorf("ephd: %s", resp.Status)
+	}
+	return nil, fmt.Errorf("ephd: %s", body.Message)
+}
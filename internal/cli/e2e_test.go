@@ -0,0 +1,105 @@
+package cli
+
+import (
+	"context"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ephlabs/eph/internal/config"
+	"github.com/ephlabs/eph/internal/controller"
+	"github.com/ephlabs/eph/internal/providers"
+	"github.com/ephlabs/eph/internal/providers/fake"
+	"github.com/ephlabs/eph/internal/reconciler"
+	"github.com/ephlabs/eph/internal/server"
+)
+
+const e2eProject = `version: "1.0"
+name: myapp
+providers:
+  primary: fake
+triggers:
+  - type: pr_label
+    labels: [preview]
+environment:
+  base_domain: preview.example.com
+  name_template: "{project}-pr-{pr_number}"
+fake:
+  fail_refs: ["fail-*"]
+  log_interval: 1s
+`
+
+type staticRefs []controller.Ref
+
+func (r staticRefs) ListRefs(context.Context) ([]controller.Ref, error) { return r, nil }
+
+// TestEndToEnd runs the fake provider under the controller and reconciler,
+// as ephd does, and reads the environments back through the API with the
+// CLI.
+func TestEndToEnd(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	project, err := config.Parse(filepath.Join(t.TempDir(), "eph.yaml"), []byte(e2eProject))
+	require.NoError(t, err)
+	require.NoError(t, config.Validate(project))
+	provider := fake.New(project, fake.OptionsFromConfig(project.Fake))
+	ctrl, err := controller.New(controller.Options{
+		Project:  project,
+		Provider: provider,
+		Refs: staticRefs{
+			{SourceRef: controller.PRRef("org/myapp", 1, "feature", "aaa"), Labels: []string{"preview"}},
+			{SourceRef: controller.PRRef("org/myapp", 2, "fail-migrations", "bbb"), Labels: []string{"preview"}},
+		},
+		NameKey: []byte("test-key"),
+	})
+	require.NoError(t, err)
+	rec := reconciler.New(reconciler.Options[string, controller.Ref, providers.Instance]{
+		Name:    "environments",
+		Desired: reconciler.SourceFunc[string, controller.Ref](ctrl.Desired),
+		Actual:  reconciler.SourceFunc[string, providers.Instance](ctrl.Actual),
+		Handler: ctrl,
+	})
+	go func() { _ = rec.Run(ctx) }()
+
+	ephd := server.New(nil)
+	ephd.SetEnvironments(ctrl)
+	ephd.SetTelemetry(ctrl)
+	srv := httptest.NewServer(ephd.Handler())
+	t.Cleanup(srv.Close)
+
+	require.Eventually(t, func() bool {
+		phases := map[string]controller.Phase{}
+		for _, env := range ctrl.Environments() {
+			phases[env.Name] = env.Phase
+		}
+		return phases["myapp-pr-1"] == controller.PhaseReady && phases["myapp-pr-2"] == controller.PhaseFailed
+	}, 5*time.Second, 10*time.Millisecond)
+
+	stdout, _, err := runAgainst(t, srv, "list")
+	require.NoError(t, err)
+	assert.Regexp(t, `NAME\s+PHASE\s+PROVIDER\s+SOURCE\s+URL\n`, stdout)
+	assert.Regexp(t, `myapp-pr-1\s+Ready\s+fake\s+org/myapp/pr/1\s+http://myapp-pr-1\.preview\.example\.com\n`, stdout)
+	assert.Regexp(t, `myapp-pr-2\s+Failed\s+org/myapp/pr/2\s+-\n`, stdout)
+
+	stdout, _, err = runAgainst(t, srv, "status")
+	require.NoError(t, err)
+	assert.Contains(t, stdout, "Environments: 2, 1 ready\n")
+
+	stdout, _, err = runAgainst(t, srv, "status", "myapp-pr-2")
+	require.NoError(t, err)
+	assert.Contains(t, stdout, "Phase:    Failed\n")
+	assert.Contains(t, stdout, "Message:  ")
+
+	stdout, _, err = runAgainst(t, srv, "logs", "myapp-pr-1", "--tail", "1")
+	require.NoError(t, err)
+	assert.Regexp(t, `^app \| \S`, stdout)
+
+	stdout, _, err = runAgainst(t, srv, "metrics", "myapp-pr-1")
+	require.NoError(t, err)
+	assert.Contains(t, stdout, "CPU:")
+}
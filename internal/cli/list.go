@@ -1,21 +1,71 @@
 package cli
 
 import (
+	"encoding/json"
 	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
 
 	"github.com/spf13/cobra"
+
+	"github.com/ephlabs/eph/internal/server"
 )
 
 var listCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List ephemeral environments",
-	Long:  "List all ephemeral environments managed by Eph.",
-	Run: func(_ *cobra.Command, _ []string) {
-		fmt.Println("📋 Environment listing coming soon!")
-		fmt.Println("You'll soon be able to see all your ephemeral environments here.")
+	Long: `List all ephemeral environments managed by Eph.
+
+Environments reached through a shared host, as with header-based routing,
+are listed with the header to send.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		resp, err := apiGet(cmd.Context(), "environments", nil)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		var list server.EnvironmentList
+		if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+			return fmt.Errorf("reading environments: %w", err)
+		}
+
+		out := cmd.OutOrStdout()
+		if len(list.Environments) == 0 {
+			fmt.Fprintln(out, "No environments.")
+			if list.Message != "" {
+				fmt.Fprintln(out, list.Message)
+			}
+			return nil
+		}
+		w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tPHASE\tPROVIDER\tSOURCE\tURL")
+		for _, env := range list.Environments {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", env.Name, env.Phase, env.Provider, env.Key, reach(env))
+		}
+		return w.Flush()
 	},
 }
 
+// reach describes how to reach env: its URL, and the headers to send with
+// requests to it.
+func reach(env server.Environment) string {
+	if env.URL == "" {
+		return "-"
+	}
+	names := make([]string, 0, len(env.Headers))
+	for name := range env.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := []string{env.URL}
+	for _, name := range names {
+		parts = append(parts, fmt.Sprintf("(%s: %s)", name, env.Headers[name]))
+	}
+	return strings.Join(parts, " ")
+}
+
 func init() {
 	rootCmd.AddCommand(listCmd)
 }
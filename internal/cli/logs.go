@@ -1,21 +1,79 @@
 package cli
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net/url"
+	"strconv"
 
 	"github.com/spf13/cobra"
+
+	"github.com/ephlabs/eph/internal/server"
 )
 
+var logsOpts struct {
+	service string
+	follow  bool
+	tail    int
+	since   string
+}
+
 var logsCmd = &cobra.Command{
-	Use:   "logs",
+	Use:   "logs <environment>",
 	Short: "Stream logs from an environment",
-	Long:  "Stream logs from an ephemeral environment to debug issues.",
-	Run: func(_ *cobra.Command, _ []string) {
-		fmt.Println("📜 Log streaming coming soon!")
-		fmt.Println("Soon you'll be able to see what the eph is happening in your environments.")
+	Long: `Stream logs from an ephemeral environment to debug issues.
+
+ephd fetches the logs from the provider hosting the environment. The
+environment is given by name, as shown in its URL.`,
+	Example: `  eph logs myapp-calm-river-42 --service api --tail 100
+  eph logs myapp-calm-river-42 --follow --since 10m`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		query := url.Values{}
+		if logsOpts.service != "" {
+			query.Set("service", logsOpts.service)
+		}
+		if logsOpts.follow {
+			query.Set("follow", "true")
+		}
+		if logsOpts.tail > 0 {
+			query.Set("tail", strconv.Itoa(logsOpts.tail))
+		}
+		if logsOpts.since != "" {
+			query.Set("since", logsOpts.since)
+		}
+		resp, err := apiGet(cmd.Context(), "environments/"+url.PathEscape(args[0])+"/logs", query)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		dec := json.NewDecoder(resp.Body)
+		for {
+			var e server.LogEntry
+			err := dec.Decode(&e)
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("reading logs: %w", err)
+			}
+			out := cmd.OutOrStdout()
+			if e.Stream == "stderr" {
+				out = cmd.ErrOrStderr()
+			}
+			fmt.Fprintf(out, "%s | %s\n", e.Service, e.Line)
+		}
 	},
 }
 
 func init() {
+	f := logsCmd.Flags()
+	f.StringVar(&logsOpts.service, "service", "", "only show this service's logs")
+	f.BoolVarP(&logsOpts.follow, "follow", "f", false, "keep streaming new logs")
+	f.IntVar(&logsOpts.tail, "tail", 0, "number of recent lines to show (default: all)")
+	f.StringVar(&logsOpts.since, "since", "", "only show logs newer than a duration such as 10m, or an RFC 3339 time")
 	rootCmd.AddCommand(logsCmd)
 }
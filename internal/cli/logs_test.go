@@ -0,0 +1,65 @@
+package cli
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeEphd serves the logs and metrics of calm-river.
+func fakeEphd(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/environments/calm-river/logs", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "service=web&tail=5", r.URL.RawQuery)
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Write([]byte(`{"service":"web","stream":"stdout","line":"GET / 200"}` + "\n" +
+			`{"service":"web","stream":"stderr","line":"warning: slow request"}` + "\n"))
+	})
+	mux.HandleFunc("GET /api/v1/environments/calm-river/metrics", func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte(`{"cpu_millicores":120,"memory_bytes":201326592,"custom":{"uptime_seconds":42}}`))
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error":"Not found","message":"missing: environment not found"}`))
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func runAgainst(t *testing.T, srv *httptest.Server, args ...string) (string, string, error) {
+	t.Helper()
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+	rootCmd.SetOut(stdout)
+	rootCmd.SetErr(stderr)
+	t.Cleanup(func() {
+		rootCmd.SetOut(nil)
+		rootCmd.SetErr(nil)
+	})
+	rootCmd.SetArgs(append(args, "--server", srv.URL))
+	err := rootCmd.Execute()
+	return stdout.String(), stderr.String(), err
+}
+
+func TestLogsCommand(t *testing.T) {
+	srv := fakeEphd(t)
+
+	stdout, stderr, err := runAgainst(t, srv, "logs", "calm-river", "--service", "web", "--tail", "5")
+	require.NoError(t, err)
+	assert.Equal(t, "web | GET / 200\n", stdout)
+	assert.Equal(t, "web | warning: slow request\n", stderr)
+
+	_, _, err = runAgainst(t, srv, "logs", "missing", "--service", "", "--tail", "0")
+	assert.EqualError(t, err, "ephd: missing: environment not found")
+}
+
+func TestMetricsCommand(t *testing.T) {
+	stdout, _, err := runAgainst(t, fakeEphd(t), "metrics", "calm-river")
+	require.NoError(t, err)
+	assert.Equal(t, "CPU:    120m\nMemory: 192Mi\nuptime_seconds: 42\n", stdout)
+}
@@ -0,0 +1,49 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ephlabs/eph/internal/server"
+)
+
+var metricsCmd = &cobra.Command{
+	Use:   "metrics <environment>",
+	Short: "Show an environment's resource usage",
+	Long: `Show the current resource usage of an ephemeral environment, as reported
+by the provider hosting it.`,
+	Example: `  eph metrics myapp-calm-river-42`,
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		resp, err := apiGet(cmd.Context(), "environments/"+url.PathEscape(args[0])+"/metrics", nil)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		var m server.Metrics
+		if err := json.NewDecoder(resp.Body).Decode(&m); err != nil {
+			return fmt.Errorf("reading metrics: %w", err)
+		}
+
+		out := cmd.OutOrStdout()
+		fmt.Fprintf(out, "CPU:    %dm\n", m.CPUMillicores)
+		fmt.Fprintf(out, "Memory: %dMi\n", m.MemoryBytes>>20)
+		names := make([]string, 0, len(m.Custom))
+		for name := range m.Custom {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(out, "%s: %g\n", name, m.Custom[name])
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(metricsCmd)
+}
@@ -23,18 +23,6 @@ func TestDownCommand(t *testing.T) {
 	})
 }
 
-func TestListCommand(t *testing.T) {
-	runPlaceholderTest(t, "list", []string{}, []string{
-		"Environment listing coming soon",
-	})
-}
-
-func TestLogsCommand(t *testing.T) {
-	runPlaceholderTest(t, "logs", []string{}, []string{
-		"Log streaming coming soon",
-	})
-}
-
 func TestAuthCommand(t *testing.T) {
 	// Test auth root command (should show help)
 	// Save and restore stdout
@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ephlabs/eph/internal/controller"
+	"github.com/ephlabs/eph/internal/providers/kubernetes"
+)
+
+var renderOpts struct {
+	repository string
+	pr         int
+	branch     string
+	commit     string
+	name       string
+	images     []string
+}
+
+var renderCmd = &cobra.Command{
+	Use:   "render",
+	Short: "Render an environment's Kubernetes manifests",
+	Long: `Render the Kubernetes manifests of an environment without touching a cluster.
+
+Manifests and kustomizations are built, patched and rewritten exactly as ephd
+would apply them, then printed as YAML. Use it to debug kubernetes.manifests,
+patches and image overrides in eph.yaml.`,
+	Example: `  eph render --pr 123 --commit 4f9c2e1
+  eph render --branch main --image api=ghcr.io/org/api:sha-4f9c2e1`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		if projectConfigErr != nil {
+			return projectConfigErr
+		}
+		if projectConfig == nil || projectConfig.Kubernetes == nil {
+			return errors.New("eph.yaml has no kubernetes section")
+		}
+
+		repo := renderOpts.repository
+		if repo == "" {
+			repo = projectConfig.Name
+		}
+		ref := controller.SourceRef{Repository: repo, Type: controller.RefBranch, Name: renderOpts.branch, CommitSHA: renderOpts.commit}
+		if renderOpts.pr > 0 {
+			ref = controller.PRRef(repo, renderOpts.pr, renderOpts.branch, renderOpts.commit)
+		}
+
+		images, _ := controller.ResolveImages(projectConfig, ref)
+		spec := controller.NewSpec(projectConfig, renderOpts.name, ref, images)
+		for _, img := range renderOpts.images {
+			name, image, ok := strings.Cut(img, "=")
+			if !ok || name == "" || image == "" {
+				return fmt.Errorf("--image %q must have the form name=reference", img)
+			}
+			spec.Images[name] = image
+		}
+
+		objs, err := kubernetes.Render(projectConfig, spec, kubernetes.Options{Registry: os.Getenv("EPH_REGISTRY")})
+		if err != nil {
+			return err
+		}
+		return kubernetes.WriteYAML(cmd.OutOrStdout(), objs)
+	},
+}
+
+func init() {
+	f := renderCmd.Flags()
+	f.StringVar(&renderOpts.repository, "repository", "", "repository as owner/name (default: the project name)")
+	f.IntVar(&renderOpts.pr, "pr", 0, "render for this pull request number")
+	f.StringVar(&renderOpts.branch, "branch", "main", "branch, or the pull request's head branch")
+	f.StringVar(&renderOpts.commit, "commit", strings.Repeat("0", 40), "commit SHA")
+	f.StringVar(&renderOpts.name, "name", "preview", "environment name")
+	f.StringArrayVar(&renderOpts.images, "image", nil, "image reference for an environment.images entry, as name=reference (repeatable)")
+	rootCmd.AddCommand(renderCmd)
+}
@@ -0,0 +1,72 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderCommand(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"eph.yaml": `version: "1.0"
+name: myapp
+providers:
+  primary: kubernetes
+environment:
+  images:
+    - name: api
+      repository: ghcr.io/org/api
+      tag_template: "pr-{pr_number}"
+kubernetes:
+  namespace_template: "{project}-pr-{pr_number}"
+  manifests:
+    - kustomization: k8s/overlays/preview
+      patches:
+        - target:
+            kind: Deployment
+            name: api
+          patch: |
+            - op: replace
+              path: /spec/replicas
+              value: 1
+`,
+		"k8s/base/kustomization.yaml": "resources: [deployment.yaml]\n",
+		"k8s/base/deployment.yaml": `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: api
+spec:
+  replicas: 3
+  template:
+    spec:
+      containers:
+        - name: api
+          image: ghcr.io/org/api:latest
+`,
+		"k8s/overlays/preview/kustomization.yaml": "resources: [../../base]\n",
+	}
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(data), 0o644))
+	}
+
+	buf := new(bytes.Buffer)
+	rootCmd.SetOut(buf)
+	rootCmd.SetErr(buf)
+	rootCmd.SetArgs([]string{"render", "--config", filepath.Join(dir, "eph.yaml"), "--pr", "7", "--name", "calm-river"})
+	require.NoError(t, rootCmd.Execute())
+
+	out := buf.String()
+	assert.Contains(t, out, "kind: Namespace\nmetadata:\n  annotations:\n    eph.io/commit: \"0000000000000000000000000000000000000000\"\n    eph.io/key: myapp/pr/7\n")
+	assert.Contains(t, out, "name: myapp-pr-7\n")
+	assert.Contains(t, out, "---\napiVersion: apps/v1\nkind: Deployment\n")
+	assert.Contains(t, out, "namespace: myapp-pr-7\n")
+	assert.Contains(t, out, "replicas: 1\n")
+	assert.Contains(t, out, "image: ghcr.io/org/api:pr-7\n")
+}
@@ -3,14 +3,23 @@ package cli
 import (
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
+
+	"github.com/ephlabs/eph/internal/config"
 )
 
 var (
-	cfgFile string
-	debug   bool
+	cfgFile   string
+	debug     bool
+	serverURL string
+
+	// projectConfig is the layered eph.yaml found by initConfig, or nil with
+	// projectConfigErr set when none could be loaded. Commands that need a
+	// project decide for themselves whether that is fatal.
+	projectConfig    *config.Config
+	projectConfigErr error
 )
 
 var rootCmd = &cobra.Command{
@@ -36,6 +45,7 @@ func init() {
 
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default: ./eph.yaml)")
 	rootCmd.PersistentFlags().BoolVar(&debug, "debug", false, "enable debug logging")
+	rootCmd.PersistentFlags().StringVar(&serverURL, "server", defaultServerURL(), "ephd address (default: $EPH_SERVER)")
 
 	rootCmd.CompletionOptions.DisableDefaultCmd = false
 	rootCmd.SetHelpTemplate(helpTemplate())
@@ -44,19 +54,12 @@ func init() {
 }
 
 func initConfig() {
-	if cfgFile != "" {
-		viper.SetConfigFile(cfgFile)
-	} else {
-		viper.AddConfigPath(".")
-		viper.SetConfigName("eph")
-		viper.SetConfigType("yaml")
-	}
-
-	viper.AutomaticEnv()
-	viper.SetEnvPrefix("EPH")
-
-	if err := viper.ReadInConfig(); err == nil && debug {
-		fmt.Println("Using config file:", viper.ConfigFileUsed())
+	projectConfig, projectConfigErr = config.Load(config.LoadOptions{
+		Path:        cfgFile,
+		Interpolate: config.InterpolateOptions{Lookup: os.LookupEnv},
+	})
+	if projectConfigErr == nil && debug {
+		fmt.Println("Using config files:", strings.Join(projectConfig.Files(), ", "))
 	}
 }
 
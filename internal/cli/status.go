@@ -0,0 +1,68 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ephlabs/eph/internal/server"
+)
+
+var statusCmd = &cobra.Command{
+	Use:   "status [environment]",
+	Short: "Show the status of ephd or of an environment",
+	Long: `Show the status of ephd and how many environments it manages, or, given an
+environment's name, where the environment is and why it is not ready.`,
+	Example: `  eph status
+  eph status myapp-calm-river-42`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out := cmd.OutOrStdout()
+		if len(args) == 0 {
+			resp, err := apiGet(cmd.Context(), "status", nil)
+			if err != nil {
+				return err
+			}
+			defer resp.Body.Close()
+			var status struct {
+				Status       string         `json:"status"`
+				Version      string         `json:"version"`
+				Uptime       string         `json:"uptime"`
+				Environments map[string]int `json:"environments"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+				return fmt.Errorf("reading status: %w", err)
+			}
+			fmt.Fprintf(out, "ephd %s is %s (up %s)\n", status.Version, status.Status, status.Uptime)
+			fmt.Fprintf(out, "Environments: %d, %d ready\n", status.Environments["total"], status.Environments["active"])
+			return nil
+		}
+
+		resp, err := apiGet(cmd.Context(), "environments/"+url.PathEscape(args[0]), nil)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		var env server.Environment
+		if err := json.NewDecoder(resp.Body).Decode(&env); err != nil {
+			return fmt.Errorf("reading environment: %w", err)
+		}
+		fmt.Fprintf(out, "Name:     %s\n", env.Name)
+		fmt.Fprintf(out, "Source:   %s\n", env.Key)
+		fmt.Fprintf(out, "Phase:    %s\n", env.Phase)
+		if env.Provider != "" {
+			fmt.Fprintf(out, "Provider: %s\n", env.Provider)
+		}
+		fmt.Fprintf(out, "URL:      %s\n", reach(env))
+		if env.Message != "" {
+			fmt.Fprintf(out, "Message:  %s\n", env.Message)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(statusCmd)
+}
@@ -3,9 +3,9 @@ package cli
 import (
 	"fmt"
 	"runtime"
+	"strings"
 
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 
 	"github.com/ephlabs/eph/pkg/version"
 )
@@ -22,7 +22,7 @@ var wtfCmd = &cobra.Command{
 		fmt.Printf("Git Commit: %s\n", version.GitCommit)
 		fmt.Printf("Built: %s\n", version.BuildDate)
 		fmt.Printf("OS/Arch: %s/%s\n", runtime.GOOS, runtime.GOARCH)
-		fmt.Printf("Config file: %s\n", viper.ConfigFileUsed())
+		fmt.Printf("Config file: %s\n", configFileSummary())
 		fmt.Printf("Debug mode: %v\n", debug)
 
 		fmt.Println("\n🚧 Advanced diagnostics coming soon!")
@@ -30,6 +30,16 @@ var wtfCmd = &cobra.Command{
 	},
 }
 
+func configFileSummary() string {
+	if projectConfigErr != nil {
+		return "none (" + projectConfigErr.Error() + ")"
+	}
+	if projectConfig == nil {
+		return "none"
+	}
+	return strings.Join(projectConfig.Files(), ", ")
+}
+
 func init() {
 	rootCmd.AddCommand(wtfCmd)
 }
@@ -0,0 +1,399 @@
+package config
+
+// Config is the typed representation of an eph.yaml file.
+type Config struct {
+	Version       string               `yaml:"version"`
+	Name          string               `yaml:"name"`
+	Providers     ProvidersConfig      `yaml:"providers"`
+	Triggers      []Trigger            `yaml:"triggers"`
+	Environment   EnvironmentConfig    `yaml:"environment"`
+	Kubernetes    *KubernetesConfig    `yaml:"kubernetes"`
+	DockerCompose *DockerComposeConfig `yaml:"docker-compose"`
+	Fake          *FakeConfig          `yaml:"fake"`
+	Database      DatabaseConfig       `yaml:"database"`
+	Services      []Service            `yaml:"services"`
+	Secrets       SecretsConfig        `yaml:"secrets"`
+	Security      SecurityConfig       `yaml:"security"`
+	Networking    NetworkingConfig     `yaml:"networking"`
+	Hooks         HooksConfig          `yaml:"hooks"`
+	Observability ObservabilityConfig  `yaml:"observability"`
+
+	// Advanced holds experimental settings that are not yet part of the
+	// stable schema, so it is deliberately left untyped.
+	Advanced map[string]any `yaml:"advanced"`
+
+	file      string
+	files     []string
+	positions map[string]Position
+	variables *InterpolationReport
+}
+
+// Files returns the files the configuration was loaded from, base file first
+// and overlays in increasing order of precedence.
+func (c *Config) Files() []string {
+	if len(c.files) == 0 && c.file != "" {
+		return []string{c.file}
+	}
+	return c.files
+}
+
+// Source returns the file that supplied the value at the given YAML path, or
+// "" if the path was not set in any file.
+func (c *Config) Source(path string) string {
+	pos, ok := c.positions[path]
+	if !ok {
+		return ""
+	}
+	return pos.File
+}
+
+// Variables reports the environment variables consulted while loading the
+// file, or nil if it was parsed without interpolation.
+func (c *Config) Variables() *InterpolationReport {
+	return c.variables
+}
+
+// Position returns the source location of the value at the given YAML path,
+// e.g. "environment.images[1].tag_template".
+func (c *Config) Position(path string) (Position, bool) {
+	pos, ok := c.positions[path]
+	return pos, ok
+}
+
+// Protected reports whether environments are access-protected.
+func (c *Config) Protected() bool {
+	t := c.Security.EnvironmentAccess.Protection.Type
+	return c.Security.EnvironmentAccess.Default == "protected" || (t != "" && t != "none")
+}
+
+// AliasesEnabled reports whether alias_template redirects should be served
+// for this project's environments.
+func (c *Config) AliasesEnabled() bool {
+	if c.Environment.AliasTemplate == "" {
+		return false
+	}
+	return !c.Protected() || !c.Security.EnvironmentAccess.Protection.DisableAliases
+}
+
+type ProvidersConfig struct {
+	Primary string `yaml:"primary"`
+
+	// Fallback hosts new environments while the primary is unavailable or
+	// lacks a capability the project requires. Environments are never
+	// moved between the two.
+	Fallback string `yaml:"fallback"`
+}
+
+type Trigger struct {
+	Type          string   `yaml:"type"`
+	Labels        []string `yaml:"labels"`
+	WaitForChecks []string `yaml:"wait_for_checks"`
+	Patterns      []string `yaml:"patterns"`
+	Branches      []string `yaml:"branches"`
+	IgnoreDraft   bool     `yaml:"ignore_draft"`
+	Pattern       string   `yaml:"pattern"`
+}
+
+type EnvironmentConfig struct {
+	NameTemplate      string            `yaml:"name_template"`
+	SubdomainTemplate string            `yaml:"subdomain_template"`
+	BaseDomain        string            `yaml:"base_domain"`
+	AliasTemplate     string            `yaml:"alias_template"`
+	TTL               Duration          `yaml:"ttl"`
+	IdleTimeout       Duration          `yaml:"idle_timeout"`
+	WakeOnAccess      bool              `yaml:"wake_on_access"`
+	Resources         ResourcesConfig   `yaml:"resources"`
+	Env               map[string]string `yaml:"env"`
+	Images            []Image           `yaml:"images"`
+}
+
+type ResourcesConfig struct {
+	CPURequest    string `yaml:"cpu_request"`
+	CPULimit      string `yaml:"cpu_limit"`
+	MemoryRequest string `yaml:"memory_request"`
+	MemoryLimit   string `yaml:"memory_limit"`
+}
+
+type Image struct {
+	Name             string   `yaml:"name"`
+	Repository       string   `yaml:"repository"`
+	Tag              string   `yaml:"tag"`
+	TagSource        string   `yaml:"tag_source"`
+	GitNoteRef       string   `yaml:"git_note_ref"`
+	TagTemplate      string   `yaml:"tag_template"`
+	TagPattern       string   `yaml:"tag_pattern"`
+	MaxAge           Duration `yaml:"max_age"`
+	RequiredLabels   []string `yaml:"required_labels"`
+	FallbackTag      string   `yaml:"fallback_tag"`
+	FallbackBehavior string   `yaml:"fallback_behavior"`
+}
+
+type KubernetesConfig struct {
+	Context           string                 `yaml:"context"`
+	NamespaceTemplate string                 `yaml:"namespace_template"`
+	Manifests         []ManifestSource       `yaml:"manifests"`
+	Images            []KubernetesImage      `yaml:"images"`
+	ImagePullSecrets  []LocalObjectReference `yaml:"imagePullSecrets"`
+	Ingress           IngressConfig          `yaml:"ingress"`
+}
+
+// ManifestSource is one entry of kubernetes.manifests. Exactly one of Path,
+// Kustomization or Helm is expected to be set.
+type ManifestSource struct {
+	Path          string      `yaml:"path"`
+	Kustomization string      `yaml:"kustomization"`
+	Helm          *HelmSource `yaml:"helm"`
+	Patches       []Patch     `yaml:"patches"`
+}
+
+// HelmSource installs a local chart as a release in the environment's
+// namespace.
+type HelmSource struct {
+	// Chart is a chart directory or packaged .tgz, relative to eph.yaml.
+	Chart string `yaml:"chart"`
+
+	// Release defaults to the chart's name.
+	Release string   `yaml:"release"`
+	Values  []string `yaml:"values"`
+
+	// Set holds --set style overrides, e.g. image.tag: "pr-{pr_number}".
+	// Values are templates.
+	Set map[string]string `yaml:"set"`
+}
+
+type Patch struct {
+	Target PatchTarget `yaml:"target"`
+	Patch  string      `yaml:"patch"`
+}
+
+type PatchTarget struct {
+	Group     string `yaml:"group"`
+	Version   string `yaml:"version"`
+	Kind      string `yaml:"kind"`
+	Name      string `yaml:"name"`
+	Namespace string `yaml:"namespace"`
+}
+
+type KubernetesImage struct {
+	Name    string `yaml:"name"`
+	NewName string `yaml:"newName"`
+	NewTag  string `yaml:"newTag"`
+	Digest  string `yaml:"digest"`
+}
+
+type LocalObjectReference struct {
+	Name string `yaml:"name"`
+}
+
+type IngressConfig struct {
+	Class       string            `yaml:"class"`
+	Annotations map[string]string `yaml:"annotations"`
+
+	// Expose lists the services Eph generates an Ingress for, on the host
+	// rendered from environment.subdomain_template, or on base_domain with
+	// path-based routing.
+	Expose []ExposedService `yaml:"expose"`
+
+	// Gateway is the Gateway API gateway that header-based routing attaches
+	// an HTTPRoute to, instead of generating an Ingress.
+	Gateway *GatewayReference `yaml:"gateway"`
+}
+
+type GatewayReference struct {
+	Name      string `yaml:"name"`
+	Namespace string `yaml:"namespace"`
+}
+
+type ExposedService struct {
+	Service string `yaml:"service"`
+	Port    int    `yaml:"port"`
+
+	// Path defaults to "/".
+	Path string `yaml:"path"`
+}
+
+type DockerComposeConfig struct {
+	ComposeFiles []string       `yaml:"compose_files"`
+	EnvFile      string         `yaml:"env_file"`
+	Scale        map[string]int `yaml:"scale"`
+}
+
+// FakeConfig configures the fake provider, which simulates environments in
+// memory for local development and tests.
+type FakeConfig struct {
+	CreateLatency  Duration `yaml:"create_latency"`
+	DestroyLatency Duration `yaml:"destroy_latency"`
+
+	// ReadyAfter is how long a created or updated environment takes to
+	// become ready.
+	ReadyAfter Duration `yaml:"ready_after"`
+
+	// FailureRate is the probability, from 0 to 1, that creating or
+	// updating an environment fails.
+	FailureRate float64 `yaml:"failure_rate"`
+
+	// FailRefs are glob patterns of ref names, e.g. "fail-*", whose
+	// environments always fail to be created. Pull requests match on their
+	// head branch as well as their number.
+	FailRefs []string `yaml:"fail_refs"`
+
+	// LogInterval is the interval between generated log lines.
+	LogInterval Duration `yaml:"log_interval"`
+}
+
+type DatabaseConfig struct {
+	Enabled   bool               `yaml:"enabled"`
+	Instances []DatabaseInstance `yaml:"instances"`
+}
+
+type DatabaseInstance struct {
+	Name       string             `yaml:"name"`
+	Type       string             `yaml:"type"`
+	Version    string             `yaml:"version"`
+	Template   DatabaseTemplate   `yaml:"template"`
+	Connection DatabaseConnection `yaml:"connection"`
+}
+
+type DatabaseTemplate struct {
+	Strategy string          `yaml:"strategy"`
+	Seed     *SeedConfig     `yaml:"seed"`
+	Snapshot *SnapshotConfig `yaml:"snapshot"`
+}
+
+type SeedConfig struct {
+	Scripts []string `yaml:"scripts"`
+}
+
+type SnapshotConfig struct {
+	Source string   `yaml:"source"`
+	MaxAge Duration `yaml:"max_age"`
+}
+
+type DatabaseConnection struct {
+	EnvVar   string `yaml:"env_var"`
+	Database string `yaml:"database"`
+}
+
+type Service struct {
+	Name       string `yaml:"name"`
+	Type       string `yaml:"type"`
+	Image      string `yaml:"image"`
+	Persistent bool   `yaml:"persistent"`
+	Endpoint   string `yaml:"endpoint"`
+}
+
+type SecretsConfig struct {
+	Provider   string             `yaml:"provider"`
+	Kubernetes *KubernetesSecrets `yaml:"kubernetes"`
+	Vault      *VaultSecrets      `yaml:"vault"`
+}
+
+type KubernetesSecrets struct {
+	CopyFromNamespace string   `yaml:"copy_from_namespace"`
+	Secrets           []string `yaml:"secrets"`
+}
+
+type VaultSecrets struct {
+	Path string `yaml:"path"`
+	Role string `yaml:"role"`
+}
+
+type SecurityConfig struct {
+	EnvironmentAccess EnvironmentAccess `yaml:"environment_access"`
+	Naming            NamingConfig      `yaml:"naming"`
+}
+
+type EnvironmentAccess struct {
+	Default    string           `yaml:"default"`
+	Protection ProtectionConfig `yaml:"protection"`
+}
+
+type ProtectionConfig struct {
+	Type      string       `yaml:"type"`
+	BasicAuth *BasicAuth   `yaml:"basic_auth"`
+	OAuth     *OAuthConfig `yaml:"oauth"`
+
+	// DisableAliases turns off alias_template redirects for protected
+	// environments. A predictable alias reveals the generated name, which
+	// defeats the point of a non-guessable URL.
+	DisableAliases bool `yaml:"disable_aliases"`
+}
+
+type BasicAuth struct {
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+type OAuthConfig struct {
+	Provider     string   `yaml:"provider"`
+	AllowedOrgs  []string `yaml:"allowed_orgs"`
+	AllowedTeams []string `yaml:"allowed_teams"`
+}
+
+type NamingConfig struct {
+	Strategy       string `yaml:"strategy"`
+	IncludeProject bool   `yaml:"include_project"`
+}
+
+type NetworkingConfig struct {
+	Routing RoutingConfig `yaml:"routing"`
+	TLS     TLSConfig     `yaml:"tls"`
+}
+
+type RoutingConfig struct {
+	Strategy   string `yaml:"strategy"`
+	PathPrefix string `yaml:"path_prefix"`
+	Header     string `yaml:"header"`
+}
+
+type TLSConfig struct {
+	Enabled  bool   `yaml:"enabled"`
+	Provider string `yaml:"provider"`
+
+	// ClusterIssuer is the cert-manager ClusterIssuer that signs
+	// certificates, unless kubernetes.ingress.annotations names an issuer.
+	ClusterIssuer string `yaml:"cluster_issuer"`
+
+	// WildcardSecret is a TLS secret for *.base_domain that the wildcard
+	// provider copies into every environment.
+	WildcardSecret *SecretReference `yaml:"wildcard_secret"`
+}
+
+type SecretReference struct {
+	Name      string `yaml:"name"`
+	Namespace string `yaml:"namespace"`
+}
+
+type HooksConfig struct {
+	PreCreate  []Hook `yaml:"pre_create"`
+	PostCreate []Hook `yaml:"post_create"`
+	PreDestroy []Hook `yaml:"pre_destroy"`
+}
+
+type Hook struct {
+	Name            string   `yaml:"name"`
+	Command         []string `yaml:"command"`
+	Timeout         Duration `yaml:"timeout"`
+	ContinueOnError bool     `yaml:"continueOnError"`
+}
+
+type ObservabilityConfig struct {
+	Metrics MetricsConfig `yaml:"metrics"`
+	Tracing TracingConfig `yaml:"tracing"`
+	Logs    LogsConfig    `yaml:"logs"`
+}
+
+type MetricsConfig struct {
+	Enabled     bool              `yaml:"enabled"`
+	Annotations map[string]string `yaml:"annotations"`
+}
+
+type TracingConfig struct {
+	Enabled          bool `yaml:"enabled"`
+	PropagateContext bool `yaml:"propagate_context"`
+}
+
+type LogsConfig struct {
+	ShipTo         string `yaml:"ship_to"`
+	IncludePodLogs bool   `yaml:"include_pod_logs"`
+}
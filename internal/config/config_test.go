@@ -0,0 +1,29 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAliasesEnabled(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want bool
+	}{
+		{"no alias template", "name: app\n", false},
+		{"public", "environment:\n  alias_template: \"{project}-pr-{pr_number}\"\n", true},
+		{"protected", "environment:\n  alias_template: a\nsecurity:\n  environment_access:\n    protection:\n      type: basic\n", true},
+		{"protected and disabled", "environment:\n  alias_template: a\nsecurity:\n  environment_access:\n    protection:\n      type: basic\n      disable_aliases: true\n", false},
+		{"disabled but public", "environment:\n  alias_template: a\nsecurity:\n  environment_access:\n    protection:\n      type: none\n      disable_aliases: true\n", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := Parse("eph.yaml", []byte(tt.src))
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, cfg.AliasesEnabled())
+		})
+	}
+}
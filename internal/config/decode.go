@@ -0,0 +1,256 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Parse strictly decodes an eph.yaml document. Keys that do not correspond to
+// a schema field are reported with their line and column rather than being
+// silently dropped. The file name is only used in error positions.
+func Parse(file string, data []byte) (*Config, error) {
+	node, err := parseNode(file, data)
+	if err != nil {
+		return nil, err
+	}
+	return decodeNode(file, node, nil)
+}
+
+func parseNode(file string, data []byte) (*yaml.Node, error) {
+	if len(data) == 0 {
+		return nil, errors.New("empty file")
+	}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, ErrorList{errorFromYAML(file, err.Error())}
+	}
+	if root.Kind == 0 || len(root.Content) == 0 || isNull(root.Content[0]) {
+		return nil, errors.New("yaml file contains no data (empty or comments-only)")
+	}
+	return root.Content[0], nil
+}
+
+// decodeNode decodes node into a Config. origin maps nodes that came from a
+// file other than file (such as overlay values merged into a base document)
+// to their file name, so positions point at the file that supplied them.
+func decodeNode(file string, node *yaml.Node, origin map[*yaml.Node]string) (*Config, error) {
+	w := &schemaWalker{file: file, origin: origin, positions: map[string]Position{}}
+	w.walk(node, node, reflect.TypeOf(Config{}), "")
+
+	cfg := &Config{}
+	if err := node.Decode(cfg); err != nil {
+		var typeErr *yaml.TypeError
+		if errors.As(err, &typeErr) {
+			for _, msg := range typeErr.Errors {
+				w.errs = append(w.errs, errorFromYAML(file, msg))
+			}
+		} else {
+			w.errs = append(w.errs, errorFromYAML(file, err.Error()))
+		}
+	}
+	if len(w.errs) > 0 {
+		sort.SliceStable(w.errs, func(i, j int) bool {
+			return w.errs[i].Pos.Line < w.errs[j].Pos.Line
+		})
+		return nil, w.errs
+	}
+
+	cfg.file = file
+	cfg.positions = w.positions
+	return cfg, nil
+}
+
+// schemaWalker walks a YAML node tree alongside the Go type it will be
+// decoded into, recording the position of every path and flagging keys that
+// have no matching field.
+type schemaWalker struct {
+	file      string
+	origin    map[*yaml.Node]string
+	positions map[string]Position
+	errs      ErrorList
+}
+
+var unmarshalerType = reflect.TypeOf((*yaml.Unmarshaler)(nil)).Elem()
+
+// walk records at as the position of path; for mapping entries this is the
+// key rather than the value, which is where a reader expects errors to point.
+func (w *schemaWalker) walk(at, node *yaml.Node, t reflect.Type, path string) {
+	if node.Kind == yaml.AliasNode && node.Alias != nil {
+		node = node.Alias
+	}
+	if path != "" {
+		w.positions[path] = w.pos(at)
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if reflect.PointerTo(t).Implements(unmarshalerType) {
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			return
+		}
+		fields := structFields(t)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, val := node.Content[i], node.Content[i+1]
+			if key.Value == "<<" {
+				w.walkMerge(val, t, path)
+				continue
+			}
+			field, ok := fields[key.Value]
+			if !ok {
+				w.unknownField(key, t, path)
+				continue
+			}
+			w.walk(key, val, field.Type, joinPath(path, key.Value))
+		}
+	case reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			return
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			w.walk(node.Content[i], node.Content[i+1], t.Elem(), joinPath(path, node.Content[i].Value))
+		}
+	case reflect.Slice, reflect.Array:
+		if node.Kind != yaml.SequenceNode {
+			return
+		}
+		for i, item := range node.Content {
+			w.walk(item, item, t.Elem(), fmt.Sprintf("%s[%d]", path, i))
+		}
+	}
+}
+
+func (w *schemaWalker) walkMerge(val *yaml.Node, t reflect.Type, path string) {
+	if val.Kind == yaml.AliasNode && val.Alias != nil {
+		val = val.Alias
+	}
+	if val.Kind == yaml.SequenceNode {
+		for _, item := range val.Content {
+			w.walkMerge(item, t, path)
+		}
+		return
+	}
+	// Merged keys keep the position of the anchor they came from, which is
+	// where the user would need to fix them.
+	saved := w.positions[path]
+	w.walk(val, val, t, path)
+	if path != "" {
+		w.positions[path] = saved
+	}
+}
+
+func (w *schemaWalker) unknownField(key *yaml.Node, t reflect.Type, path string) {
+	msg := fmt.Sprintf("unknown field %q", key.Value)
+	if s := suggest(key.Value, structFields(t)); s != "" {
+		msg += fmt.Sprintf(" (did you mean %q?)", s)
+	}
+	where := path
+	if where == "" {
+		where = "(root)"
+	}
+	w.errs = append(w.errs, &Error{Path: where, Pos: w.pos(key), Message: msg})
+}
+
+func (w *schemaWalker) pos(node *yaml.Node) Position {
+	file := w.file
+	if f, ok := w.origin[node]; ok {
+		file = f
+	}
+	return Position{File: file, Line: node.Line, Column: node.Column}
+}
+
+func joinPath(parent, key string) string {
+	if parent == "" {
+		return key
+	}
+	return parent + "." + key
+}
+
+func isNull(node *yaml.Node) bool {
+	return node.Kind == yaml.ScalarNode && node.Tag == "!!null"
+}
+
+var fieldCache sync.Map // reflect.Type -> map[string]reflect.StructField
+
+// structFields returns the fields of t keyed by their YAML name, flattening
+// ",inline" members the same way yaml.v3 does.
+func structFields(t reflect.Type) map[string]reflect.StructField {
+	if cached, ok := fieldCache.Load(t); ok {
+		return cached.(map[string]reflect.StructField)
+	}
+	fields := map[string]reflect.StructField{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		tag := f.Tag.Get("yaml")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if strings.Contains(opts, "inline") {
+			inner := f.Type
+			if inner.Kind() == reflect.Pointer {
+				inner = inner.Elem()
+			}
+			for k, v := range structFields(inner) {
+				fields[k] = v
+			}
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		fields[name] = f
+	}
+	fieldCache.Store(t, fields)
+	return fields
+}
+
+// suggest returns the closest known field name to key, if any is close
+// enough to plausibly be a typo.
+func suggest(key string, fields map[string]reflect.StructField) string {
+	best, bestDist := "", 3
+	for name := range fields {
+		d := levenshtein(strings.ToLower(key), strings.ToLower(name))
+		if d < bestDist || (d == bestDist && best != "" && name < best) {
+			best, bestDist = name, d
+		}
+	}
+	if bestDist > len(key)/2 {
+		return ""
+	}
+	return best
+}
+
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,172 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parseTestdata(t *testing.T, name string) (*Config, error) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	return Parse(path, data)
+}
+
+func TestParseDocumentedExample(t *testing.T) {
+	cfg, err := parseTestdata(t, "eph.yaml")
+	require.NoError(t, err)
+
+	assert.Equal(t, "1.0", cfg.Version)
+	assert.Equal(t, "my-application", cfg.Name)
+	assert.Equal(t, "kubernetes", cfg.Providers.Primary)
+	assert.Equal(t, "docker-compose", cfg.Providers.Fallback)
+
+	require.Len(t, cfg.Triggers, 3)
+	assert.Equal(t, []string{"build", "test"}, cfg.Triggers[0].WaitForChecks)
+	assert.True(t, cfg.Triggers[2].IgnoreDraft)
+
+	env := cfg.Environment
+	assert.Equal(t, 72*time.Hour, env.TTL.Duration)
+	assert.Equal(t, 4*time.Hour, env.IdleTimeout.Duration)
+	assert.Equal(t, "2", env.Resources.CPULimit)
+	assert.Equal(t, "preview", env.Env["APP_ENV"])
+	require.Len(t, env.Images, 3)
+	assert.Equal(t, 7*24*time.Hour, env.Images[0].MaxAge.Duration)
+	assert.Equal(t, "v2.1.0", env.Images[2].Tag)
+
+	require.NotNil(t, cfg.Kubernetes)
+	require.Len(t, cfg.Kubernetes.Manifests, 2)
+	assert.Equal(t, "./k8s/overlays/preview", cfg.Kubernetes.Manifests[1].Kustomization)
+	assert.Equal(t, "Deployment", cfg.Kubernetes.Manifests[1].Patches[0].Target.Kind)
+	assert.Equal(t, "pr-{pr_number}-{commit_sha:0:7}", cfg.Kubernetes.Images[0].NewTag)
+	assert.Equal(t, "nginx", cfg.Kubernetes.Ingress.Class)
+
+	require.NotNil(t, cfg.DockerCompose)
+	assert.Equal(t, 1, cfg.DockerCompose.Scale["web"])
+
+	assert.Equal(t, "seed", cfg.Database.Instances[0].Template.Strategy)
+	assert.Len(t, cfg.Database.Instances[0].Template.Seed.Scripts, 3)
+	assert.Equal(t, "external", cfg.Services[1].Type)
+	assert.Equal(t, "default", cfg.Secrets.Kubernetes.CopyFromNamespace)
+	assert.True(t, cfg.Security.Naming.IncludeProject)
+	assert.Equal(t, "subdomain", cfg.Networking.Routing.Strategy)
+	assert.Equal(t, 10*time.Minute, cfg.Hooks.PostCreate[1].Timeout.Duration)
+	assert.True(t, cfg.Hooks.PostCreate[1].ContinueOnError)
+	assert.Equal(t, "true", cfg.Observability.Metrics.Annotations["prometheus.io/scrape"])
+}
+
+func TestParseRecordsPositions(t *testing.T) {
+	cfg, err := parseTestdata(t, "eph.yaml")
+	require.NoError(t, err)
+
+	pos, ok := cfg.Position("environment.images[1].tag_template")
+	require.True(t, ok)
+	assert.Equal(t, 88, pos.Line)
+	assert.Equal(t, 7, pos.Column)
+
+	_, ok = cfg.Position("environment.images[9]")
+	assert.False(t, ok)
+}
+
+func TestParseRejectsUnknownFields(t *testing.T) {
+	_, err := parseTestdata(t, "unknown_field.yaml")
+	require.Error(t, err)
+
+	var list ErrorList
+	require.True(t, errors.As(err, &list))
+	require.Len(t, list, 2)
+
+	assert.Equal(t, "environment.images[1]", list[0].Path)
+	assert.Equal(t, 12, list[0].Pos.Line)
+	assert.Equal(t, 7, list[0].Pos.Column)
+	assert.Contains(t, list[0].Message, `unknown field "tag_templte"`)
+	assert.Contains(t, list[0].Message, `did you mean "tag_template"`)
+
+	assert.Equal(t, "(root)", list[1].Path)
+	assert.Contains(t, list[1].Message, `did you mean "networking"`)
+	assert.Contains(t, err.Error(), "unknown_field.yaml:12:7")
+}
+
+func TestParseReportsTypeErrorsWithLine(t *testing.T) {
+	data := []byte("name: app\nenvironment:\n  wake_on_access: sometimes\n  ttl: forever\n")
+	_, err := Parse("eph.yaml", data)
+	require.Error(t, err)
+
+	var list ErrorList
+	require.True(t, errors.As(err, &list))
+	require.NotEmpty(t, list)
+	assert.Equal(t, 3, list[0].Pos.Line)
+}
+
+func TestParseRejectsInvalidDuration(t *testing.T) {
+	_, err := Parse("eph.yaml", []byte("environment:\n  ttl: forever\n"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "eph.yaml:2")
+	assert.Contains(t, err.Error(), `invalid duration "forever"`)
+}
+
+func TestParseEmptyDocuments(t *testing.T) {
+	_, err := Parse("eph.yaml", nil)
+	assert.Error(t, err)
+
+	_, err = Parse("eph.yaml", []byte("# only a comment\n"))
+	assert.Error(t, err)
+}
+
+func TestParseSyntaxError(t *testing.T) {
+	_, err := parseTestdata(t, "invalid.yaml")
+	require.Error(t, err)
+
+	var list ErrorList
+	require.True(t, errors.As(err, &list))
+	assert.Positive(t, list[0].Pos.Line)
+}
+
+func TestParseMergeKeys(t *testing.T) {
+	data := []byte(`
+hooks:
+  post_create:
+    - &smoke
+      name: smoke
+      command: ["./smoke.sh"]
+      timeout: 1m
+    - <<: *smoke
+      name: smoke-again
+`)
+	cfg, err := Parse("eph.yaml", data)
+	require.NoError(t, err)
+	require.Len(t, cfg.Hooks.PostCreate, 2)
+	assert.Equal(t, "smoke-again", cfg.Hooks.PostCreate[1].Name)
+	assert.Equal(t, time.Minute, cfg.Hooks.PostCreate[1].Timeout.Duration)
+}
+
+func TestDuration(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+		str  string
+	}{
+		{"30s", 30 * time.Second, "30s"},
+		{"4h", 4 * time.Hour, "4h0m0s"},
+		{"7d", 7 * 24 * time.Hour, "7d"},
+		{"72h", 72 * time.Hour, "3d"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			d, err := ParseDuration(tt.in)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, d)
+			assert.Equal(t, tt.str, Duration{d}.String())
+		})
+	}
+
+	_, err := ParseDuration("-1d")
+	assert.Error(t, err)
+}
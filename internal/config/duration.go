@@ -0,0 +1,60 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Duration is a time.Duration that unmarshals from Go duration strings
+// ("30s", "4h") and additionally accepts whole days ("7d"), which
+// time.ParseDuration does not.
+type Duration struct {
+	time.Duration
+}
+
+func ParseDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	return d, nil
+}
+
+func (d *Duration) UnmarshalYAML(node *yaml.Node) error {
+	// Returning a *yaml.TypeError lets the decoder keep going and report
+	// this alongside any other problems in the file.
+	if node.Kind != yaml.ScalarNode {
+		return &yaml.TypeError{Errors: []string{
+			fmt.Sprintf("line %d: duration must be a string such as \"30s\" or \"7d\"", node.Line),
+		}}
+	}
+	parsed, err := ParseDuration(node.Value)
+	if err != nil {
+		return &yaml.TypeError{Errors: []string{fmt.Sprintf("line %d: %v", node.Line, err)}}
+	}
+	d.Duration = parsed
+	return nil
+}
+
+func (d Duration) MarshalYAML() (any, error) {
+	return d.String(), nil
+}
+
+func (d Duration) String() string {
+	if d.Duration != 0 && d.Duration%(24*time.Hour) == 0 {
+		return strconv.FormatInt(int64(d.Duration/(24*time.Hour)), 10) + "d"
+	}
+	return d.Duration.String()
+}
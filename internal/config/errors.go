@@ -0,0 +1,94 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Position is a location in an eph.yaml source file. Line and Column are
+// 1-based; zero means unknown.
+type Position struct {
+	File   string `json:"file,omitempty"`
+	Line   int    `json:"line,omitempty"`
+	Column int    `json:"column,omitempty"`
+}
+
+func (p Position) String() string {
+	s := p.File
+	if s == "" {
+		s = defaultConfigFile
+	}
+	if p.Line > 0 {
+		s += ":" + strconv.Itoa(p.Line)
+		if p.Column > 0 {
+			s += ":" + strconv.Itoa(p.Column)
+		}
+	}
+	return s
+}
+
+// Error describes a single problem with a configuration file, addressed by
+// YAML path (e.g. "environment.images[1].tag_template").
+type Error struct {
+	Path    string   `json:"path,omitempty"`
+	Pos     Position `json:"position"`
+	Message string   `json:"message"`
+}
+
+func (e *Error) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("%s: %s", e.Pos, e.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", e.Pos, e.Path, e.Message)
+}
+
+// ErrorList aggregates every problem found in a configuration file so they
+// can be reported at once instead of one per run.
+type ErrorList []*Error
+
+func (l ErrorList) Error() string {
+	switch len(l) {
+	case 0:
+		return "no errors"
+	case 1:
+		return l[0].Error()
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d errors:", len(l))
+	for _, e := range l {
+		b.WriteString("\n  ")
+		b.WriteString(e.Error())
+	}
+	return b.String()
+}
+
+func (l ErrorList) Unwrap() []error {
+	errs := make([]error, len(l))
+	for i, e := range l {
+		errs[i] = e
+	}
+	return errs
+}
+
+// Err returns nil for an empty list so callers can write `return errs.Err()`.
+func (l ErrorList) Err() error {
+	if len(l) == 0 {
+		return nil
+	}
+	return l
+}
+
+var yamlLinePrefix = regexp.MustCompile(`^(?:yaml: )?line (\d+): `)
+
+// errorFromYAML converts a yaml.v3 message of the form "line N: msg" into an
+// Error carrying that line.
+func errorFromYAML(file, msg string) *Error {
+	e := &Error{Pos: Position{File: file}, Message: strings.TrimPrefix(msg, "yaml: ")}
+	if m := yamlLinePrefix.FindStringSubmatch(msg); m != nil {
+		e.Pos.Line, _ = strconv.Atoi(m[1])
+		e.Message = msg[len(m[0]):]
+	}
+	return e
+}
@@ -0,0 +1,274 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// InterpolateOptions controls ${VAR} expansion in eph.yaml string values.
+type InterpolateOptions struct {
+	// Lookup resolves a variable; it defaults to os.LookupEnv.
+	Lookup func(name string) (string, bool)
+
+	// Strict turns references to undefined variables that have no default
+	// into errors instead of expanding them to the empty string.
+	Strict bool
+}
+
+// Variable records how one environment variable was used during
+// interpolation.
+type Variable struct {
+	Name string `json:"name"`
+	// Set reports whether the variable was defined when it was consulted.
+	Set bool `json:"set"`
+	// DefaultUsed reports whether at least one reference fell back to its
+	// ${VAR:-default} value.
+	DefaultUsed bool `json:"default_used"`
+	// Paths lists the YAML paths whose values referenced the variable.
+	Paths []string `json:"paths"`
+}
+
+// InterpolationReport lists every variable consulted while interpolating a
+// file, which is useful when debugging why a value came out the way it did.
+type InterpolationReport struct {
+	Variables map[string]*Variable `json:"variables"`
+}
+
+// Names returns the consulted variable names in sorted order.
+func (r *InterpolationReport) Names() []string {
+	if r == nil {
+		return nil
+	}
+	names := make([]string, 0, len(r.Variables))
+	for name := range r.Variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Undefined returns the sorted names of consulted variables that were not set.
+func (r *InterpolationReport) Undefined() []string {
+	var names []string
+	for _, name := range r.Names() {
+		if !r.Variables[name].Set {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func (r *InterpolationReport) record(name, path string, set, defaulted bool) {
+	v, ok := r.Variables[name]
+	if !ok {
+		v = &Variable{Name: name, Set: set}
+		r.Variables[name] = v
+	}
+	v.DefaultUsed = v.DefaultUsed || defaulted
+	if len(v.Paths) == 0 || v.Paths[len(v.Paths)-1] != path {
+		v.Paths = append(v.Paths, path)
+	}
+}
+
+// ParseWithEnv is Parse with ${VAR} interpolation applied to string values
+// before decoding. The report of consulted variables is available from
+// Config.Variables.
+func ParseWithEnv(file string, data []byte, opts InterpolateOptions) (*Config, error) {
+	node, err := parseNode(file, data)
+	if err != nil {
+		return nil, err
+	}
+	report := newInterpolationReport()
+	if err := interpolateNode(file, node, opts, report); err != nil {
+		return nil, err
+	}
+	cfg, err := decodeNode(file, node, nil)
+	if err != nil {
+		return nil, err
+	}
+	cfg.variables = report
+	return cfg, nil
+}
+
+// deferredInterpolation reports paths whose ${...} references are resolved
+// later with runtime values (e.g. ${environment_url} in hook commands) and
+// must therefore be left untouched at load time.
+func deferredInterpolation(path string) bool {
+	return strings.HasPrefix(path, "hooks.") && strings.Contains(path, ".command")
+}
+
+func newInterpolationReport() *InterpolationReport {
+	return &InterpolationReport{Variables: map[string]*Variable{}}
+}
+
+// interpolateNode expands references in every string scalar under root in
+// place, recording consulted variables in report.
+func interpolateNode(file string, root *yaml.Node, opts InterpolateOptions, report *InterpolationReport) error {
+	if opts.Lookup == nil {
+		opts.Lookup = os.LookupEnv
+	}
+	var errs ErrorList
+
+	var walk func(node *yaml.Node, path string)
+	walk = func(node *yaml.Node, path string) {
+		switch node.Kind {
+		case yaml.DocumentNode:
+			for _, c := range node.Content {
+				walk(c, path)
+			}
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				walk(node.Content[i+1], joinPath(path, node.Content[i].Value))
+			}
+		case yaml.SequenceNode:
+			for i, c := range node.Content {
+				walk(c, fmt.Sprintf("%s[%d]", path, i))
+			}
+		case yaml.ScalarNode:
+			if node.Tag != "!!str" || !strings.Contains(node.Value, "$") || deferredInterpolation(path) {
+				return
+			}
+			out, err := expand(node.Value, opts, func(name string, set, defaulted bool) {
+				report.record(name, path, set, defaulted)
+			})
+			if err != nil {
+				errs = append(errs, &Error{
+					Path:    path,
+					Pos:     Position{File: file, Line: node.Line, Column: node.Column},
+					Message: err.Error(),
+				})
+				return
+			}
+			node.Value = out
+			// Let plain scalars be re-resolved so `replicas: ${N}` can still
+			// decode into an int; quoted values stay strings.
+			if node.Style == 0 {
+				node.Tag = ""
+			}
+		}
+	}
+	walk(root, "")
+
+	return errs.Err()
+}
+
+// ExpandEnv expands ${VAR}, ${VAR:-default}, ${VAR:?message} and $$ in s
+// using opts. It is exported for callers that interpolate values outside of
+// eph.yaml, such as overlay file names.
+func ExpandEnv(s string, opts InterpolateOptions) (string, error) {
+	if opts.Lookup == nil {
+		opts.Lookup = os.LookupEnv
+	}
+	return expand(s, opts, func(string, bool, bool) {})
+}
+
+func expand(s string, opts InterpolateOptions, seen func(name string, set, defaulted bool)) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		switch s[i+1] {
+		case '$':
+			b.WriteByte('$')
+			i++
+		case '{':
+			end := matchingBrace(s, i+2)
+			if end < 0 {
+				return "", fmt.Errorf("unterminated variable reference in %q", s)
+			}
+			val, err := expandReference(s[i+2:end], opts, seen)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(val)
+			i = end
+		default:
+			b.WriteByte('$')
+		}
+	}
+	return b.String(), nil
+}
+
+// matchingBrace returns the index of the '}' closing a reference whose body
+// starts at start, allowing nested references inside defaults.
+func matchingBrace(s string, start int) int {
+	depth := 1
+	for i := start; i < len(s); i++ {
+		switch {
+		case s[i] == '$' && i+1 < len(s) && s[i+1] == '$':
+			i++
+		case s[i] == '$' && i+1 < len(s) && s[i+1] == '{':
+			depth++
+			i++
+		case s[i] == '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func expandReference(body string, opts InterpolateOptions, seen func(string, bool, bool)) (string, error) {
+	name, op, arg := body, "", ""
+	if i := strings.Index(body, ":"); i >= 0 {
+		name, op, arg = body[:i], body[i:min(i+2, len(body))], body[min(i+2, len(body)):]
+	}
+	if !validVariableName(name) {
+		return "", fmt.Errorf("invalid variable name %q", name)
+	}
+
+	value, set := opts.Lookup(name)
+	switch op {
+	case "":
+		seen(name, set, false)
+		if !set && opts.Strict {
+			return "", fmt.Errorf("variable %s is not set", name)
+		}
+		return value, nil
+	case ":-":
+		if set && value != "" {
+			seen(name, true, false)
+			return value, nil
+		}
+		seen(name, set, true)
+		return expand(arg, opts, seen)
+	case ":?":
+		seen(name, set, false)
+		if set && value != "" {
+			return value, nil
+		}
+		msg, err := expand(arg, opts, seen)
+		if err != nil {
+			return "", err
+		}
+		if msg == "" {
+			return "", fmt.Errorf("variable %s is required", name)
+		}
+		return "", fmt.Errorf("%s: %s", name, msg)
+	default:
+		return "", fmt.Errorf("unsupported modifier %q in ${%s}", op, body)
+	}
+}
+
+func validVariableName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		switch {
+		case r == '_', r >= 'A' && r <= 'Z', r >= 'a' && r <= 'z':
+		case r >= '0' && r <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,143 @@
+package config
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func lookupFrom(env map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}
+}
+
+func TestExpandEnv(t *testing.T) {
+	opts := InterpolateOptions{Lookup: lookupFrom(map[string]string{
+		"DOMAIN": "preview.acme.dev",
+		"EMPTY":  "",
+		"INNER":  "inner",
+	})}
+
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"plain", "plain"},
+		{"${DOMAIN}", "preview.acme.dev"},
+		{"api.${DOMAIN}", "api.preview.acme.dev"},
+		{"${MISSING}", ""},
+		{"${MISSING:-fallback.dev}", "fallback.dev"},
+		{"${EMPTY:-fallback}", "fallback"},
+		{"${DOMAIN:-fallback}", "preview.acme.dev"},
+		{"${MISSING:-${INNER}-x}", "inner-x"},
+		{"${MISSING:-https://a.b/c}", "https://a.b/c"},
+		{"cost: $$5", "cost: $5"},
+		{"$$${DOMAIN}", "$preview.acme.dev"},
+		{"$HOME stays", "$HOME stays"},
+		{"trailing $", "trailing $"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ExpandEnv(tt.in, opts)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestExpandEnvErrors(t *testing.T) {
+	opts := InterpolateOptions{Lookup: lookupFrom(nil)}
+
+	_, err := ExpandEnv("${K8S_CONTEXT:?set it to your cluster}", opts)
+	assert.EqualError(t, err, "K8S_CONTEXT: set it to your cluster")
+
+	_, err = ExpandEnv("${K8S_CONTEXT:?}", opts)
+	assert.EqualError(t, err, "variable K8S_CONTEXT is required")
+
+	_, err = ExpandEnv("${UNCLOSED", opts)
+	assert.Error(t, err)
+
+	_, err = ExpandEnv("${1BAD}", opts)
+	assert.Error(t, err)
+
+	_, err = ExpandEnv("${A:+x}", opts)
+	assert.Error(t, err)
+
+	opts.Strict = true
+	_, err = ExpandEnv("${MISSING}", opts)
+	assert.EqualError(t, err, "variable MISSING is not set")
+
+	got, err := ExpandEnv("${MISSING:-ok}", opts)
+	require.NoError(t, err)
+	assert.Equal(t, "ok", got)
+}
+
+func TestParseWithEnvDocumentedExample(t *testing.T) {
+	data := []byte(`version: "1.0"
+name: app
+environment:
+  base_domain: "${EPH_BASE_DOMAIN:-preview.example.com}"
+kubernetes:
+  context: "${K8S_CONTEXT}"
+services:
+  - name: auth-service
+    type: external
+    endpoint: "${AUTH_SERVICE_URL:-https://auth.staging.example.com}"
+hooks:
+  post_create:
+    - name: warm-cache
+      command: ["./warm.sh", "${environment_url}"]
+`)
+	cfg, err := ParseWithEnv("eph.yaml", data, InterpolateOptions{
+		Lookup: lookupFrom(map[string]string{"K8S_CONTEXT": "kind-eph"}),
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "preview.example.com", cfg.Environment.BaseDomain)
+	assert.Equal(t, "kind-eph", cfg.Kubernetes.Context)
+	assert.Equal(t, "https://auth.staging.example.com", cfg.Services[0].Endpoint)
+	assert.Equal(t, "${environment_url}", cfg.Hooks.PostCreate[0].Command[1], "hook commands are expanded at run time")
+
+	report := cfg.Variables()
+	require.NotNil(t, report)
+	assert.Equal(t, []string{"AUTH_SERVICE_URL", "EPH_BASE_DOMAIN", "K8S_CONTEXT"}, report.Names())
+	assert.Equal(t, []string{"AUTH_SERVICE_URL", "EPH_BASE_DOMAIN"}, report.Undefined())
+	assert.True(t, report.Variables["EPH_BASE_DOMAIN"].DefaultUsed)
+	assert.Equal(t, []string{"kubernetes.context"}, report.Variables["K8S_CONTEXT"].Paths)
+}
+
+func TestParseWithEnvStrictReportsEveryUndefinedVariable(t *testing.T) {
+	data := []byte(`name: app
+kubernetes:
+  context: ${K8S_CONTEXT}
+observability:
+  logs:
+    ship_to: "${LOG_DESTINATION}"
+`)
+	_, err := ParseWithEnv("eph.yaml", data, InterpolateOptions{Lookup: lookupFrom(nil), Strict: true})
+	require.Error(t, err)
+
+	var list ErrorList
+	require.True(t, errors.As(err, &list))
+	require.Len(t, list, 2)
+	assert.Equal(t, "kubernetes.context", list[0].Path)
+	assert.Equal(t, 3, list[0].Pos.Line)
+	assert.Equal(t, "observability.logs.ship_to", list[1].Path)
+}
+
+func TestParseWithEnvOnlyTouchesStrings(t *testing.T) {
+	data := []byte(`name: app
+docker-compose:
+  compose_files: ["${COMPOSE_FILE:-docker-compose.yml}"]
+  scale:
+    web: ${WEB_REPLICAS:-2}
+`)
+	cfg, err := ParseWithEnv("eph.yaml", data, InterpolateOptions{Lookup: lookupFrom(nil)})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"docker-compose.yml"}, cfg.DockerCompose.ComposeFiles)
+	assert.Equal(t, 2, cfg.DockerCompose.Scale["web"], "plain scalars are re-resolved after expansion")
+}
@@ -0,0 +1,223 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	defaultConfigFile = "eph.yaml"
+
+	// EnvOverlayVar names the environment variable that selects the
+	// eph.<env>.yaml overlay when LoadOptions.Environment is empty.
+	EnvOverlayVar = "EPH_ENV"
+)
+
+// ErrNotFound is returned by Load when discovery finds no eph.yaml.
+var ErrNotFound = errors.New("config file not found")
+
+// LoadOptions controls how Load locates and layers configuration files.
+type LoadOptions struct {
+	// Path is an explicit config file (or a directory containing eph.yaml).
+	// When set, discovery is skipped.
+	Path string
+
+	// Dir is where discovery starts; it defaults to the working directory.
+	// Discovery walks up towards the repository root (the first directory
+	// containing .git) looking for eph.yaml.
+	Dir string
+
+	// Environment selects the eph.<env>.yaml overlay. It defaults to the
+	// EPH_ENV environment variable.
+	Environment string
+
+	// Interpolate controls ${VAR} expansion, which is applied to every file
+	// before merging.
+	Interpolate InterpolateOptions
+}
+
+// Load locates eph.yaml, layers any overlays on top of it, interpolates
+// environment variables and strictly decodes the result.
+//
+// Overlays live next to the base file and are applied in increasing order of
+// precedence:
+//
+//	eph.yaml            base configuration, committed to the repository
+//	eph.<env>.yaml      per-environment overrides (LoadOptions.Environment)
+//	eph.local.yaml      personal overrides, typically gitignored
+//
+// Mappings are merged key by key; any other value, including a list, in a
+// later file replaces the earlier one wholesale. Config.Source reports which
+// file supplied each value.
+func Load(opts LoadOptions) (*Config, error) {
+	base, err := resolveConfigPath(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	env := opts.Environment
+	if env == "" {
+		env = os.Getenv(EnvOverlayVar)
+	}
+	files := []string{base}
+	for _, overlay := range overlayPaths(base, env) {
+		if _, err := os.Stat(overlay); err == nil {
+			files = append(files, overlay)
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+	}
+
+	report := newInterpolationReport()
+	origin := map[*yaml.Node]string{}
+	var merged *yaml.Node
+	var errs ErrorList
+
+	for i, file := range files {
+		data, err := os.ReadFile(file) /* #nosec G304 user input for filepath is ok for yaml config */
+		if err != nil {
+			return nil, err
+		}
+		node, err := parseNode(file, data)
+		if err != nil {
+			var list ErrorList
+			if errors.As(err, &list) {
+				errs = append(errs, list...)
+				continue
+			}
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		if err := interpolateNode(file, node, opts.Interpolate, report); err != nil {
+			errs = append(errs, err.(ErrorList)...)
+			continue
+		}
+		// Check each file on its own so errors point at the file that
+		// contains them, not at the merged document.
+		if _, err := decodeNode(file, node, nil); err != nil {
+			errs = append(errs, err.(ErrorList)...)
+			continue
+		}
+
+		if i == 0 {
+			merged = node
+			continue
+		}
+		markOrigin(node, file, origin)
+		merged = mergeNodes(merged, node)
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	cfg, err := decodeNode(base, merged, origin)
+	if err != nil {
+		return nil, err
+	}
+	cfg.files = files
+	cfg.variables = report
+	return cfg, nil
+}
+
+// resolveConfigPath returns the base config file selected by opts.
+func resolveConfigPath(opts LoadOptions) (string, error) {
+	if opts.Path != "" {
+		info, err := os.Stat(opts.Path)
+		if err != nil {
+			return "", err
+		}
+		if info.IsDir() {
+			return filepath.Join(opts.Path, defaultConfigFile), nil
+		}
+		return opts.Path, nil
+	}
+
+	dir := opts.Dir
+	if dir == "" {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return "", err
+		}
+		dir = cwd
+	}
+	return Discover(dir)
+}
+
+// Discover walks up from dir to the repository root looking for eph.yaml and
+// returns the first one found.
+func Discover(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	start := dir
+	for {
+		candidate := filepath.Join(dir, defaultConfigFile)
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+			return candidate, nil
+		}
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return "", fmt.Errorf("%w: no %s in %s or its parents up to the repository root %s",
+				ErrNotFound, defaultConfigFile, start, dir)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", fmt.Errorf("%w: no %s in %s or any parent directory",
+				ErrNotFound, defaultConfigFile, start)
+		}
+		dir = parent
+	}
+}
+
+// overlayPaths returns the overlay files for base in increasing precedence:
+// eph.yaml -> eph.<env>.yaml, eph.local.yaml.
+func overlayPaths(base, env string) []string {
+	ext := filepath.Ext(base)
+	stem := strings.TrimSuffix(base, ext)
+	var paths []string
+	if env != "" {
+		paths = append(paths, stem+"."+env+ext)
+	}
+	return append(paths, stem+".local"+ext)
+}
+
+// mergeNodes overlays src onto dst. Mappings are merged recursively; any
+// other node in src replaces the one in dst.
+func mergeNodes(dst, src *yaml.Node) *yaml.Node {
+	if dst.Kind != yaml.MappingNode || src.Kind != yaml.MappingNode {
+		return src
+	}
+	for i := 0; i+1 < len(src.Content); i += 2 {
+		key, val := src.Content[i], src.Content[i+1]
+		found := false
+		for j := 0; j+1 < len(dst.Content); j += 2 {
+			if dst.Content[j].Value != key.Value {
+				continue
+			}
+			if dst.Content[j+1].Kind == yaml.MappingNode && val.Kind == yaml.MappingNode {
+				mergeNodes(dst.Content[j+1], val)
+			} else {
+				// Take the overlay's key too, so the value's position (which
+				// is recorded at its key) points at the overlay file.
+				dst.Content[j], dst.Content[j+1] = key, val
+			}
+			found = true
+			break
+		}
+		if !found {
+			dst.Content = append(dst.Content, key, val)
+		}
+	}
+	return dst
+}
+
+func markOrigin(node *yaml.Node, file string, origin map[*yaml.Node]string) {
+	origin[node] = file
+	for _, c := range node.Content {
+		markOrigin(c, file, origin)
+	}
+}
@@ -0,0 +1,187 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const baseConfig = `version: "1.0"
+name: app
+environment:
+  ttl: 72h
+  idle_timeout: 4h
+  env:
+    LOG_LEVEL: info
+    FEATURE_X: "off"
+networking:
+  routing:
+    strategy: subdomain
+`
+
+// writeFiles creates files (relative path -> contents) under a new temporary
+// directory and returns it.
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, contents := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(contents), 0o600))
+	}
+	return dir
+}
+
+func TestLoadRejectsEmptyFiles(t *testing.T) {
+	for _, name := range []string{"empty.yaml", "comments_only.yaml"} {
+		_, err := Load(LoadOptions{Path: filepath.Join("testdata", name)})
+		assert.Error(t, err, name)
+	}
+}
+
+func TestLoadRejectsBadYAML(t *testing.T) {
+	_, err := Load(LoadOptions{Path: filepath.Join("testdata", "invalid.yaml")})
+	assert.Error(t, err)
+}
+
+func TestLoadMissingPath(t *testing.T) {
+	_, err := Load(LoadOptions{Path: "blah"})
+	assert.Error(t, err)
+}
+
+func TestLoadExplicitPath(t *testing.T) {
+	cfg, err := Load(LoadOptions{Path: filepath.Join("testdata", "eph.yaml")})
+	require.NoError(t, err)
+	assert.Equal(t, "my-application", cfg.Name)
+	assert.Equal(t, []string{filepath.Join("testdata", "eph.yaml")}, cfg.Files())
+}
+
+func TestLoadDirectoryPath(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"eph.yaml": baseConfig})
+	cfg, err := Load(LoadOptions{Path: dir})
+	require.NoError(t, err)
+	assert.Equal(t, "app", cfg.Name)
+}
+
+func TestDiscoverWalksUpToRepositoryRoot(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		".git/HEAD":            "ref: refs/heads/main\n",
+		"eph.yaml":             baseConfig,
+		"services/api/main.go": "package main\n",
+	})
+
+	path, err := Discover(filepath.Join(dir, "services", "api"))
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "eph.yaml"), path)
+
+	cfg, err := Load(LoadOptions{Dir: filepath.Join(dir, "services")})
+	require.NoError(t, err)
+	assert.Equal(t, "app", cfg.Name)
+}
+
+func TestDiscoverStopsAtRepositoryRoot(t *testing.T) {
+	// An eph.yaml above the repository root belongs to something else.
+	dir := writeFiles(t, map[string]string{
+		"eph.yaml":       baseConfig,
+		"repo/.git/HEAD": "ref: refs/heads/main\n",
+		"repo/src/x.go":  "package x\n",
+	})
+
+	_, err := Discover(filepath.Join(dir, "repo", "src"))
+	assert.True(t, errors.Is(err, ErrNotFound), "got %v", err)
+
+	_, err = Load(LoadOptions{Dir: filepath.Join(dir, "repo")})
+	assert.True(t, errors.Is(err, ErrNotFound), "got %v", err)
+}
+
+func TestLoadOverlays(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"eph.yaml": baseConfig,
+		"eph.staging.yaml": `environment:
+  ttl: 24h
+  env:
+    FEATURE_X: "on"
+`,
+		"eph.local.yaml": `environment:
+  env:
+    LOG_LEVEL: debug
+`,
+	})
+
+	cfg, err := Load(LoadOptions{Path: dir, Environment: "staging"})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		filepath.Join(dir, "eph.yaml"),
+		filepath.Join(dir, "eph.staging.yaml"),
+		filepath.Join(dir, "eph.local.yaml"),
+	}, cfg.Files())
+
+	// Mappings merge key by key; later files win.
+	assert.Equal(t, map[string]string{"LOG_LEVEL": "debug", "FEATURE_X": "on"}, cfg.Environment.Env)
+	assert.Equal(t, "24h0m0s", cfg.Environment.TTL.Duration.String())
+	assert.Equal(t, "4h0m0s", cfg.Environment.IdleTimeout.Duration.String())
+	assert.Equal(t, "subdomain", cfg.Networking.Routing.Strategy)
+
+	assert.Equal(t, filepath.Join(dir, "eph.staging.yaml"), cfg.Source("environment.ttl"))
+	assert.Equal(t, filepath.Join(dir, "eph.local.yaml"), cfg.Source("environment.env.LOG_LEVEL"))
+	assert.Equal(t, filepath.Join(dir, "eph.yaml"), cfg.Source("environment.idle_timeout"))
+
+	pos, ok := cfg.Position("environment.ttl")
+	require.True(t, ok)
+	assert.Equal(t, filepath.Join(dir, "eph.staging.yaml"), pos.File)
+	assert.Equal(t, 2, pos.Line)
+}
+
+func TestLoadEnvironmentFromEnvVar(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"eph.yaml":     baseConfig,
+		"eph.dev.yaml": "name: app-dev\n",
+	})
+	t.Setenv(EnvOverlayVar, "dev")
+
+	cfg, err := Load(LoadOptions{Path: dir})
+	require.NoError(t, err)
+	assert.Equal(t, "app-dev", cfg.Name)
+}
+
+func TestLoadReportsErrorsPerFile(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"eph.yaml": baseConfig,
+		"eph.local.yaml": `environment:
+  tll: 1h
+`,
+	})
+
+	_, err := Load(LoadOptions{Path: dir})
+	var list ErrorList
+	require.True(t, errors.As(err, &list), "got %v", err)
+	require.Len(t, list, 1)
+	assert.Equal(t, filepath.Join(dir, "eph.local.yaml"), list[0].Pos.File)
+	assert.Equal(t, 2, list[0].Pos.Line)
+	assert.Contains(t, list[0].Message, `did you mean "ttl"`)
+}
+
+func TestLoadInterpolatesEveryFile(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"eph.yaml":       baseConfig,
+		"eph.local.yaml": "name: ${APP_NAME:-fallback}\n",
+	})
+
+	cfg, err := Load(LoadOptions{
+		Path: dir,
+		Interpolate: InterpolateOptions{Lookup: func(name string) (string, bool) {
+			if name == "APP_NAME" {
+				return "from-env", true
+			}
+			return "", false
+		}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "from-env", cfg.Name)
+	assert.Contains(t, cfg.Variables().Names(), "APP_NAME")
+}
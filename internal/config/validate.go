@@ -0,0 +1,676 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"path"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/ephlabs/eph/internal/template"
+)
+
+var (
+	// knownProviders lists the provider names accepted in providers.primary
+	// and providers.fallback. knownProvidersMu guards it, as plugins add to
+	// it at runtime.
+	knownProviders   = []string{"kubernetes", "docker-compose", "fake"}
+	knownProvidersMu sync.RWMutex
+
+	triggerTypes      = []string{"pr_label", "pr_comment", "auto", "git_branch", "git_tag"}
+	tagSources        = []string{"git_note"}
+	fallbackBehaviors = []string{"fail", "use-fallback", "wait"}
+	databaseTypes     = []string{"postgres", "mysql"}
+	databaseSeeding   = []string{"empty", "seed", "snapshot", "branch"}
+	serviceTypes      = []string{"internal", "external"}
+	secretProviders   = []string{"kubernetes", "vault", "aws-secrets-manager"}
+	accessLevels      = []string{"public", "protected"}
+	protectionTypes   = []string{"none", "basic", "oauth"}
+	namingStrategies  = []string{"readable"}
+	routingStrategies = []string{"subdomain", "path", "header"}
+	tlsProviders      = []string{"cert-manager", "letsencrypt", "self-signed", "wildcard"}
+	jsonPatchOps      = []string{"add", "remove", "replace", "move", "copy", "test"}
+
+	dnsLabel = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+	quantity = regexp.MustCompile(`^([0-9]+(?:\.[0-9]+)?)(m|k|M|G|T|Ki|Mi|Gi|Ti)?$`)
+)
+
+// AddKnownProvider accepts name in providers.primary and providers.fallback,
+// for providers that are only known at runtime, such as plugins. It must be
+// called before configs are validated.
+func AddKnownProvider(name string) {
+	knownProvidersMu.Lock()
+	defer knownProvidersMu.Unlock()
+	if !slices.Contains(knownProviders, name) {
+		knownProviders = append(knownProviders, name)
+	}
+}
+
+// KnownProviders returns the provider names accepted in providers.primary
+// and providers.fallback.
+func KnownProviders() []string {
+	knownProvidersMu.RLock()
+	defer knownProvidersMu.RUnlock()
+	return slices.Clone(knownProviders)
+}
+
+// Validate checks the semantic rules that strict decoding cannot express,
+// such as required fields and combinations that only make sense together.
+// Every violation is returned in a single ErrorList, each addressed by its
+// YAML path and, where known, its source position.
+func Validate(cfg *Config) error {
+	v := &validator{cfg: cfg}
+	v.validate()
+	return v.errs.Err()
+}
+
+type validator struct {
+	cfg  *Config
+	errs ErrorList
+}
+
+func (v *validator) errorf(p, format string, args ...any) {
+	v.errs = append(v.errs, &Error{
+		Path:    p,
+		Pos:     v.cfg.nearestPosition(p),
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+func (v *validator) required(p, value string) bool {
+	if strings.TrimSpace(value) == "" {
+		v.errorf(p, "is required")
+		return false
+	}
+	return true
+}
+
+func (v *validator) oneOf(p, value string, allowed []string) {
+	if value == "" {
+		return
+	}
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	v.errorf(p, "must be one of %s, got %q", strings.Join(allowed, ", "), value)
+}
+
+// template checks that a templated value parses and only references the
+// variables available where it is used.
+func (v *validator) template(p, value string, ctx template.Context) {
+	if value == "" {
+		return
+	}
+	if err := template.Check(value, ctx); err != nil {
+		v.errorf(p, "%v", err)
+	}
+}
+
+func (v *validator) validate() {
+	c := v.cfg
+	if v.required("version", c.Version) && c.Version != "1.0" {
+		v.errorf("version", "unsupported version %q (supported: 1.0)", c.Version)
+	}
+	if v.required("name", c.Name) && !dnsLabel.MatchString(c.Name) {
+		v.errorf("name", "must be a lowercase DNS label (a-z, 0-9 and '-'), got %q", c.Name)
+	}
+
+	v.validateProviders()
+	for i, t := range c.Triggers {
+		v.validateTrigger(fmt.Sprintf("triggers[%d]", i), t)
+	}
+	v.validateEnvironment()
+	if c.Kubernetes != nil {
+		v.validateKubernetes()
+	}
+	if c.DockerCompose != nil {
+		v.validateDockerCompose()
+	}
+	if c.Fake != nil {
+		v.validateFake()
+	}
+	v.validateDatabase()
+	v.validateServices()
+	v.validateSecrets()
+	v.validateSecurity()
+	v.validateNetworking()
+	v.validateHooks()
+}
+
+func (v *validator) validateProviders() {
+	p := v.cfg.Providers
+	if p.Primary == "" {
+		return
+	}
+	known := KnownProviders()
+	v.oneOf("providers.primary", p.Primary, known)
+	v.oneOf("providers.fallback", p.Fallback, known)
+	if p.Fallback != "" && p.Fallback == p.Primary {
+		v.errorf("providers.fallback", "must differ from providers.primary")
+	}
+	for _, name := range []string{p.Primary, p.Fallback} {
+		switch {
+		case name == "kubernetes" && v.cfg.Kubernetes == nil:
+			v.errorf("kubernetes", "is required when %q is a configured provider", name)
+		case name == "docker-compose" && v.cfg.DockerCompose == nil:
+			v.errorf("docker-compose", "is required when %q is a configured provider", name)
+		}
+	}
+}
+
+func (v *validator) validateTrigger(p string, t Trigger) {
+	if !v.required(p+".type", t.Type) {
+		return
+	}
+	v.oneOf(p+".type", t.Type, triggerTypes)
+	switch t.Type {
+	case "pr_label":
+		if len(t.Labels) == 0 {
+			v.errorf(p+".labels", "at least one label is required for pr_label triggers")
+		}
+	case "pr_comment":
+		if len(t.Patterns) == 0 {
+			v.errorf(p+".patterns", "at least one pattern is required for pr_comment triggers")
+		}
+	case "auto":
+		if len(t.Branches) == 0 {
+			v.errorf(p+".branches", "at least one branch pattern is required for auto triggers")
+		}
+		for i, b := range t.Branches {
+			v.globPattern(fmt.Sprintf("%s.branches[%d]", p, i), b)
+		}
+	case "git_branch", "git_tag":
+		if v.required(p+".pattern", t.Pattern) {
+			v.globPattern(p+".pattern", t.Pattern)
+		}
+	}
+}
+
+func (v *validator) globPattern(p, pattern string) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		v.errorf(p, "invalid glob pattern %q", pattern)
+	}
+}
+
+func (v *validator) validateEnvironment() {
+	e := v.cfg.Environment
+	if e.TTL.Duration < 0 {
+		v.errorf("environment.ttl", "must not be negative")
+	}
+	if e.IdleTimeout.Duration < 0 {
+		v.errorf("environment.idle_timeout", "must not be negative")
+	}
+	if e.TTL.Duration > 0 && e.IdleTimeout.Duration > e.TTL.Duration {
+		v.errorf("environment.ttl", "(%s) is shorter than idle_timeout (%s)", e.TTL, e.IdleTimeout)
+	}
+	if e.WakeOnAccess && e.IdleTimeout.Duration == 0 {
+		v.errorf("environment.wake_on_access", "has no effect without idle_timeout")
+	}
+
+	v.template("environment.name_template", e.NameTemplate, template.ContextName)
+	v.template("environment.subdomain_template", e.SubdomainTemplate, template.ContextSubdomain)
+	v.template("environment.alias_template", e.AliasTemplate, template.ContextAlias)
+
+	v.validateResources("environment.resources", e.Resources)
+
+	names := map[string]int{}
+	for i, img := range e.Images {
+		p := fmt.Sprintf("environment.images[%d]", i)
+		if v.required(p+".name", img.Name) {
+			if prev, dup := names[img.Name]; dup {
+				v.errorf(p+".name", "duplicate image name %q (also used by environment.images[%d])", img.Name, prev)
+			}
+			names[img.Name] = i
+		}
+		v.required(p+".repository", img.Repository)
+		v.validateImage(p, img)
+	}
+}
+
+func (v *validator) validateImage(p string, img Image) {
+	if img.Tag == "" && img.TagTemplate == "" && img.TagPattern == "" && img.TagSource == "" {
+		v.errorf(p, "one of tag, tag_template, tag_pattern or tag_source is required")
+	}
+	if img.Tag != "" && (img.TagTemplate != "" || img.TagPattern != "" || img.TagSource != "") {
+		v.errorf(p+".tag", "a fixed tag cannot be combined with tag_template, tag_pattern or tag_source")
+	}
+	v.template(p+".tag_template", img.TagTemplate, template.ContextImageTag)
+	v.template(p+".tag_pattern", img.TagPattern, template.ContextImageTag)
+	v.oneOf(p+".tag_source", img.TagSource, tagSources)
+	if img.TagSource == "git_note" && img.GitNoteRef == "" {
+		v.errorf(p+".git_note_ref", "is required when tag_source is git_note")
+	}
+	if img.MaxAge.Duration < 0 {
+		v.errorf(p+".max_age", "must not be negative")
+	}
+	v.oneOf(p+".fallback_behavior", img.FallbackBehavior, fallbackBehaviors)
+	if img.FallbackBehavior == "use-fallback" && img.FallbackTag == "" {
+		v.errorf(p+".fallback_tag", "is required when fallback_behavior is use-fallback")
+	}
+	for i, label := range img.RequiredLabels {
+		lp := fmt.Sprintf("%s.required_labels[%d]", p, i)
+		if k, _, ok := strings.Cut(label, "="); !ok || k == "" {
+			v.errorf(lp, "must have the form key=value, got %q", label)
+			continue
+		}
+		v.template(lp, label, template.ContextImageTag)
+	}
+}
+
+func (v *validator) validateResources(p string, r ResourcesConfig) {
+	cpuReq := v.quantity(p+".cpu_request", r.CPURequest)
+	cpuLim := v.quantity(p+".cpu_limit", r.CPULimit)
+	memReq := v.quantity(p+".memory_request", r.MemoryRequest)
+	memLim := v.quantity(p+".memory_limit", r.MemoryLimit)
+	if cpuReq > 0 && cpuLim > 0 && cpuReq > cpuLim {
+		v.errorf(p+".cpu_request", "(%s) exceeds cpu_limit (%s)", r.CPURequest, r.CPULimit)
+	}
+	if memReq > 0 && memLim > 0 && memReq > memLim {
+		v.errorf(p+".memory_request", "(%s) exceeds memory_limit (%s)", r.MemoryRequest, r.MemoryLimit)
+	}
+}
+
+// quantity parses a Kubernetes-style resource quantity into a comparable
+// float, reporting an error and returning 0 when it is malformed.
+func (v *validator) quantity(p, s string) float64 {
+	if s == "" {
+		return 0
+	}
+	q, err := ParseQuantity(s)
+	if err != nil {
+		v.errorf(p, "%v", err)
+		return 0
+	}
+	return q
+}
+
+// ParseQuantity parses the subset of Kubernetes resource quantities used in
+// eph.yaml ("100m", "2", "128Mi", "4Gi") into base units.
+func ParseQuantity(s string) (float64, error) {
+	m := quantity.FindStringSubmatch(s)
+	if m == nil {
+		return 0, fmt.Errorf("invalid resource quantity %q", s)
+	}
+	n, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid resource quantity %q", s)
+	}
+	multipliers := map[string]float64{
+		"": 1, "m": 1e-3, "k": 1e3, "M": 1e6, "G": 1e9, "T": 1e12,
+		"Ki": 1 << 10, "Mi": 1 << 20, "Gi": 1 << 30, "Ti": 1 << 40,
+	}
+	return n * multipliers[m[2]], nil
+}
+
+func (v *validator) validateKubernetes() {
+	k := v.cfg.Kubernetes
+	v.template("kubernetes.namespace_template", k.NamespaceTemplate, template.ContextNamespace)
+	for i, m := range k.Manifests {
+		p := fmt.Sprintf("kubernetes.manifests[%d]", i)
+		switch sources := countSet(m.Path != "", m.Kustomization != "", m.Helm != nil); {
+		case sources == 0:
+			v.errorf(p, "one of path, kustomization or helm is required")
+		case sources > 1:
+			v.errorf(p, "path, kustomization and helm are mutually exclusive")
+		}
+		if m.Helm != nil {
+			v.validateHelm(p+".helm", m.Helm)
+		}
+		if len(m.Patches) > 0 && m.Kustomization == "" {
+			v.errorf(p+".patches", "patches are only supported on kustomization sources")
+		}
+		for j, patch := range m.Patches {
+			v.validatePatch(fmt.Sprintf("%s.patches[%d]", p, j), patch)
+		}
+	}
+	for i, img := range k.Images {
+		p := fmt.Sprintf("kubernetes.images[%d]", i)
+		v.required(p+".name", img.Name)
+		if img.NewName == "" && img.NewTag == "" && img.Digest == "" {
+			v.errorf(p, "one of newName, newTag or digest is required")
+		}
+		v.template(p+".newName", img.NewName, template.ContextImageOverlay)
+		v.template(p+".newTag", img.NewTag, template.ContextImageOverlay)
+		if img.NewTag != "" && img.Digest != "" {
+			v.errorf(p+".digest", "newTag and digest are mutually exclusive")
+		}
+	}
+	for i, s := range k.ImagePullSecrets {
+		v.required(fmt.Sprintf("kubernetes.imagePullSecrets[%d].name", i), s.Name)
+	}
+	for i, e := range k.Ingress.Expose {
+		p := fmt.Sprintf("kubernetes.ingress.expose[%d]", i)
+		if v.required(p+".service", e.Service) && !template.IsLabel(e.Service) {
+			v.errorf(p+".service", "must be a DNS label, got %q", e.Service)
+		}
+		if e.Port < 1 || e.Port > 65535 {
+			v.errorf(p+".port", "must be between 1 and 65535, got %d", e.Port)
+		}
+		if e.Path != "" && !strings.HasPrefix(e.Path, "/") {
+			v.errorf(p+".path", "must start with '/', got %q", e.Path)
+		}
+	}
+	if len(k.Ingress.Expose) > 0 {
+		switch strategy := v.cfg.Networking.Routing.Strategy; strategy {
+		case "path", "header":
+			if v.cfg.Environment.BaseDomain == "" {
+				v.errorf("environment.base_domain", "is required to expose services with %s routing", strategy)
+			}
+		default:
+			if v.cfg.Environment.BaseDomain == "" && v.cfg.Environment.SubdomainTemplate == "" {
+				v.errorf("kubernetes.ingress.expose", "needs environment.base_domain or environment.subdomain_template")
+			}
+		}
+		if v.cfg.Networking.Routing.Strategy == "header" && (k.Ingress.Gateway == nil || k.Ingress.Gateway.Name == "") {
+			v.errorf("kubernetes.ingress.gateway.name", "is required to expose services with header routing")
+		}
+	}
+	tls := v.cfg.Networking.TLS
+	if tls.Enabled && tls.Provider == "cert-manager" && tls.ClusterIssuer == "" &&
+		k.Ingress.Annotations["cert-manager.io/cluster-issuer"] == "" && k.Ingress.Annotations["cert-manager.io/issuer"] == "" {
+		v.errorf("networking.tls.cluster_issuer", "is required for cert-manager unless kubernetes.ingress.annotations names an issuer")
+	}
+	// Every environment's Ingress would ask cert-manager for its own
+	// certificate for base_domain, running into the ACME duplicate
+	// certificate limits.
+	if tls.Enabled && tls.Provider == "cert-manager" && len(k.Ingress.Expose) > 0 && v.cfg.Networking.Routing.Strategy == "path" {
+		v.errorf("networking.tls.provider", "cert-manager cannot be used with path routing, as environments share base_domain; use the wildcard provider")
+	}
+}
+
+func (v *validator) validateHelm(p string, h *HelmSource) {
+	v.required(p+".chart", h.Chart)
+	if h.Release != "" && !template.IsLabel(h.Release) {
+		v.errorf(p+".release", "must be a DNS label, got %q", h.Release)
+	}
+	for i, f := range h.Values {
+		v.required(fmt.Sprintf("%s.values[%d]", p, i), f)
+	}
+	keys := make([]string, 0, len(h.Set))
+	for k := range h.Set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if k == "" || strings.ContainsAny(k, "=,") {
+			v.errorf(p+".set", "invalid key %q", k)
+			continue
+		}
+		v.template(p+".set."+k, h.Set[k], template.ContextHelmSet)
+	}
+}
+
+func countSet(conds ...bool) int {
+	n := 0
+	for _, c := range conds {
+		if c {
+			n++
+		}
+	}
+	return n
+}
+
+type jsonPatchOp struct {
+	Op    string `json:"op" yaml:"op"`
+	Path  string `json:"path" yaml:"path"`
+	From  string `json:"from" yaml:"from"`
+	Value any    `json:"value" yaml:"value"`
+}
+
+func (v *validator) validatePatch(p string, patch Patch) {
+	if patch.Target.Kind == "" && patch.Target.Name == "" {
+		v.errorf(p+".target", "at least one of kind or name is required")
+	}
+	if !v.required(p+".patch", patch.Patch) {
+		return
+	}
+	var ops []jsonPatchOp
+	if err := yaml.Unmarshal([]byte(patch.Patch), &ops); err != nil {
+		if jerr := json.Unmarshal([]byte(patch.Patch), &ops); jerr != nil {
+			v.errorf(p+".patch", "must be an RFC 6902 JSON patch (a list of operations): %v", err)
+			return
+		}
+	}
+	if len(ops) == 0 {
+		v.errorf(p+".patch", "contains no operations")
+	}
+	for i, op := range ops {
+		v.oneOf(fmt.Sprintf("%s.patch[%d].op", p, i), op.Op, jsonPatchOps)
+		if op.Op == "" {
+			v.errorf(p+".patch", "operation %d is missing op", i)
+		}
+		if !strings.HasPrefix(op.Path, "/") {
+			v.errorf(p+".patch", "operation %d path must be a JSON pointer starting with '/', got %q", i, op.Path)
+		}
+		if (op.Op == "move" || op.Op == "copy") && op.From == "" {
+			v.errorf(p+".patch", "operation %d (%s) requires from", i, op.Op)
+		}
+	}
+}
+
+func (v *validator) validateDockerCompose() {
+	dc := v.cfg.DockerCompose
+	if len(dc.ComposeFiles) == 0 {
+		v.errorf("docker-compose.compose_files", "at least one compose file is required")
+	}
+	for _, svc := range slices.Sorted(maps.Keys(dc.Scale)) {
+		if dc.Scale[svc] < 0 {
+			v.errorf("docker-compose.scale."+svc, "must not be negative")
+		}
+	}
+}
+
+func (v *validator) validateFake() {
+	f := v.cfg.Fake
+	durations := []struct {
+		name string
+		d    Duration
+	}{
+		{"create_latency", f.CreateLatency},
+		{"destroy_latency", f.DestroyLatency},
+		{"ready_after", f.ReadyAfter},
+		{"log_interval", f.LogInterval},
+	}
+	for _, d := range durations {
+		if d.d.Duration < 0 {
+			v.errorf("fake."+d.name, "must not be negative")
+		}
+	}
+	if f.FailureRate < 0 || f.FailureRate > 1 {
+		v.errorf("fake.failure_rate", "must be between 0 and 1")
+	}
+	for i, pattern := range f.FailRefs {
+		if _, err := path.Match(pattern, ""); err != nil {
+			v.errorf(fmt.Sprintf("fake.fail_refs[%d]", i), "invalid pattern %q", pattern)
+		}
+	}
+}
+
+func (v *validator) validateDatabase() {
+	db := v.cfg.Database
+	if db.Enabled && len(db.Instances) == 0 {
+		v.errorf("database.instances", "at least one instance is required when database.enabled is true")
+	}
+	names := map[string]bool{}
+	for i, inst := range db.Instances {
+		p := fmt.Sprintf("database.instances[%d]", i)
+		if v.required(p+".name", inst.Name) {
+			if names[inst.Name] {
+				v.errorf(p+".name", "duplicate database instance name %q", inst.Name)
+			}
+			names[inst.Name] = true
+		}
+		if v.required(p+".type", inst.Type) {
+			v.oneOf(p+".type", inst.Type, databaseTypes)
+		}
+		v.template(p+".connection.database", inst.Connection.Database, template.ContextDatabase)
+		v.oneOf(p+".template.strategy", inst.Template.Strategy, databaseSeeding)
+		switch inst.Template.Strategy {
+		case "seed":
+			if inst.Template.Seed == nil || len(inst.Template.Seed.Scripts) == 0 {
+				v.errorf(p+".template.seed.scripts", "at least one script is required for the seed strategy")
+			}
+		case "snapshot":
+			if inst.Template.Snapshot == nil || inst.Template.Snapshot.Source == "" {
+				v.errorf(p+".template.snapshot.source", "is required for the snapshot strategy")
+			}
+		}
+	}
+}
+
+func (v *validator) validateServices() {
+	names := map[string]bool{}
+	for i, svc := range v.cfg.Services {
+		p := fmt.Sprintf("services[%d]", i)
+		if v.required(p+".name", svc.Name) {
+			if names[svc.Name] {
+				v.errorf(p+".name", "duplicate service name %q", svc.Name)
+			}
+			names[svc.Name] = true
+		}
+		if !v.required(p+".type", svc.Type) {
+			continue
+		}
+		v.oneOf(p+".type", svc.Type, serviceTypes)
+		switch svc.Type {
+		case "internal":
+			v.required(p+".image", svc.Image)
+			if svc.Endpoint != "" {
+				v.errorf(p+".endpoint", "is only valid for external services")
+			}
+		case "external":
+			v.required(p+".endpoint", svc.Endpoint)
+			if svc.Image != "" {
+				v.errorf(p+".image", "is only valid for internal services")
+			}
+		}
+	}
+}
+
+func (v *validator) validateSecrets() {
+	s := v.cfg.Secrets
+	v.oneOf("secrets.provider", s.Provider, secretProviders)
+	switch s.Provider {
+	case "kubernetes":
+		if s.Kubernetes == nil {
+			v.errorf("secrets.kubernetes", "is required when secrets.provider is kubernetes")
+		} else if len(s.Kubernetes.Secrets) > 0 && s.Kubernetes.CopyFromNamespace == "" {
+			v.errorf("secrets.kubernetes.copy_from_namespace", "is required when secrets are listed")
+		}
+	case "vault":
+		if s.Vault == nil {
+			v.errorf("secrets.vault", "is required when secrets.provider is vault")
+		} else {
+			v.required("secrets.vault.path", s.Vault.Path)
+			v.required("secrets.vault.role", s.Vault.Role)
+		}
+	}
+}
+
+func (v *validator) validateSecurity() {
+	s := v.cfg.Security
+	v.oneOf("security.environment_access.default", s.EnvironmentAccess.Default, accessLevels)
+	prot := s.EnvironmentAccess.Protection
+	v.oneOf("security.environment_access.protection.type", prot.Type, protectionTypes)
+	switch prot.Type {
+	case "basic":
+		if prot.BasicAuth == nil {
+			v.errorf("security.environment_access.protection.basic_auth", "is required when protection type is basic")
+		} else {
+			v.required("security.environment_access.protection.basic_auth.username", prot.BasicAuth.Username)
+			v.required("security.environment_access.protection.basic_auth.password", prot.BasicAuth.Password)
+		}
+	case "oauth":
+		if prot.OAuth == nil {
+			v.errorf("security.environment_access.protection.oauth", "is required when protection type is oauth")
+		} else {
+			v.required("security.environment_access.protection.oauth.provider", prot.OAuth.Provider)
+		}
+	}
+	if s.EnvironmentAccess.Default == "protected" && (prot.Type == "" || prot.Type == "none") {
+		v.errorf("security.environment_access.protection.type", "must not be none when default access is protected")
+	}
+	v.oneOf("security.naming.strategy", s.Naming.Strategy, namingStrategies)
+}
+
+func (v *validator) validateNetworking() {
+	n := v.cfg.Networking
+	v.oneOf("networking.routing.strategy", n.Routing.Strategy, routingStrategies)
+	switch n.Routing.Strategy {
+	case "path":
+		if v.required("networking.routing.path_prefix", n.Routing.PathPrefix) && !strings.HasPrefix(n.Routing.PathPrefix, "/") {
+			v.errorf("networking.routing.path_prefix", "must start with '/', got %q", n.Routing.PathPrefix)
+		}
+		v.template("networking.routing.path_prefix", n.Routing.PathPrefix, template.ContextPathPrefix)
+	case "header":
+		v.required("networking.routing.header", n.Routing.Header)
+	}
+	if n.TLS.Enabled {
+		v.oneOf("networking.tls.provider", n.TLS.Provider, tlsProviders)
+		if n.TLS.Provider == "wildcard" {
+			if n.TLS.WildcardSecret == nil {
+				v.errorf("networking.tls.wildcard_secret", "is required for the wildcard provider")
+			} else {
+				v.required("networking.tls.wildcard_secret.name", n.TLS.WildcardSecret.Name)
+				v.required("networking.tls.wildcard_secret.namespace", n.TLS.WildcardSecret.Namespace)
+			}
+		}
+	}
+}
+
+func (v *validator) validateHooks() {
+	phases := []struct {
+		name  string
+		hooks []Hook
+	}{
+		{"pre_create", v.cfg.Hooks.PreCreate},
+		{"post_create", v.cfg.Hooks.PostCreate},
+		{"pre_destroy", v.cfg.Hooks.PreDestroy},
+	}
+	for _, phase := range phases {
+		names := map[string]bool{}
+		for i, h := range phase.hooks {
+			p := fmt.Sprintf("hooks.%s[%d]", phase.name, i)
+			if v.required(p+".name", h.Name) {
+				if names[h.Name] {
+					v.errorf(p+".name", "duplicate hook name %q", h.Name)
+				}
+				names[h.Name] = true
+			}
+			if len(h.Command) == 0 || h.Command[0] == "" {
+				v.errorf(p+".command", "is required")
+			}
+			if h.Timeout.Duration < 0 {
+				v.errorf(p+".timeout", "must not be negative")
+			}
+		}
+	}
+}
+
+// nearestPosition returns the position of p or, when p is absent from the
+// source (typically a missing required field), of its closest ancestor.
+func (c *Config) nearestPosition(p string) Position {
+	for p != "" {
+		if pos, ok := c.Position(p); ok {
+			return pos
+		}
+		p = parentPath(p)
+	}
+	return Position{File: c.file}
+}
+
+func parentPath(p string) string {
+	if i := strings.LastIndexAny(p, ".["); i >= 0 {
+		return p[:i]
+	}
+	return ""
+}
@@ -0,0 +1,186 @@
+package config
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func validationErrors(t *testing.T, src string) ErrorList {
+	t.Helper()
+	cfg, err := Parse("eph.yaml", []byte(src))
+	require.NoError(t, err)
+
+	err = Validate(cfg)
+	if err == nil {
+		return nil
+	}
+	var list ErrorList
+	require.True(t, errors.As(err, &list))
+	return list
+}
+
+func findError(list ErrorList, path string) *Error {
+	for _, e := range list {
+		if e.Path == path {
+			return e
+		}
+	}
+	return nil
+}
+
+func TestValidateDocumentedExample(t *testing.T) {
+	cfg, err := parseTestdata(t, "eph.yaml")
+	require.NoError(t, err)
+	assert.NoError(t, Validate(cfg))
+}
+
+func TestValidateAggregatesErrors(t *testing.T) {
+	list := validationErrors(t, `version: "1.0"
+name: app
+environment:
+  ttl: 1h
+  idle_timeout: 4h
+  images:
+    - name: api
+      repository: ghcr.io/org/api
+      tag_template: "pr-{pr_number}"
+    - name: worker
+      repository: ghcr.io/org/worker
+      tag_template: "pr-{pr_number}"
+      fallback_behavior: use-fallback
+networking:
+  routing:
+    strategy: path
+`)
+	require.Len(t, list, 3)
+
+	ttl := findError(list, "environment.ttl")
+	require.NotNil(t, ttl)
+	assert.Equal(t, 4, ttl.Pos.Line)
+	assert.Contains(t, ttl.Message, "shorter than idle_timeout")
+
+	// Missing fields are reported at the position of their closest parent.
+	fallback := findError(list, "environment.images[1].fallback_tag")
+	require.NotNil(t, fallback)
+	assert.Equal(t, 10, fallback.Pos.Line)
+
+	prefix := findError(list, "networking.routing.path_prefix")
+	require.NotNil(t, prefix)
+	assert.Equal(t, 15, prefix.Pos.Line)
+	assert.Contains(t, prefix.Error(), "eph.yaml:15:")
+}
+
+func TestValidateRules(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		path string
+	}{
+		{"missing version", "name: app\n", "version"},
+		{"bad name", "version: \"1.0\"\nname: My_App\n", "name"},
+		{"unknown provider", "version: \"1.0\"\nname: app\nproviders:\n  primary: nomad\n", "providers.primary"},
+		{"provider section missing", "version: \"1.0\"\nname: app\nproviders:\n  primary: kubernetes\n", "kubernetes"},
+		{"label trigger without labels", "version: \"1.0\"\nname: app\ntriggers:\n  - type: pr_label\n", "triggers[0].labels"},
+		{"branch trigger without pattern", "version: \"1.0\"\nname: app\ntriggers:\n  - type: git_branch\n", "triggers[0].pattern"},
+		{"image without tag strategy", "version: \"1.0\"\nname: app\nenvironment:\n  images:\n    - name: api\n      repository: r\n", "environment.images[0]"},
+		{"git note without ref", "version: \"1.0\"\nname: app\nenvironment:\n  images:\n    - name: api\n      repository: r\n      tag_source: git_note\n", "environment.images[0].git_note_ref"},
+		{"request above limit", "version: \"1.0\"\nname: app\nenvironment:\n  resources:\n    memory_request: 2Gi\n    memory_limit: 512Mi\n", "environment.resources.memory_request"},
+		{"bad quantity", "version: \"1.0\"\nname: app\nenvironment:\n  resources:\n    cpu_limit: lots\n", "environment.resources.cpu_limit"},
+		{"manifest without source", "version: \"1.0\"\nname: app\nkubernetes:\n  manifests:\n    - patches: []\n", "kubernetes.manifests[0]"},
+		{"bad json patch", "version: \"1.0\"\nname: app\nkubernetes:\n  manifests:\n    - kustomization: ./k8s\n      patches:\n        - target: {kind: Deployment}\n          patch: |\n            - op: frobnicate\n              path: /spec\n", "kubernetes.manifests[0].patches[0].patch[0].op"},
+		{"helm without chart", "version: \"1.0\"\nname: app\nkubernetes:\n  manifests:\n    - helm:\n        release: api\n", "kubernetes.manifests[0].helm.chart"},
+		{"helm and path", "version: \"1.0\"\nname: app\nkubernetes:\n  manifests:\n    - path: ./k8s\n      helm:\n        chart: ./chart\n", "kubernetes.manifests[0]"},
+		{"helm set with unknown variable", "version: \"1.0\"\nname: app\nkubernetes:\n  manifests:\n    - helm:\n        chart: ./chart\n        set:\n          image.tag: \"{words}\"\n", "kubernetes.manifests[0].helm.set.image.tag"},
+		{"exposed service without port", "version: \"1.0\"\nname: app\nenvironment:\n  base_domain: example.com\nkubernetes:\n  ingress:\n    expose:\n      - service: web\n", "kubernetes.ingress.expose[0].port"},
+		{"exposed service without domain", "version: \"1.0\"\nname: app\nkubernetes:\n  ingress:\n    expose:\n      - service: web\n        port: 80\n", "kubernetes.ingress.expose"},
+		{"path routing without base domain", "version: \"1.0\"\nname: app\nenvironment:\n  subdomain_template: \"{name}.example.com\"\nkubernetes:\n  ingress:\n    expose:\n      - service: web\n        port: 80\nnetworking:\n  routing:\n    strategy: path\n    path_prefix: /preview/{name}\n", "environment.base_domain"},
+		{"header routing without gateway", "version: \"1.0\"\nname: app\nenvironment:\n  base_domain: example.com\nkubernetes:\n  ingress:\n    expose:\n      - service: web\n        port: 80\nnetworking:\n  routing:\n    strategy: header\n    header: X-Eph-Environment\n", "kubernetes.ingress.gateway.name"},
+		{"cert-manager without issuer", "version: \"1.0\"\nname: app\nkubernetes: {}\nnetworking:\n  tls:\n    enabled: true\n    provider: cert-manager\n", "networking.tls.cluster_issuer"},
+		{"cert-manager with path routing", "version: \"1.0\"\nname: app\nenvironment:\n  base_domain: example.com\nkubernetes:\n  ingress:\n    expose:\n      - service: web\n        port: 80\nnetworking:\n  routing:\n    strategy: path\n    path_prefix: /preview/{name}\n  tls:\n    enabled: true\n    provider: cert-manager\n    cluster_issuer: letsencrypt\n", "networking.tls.provider"},
+		{"wildcard tls without secret", "version: \"1.0\"\nname: app\nnetworking:\n  tls:\n    enabled: true\n    provider: wildcard\n", "networking.tls.wildcard_secret"},
+		{"header routing without header", "version: \"1.0\"\nname: app\nnetworking:\n  routing:\n    strategy: header\n", "networking.routing.header"},
+		{"external service without endpoint", "version: \"1.0\"\nname: app\nservices:\n  - name: auth\n    type: external\n", "services[0].endpoint"},
+		{"basic auth without credentials", "version: \"1.0\"\nname: app\nsecurity:\n  environment_access:\n    protection:\n      type: basic\n", "security.environment_access.protection.basic_auth"},
+		{"seed without scripts", "version: \"1.0\"\nname: app\ndatabase:\n  instances:\n    - name: main\n      type: postgres\n      template:\n        strategy: seed\n", "database.instances[0].template.seed.scripts"},
+		{"fake failure rate above one", "version: \"1.0\"\nname: app\nfake:\n  failure_rate: 1.5\n", "fake.failure_rate"},
+		{"fake bad ref pattern", "version: \"1.0\"\nname: app\nfake:\n  fail_refs: [\"[\"]\n", "fake.fail_refs[0]"},
+		{"hook without command", "version: \"1.0\"\nname: app\nhooks:\n  pre_create:\n    - name: check\n", "hooks.pre_create[0].command"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list := validationErrors(t, tt.src)
+			assert.NotNil(t, findError(list, tt.path), "expected error at %s, got %v", tt.path, list)
+		})
+	}
+}
+
+func TestValidateScaleOrder(t *testing.T) {
+	list := validationErrors(t, "version: \"1.0\"\nname: app\ndocker-compose:\n  compose_files: [docker-compose.yml]\n  scale:\n    worker: -1\n    api: -1\n    cron: -1\n")
+	var paths []string
+	for _, e := range list {
+		paths = append(paths, e.Path)
+	}
+	assert.Equal(t, []string{"docker-compose.scale.api", "docker-compose.scale.cron", "docker-compose.scale.worker"}, paths)
+}
+
+func TestAddKnownProvider(t *testing.T) {
+	saved := KnownProviders()
+	t.Cleanup(func() { knownProviders = saved })
+
+	// Plugins are registered while configs may be validated elsewhere.
+	var wg sync.WaitGroup
+	for _, name := range []string{"nomad", "ecs"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			AddKnownProvider(name)
+		}()
+	}
+	list := validationErrors(t, "version: \"1.0\"\nname: app\nproviders:\n  primary: lambda\n")
+	wg.Wait()
+	assert.NotNil(t, findError(list, "providers.primary"))
+
+	AddKnownProvider("nomad")
+	assert.ElementsMatch(t, append(slices.Clone(saved), "nomad", "ecs"), KnownProviders(), "names are added once")
+}
+
+func TestParseQuantity(t *testing.T) {
+	tests := map[string]float64{
+		"100m":  0.1,
+		"2":     2,
+		"128Mi": 128 << 20,
+		"1.5Gi": 1.5 * (1 << 30),
+		"1k":    1000,
+	}
+	for in, want := range tests {
+		got, err := ParseQuantity(in)
+		require.NoError(t, err, in)
+		assert.InDelta(t, want, got, 1e-9, in)
+	}
+
+	_, err := ParseQuantity("12 cores")
+	assert.Error(t, err)
+}
+
+func TestValidateTemplateVariables(t *testing.T) {
+	list := validationErrors(t, `version: "1.0"
+name: app
+environment:
+  name_template: "{project}-{commit_sha:0:7}-{number}"
+  images:
+    - name: api
+      repository: ghcr.io/org/api
+      tag_template: "{ref_type}-{words}"
+kubernetes:
+  namespace_template: "{project}-pr-{pr_number"
+`)
+	require.Len(t, list, 3)
+	assert.Contains(t, findError(list, "environment.name_template").Message, "{commit_sha} not available")
+	assert.Contains(t, findError(list, "environment.images[0].tag_template").Message, "{words} not available")
+	assert.Contains(t, findError(list, "kubernetes.namespace_template").Message, "unterminated")
+}
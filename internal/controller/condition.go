@@ -0,0 +1,48 @@
+package controller
+
+import "time"
+
+// ConditionType names an aspect of an environment's state. Conditions add
+// detail that the phase alone cannot express, e.g. a Ready environment whose
+// latest image could not be resolved.
+type ConditionType string
+
+const (
+	// ConditionImageResolved reports whether images were found for the
+	// current commit.
+	ConditionImageResolved ConditionType = "ImageResolved"
+
+	// ConditionProvisioned reports whether the provider has created the
+	// environment's resources.
+	ConditionProvisioned ConditionType = "Provisioned"
+
+	// ConditionProviderSelected reports whether a provider could be chosen
+	// for a new environment and, for the fallback, why the primary was not.
+	ConditionProviderSelected ConditionType = "ProviderSelected"
+
+	// ConditionReady reports whether the environment is serving traffic. It
+	// is maintained by Environment.Transition.
+	ConditionReady ConditionType = "Ready"
+)
+
+// ConditionStatus is the state of a condition.
+type ConditionStatus string
+
+const (
+	ConditionTrue    ConditionStatus = "True"
+	ConditionFalse   ConditionStatus = "False"
+	ConditionUnknown ConditionStatus = "Unknown"
+)
+
+// Condition is an observation about an environment, modelled on Kubernetes
+// status conditions so it reads familiarly in the API and CLI.
+type Condition struct {
+	Type    ConditionType   `json:"type"`
+	Status  ConditionStatus `json:"status"`
+	Reason  string          `json:"reason,omitempty"`
+	Message string          `json:"message,omitempty"`
+
+	// LastTransitionTime is when Status last changed, not when the
+	// condition was last updated.
+	LastTransitionTime time.Time `json:"last_transition_time"`
+}
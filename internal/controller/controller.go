@@ -0,0 +1,466 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ephlabs/eph/internal/config"
+	"github.com/ephlabs/eph/internal/log"
+	"github.com/ephlabs/eph/internal/naming"
+	"github.com/ephlabs/eph/internal/providers"
+	"github.com/ephlabs/eph/internal/template"
+)
+
+// AliasSetter receives alias_template redirects as environments come and go.
+// server.AliasTable implements it.
+type AliasSetter interface {
+	Set(alias, url string)
+	Delete(alias string)
+}
+
+// DefaultImageWaitTimeout is how long CI gets to push an environment's
+// images.
+const DefaultImageWaitTimeout = 30 * time.Minute
+
+// Options configures a Controller.
+type Options struct {
+	Project  *config.Config
+	Provider providers.Provider
+	Refs     RefLister
+
+	// Fallback, if set, hosts new environments while Provider is
+	// unavailable or lacks a capability eph.yaml requires. Environments
+	// stay on the provider they were created on.
+	Fallback providers.Provider
+
+	// HealthInterval is how often the providers' access is re-checked when
+	// there is a fallback; it defaults to DefaultHealthInterval.
+	HealthInterval time.Duration
+
+	// CheckTimeout bounds health and capability checks; it defaults to
+	// DefaultCheckTimeout.
+	CheckTimeout time.Duration
+
+	// ImageWaitTimeout is how long an environment waits for its images
+	// before it is marked ImageNotFound; it defaults to
+	// DefaultImageWaitTimeout.
+	ImageWaitTimeout time.Duration
+
+	// NameKey is the secret that environment names are derived from. It
+	// must be stable across restarts, or every environment gets a new name.
+	NameKey []byte
+
+	// Aliases, if set, is kept in sync with the project's alias_template.
+	Aliases AliasSetter
+
+	// Now defaults to time.Now.
+	Now func() time.Time
+}
+
+// Controller turns the refs a forge informer reports into environments on a
+// provider, or on the fallback provider when the primary cannot take them.
+// It supplies the desired and actual state sources and the action handler
+// for a reconciler.Reconciler keyed by SourceRef.Key, and keeps an in-memory
+// view of environments for the API.
+type Controller struct {
+	opts  Options
+	names naming.Generator
+
+	// backends are the primary provider and the fallback, if any.
+	backends []*backend
+
+	mu   sync.RWMutex
+	envs map[string]*Environment
+
+	// hosts records the backend hosting each environment, by key.
+	hosts map[string]*backend
+}
+
+// New returns a Controller.
+func New(opts Options) (*Controller, error) {
+	if opts.Project == nil || opts.Provider == nil || opts.Refs == nil {
+		return nil, errors.New("controller: project, provider and refs are required")
+	}
+	if len(opts.NameKey) == 0 {
+		return nil, errors.New("controller: a name key is required to derive stable environment names")
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	if opts.HealthInterval <= 0 {
+		opts.HealthInterval = DefaultHealthInterval
+	}
+	if opts.CheckTimeout <= 0 {
+		opts.CheckTimeout = DefaultCheckTimeout
+	}
+	if opts.ImageWaitTimeout <= 0 {
+		opts.ImageWaitTimeout = DefaultImageWaitTimeout
+	}
+	backends := []*backend{{provider: opts.Provider}}
+	if opts.Fallback != nil {
+		backends = append(backends, &backend{provider: opts.Fallback})
+	}
+	return &Controller{opts: opts, backends: backends, envs: map[string]*Environment{}, hosts: map[string]*backend{}}, nil
+}
+
+// Desired lists the refs that should have an environment, keyed by
+// SourceRef.Key.
+func (c *Controller) Desired(ctx context.Context) (map[string]Ref, error) {
+	refs, err := c.opts.Refs.ListRefs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	desired := map[string]Ref{}
+	for _, ref := range refs {
+		if Triggered(c.opts.Project.Triggers, ref) {
+			desired[ref.Key()] = ref
+		}
+	}
+	return desired, nil
+}
+
+// Actual lists the environments that exist on the providers, keyed by
+// SourceRef.Key. A provider that fails to list contributes the environments
+// it last listed; Actual only fails if every provider does.
+func (c *Controller) Actual(ctx context.Context) (map[string]providers.Instance, error) {
+	c.checkHealth(ctx)
+	actual, hosts, err := c.actual(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.hosts = hosts
+	c.mu.Unlock()
+	for _, inst := range actual {
+		c.observe(ctx, inst, hosts[inst.Key])
+	}
+	return actual, nil
+}
+
+// UpToDate reports whether inst runs ref's commit and is ready. Instances
+// that are still starting are re-applied on every pass, which is how their
+// readiness is picked up.
+func (c *Controller) UpToDate(ref Ref, inst providers.Instance) bool {
+	return inst.CommitSHA == ref.CommitSHA && inst.Ready
+}
+
+// Create creates the environment for ref.
+func (c *Controller) Create(ctx context.Context, _ string, ref Ref) error {
+	return c.apply(ctx, ref)
+}
+
+// Update brings the environment for ref up to date.
+func (c *Controller) Update(ctx context.Context, _ string, ref Ref, _ providers.Instance) error {
+	return c.apply(ctx, ref)
+}
+
+// Delete destroys an environment whose ref no longer asks for one.
+func (c *Controller) Delete(ctx context.Context, key string, inst providers.Instance) error {
+	env := c.environment(key, nil, inst.Name)
+	ctx = log.WithEnvironment(ctx, env.ID, env.Name)
+	if err := c.transition(ctx, env, PhaseDeleting, "", ""); err != nil {
+		return err
+	}
+
+	c.mu.RLock()
+	b, ok := c.hosts[key]
+	c.mu.RUnlock()
+	if !ok {
+		b = c.backends[0]
+	}
+	if err := b.provider.Destroy(ctx, inst); err != nil {
+		return errors.Join(fmt.Errorf("destroying %s: %w", inst.Name, err),
+			c.transition(ctx, env, PhaseFailed, "DestroyFailed", err.Error()))
+	}
+	if alias := c.alias(env.Source); alias != "" && c.opts.Aliases != nil {
+		c.opts.Aliases.Delete(alias)
+	}
+
+	c.mu.Lock()
+	delete(c.envs, key)
+	delete(c.hosts, key)
+	b.forget(key)
+	c.mu.Unlock()
+	log.Info(ctx, "Environment deleted")
+	return nil
+}
+
+// Environments returns a snapshot of all known environments, sorted by name.
+func (c *Controller) Environments() []Environment {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	out := make([]Environment, 0, len(c.envs))
+	for _, env := range c.envs {
+		cp := *env
+		// The reconciler keeps updating these.
+		cp.Headers = maps.Clone(env.Headers)
+		cp.Images = slices.Clone(env.Images)
+		cp.Conditions = slices.Clone(env.Conditions)
+		out = append(out, cp)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+func (c *Controller) apply(ctx context.Context, ref Ref) error {
+	key := ref.Key()
+	name, err := c.name(ref.SourceRef)
+	if err != nil {
+		return err
+	}
+	env := c.environment(key, &ref.SourceRef, name)
+	ctx = log.WithEnvironment(ctx, env.ID, env.Name)
+
+	images, unresolved := ResolveImages(c.opts.Project, ref.SourceRef)
+	if len(unresolved) > 0 {
+		msg := fmt.Sprintf("no image tag for %v", unresolved)
+		c.setCondition(env, Condition{Type: ConditionImageResolved, Status: ConditionFalse, Reason: "TagNotFound", Message: msg})
+		// Images with a fallback_tag always resolve, so there is nothing to
+		// fall back to once the wait is over.
+		c.mu.RLock()
+		cond, _ := env.Condition(ConditionImageResolved)
+		timedOut := c.opts.Now().Sub(cond.LastTransitionTime) >= c.opts.ImageWaitTimeout &&
+			env.Phase.CanTransitionTo(PhaseImageNotFound)
+		c.mu.RUnlock()
+		if timedOut {
+			return c.transition(ctx, env, PhaseImageNotFound, "ImageWaitTimeout",
+				fmt.Sprintf("%s after %s, CI may have failed to build it", msg, c.opts.ImageWaitTimeout))
+		}
+		return c.transition(ctx, env, PhaseWaitingForImage, "", msg)
+	}
+	c.setCondition(env, Condition{Type: ConditionImageResolved, Status: ConditionTrue})
+
+	message := ""
+	for _, img := range images {
+		if img.Fallback {
+			message = "deploying fallback image"
+			c.mu.RLock()
+			viaFallback := env.Phase.CanTransitionTo(PhaseUsingFallbackImage)
+			c.mu.RUnlock()
+			if viaFallback {
+				if err := c.transition(ctx, env, PhaseUsingFallbackImage, "", ""); err != nil {
+					return err
+				}
+			}
+			break
+		}
+	}
+	b, err := c.place(ctx, env, key)
+	if err != nil {
+		return errors.Join(fmt.Errorf("placing %s: %w", env.Name, err),
+			c.transition(ctx, env, PhaseFailed, "NoProvider", err.Error()))
+	}
+	if err := c.transition(ctx, env, PhaseCreating, "", message); err != nil {
+		return err
+	}
+
+	spec := NewSpec(c.opts.Project, env.Name, ref.SourceRef, images)
+	inst, err := b.provider.Apply(ctx, spec)
+	if err != nil {
+		c.setCondition(env, Condition{Type: ConditionProvisioned, Status: ConditionFalse, Reason: "ApplyFailed", Message: err.Error()})
+		return errors.Join(fmt.Errorf("applying %s: %w", env.Name, err),
+			c.transition(ctx, env, PhaseFailed, "ApplyFailed", err.Error()))
+	}
+
+	c.mu.Lock()
+	env.Images = images
+	env.URL = inst.URL
+	env.Headers = inst.Headers
+	env.Provider = b.name()
+	c.hosts[key] = b
+	b.remember(inst)
+	c.mu.Unlock()
+	c.setCondition(env, Condition{Type: ConditionProvisioned, Status: ConditionTrue})
+	c.setAlias(ref.SourceRef, inst.URL)
+	if inst.Ready {
+		return c.transition(ctx, env, PhaseReady, "", "")
+	}
+	return c.transition(ctx, env, PhaseCreating, "", inst.Message)
+}
+
+// environment returns the tracked environment for key, creating it if needed.
+func (c *Controller) environment(key string, ref *SourceRef, name string) *Environment {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	env, ok := c.envs[key]
+	if !ok {
+		var src SourceRef
+		if ref != nil {
+			src = *ref
+		}
+		env = NewEnvironment(name, name, c.opts.Project.Name, src, c.opts.Now())
+		if ttl := c.opts.Project.Environment.TTL.Duration; ttl > 0 {
+			env.ExpiresAt = env.CreatedAt.Add(ttl)
+		}
+		c.envs[key] = env
+	}
+	if ref != nil {
+		env.Source = *ref
+	}
+	return env
+}
+
+// observe records an instance found on b, so that environments
+// created before a restart show up in the API and their aliases resolve,
+// and marks tracked environments ready once the provider reports them
+// ready: UpToDate then holds, so they are not re-applied.
+func (c *Controller) observe(ctx context.Context, inst providers.Instance, b *backend) {
+	c.mu.Lock()
+	if env, ok := c.envs[inst.Key]; ok {
+		env.Provider = b.name()
+		ready := inst.Ready && env.Phase == PhaseCreating && inst.CommitSHA == env.Source.CommitSHA
+		c.mu.Unlock()
+		if ready {
+			ctx := log.WithEnvironment(ctx, env.ID, env.Name)
+			if err := c.transition(ctx, env, PhaseReady, "", ""); err != nil {
+				log.Warn(ctx, "Not marking environment ready", "error", err)
+			}
+		}
+		return
+	}
+	// The key is all that providers record of the ref; the head branch of a
+	// pull request is filled in by the next apply.
+	src, _ := ParseKey(inst.Key)
+	src.CommitSHA = inst.CommitSHA
+	env := NewEnvironment(inst.Name, inst.Name, c.opts.Project.Name, src, c.opts.Now())
+	env.URL = inst.URL
+	env.Headers = inst.Headers
+	env.Provider = b.name()
+	env.Phase = PhaseCreating
+	if inst.Ready {
+		env.Phase = PhaseReady
+	}
+	c.envs[inst.Key] = env
+	c.mu.Unlock()
+	c.setAlias(src, inst.URL)
+}
+
+// transition moves env to phase to. A move the state machine forbids is
+// returned as a *TransitionError and leaves env unchanged.
+func (c *Controller) transition(ctx context.Context, env *Environment, to Phase, reason, message string) error {
+	c.mu.Lock()
+	from := env.Phase
+	err := env.Transition(to, reason, message, c.opts.Now())
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if from != to {
+		log.Info(ctx, "Environment phase changed", "from", from, "to", to)
+	}
+	return nil
+}
+
+func (c *Controller) setCondition(env *Environment, cond Condition) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	env.SetCondition(cond, c.opts.Now())
+}
+
+// name derives the environment name for ref. Names are derived rather than
+// random so that a restarted ephd arrives at the same name without storing it.
+func (c *Controller) name(ref SourceRef) (string, error) {
+	seed := naming.RefSeed(ref.Repository, string(ref.Type), ref.Name)
+	if ref.Type == RefPR {
+		if n, err := strconv.Atoi(ref.Name); err == nil {
+			seed = naming.PRSeed(ref.Repository, n)
+		}
+	}
+	generated, err := c.names.Derive(c.opts.NameKey, seed)
+	if err != nil {
+		return "", err
+	}
+
+	project := c.opts.Project
+	if project.Environment.NameTemplate == "" {
+		if project.Security.Naming.IncludeProject {
+			return generated.Label(project.Name), nil
+		}
+		return generated.String(), nil
+	}
+	vars := ref.Vars(project.Name).Merge(generated.Vars())
+	return template.RenderLabel(project.Environment.NameTemplate, vars)
+}
+
+// setAlias points ref's alias at url, if aliases are enabled.
+func (c *Controller) setAlias(ref SourceRef, url string) {
+	if alias := c.alias(ref); alias != "" && c.opts.Aliases != nil && url != "" {
+		c.opts.Aliases.Set(alias, url)
+	}
+}
+
+func (c *Controller) alias(ref SourceRef) string {
+	if !c.opts.Project.AliasesEnabled() || ref.Repository == "" {
+		return ""
+	}
+	alias, err := template.RenderLabel(c.opts.Project.Environment.AliasTemplate, ref.Vars(c.opts.Project.Name))
+	if err != nil {
+		return ""
+	}
+	return alias
+}
+
+// ResolveImages picks a tag for every image in eph.yaml: a fixed tag, then
+// tag_template, then fallback_tag. tag_pattern and tag_source need registry
+// and Git access and are not resolved here yet, so such images go straight to
+// their fallback_tag. It returns the names of images it could not resolve.
+func ResolveImages(project *config.Config, ref SourceRef) ([]ResolvedImage, []string) {
+	vars := ref.Vars(project.Name)
+	var images []ResolvedImage
+	var unresolved []string
+	for _, img := range project.Environment.Images {
+		resolved := ResolvedImage{Name: img.Name, Repository: img.Repository}
+		switch {
+		case img.Tag != "":
+			resolved.Tag = img.Tag
+		case img.TagTemplate != "":
+			tag, err := template.Render(img.TagTemplate, vars)
+			if err != nil {
+				unresolved = append(unresolved, img.Name)
+				continue
+			}
+			resolved.Tag = template.SanitizeTag(tag)
+		case img.FallbackTag != "":
+			resolved.Tag = img.FallbackTag
+			resolved.Fallback = true
+		default:
+			unresolved = append(unresolved, img.Name)
+			continue
+		}
+		images = append(images, resolved)
+	}
+	return images, unresolved
+}
+
+// NewSpec describes the environment named name for ref to the provider.
+func NewSpec(project *config.Config, name string, ref SourceRef, images []ResolvedImage) providers.Spec {
+	vars := ref.Vars(project.Name).Merge(template.Vars{
+		template.VarName:            name,
+		template.VarEnvironmentName: name,
+		template.VarBaseDomain:      project.Environment.BaseDomain,
+	})
+	refs := make(map[string]string, len(images))
+	for _, img := range images {
+		refs[img.Name] = img.Reference()
+	}
+	return providers.Spec{
+		Key:        ref.Key(),
+		Name:       name,
+		Project:    project.Name,
+		Repository: ref.Repository,
+		RefType:    string(ref.Type),
+		RefName:    ref.Name,
+		CommitSHA:  ref.CommitSHA,
+		Images:     refs,
+		Env:        project.Environment.Env,
+		Vars:       vars,
+		Config:     project,
+	}
+}
@@ -0,0 +1,385 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ephlabs/eph/internal/config"
+	"github.com/ephlabs/eph/internal/providers"
+)
+
+const controllerConfig = `version: "1.0"
+name: myapp
+triggers:
+  - type: pr_label
+    labels: [preview]
+environment:
+  name_template: "{project}-{words}-{number}"
+  alias_template: "{project}-pr-{pr_number}"
+  images:
+    - name: api
+      repository: ghcr.io/org/api
+      tag_template: "pr-{pr_number}-{commit_sha:0:7}"
+    - name: worker
+      repository: ghcr.io/org/worker
+      tag_pattern: "pr-{pr_number}-*"
+      fallback_tag: latest
+`
+
+type fakeRefs struct{ refs []Ref }
+
+func (f *fakeRefs) ListRefs(context.Context) ([]Ref, error) { return f.refs, nil }
+
+type fakeProvider struct {
+	mu        sync.Mutex
+	name      string
+	instances map[string]providers.Instance
+	specs     []providers.Spec
+	ready     bool
+	applyErr  error
+	accessErr error
+	listErr   error
+}
+
+func (p *fakeProvider) Name() string {
+	if p.name == "" {
+		return "fake"
+	}
+	return p.name
+}
+
+func (p *fakeProvider) CheckAccess(context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.accessErr
+}
+
+func (p *fakeProvider) List(context.Context) ([]providers.Instance, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.listErr != nil {
+		return nil, p.listErr
+	}
+	var out []providers.Instance
+	for _, inst := range p.instances {
+		out = append(out, inst)
+	}
+	return out, nil
+}
+
+func (p *fakeProvider) Apply(_ context.Context, spec providers.Spec) (providers.Instance, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.specs = append(p.specs, spec)
+	if p.applyErr != nil {
+		return providers.Instance{}, p.applyErr
+	}
+	inst := providers.Instance{
+		Key:       spec.Key,
+		Name:      spec.Name,
+		CommitSHA: spec.CommitSHA,
+		URL:       "https://" + spec.Name + ".preview.example.com",
+		Ready:     p.ready,
+	}
+	p.instances[spec.Key] = inst
+	return inst, nil
+}
+
+func (p *fakeProvider) Destroy(_ context.Context, inst providers.Instance) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.instances, inst.Key)
+	return nil
+}
+
+type fakeAliases map[string]string
+
+func (a fakeAliases) Set(alias, url string) { a[alias] = url }
+func (a fakeAliases) Delete(alias string)   { delete(a, alias) }
+
+func newTestController(t *testing.T, refs ...Ref) (*Controller, *fakeProvider, fakeAliases) {
+	t.Helper()
+	project, err := config.Parse("eph.yaml", []byte(controllerConfig))
+	require.NoError(t, err)
+	require.NoError(t, config.Validate(project))
+
+	provider := &fakeProvider{instances: map[string]providers.Instance{}, ready: true}
+	aliases := fakeAliases{}
+	c, err := New(Options{
+		Project:  project,
+		Provider: provider,
+		Refs:     &fakeRefs{refs: refs},
+		NameKey:  []byte("test-key"),
+		Aliases:  aliases,
+		Now:      func() time.Time { return t0 },
+	})
+	require.NoError(t, err)
+	return c, provider, aliases
+}
+
+func labelledPR(number int, sha string, labels ...string) Ref {
+	return Ref{SourceRef: PRRef("org/myapp", number, "feature/x", sha), Labels: labels}
+}
+
+func TestControllerDesiredFiltersByTrigger(t *testing.T) {
+	c, _, _ := newTestController(t,
+		labelledPR(1, "aaa", "preview"),
+		labelledPR(2, "bbb", "wip"),
+		Ref{SourceRef: SourceRef{Repository: "org/myapp", Type: RefBranch, Name: "main"}},
+	)
+	desired, err := c.Desired(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, desired, 1)
+	assert.Contains(t, desired, "org/myapp/pr/1")
+}
+
+func TestControllerCreate(t *testing.T) {
+	ref := labelledPR(123, "abcdef1234567")
+	c, provider, aliases := newTestController(t, ref)
+
+	require.NoError(t, c.Create(context.Background(), ref.Key(), ref))
+
+	require.Len(t, provider.specs, 1)
+	spec := provider.specs[0]
+	assert.Equal(t, "org/myapp/pr/123", spec.Key)
+	assert.Regexp(t, `^myapp-[a-z]+-[a-z]+-\d+$`, spec.Name)
+	assert.Equal(t, "ghcr.io/org/api:pr-123-abcdef1", spec.Images["api"])
+	assert.Equal(t, "ghcr.io/org/worker:latest", spec.Images["worker"])
+	assert.Equal(t, spec.Name, spec.Vars["environment_name"])
+
+	envs := c.Environments()
+	require.Len(t, envs, 1)
+	assert.Equal(t, PhaseReady, envs[0].Phase)
+	assert.Equal(t, "fake", envs[0].Provider)
+	assert.Equal(t, "https://"+spec.Name+".preview.example.com", aliases["myapp-pr-123"])
+
+	// Names are derived, so a second controller (i.e. a restarted ephd)
+	// arrives at the same one.
+	again, provider2, _ := newTestController(t, ref)
+	require.NoError(t, again.Create(context.Background(), ref.Key(), ref))
+	assert.Equal(t, spec.Name, provider2.specs[0].Name)
+}
+
+func TestControllerApplyFailure(t *testing.T) {
+	ref := labelledPR(7, "abc")
+	c, provider, _ := newTestController(t, ref)
+	provider.applyErr = errors.New("quota exceeded")
+
+	err := c.Create(context.Background(), ref.Key(), ref)
+	assert.ErrorContains(t, err, "quota exceeded")
+
+	env := c.Environments()[0]
+	assert.Equal(t, PhaseFailed, env.Phase)
+	cond, ok := env.Condition(ConditionProvisioned)
+	require.True(t, ok)
+	assert.Equal(t, ConditionFalse, cond.Status)
+}
+
+func TestControllerUpToDateAndDelete(t *testing.T) {
+	ref := labelledPR(5, "abc")
+	c, provider, aliases := newTestController(t, ref)
+	provider.ready = false
+	ctx := context.Background()
+
+	require.NoError(t, c.Create(ctx, ref.Key(), ref))
+	actual, err := c.Actual(ctx)
+	require.NoError(t, err)
+	inst := actual[ref.Key()]
+	assert.False(t, c.UpToDate(ref, inst), "not ready yet")
+	assert.Equal(t, PhaseCreating, c.Environments()[0].Phase)
+
+	provider.ready = true
+	require.NoError(t, c.Update(ctx, ref.Key(), ref, inst))
+	actual, _ = c.Actual(ctx)
+	assert.True(t, c.UpToDate(ref, actual[ref.Key()]))
+
+	newer := labelledPR(5, "def")
+	assert.False(t, c.UpToDate(newer, actual[ref.Key()]))
+
+	require.NoError(t, c.Delete(ctx, ref.Key(), actual[ref.Key()]))
+	assert.Empty(t, provider.instances)
+	assert.Empty(t, c.Environments())
+	assert.Empty(t, aliases)
+}
+
+func TestControllerObservesExistingInstances(t *testing.T) {
+	c, provider, aliases := newTestController(t)
+	inst := providers.Instance{Key: "org/myapp/pr/9", Name: "myapp-old-env-1", URL: "https://old.preview.example.com", Ready: true}
+	provider.instances[inst.Key] = inst
+
+	ctx := context.Background()
+	_, err := c.Actual(ctx)
+	require.NoError(t, err)
+	envs := c.Environments()
+	require.Len(t, envs, 1)
+	assert.Equal(t, "myapp-old-env-1", envs[0].Name)
+	assert.Equal(t, PhaseReady, envs[0].Phase)
+	assert.Equal(t, "org/myapp/pr/9", envs[0].Source.Key(), "the ref is recovered from the key")
+	assert.Equal(t, inst.URL, aliases["myapp-pr-9"], "aliases resolve after a restart")
+
+	require.NoError(t, c.Delete(ctx, inst.Key, inst))
+	assert.Empty(t, aliases)
+}
+
+func TestControllerEnvironmentsAreCopies(t *testing.T) {
+	ref := labelledPR(3, "abc")
+	c, _, _ := newTestController(t, ref)
+	require.NoError(t, c.Create(context.Background(), ref.Key(), ref))
+	c.envs[ref.Key()].Headers = map[string]string{"X-Eph-Environment": "calm-river"}
+
+	envs := c.Environments()
+	require.NotEmpty(t, envs[0].Conditions)
+	envs[0].Headers["X-Eph-Environment"] = "changed"
+	envs[0].Conditions[0].Reason = "Changed"
+
+	again := c.Environments()
+	assert.Equal(t, "calm-river", again[0].Headers["X-Eph-Environment"])
+	assert.NotEqual(t, "Changed", again[0].Conditions[0].Reason)
+}
+
+func TestControllerDestroyFailure(t *testing.T) {
+	ref := labelledPR(3, "abc")
+	c, provider, _ := newTestController(t, ref)
+	ctx := context.Background()
+	require.NoError(t, c.Create(ctx, ref.Key(), ref))
+
+	failing := &failingDestroy{fakeProvider: provider}
+	c.backends[0].provider = failing
+	err := c.Delete(ctx, ref.Key(), provider.instances[ref.Key()])
+	assert.ErrorContains(t, err, "volume in use")
+	env := c.Environments()[0]
+	assert.Equal(t, PhaseFailed, env.Phase, "a failed teardown leaves Deleting")
+
+	// The ref asks for an environment again before the retry.
+	require.NoError(t, c.Update(ctx, ref.Key(), ref, provider.instances[ref.Key()]))
+	assert.Equal(t, PhaseReady, c.Environments()[0].Phase)
+}
+
+func TestControllerRejectsInvalidTransition(t *testing.T) {
+	ref := labelledPR(4, "abc")
+	c, provider, _ := newTestController(t, ref)
+	ctx := context.Background()
+	require.NoError(t, c.Create(ctx, ref.Key(), ref))
+
+	env := c.envs[ref.Key()]
+	env.Phase = PhaseDeleting
+	err := c.Update(ctx, ref.Key(), ref, provider.instances[ref.Key()])
+	assert.ErrorIs(t, err, ErrInvalidTransition)
+	assert.Equal(t, PhaseDeleting, c.Environments()[0].Phase, "the phase is not overwritten")
+}
+
+func TestControllerImageNotFound(t *testing.T) {
+	// tag_pattern needs a registry scan, so without a fallback_tag the
+	// worker image never resolves.
+	project, err := config.Parse("eph.yaml", []byte(`version: "1.0"
+name: myapp
+environment:
+  images:
+    - name: worker
+      repository: ghcr.io/org/worker
+      tag_pattern: "pr-{pr_number}-*"
+`))
+	require.NoError(t, err)
+	require.NoError(t, config.Validate(project))
+	ref := labelledPR(5, "abc")
+	now := t0
+	provider := &fakeProvider{instances: map[string]providers.Instance{}, ready: true}
+	c, err := New(Options{
+		Project:          project,
+		Provider:         provider,
+		Refs:             &fakeRefs{refs: []Ref{ref}},
+		NameKey:          []byte("test-key"),
+		ImageWaitTimeout: 10 * time.Minute,
+		Now:              func() time.Time { return now },
+	})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	require.NoError(t, c.Create(ctx, ref.Key(), ref))
+	assert.Equal(t, PhaseWaitingForImage, c.Environments()[0].Phase)
+
+	now = now.Add(9 * time.Minute)
+	require.NoError(t, c.Create(ctx, ref.Key(), ref))
+	assert.Equal(t, PhaseWaitingForImage, c.Environments()[0].Phase)
+
+	now = now.Add(time.Minute)
+	require.NoError(t, c.Create(ctx, ref.Key(), ref))
+	env := c.Environments()[0]
+	assert.Equal(t, PhaseImageNotFound, env.Phase)
+	ready, _ := env.Condition(ConditionReady)
+	assert.Equal(t, "ImageWaitTimeout", ready.Reason)
+	assert.Equal(t, "no image tag for [worker] after 10m0s, CI may have failed to build it", ready.Message)
+	assert.Empty(t, provider.specs, "nothing is deployed without images")
+
+	// The image is still looked for, e.g. once CI is re-run.
+	c.opts.Project.Environment.Images[0].Tag = "pr-5-abc"
+	require.NoError(t, c.Create(ctx, ref.Key(), ref))
+	assert.Equal(t, PhaseReady, c.Environments()[0].Phase)
+}
+
+type failingDestroy struct{ *fakeProvider }
+
+func (failingDestroy) Destroy(context.Context, providers.Instance) error {
+	return errors.New("volume in use")
+}
+
+func TestTriggered(t *testing.T) {
+	pr := Ref{SourceRef: PRRef("o/r", 1, "feature/login", "sha"), Labels: []string{"preview"}}
+	draft := pr
+	draft.Draft = true
+
+	tests := []struct {
+		name    string
+		trigger config.Trigger
+		ref     Ref
+		want    bool
+	}{
+		{"label", config.Trigger{Type: "pr_label", Labels: []string{"preview"}}, pr, true},
+		{"label missing", config.Trigger{Type: "pr_label", Labels: []string{"deploy"}}, pr, false},
+		{"checks pending", config.Trigger{Type: "pr_label", Labels: []string{"preview"}, WaitForChecks: []string{"build"}}, pr, false},
+		{"checks passed", config.Trigger{Type: "pr_label", Labels: []string{"preview"}, WaitForChecks: []string{"build"}},
+			Ref{SourceRef: pr.SourceRef, Labels: pr.Labels, Checks: map[string]string{"build": "success"}}, true},
+		{"comment", config.Trigger{Type: "pr_comment", Patterns: []string{"/deploy"}},
+			Ref{SourceRef: pr.SourceRef, Comments: []string{"LGTM", "  /deploy please"}}, true},
+		{"auto branch", config.Trigger{Type: "auto", Branches: []string{"feature/*"}}, pr, true},
+		{"auto other branch", config.Trigger{Type: "auto", Branches: []string{"fix/*"}}, pr, false},
+		{"auto draft", config.Trigger{Type: "auto", IgnoreDraft: true}, draft, false},
+		{"git branch", config.Trigger{Type: "git_branch", Pattern: "release/*"},
+			Ref{SourceRef: SourceRef{Type: RefBranch, Name: "release/1.2"}}, true},
+		{"git tag", config.Trigger{Type: "git_tag", Pattern: "v*"},
+			Ref{SourceRef: SourceRef{Type: RefTag, Name: "v1.0.0"}}, true},
+		{"git tag on PR", config.Trigger{Type: "git_tag", Pattern: "*"}, pr, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Triggered([]config.Trigger{tt.trigger}, tt.ref))
+		})
+	}
+}
+
+func TestControllerObservesReadiness(t *testing.T) {
+	ref := labelledPR(5, "abc")
+	c, provider, _ := newTestController(t, ref)
+	provider.ready = false
+	ctx := context.Background()
+	require.NoError(t, c.Create(ctx, ref.Key(), ref))
+
+	// The instance becomes ready between passes, so it is up to date and
+	// not re-applied: listing it must mark the environment ready.
+	provider.mu.Lock()
+	inst := provider.instances[ref.Key()]
+	inst.Ready = true
+	provider.instances[ref.Key()] = inst
+	provider.mu.Unlock()
+
+	actual, err := c.Actual(ctx)
+	require.NoError(t, err)
+	assert.True(t, c.UpToDate(ref, actual[ref.Key()]))
+	assert.Equal(t, PhaseReady, c.Environments()[0].Phase)
+}
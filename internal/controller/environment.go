@@ -0,0 +1,244 @@
+package controller
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ephlabs/eph/internal/log"
+	"github.com/ephlabs/eph/internal/template"
+)
+
+// RefType is the kind of Git ref an environment is built from.
+type RefType string
+
+const (
+	RefPR     RefType = template.RefTypePR
+	RefBranch RefType = template.RefTypeBranch
+	RefTag    RefType = template.RefTypeTag
+)
+
+// SourceRef identifies the Git ref an environment is built from.
+type SourceRef struct {
+	// Repository is "owner/name" on the forge.
+	Repository string  `json:"repository"`
+	Type       RefType `json:"type"`
+
+	// Name is the PR number, branch name or tag name.
+	Name string `json:"name"`
+
+	// Branch is the head branch, for PRs.
+	Branch    string `json:"branch,omitempty"`
+	CommitSHA string `json:"commit_sha"`
+}
+
+// PRRef returns the SourceRef for pull request number of repo.
+func PRRef(repo string, number int, branch, commitSHA string) SourceRef {
+	return SourceRef{
+		Repository: repo,
+		Type:       RefPR,
+		Name:       strconv.Itoa(number),
+		Branch:     branch,
+		CommitSHA:  commitSHA,
+	}
+}
+
+// Key identifies the ref independently of its commit, e.g.
+// "ephlabs/eph/pr/123". It is the key the reconciler and informers use for an
+// environment's desired and actual state.
+func (r SourceRef) Key() string {
+	return r.Repository + "/" + string(r.Type) + "/" + r.Name
+}
+
+// ParseKey recovers the repository, type and name of a ref from its Key. The
+// repository is taken to be everything before the first ref type segment
+// after "owner/name", so nested GitLab groups are supported as long as
+// they are not named "pr", "branch" or "tag".
+func ParseKey(key string) (SourceRef, bool) {
+	parts := strings.Split(key, "/")
+	for i := 2; i < len(parts)-1; i++ {
+		switch t := RefType(parts[i]); t {
+		case RefPR, RefBranch, RefTag:
+			return SourceRef{
+				Repository: strings.Join(parts[:i], "/"),
+				Type:       t,
+				Name:       strings.Join(parts[i+1:], "/"),
+			}, true
+		}
+	}
+	return SourceRef{}, false
+}
+
+// String returns a human-readable form such as "ephlabs/eph#123" or
+// "ephlabs/eph@main".
+func (r SourceRef) String() string {
+	if r.Type == RefPR {
+		return r.Repository + "#" + r.Name
+	}
+	return r.Repository + "@" + r.Name
+}
+
+// Vars returns the template variables describing the ref.
+func (r SourceRef) Vars(project string) template.Vars {
+	if r.Type == RefPR {
+		if n, err := strconv.Atoi(r.Name); err == nil {
+			return template.PRVars(project, n, r.Branch, r.CommitSHA)
+		}
+	}
+	return template.RefVars(project, string(r.Type), r.Name, r.CommitSHA)
+}
+
+// ResolvedImage is the concrete image chosen for one of the images listed in
+// eph.yaml.
+type ResolvedImage struct {
+	// Name matches environment.images[].name in eph.yaml.
+	Name       string `json:"name"`
+	Repository string `json:"repository"`
+	Tag        string `json:"tag"`
+	Digest     string `json:"digest,omitempty"`
+
+	// Fallback is set when Tag is the configured fallback_tag rather than an
+	// image built for the ref.
+	Fallback bool `json:"fallback,omitempty"`
+}
+
+// Reference returns the full image reference, preferring the digest.
+func (i ResolvedImage) Reference() string {
+	if i.Digest != "" {
+		return i.Repository + "@" + i.Digest
+	}
+	return i.Repository + ":" + i.Tag
+}
+
+// Environment is an ephemeral environment as seen by the controller.
+type Environment struct {
+	// ID is stable for the lifetime of the environment.
+	ID string `json:"id"`
+
+	// Name is the generated, non-guessable name, e.g. "myapp-serene-ocean-42".
+	Name    string    `json:"name"`
+	Project string    `json:"project"`
+	Source  SourceRef `json:"source"`
+
+	// Provider hosts the environment. Environments are never moved to
+	// another provider once created.
+	Provider string `json:"provider,omitempty"`
+	URL      string `json:"url,omitempty"`
+
+	// Headers must be sent with requests to URL, e.g. X-Eph-Environment
+	// with header-based routing.
+	Headers map[string]string `json:"headers,omitempty"`
+
+	Images     []ResolvedImage `json:"images,omitempty"`
+	Phase      Phase           `json:"phase"`
+	Conditions []Condition     `json:"conditions,omitempty"`
+
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+	PhaseChangedAt    time.Time `json:"phase_changed_at"`
+	LastActivityAt    time.Time `json:"last_activity_at,omitzero"`
+	ExpiresAt         time.Time `json:"expires_at,omitzero"`
+	DeletionRequested time.Time `json:"deletion_requested_at,omitzero"`
+}
+
+// NewEnvironment returns a Pending environment for ref.
+func NewEnvironment(id, name, project string, ref SourceRef, now time.Time) *Environment {
+	return &Environment{
+		ID:             id,
+		Name:           name,
+		Project:        project,
+		Source:         ref,
+		Phase:          PhasePending,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+		PhaseChangedAt: now,
+	}
+}
+
+// Transition moves the environment to phase to, recording reason and message
+// on the Ready condition. It returns a *TransitionError, leaving the
+// environment unchanged, if the move is not allowed.
+func (e *Environment) Transition(to Phase, reason, message string, now time.Time) error {
+	if !e.Phase.CanTransitionTo(to) {
+		return &TransitionError{From: e.Phase, To: to}
+	}
+	if e.Phase != to {
+		e.Phase = to
+		e.PhaseChangedAt = now
+	}
+	if to == PhaseDeleting && e.DeletionRequested.IsZero() {
+		e.DeletionRequested = now
+	}
+
+	status := ConditionFalse
+	if to == PhaseReady {
+		status = ConditionTrue
+	}
+	if reason == "" {
+		reason = string(to)
+	}
+	e.SetCondition(Condition{Type: ConditionReady, Status: status, Reason: reason, Message: message}, now)
+	return nil
+}
+
+// SetCondition adds or updates a condition. LastTransitionTime is only moved
+// when the status changes.
+func (e *Environment) SetCondition(c Condition, now time.Time) {
+	e.UpdatedAt = now
+	for i := range e.Conditions {
+		if e.Conditions[i].Type != c.Type {
+			continue
+		}
+		c.LastTransitionTime = e.Conditions[i].LastTransitionTime
+		if e.Conditions[i].Status != c.Status {
+			c.LastTransitionTime = now
+		}
+		e.Conditions[i] = c
+		return
+	}
+	c.LastTransitionTime = now
+	e.Conditions = append(e.Conditions, c)
+}
+
+// Condition returns the condition of type t, if set.
+func (e *Environment) Condition(t ConditionType) (Condition, bool) {
+	for _, c := range e.Conditions {
+		if c.Type == t {
+			return c, true
+		}
+	}
+	return Condition{}, false
+}
+
+// Expired reports whether the environment has outlived its TTL.
+func (e *Environment) Expired(now time.Time) bool {
+	return !e.ExpiresAt.IsZero() && !now.Before(e.ExpiresAt)
+}
+
+// Idle reports whether the environment has seen no activity for timeout.
+func (e *Environment) Idle(timeout time.Duration, now time.Time) bool {
+	if timeout <= 0 {
+		return false
+	}
+	last := e.LastActivityAt
+	if last.IsZero() {
+		last = e.CreatedAt
+	}
+	return now.Sub(last) >= timeout
+}
+
+// LogEnvironment returns the subset of the environment that is attached to
+// log records.
+func (e *Environment) LogEnvironment() log.Environment {
+	return log.Environment{
+		ID:       e.ID,
+		Name:     e.Name,
+		URL:      e.URL,
+		Provider: e.Provider,
+	}
+}
+
+func (e *Environment) String() string {
+	return fmt.Sprintf("%s (%s, %s)", e.Name, e.Source, e.Phase)
+}
@@ -0,0 +1,191 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ephlabs/eph/internal/template"
+)
+
+var t0 = time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func newTestEnvironment() *Environment {
+	ref := PRRef("ephlabs/eph", 123, "feature/login", "abc1234def")
+	return NewEnvironment("env-1", "eph-serene-ocean-42", "eph", ref, t0)
+}
+
+func TestPhaseTransitions(t *testing.T) {
+	allowed := [][2]Phase{
+		{PhasePending, PhaseWaitingForImage},
+		{PhaseWaitingForImage, PhaseUsingFallbackImage},
+		{PhaseWaitingForImage, PhaseImageNotFound},
+		{PhaseImageNotFound, PhaseCreating},
+		{PhaseImageNotFound, PhaseFailed},
+		{PhaseUsingFallbackImage, PhaseCreating},
+		{PhaseCreating, PhaseReady},
+		{PhaseReady, PhaseSleeping},
+		{PhaseSleeping, PhaseCreating},
+		{PhaseFailed, PhasePending},
+		{PhaseReady, PhaseReady},
+	}
+	for _, tr := range allowed {
+		assert.True(t, tr[0].CanTransitionTo(tr[1]), "%s -> %s", tr[0], tr[1])
+	}
+
+	forbidden := [][2]Phase{
+		{PhasePending, PhaseReady},
+		{PhaseWaitingForImage, PhaseReady},
+		{PhaseSleeping, PhaseWaitingForImage},
+		{PhaseDeleting, PhaseReady},
+		{PhaseDeleting, PhasePending},
+		{PhaseReady, "Exploded"},
+	}
+	for _, tr := range forbidden {
+		assert.False(t, tr[0].CanTransitionTo(tr[1]), "%s -> %s", tr[0], tr[1])
+	}
+
+	// Any phase can be torn down.
+	for _, p := range Phases {
+		assert.True(t, p.CanTransitionTo(PhaseDeleting), p)
+		assert.True(t, p.Valid(), p)
+	}
+}
+
+func TestEnvironmentLifecycle(t *testing.T) {
+	env := newTestEnvironment()
+	assert.Equal(t, PhasePending, env.Phase)
+
+	steps := []Phase{PhaseWaitingForImage, PhaseCreating, PhaseReady, PhaseSleeping, PhaseCreating, PhaseReady, PhaseDeleting}
+	for i, p := range steps {
+		now := t0.Add(time.Duration(i+1) * time.Minute)
+		require.NoError(t, env.Transition(p, "", "", now))
+		assert.Equal(t, p, env.Phase)
+		assert.Equal(t, now, env.PhaseChangedAt)
+	}
+	assert.Equal(t, t0.Add(7*time.Minute), env.DeletionRequested)
+}
+
+func TestEnvironmentInvalidTransition(t *testing.T) {
+	env := newTestEnvironment()
+	err := env.Transition(PhaseReady, "", "", t0.Add(time.Minute))
+
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrInvalidTransition))
+	var te *TransitionError
+	require.True(t, errors.As(err, &te))
+	assert.Equal(t, PhasePending, te.From)
+	assert.Equal(t, PhaseReady, te.To)
+
+	assert.Equal(t, PhasePending, env.Phase, "a rejected transition must not change the phase")
+	assert.Empty(t, env.Conditions)
+}
+
+func TestEnvironmentReadyCondition(t *testing.T) {
+	env := newTestEnvironment()
+	require.NoError(t, env.Transition(PhaseCreating, "", "applying manifests", t0.Add(time.Minute)))
+
+	ready, ok := env.Condition(ConditionReady)
+	require.True(t, ok)
+	assert.Equal(t, ConditionFalse, ready.Status)
+	assert.Equal(t, "Creating", ready.Reason)
+	assert.Equal(t, "applying manifests", ready.Message)
+
+	require.NoError(t, env.Transition(PhaseReady, "RolloutComplete", "", t0.Add(3*time.Minute)))
+	ready, _ = env.Condition(ConditionReady)
+	assert.Equal(t, ConditionTrue, ready.Status)
+	assert.Equal(t, t0.Add(3*time.Minute), ready.LastTransitionTime)
+
+	// Re-asserting the same phase updates the message but keeps the
+	// transition time.
+	require.NoError(t, env.Transition(PhaseReady, "RolloutComplete", "all pods healthy", t0.Add(5*time.Minute)))
+	ready, _ = env.Condition(ConditionReady)
+	assert.Equal(t, t0.Add(3*time.Minute), ready.LastTransitionTime)
+	assert.Equal(t, "all pods healthy", ready.Message)
+	assert.Equal(t, t0.Add(5*time.Minute), env.UpdatedAt)
+	assert.Equal(t, t0.Add(3*time.Minute), env.PhaseChangedAt)
+}
+
+func TestSetCondition(t *testing.T) {
+	env := newTestEnvironment()
+	env.SetCondition(Condition{Type: ConditionImageResolved, Status: ConditionFalse, Reason: "TagNotFound"}, t0)
+	env.SetCondition(Condition{Type: ConditionImageResolved, Status: ConditionTrue}, t0.Add(time.Minute))
+
+	require.Len(t, env.Conditions, 1)
+	c, ok := env.Condition(ConditionImageResolved)
+	require.True(t, ok)
+	assert.Equal(t, ConditionTrue, c.Status)
+	assert.Equal(t, t0.Add(time.Minute), c.LastTransitionTime)
+
+	_, ok = env.Condition(ConditionProvisioned)
+	assert.False(t, ok)
+}
+
+func TestSourceRef(t *testing.T) {
+	pr := PRRef("ephlabs/eph", 123, "feature/login", "abc1234def")
+	assert.Equal(t, "ephlabs/eph/pr/123", pr.Key())
+	assert.Equal(t, "ephlabs/eph#123", pr.String())
+
+	vars := pr.Vars("eph")
+	assert.Equal(t, "123", vars[template.VarPRNumber])
+	assert.Equal(t, "feature-login", vars[template.VarBranchName])
+
+	branch := SourceRef{Repository: "ephlabs/eph", Type: RefBranch, Name: "main", CommitSHA: "abc"}
+	assert.Equal(t, "ephlabs/eph/branch/main", branch.Key())
+	assert.Equal(t, "ephlabs/eph@main", branch.String())
+	assert.Equal(t, "main", branch.Vars("eph")[template.VarBranchName])
+}
+
+func TestParseKey(t *testing.T) {
+	tests := []struct {
+		key  string
+		want SourceRef
+	}{
+		{"ephlabs/eph/pr/123", SourceRef{Repository: "ephlabs/eph", Type: RefPR, Name: "123"}},
+		{"ephlabs/eph/branch/feature/login", SourceRef{Repository: "ephlabs/eph", Type: RefBranch, Name: "feature/login"}},
+		{"group/sub/app/tag/v1.0.0", SourceRef{Repository: "group/sub/app", Type: RefTag, Name: "v1.0.0"}},
+		{"owner/pr/pr/1", SourceRef{Repository: "owner/pr", Type: RefPR, Name: "1"}},
+	}
+	for _, tt := range tests {
+		ref, ok := ParseKey(tt.key)
+		require.True(t, ok, tt.key)
+		assert.Equal(t, tt.want, ref)
+		assert.Equal(t, tt.key, ref.Key())
+	}
+
+	_, ok := ParseKey("not-a-key")
+	assert.False(t, ok)
+}
+
+func TestEnvironmentTimers(t *testing.T) {
+	env := newTestEnvironment()
+	env.ExpiresAt = t0.Add(72 * time.Hour)
+
+	assert.False(t, env.Expired(t0.Add(71*time.Hour)))
+	assert.True(t, env.Expired(t0.Add(72*time.Hour)))
+
+	assert.True(t, env.Idle(4*time.Hour, t0.Add(4*time.Hour)))
+	env.LastActivityAt = t0.Add(3 * time.Hour)
+	assert.False(t, env.Idle(4*time.Hour, t0.Add(4*time.Hour)))
+	assert.False(t, env.Idle(0, t0.Add(100*time.Hour)))
+}
+
+func TestEnvironmentJSON(t *testing.T) {
+	env := newTestEnvironment()
+	env.Images = []ResolvedImage{{Name: "api", Repository: "ghcr.io/ephlabs/api", Tag: "pr-123"}}
+	require.NoError(t, env.Transition(PhaseCreating, "", "", t0))
+
+	data, err := json.Marshal(env)
+	require.NoError(t, err)
+
+	var out map[string]any
+	require.NoError(t, json.Unmarshal(data, &out))
+	assert.Equal(t, "Creating", out["phase"])
+	assert.NotContains(t, out, "expires_at")
+	assert.Equal(t, "ghcr.io/ephlabs/api:pr-123", env.Images[0].Reference())
+	assert.Equal(t, "eph-serene-ocean-42", env.LogEnvironment().Name)
+}
@@ -0,0 +1,118 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// Phase is the coarse lifecycle state of an environment.
+type Phase string
+
+const (
+	// PhasePending means the environment should exist but nothing has been
+	// done about it yet.
+	PhasePending Phase = "Pending"
+
+	// PhaseWaitingForImage means no image has been found for the ref yet,
+	// typically because CI is still building it. Resolution is retried on
+	// every reconciliation.
+	PhaseWaitingForImage Phase = "WaitingForImage"
+
+	// PhaseUsingFallbackImage means the image did not appear in time and the
+	// configured fallback_tag is being deployed instead.
+	PhaseUsingFallbackImage Phase = "UsingFallbackImage"
+
+	// PhaseCreating means the provider is creating or updating resources.
+	PhaseCreating Phase = "Creating"
+
+	// PhaseReady means the environment is up and serving at its URL.
+	PhaseReady Phase = "Ready"
+
+	// PhaseSleeping means the environment was scaled down after idle_timeout
+	// and will be woken on access.
+	PhaseSleeping Phase = "Sleeping"
+
+	// PhaseFailed means the last attempt to create or update the environment
+	// failed. The reconciler keeps retrying.
+	PhaseFailed Phase = "Failed"
+
+	// PhaseImageNotFound means no image (and no usable fallback) appeared
+	// within the image wait timeout, so CI has most likely failed.
+	// Resolution is still retried on every reconciliation.
+	PhaseImageNotFound Phase = "ImageNotFound"
+
+	// PhaseDeleting means the environment's resources are being torn down.
+	PhaseDeleting Phase = "Deleting"
+)
+
+// Phases lists every phase in lifecycle order.
+var Phases = []Phase{
+	PhasePending,
+	PhaseWaitingForImage,
+	PhaseUsingFallbackImage,
+	PhaseImageNotFound,
+	PhaseCreating,
+	PhaseReady,
+	PhaseSleeping,
+	PhaseFailed,
+	PhaseDeleting,
+}
+
+// transitions lists the phases reachable from each phase. Staying in the same
+// phase is always allowed and is not listed. Every phase except Deleting can
+// move to Deleting, since an environment may be torn down at any point; once
+// deletion has started it only ends with the environment disappearing, or
+// with Failed if the provider could not tear it down.
+var transitions = map[Phase][]Phase{
+	PhasePending:            {PhaseWaitingForImage, PhaseUsingFallbackImage, PhaseImageNotFound, PhaseCreating, PhaseFailed, PhaseDeleting},
+	PhaseWaitingForImage:    {PhaseUsingFallbackImage, PhaseImageNotFound, PhaseCreating, PhaseFailed, PhaseDeleting},
+	PhaseUsingFallbackImage: {PhaseCreating, PhaseFailed, PhaseDeleting},
+	PhaseImageNotFound:      {PhaseWaitingForImage, PhaseUsingFallbackImage, PhaseCreating, PhaseFailed, PhaseDeleting},
+	PhaseCreating:           {PhaseWaitingForImage, PhaseReady, PhaseFailed, PhaseDeleting},
+	PhaseReady:              {PhaseWaitingForImage, PhaseCreating, PhaseSleeping, PhaseFailed, PhaseDeleting},
+	PhaseSleeping:           {PhaseCreating, PhaseReady, PhaseFailed, PhaseDeleting},
+	PhaseFailed:             {PhasePending, PhaseWaitingForImage, PhaseCreating, PhaseDeleting},
+	PhaseDeleting:           {PhaseFailed},
+}
+
+// ErrInvalidTransition is matched by errors.Is for every *TransitionError.
+var ErrInvalidTransition = errors.New("invalid phase transition")
+
+// TransitionError reports a phase change that the state machine forbids.
+type TransitionError struct {
+	From Phase
+	To   Phase
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("invalid phase transition from %s to %s", e.From, e.To)
+}
+
+func (e *TransitionError) Is(target error) bool {
+	return target == ErrInvalidTransition
+}
+
+// Valid reports whether p is a known phase.
+func (p Phase) Valid() bool {
+	_, ok := transitions[p]
+	return ok
+}
+
+// CanTransitionTo reports whether an environment in phase p may move to next.
+func (p Phase) CanTransitionTo(next Phase) bool {
+	if !p.Valid() || !next.Valid() {
+		return false
+	}
+	return p == next || slices.Contains(transitions[p], next)
+}
+
+// Active reports whether the environment has, or is getting, running
+// resources.
+func (p Phase) Active() bool {
+	switch p {
+	case PhaseUsingFallbackImage, PhaseCreating, PhaseReady:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,258 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ephlabs/eph/internal/log"
+	"github.com/ephlabs/eph/internal/providers"
+)
+
+const (
+	// DefaultHealthInterval is how often providers' access is re-checked
+	// when there is a fallback to choose.
+	DefaultHealthInterval = 30 * time.Second
+
+	// DefaultCheckTimeout bounds a provider's health and capability checks.
+	DefaultCheckTimeout = 10 * time.Second
+)
+
+// backend is what the controller knows about one of its providers.
+type backend struct {
+	provider providers.Provider
+
+	// listed is set once List has succeeded. instances holds the last
+	// result, kept while List fails so that the environments the provider
+	// hosts are neither recreated elsewhere nor deleted.
+	listed    bool
+	instances []providers.Instance
+
+	// listErr and accessErr are the last List and CheckAccess failures; the
+	// provider is unavailable while either is set.
+	listErr   error
+	accessErr error
+	checkedAt time.Time
+}
+
+func (b *backend) name() string { return b.provider.Name() }
+
+func (b *backend) unavailable() error {
+	if b.accessErr != nil {
+		return b.accessErr
+	}
+	return b.listErr
+}
+
+// syncer is implemented by providers that answer List from a cache, which
+// is empty rather than authoritative until it has synced; it matches
+// informers.Informer.
+type syncer interface {
+	HasSynced() bool
+}
+
+// errNotSynced is the List error of a provider whose cache has not synced.
+var errNotSynced = errors.New("cache not synced yet")
+
+// list lists b's environments, falling back to the last successful result
+// if List fails.
+func (c *Controller) list(ctx context.Context, b *backend) ([]providers.Instance, error) {
+	var instances []providers.Instance
+	var err error
+	if s, ok := b.provider.(syncer); ok && !s.HasSynced() {
+		err = errNotSynced
+	} else {
+		instances, err = b.provider.List(ctx)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err != nil {
+		c.setHealth(ctx, b, func() { b.listErr = fmt.Errorf("listing environments: %w", err) })
+		return b.instances, fmt.Errorf("%s: %w", b.name(), err)
+	}
+	c.setHealth(ctx, b, func() { b.listErr = nil })
+	b.listed, b.instances = true, instances
+	return instances, nil
+}
+
+// remember adds or replaces inst in b's last listed environments, so that
+// an environment created since then is still known if List fails next.
+// c.mu must be held.
+func (b *backend) remember(inst providers.Instance) {
+	b.forget(inst.Key)
+	b.instances = append(b.instances, inst)
+}
+
+// forget removes the environment for key from b's last listed
+// environments. c.mu must be held.
+func (b *backend) forget(key string) {
+	b.instances = slices.DeleteFunc(slices.Clone(b.instances), func(inst providers.Instance) bool { return inst.Key == key })
+}
+
+// checkHealth re-checks the providers' access every HealthInterval. Health
+// only decides anything when there is a fallback to choose.
+func (c *Controller) checkHealth(ctx context.Context) {
+	if len(c.backends) < 2 {
+		return
+	}
+	for _, b := range c.backends {
+		c.mu.RLock()
+		due := c.opts.Now().Sub(b.checkedAt) >= c.opts.HealthInterval
+		c.mu.RUnlock()
+		if !due {
+			continue
+		}
+		checkCtx, cancel := context.WithTimeout(ctx, c.opts.CheckTimeout)
+		err := b.provider.CheckAccess(checkCtx)
+		cancel()
+		c.mu.Lock()
+		b.checkedAt = c.opts.Now()
+		c.setHealth(ctx, b, func() { b.accessErr = err })
+		c.mu.Unlock()
+	}
+}
+
+// setHealth applies update to b and logs if that changes whether b is
+// available. c.mu must be held.
+func (c *Controller) setHealth(ctx context.Context, b *backend, update func()) {
+	was := b.unavailable()
+	update()
+	now := b.unavailable()
+	switch {
+	case was == nil && now != nil:
+		log.Warn(ctx, "Provider unavailable", "provider", b.name(), "error", now)
+	case was != nil && now == nil:
+		log.Info(ctx, "Provider available again", "provider", b.name())
+		if b == c.backends[0] {
+			// Environments are never migrated: those created on the
+			// fallback in the meantime stay there until deleted.
+			if n := c.hostedBy(c.backends[1:]...); n > 0 {
+				log.Info(ctx, "Environments stay on the fallback provider", "provider", c.backends[1].name(), "environments", n)
+			}
+		}
+	}
+}
+
+// hostedBy counts the environments hosted by bs. c.mu must be held.
+func (c *Controller) hostedBy(bs ...*backend) int {
+	n := 0
+	for _, b := range c.hosts {
+		for _, candidate := range bs {
+			if b == candidate {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// actual merges the providers' environments and records which provider
+// hosts each one. An environment found on two providers stays with the one
+// it is recorded on, or the primary; the other copy is left alone for an
+// operator rather than deleted or adopted.
+func (c *Controller) actual(ctx context.Context) (map[string]providers.Instance, map[string]*backend, error) {
+	actual := map[string]providers.Instance{}
+	hosts := map[string]*backend{}
+	var errs []error
+	for _, b := range c.backends {
+		instances, err := c.list(ctx, b)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		for _, inst := range instances {
+			if kept, dup := hosts[inst.Key]; dup {
+				other := b
+				c.mu.RLock()
+				recorded := c.hosts[inst.Key]
+				c.mu.RUnlock()
+				if recorded == b {
+					hosts[inst.Key], actual[inst.Key] = b, inst
+					kept, other = b, kept
+				}
+				log.Warn(ctx, "Environment exists on more than one provider, leaving the other copy alone",
+					"key", inst.Key, "provider", kept.name(), "other", other.name())
+				continue
+			}
+			hosts[inst.Key], actual[inst.Key] = b, inst
+		}
+	}
+	if len(errs) == len(c.backends) {
+		return nil, nil, errors.Join(errs...)
+	}
+	return actual, hosts, nil
+}
+
+// place returns the provider hosting the environment for key. An existing
+// environment stays where it is; a new one goes to the primary unless it is
+// unavailable or lacks a capability eph.yaml requires, and then to the
+// fallback. The ProviderSelected condition records the choice.
+func (c *Controller) place(ctx context.Context, env *Environment, key string) (*backend, error) {
+	c.mu.RLock()
+	b, ok := c.hosts[key]
+	c.mu.RUnlock()
+	if ok {
+		return b, nil
+	}
+	if len(c.backends) == 1 {
+		return c.backends[0], nil
+	}
+
+	// An environment may exist on a provider that has never been listed;
+	// creating it elsewhere would split it across providers.
+	c.mu.RLock()
+	for _, b := range c.backends {
+		if !b.listed {
+			c.mu.RUnlock()
+			return nil, fmt.Errorf("%s has not listed its environments yet", b.name())
+		}
+	}
+	c.mu.RUnlock()
+
+	var reasons []string
+	for i, b := range c.backends {
+		if reason := c.unsuitable(ctx, b); reason != "" {
+			reasons = append(reasons, b.name()+" "+reason)
+			continue
+		}
+		cond := Condition{Type: ConditionProviderSelected, Status: ConditionTrue, Reason: "Primary"}
+		if i > 0 {
+			cond.Reason, cond.Message = "Fallback", strings.Join(reasons, "; ")
+			log.Warn(ctx, "Creating environment on the fallback provider", "provider", b.name(), "reason", cond.Message)
+		}
+		c.setCondition(env, cond)
+		c.mu.Lock()
+		c.hosts[key] = b
+		c.mu.Unlock()
+		return b, nil
+	}
+	msg := strings.Join(reasons, "; ")
+	c.setCondition(env, Condition{Type: ConditionProviderSelected, Status: ConditionFalse, Reason: "NoProvider", Message: msg})
+	return nil, fmt.Errorf("no provider can host the environment: %s", msg)
+}
+
+// unsuitable explains why b cannot host a new environment, or returns "".
+func (c *Controller) unsuitable(ctx context.Context, b *backend) string {
+	c.mu.RLock()
+	err := b.unavailable()
+	c.mu.RUnlock()
+	if err != nil {
+		return "is unavailable: " + err.Error()
+	}
+	reporter, ok := b.provider.(providers.CapabilityReporter)
+	if !ok {
+		return ""
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.opts.CheckTimeout)
+	defer cancel()
+	caps, err := reporter.Capabilities(ctx)
+	if err != nil {
+		return "did not report its capabilities: " + err.Error()
+	}
+	if missing := caps.Missing(c.opts.Project); len(missing) > 0 {
+		return "lacks " + strings.Join(missing, ", ")
+	}
+	return ""
+}
@@ -0,0 +1,222 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ephlabs/eph/internal/config"
+	"github.com/ephlabs/eph/internal/providers"
+)
+
+// capableProvider reports capabilities, as plugins do.
+type capableProvider struct {
+	*fakeProvider
+	caps providers.Capabilities
+}
+
+func (p capableProvider) Capabilities(context.Context) (providers.Capabilities, error) {
+	return p.caps, nil
+}
+
+type fallbackFixture struct {
+	c                 *Controller
+	primary, fallback *fakeProvider
+	now               time.Time
+}
+
+func newFallbackController(t *testing.T, yaml string, wrap func(*fakeProvider) providers.Provider, refs ...Ref) *fallbackFixture {
+	t.Helper()
+	project, err := config.Parse("eph.yaml", []byte(yaml))
+	require.NoError(t, err)
+
+	f := &fallbackFixture{
+		primary:  &fakeProvider{name: "kubernetes", instances: map[string]providers.Instance{}, ready: true},
+		fallback: &fakeProvider{name: "docker-compose", instances: map[string]providers.Instance{}, ready: true},
+		now:      t0,
+	}
+	var primary providers.Provider = f.primary
+	if wrap != nil {
+		primary = wrap(f.primary)
+	}
+	f.c, err = New(Options{
+		Project:  project,
+		Provider: primary,
+		Fallback: f.fallback,
+		Refs:     &fakeRefs{refs: refs},
+		NameKey:  []byte("test-key"),
+		Now:      func() time.Time { return f.now },
+	})
+	require.NoError(t, err)
+	return f
+}
+
+// pass runs the part of a reconciliation pass that lists the providers.
+func (f *fallbackFixture) pass(t *testing.T) map[string]providers.Instance {
+	t.Helper()
+	actual, err := f.c.Actual(context.Background())
+	require.NoError(t, err)
+	return actual
+}
+
+func (f *fallbackFixture) env(t *testing.T, key string) Environment {
+	t.Helper()
+	for _, env := range f.c.Environments() {
+		if env.Source.Key() == key {
+			return env
+		}
+	}
+	t.Fatalf("no environment for %s", key)
+	return Environment{}
+}
+
+func TestControllerFallbackWhenPrimaryUnavailable(t *testing.T) {
+	ctx := context.Background()
+	pr1, pr2 := labelledPR(1, "aaa"), labelledPR(2, "bbb")
+	f := newFallbackController(t, controllerConfig, nil)
+	f.primary.accessErr = errors.New("connection refused")
+
+	f.pass(t)
+	require.NoError(t, f.c.Create(ctx, pr1.Key(), pr1))
+	assert.Empty(t, f.primary.specs)
+	require.Len(t, f.fallback.specs, 1)
+	env := f.env(t, pr1.Key())
+	assert.Equal(t, "docker-compose", env.Provider)
+	cond, ok := env.Condition(ConditionProviderSelected)
+	require.True(t, ok)
+	assert.Equal(t, "Fallback", cond.Reason)
+	assert.Equal(t, "kubernetes is unavailable: connection refused", cond.Message)
+
+	// The primary recovers: new environments go back to it, but the one on
+	// the fallback stays there, even when its ref moves on.
+	f.primary.accessErr = nil
+	f.now = f.now.Add(DefaultHealthInterval)
+	actual := f.pass(t)
+	assert.Equal(t, "docker-compose", f.env(t, pr1.Key()).Provider)
+
+	pr1 = labelledPR(1, "ccc")
+	require.NoError(t, f.c.Update(ctx, pr1.Key(), pr1, actual[pr1.Key()]))
+	require.NoError(t, f.c.Create(ctx, pr2.Key(), pr2))
+	assert.Len(t, f.fallback.specs, 2)
+	assert.Equal(t, "ccc", f.fallback.specs[1].CommitSHA)
+	require.Len(t, f.primary.specs, 1)
+	assert.Equal(t, pr2.Key(), f.primary.specs[0].Key)
+	env = f.env(t, pr2.Key())
+	cond, _ = env.Condition(ConditionProviderSelected)
+	assert.Equal(t, "Primary", cond.Reason)
+
+	actual = f.pass(t)
+	require.NoError(t, f.c.Delete(ctx, pr1.Key(), actual[pr1.Key()]))
+	assert.Empty(t, f.fallback.instances, "environments are destroyed where they are hosted")
+	assert.Len(t, f.primary.instances, 1)
+}
+
+func TestControllerFallbackForMissingCapability(t *testing.T) {
+	yaml := controllerConfig + `services:
+  - name: postgres
+    type: database
+    persistent: true
+`
+	pr := labelledPR(1, "aaa")
+	f := newFallbackController(t, yaml, func(p *fakeProvider) providers.Provider {
+		return capableProvider{fakeProvider: p, caps: providers.Capabilities{CustomDomains: true}}
+	})
+
+	f.pass(t)
+	require.NoError(t, f.c.Create(context.Background(), pr.Key(), pr))
+	assert.Empty(t, f.primary.specs)
+	assert.Len(t, f.fallback.specs, 1)
+	env := f.env(t, pr.Key())
+	cond, _ := env.Condition(ConditionProviderSelected)
+	assert.Equal(t, "kubernetes lacks persistent storage", cond.Message)
+}
+
+func TestControllerNoProvider(t *testing.T) {
+	pr := labelledPR(1, "aaa")
+	f := newFallbackController(t, controllerConfig, nil)
+	f.primary.accessErr = errors.New("connection refused")
+	f.fallback.accessErr = errors.New("no such host")
+
+	f.pass(t)
+	err := f.c.Create(context.Background(), pr.Key(), pr)
+	assert.ErrorContains(t, err, "no provider can host the environment: kubernetes is unavailable: connection refused; docker-compose is unavailable: no such host")
+	env := f.env(t, pr.Key())
+	assert.Equal(t, PhaseFailed, env.Phase)
+	cond, _ := env.Condition(ConditionProviderSelected)
+	assert.Equal(t, ConditionFalse, cond.Status)
+}
+
+func TestControllerListFailure(t *testing.T) {
+	ctx := context.Background()
+	pr1, pr2 := labelledPR(1, "aaa"), labelledPR(2, "bbb")
+	f := newFallbackController(t, controllerConfig, nil)
+	f.pass(t)
+	require.NoError(t, f.c.Create(ctx, pr1.Key(), pr1))
+
+	// The primary's environments are still known while it cannot list them,
+	// so they are neither recreated on the fallback nor deleted.
+	f.primary.listErr = errors.New("timeout")
+	actual := f.pass(t)
+	assert.Contains(t, actual, pr1.Key())
+	require.NoError(t, f.c.Create(ctx, pr2.Key(), pr2))
+	assert.Equal(t, "docker-compose", f.env(t, pr2.Key()).Provider)
+
+	f.fallback.listErr = errors.New("timeout")
+	_, err := f.c.Actual(ctx)
+	assert.EqualError(t, err, "kubernetes: timeout\ndocker-compose: timeout")
+}
+
+func TestControllerWaitsForListing(t *testing.T) {
+	pr := labelledPR(1, "aaa")
+	f := newFallbackController(t, controllerConfig, nil)
+	f.primary.listErr = errors.New("timeout")
+
+	// The environment might already exist on the primary.
+	f.pass(t)
+	err := f.c.Create(context.Background(), pr.Key(), pr)
+	assert.ErrorContains(t, err, "kubernetes has not listed its environments yet")
+	assert.Empty(t, f.fallback.specs)
+}
+
+func TestControllerEnvironmentOnBothProviders(t *testing.T) {
+	key := "org/myapp/pr/1"
+	f := newFallbackController(t, controllerConfig, nil)
+	f.primary.instances[key] = providers.Instance{Key: key, Name: "on-primary", Ready: true}
+	f.fallback.instances[key] = providers.Instance{Key: key, Name: "on-fallback", Ready: true}
+
+	actual := f.pass(t)
+	assert.Equal(t, "on-primary", actual[key].Name)
+	assert.Equal(t, "kubernetes", f.c.Environments()[0].Provider)
+}
+
+// syncingProvider answers List from a cache, as the kubernetes provider does.
+type syncingProvider struct {
+	*fakeProvider
+	synced bool
+}
+
+func (p *syncingProvider) HasSynced() bool { return p.synced }
+
+func TestControllerWaitsForFallbackSync(t *testing.T) {
+	ctx := context.Background()
+	pr := labelledPR(1, "aaa")
+	f := newFallbackController(t, controllerConfig, nil)
+	fallback := &syncingProvider{fakeProvider: f.fallback}
+	f.c.backends[1].provider = fallback
+
+	// The primary is reconciled while the fallback's cache syncs, but no
+	// environment is created until the fallback has listed its own.
+	f.pass(t)
+	err := f.c.Create(ctx, pr.Key(), pr)
+	assert.ErrorContains(t, err, "docker-compose has not listed its environments yet")
+	assert.Empty(t, f.primary.specs)
+
+	fallback.synced = true
+	f.pass(t)
+	require.NoError(t, f.c.Create(ctx, pr.Key(), pr))
+	assert.Len(t, f.primary.specs, 1)
+}
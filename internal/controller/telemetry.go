@@ -0,0 +1,66 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ephlabs/eph/internal/providers"
+)
+
+// ErrNotFound is returned for an environment the controller does not know.
+var ErrNotFound = errors.New("environment not found")
+
+// Logs streams the logs of the environment with the given ID or name from
+// the provider hosting it. It fails with providers.ErrNotSupported if that
+// provider cannot stream logs.
+func (c *Controller) Logs(ctx context.Context, id string, opts providers.LogOptions, send func(providers.LogEntry) error) error {
+	inst, b, err := c.hosted(id)
+	if err != nil {
+		return err
+	}
+	streamer, ok := b.provider.(providers.LogStreamer)
+	if !ok {
+		return fmt.Errorf("%s: log streaming is %w", b.name(), providers.ErrNotSupported)
+	}
+	return streamer.Logs(ctx, inst, opts, send)
+}
+
+// Metrics reports the resource usage of the environment with the given ID
+// or name. It fails with providers.ErrNotSupported if the provider hosting
+// it does not report metrics.
+func (c *Controller) Metrics(ctx context.Context, id string) (providers.Metrics, error) {
+	inst, b, err := c.hosted(id)
+	if err != nil {
+		return providers.Metrics{}, err
+	}
+	reporter, ok := b.provider.(providers.MetricsReporter)
+	if !ok {
+		return providers.Metrics{}, fmt.Errorf("%s: metrics are %w", b.name(), providers.ErrNotSupported)
+	}
+	return reporter.Metrics(ctx, inst)
+}
+
+// hosted finds the environment with the given ID or name, as last listed
+// by the provider hosting it.
+func (c *Controller) hosted(id string) (providers.Instance, *backend, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for key, env := range c.envs {
+		if env.ID != id && env.Name != id {
+			continue
+		}
+		b, ok := c.hosts[key]
+		if !ok {
+			// Not created yet.
+			break
+		}
+		for _, inst := range b.instances {
+			if inst.Key == key {
+				return inst, b, nil
+			}
+		}
+		return providers.Instance{Key: key, Name: env.Name}, b, nil
+	}
+	return providers.Instance{}, nil, fmt.Errorf("%s: %w", id, ErrNotFound)
+}
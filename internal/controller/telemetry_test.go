@@ -0,0 +1,47 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ephlabs/eph/internal/providers"
+)
+
+// streamingProvider streams a line naming the instance it was given.
+type streamingProvider struct {
+	*fakeProvider
+}
+
+func (p streamingProvider) Logs(_ context.Context, inst providers.Instance, opts providers.LogOptions, send func(providers.LogEntry) error) error {
+	return send(providers.LogEntry{Service: opts.Service, Line: inst.Name + " at " + inst.URL})
+}
+
+func TestControllerLogs(t *testing.T) {
+	ctx := context.Background()
+	pr := labelledPR(1, "aaa")
+	f := newFallbackController(t, controllerConfig, func(p *fakeProvider) providers.Provider {
+		return streamingProvider{fakeProvider: p}
+	})
+	f.pass(t)
+	require.NoError(t, f.c.Create(ctx, pr.Key(), pr))
+	env := f.env(t, pr.Key())
+
+	var entries []providers.LogEntry
+	err := f.c.Logs(ctx, env.Name, providers.LogOptions{Service: "api"}, func(e providers.LogEntry) error {
+		entries = append(entries, e)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []providers.LogEntry{{Service: "api", Line: env.Name + " at " + env.URL}}, entries,
+		"the provider is given the instance it reported")
+
+	_, err = f.c.Metrics(ctx, env.ID)
+	assert.ErrorIs(t, err, providers.ErrNotSupported)
+	assert.EqualError(t, err, "kubernetes: metrics are not supported by the provider")
+
+	err = f.c.Logs(ctx, "missing", providers.LogOptions{}, func(providers.LogEntry) error { return nil })
+	assert.ErrorIs(t, err, ErrNotFound)
+}
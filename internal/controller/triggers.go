@@ -0,0 +1,81 @@
+package controller
+
+import (
+	"context"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/ephlabs/eph/internal/config"
+)
+
+// Ref is a Git ref as observed by a forge informer, with the metadata that
+// eph.yaml triggers are evaluated against.
+type Ref struct {
+	SourceRef
+
+	// Labels, Draft and Comments only apply to pull requests.
+	Labels []string
+	Draft  bool
+
+	// Comments holds PR comment bodies, for pr_comment triggers.
+	Comments []string
+
+	// Checks maps CI check names on the head commit to their conclusion,
+	// e.g. "success" or "failure".
+	Checks map[string]string
+}
+
+// RefLister lists the open pull requests, branches and tags of a repository.
+type RefLister interface {
+	ListRefs(ctx context.Context) ([]Ref, error)
+}
+
+// Triggered reports whether any of triggers asks for an environment for ref.
+func Triggered(triggers []config.Trigger, ref Ref) bool {
+	for _, t := range triggers {
+		if triggerMatches(t, ref) {
+			return true
+		}
+	}
+	return false
+}
+
+func triggerMatches(t config.Trigger, ref Ref) bool {
+	switch t.Type {
+	case "pr_label":
+		if ref.Type != RefPR || !slices.ContainsFunc(t.Labels, func(l string) bool { return slices.Contains(ref.Labels, l) }) {
+			return false
+		}
+		for _, check := range t.WaitForChecks {
+			if ref.Checks[check] != "success" {
+				return false
+			}
+		}
+		return true
+	case "pr_comment":
+		return ref.Type == RefPR && slices.ContainsFunc(ref.Comments, func(body string) bool {
+			body = strings.TrimSpace(body)
+			return slices.ContainsFunc(t.Patterns, func(p string) bool { return strings.HasPrefix(body, p) })
+		})
+	case "auto":
+		if ref.Type != RefPR || (t.IgnoreDraft && ref.Draft) {
+			return false
+		}
+		return len(t.Branches) == 0 || matchAny(t.Branches, ref.Branch)
+	case "git_branch":
+		return ref.Type == RefBranch && matchAny([]string{t.Pattern}, ref.Name)
+	case "git_tag":
+		return ref.Type == RefTag && matchAny([]string{t.Pattern}, ref.Name)
+	}
+	return false
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+package informers
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/ephlabs/eph/internal/controller"
+)
+
+// RefCache is a thread-safe cache of refs per repository, for forge informers
+// to build on. It implements controller.RefLister, HasSynced and OnChange.
+type RefCache struct {
+	Notifier
+
+	mu     sync.RWMutex
+	repos  map[string]map[string]controller.Ref
+	synced bool
+}
+
+// NewRefCache returns an empty RefCache.
+func NewRefCache() *RefCache {
+	return &RefCache{repos: map[string]map[string]controller.Ref{}}
+}
+
+// Replace sets the refs of repo, keyed by SourceRef.Key, and notifies
+// listeners if anything changed. It reports whether it did.
+func (c *RefCache) Replace(repo string, refs []controller.Ref) bool {
+	next := make(map[string]controller.Ref, len(refs))
+	for _, ref := range refs {
+		next[ref.Key()] = ref
+	}
+
+	c.mu.Lock()
+	changed := !reflect.DeepEqual(c.repos[repo], next)
+	if changed {
+		c.repos[repo] = next
+	}
+	c.mu.Unlock()
+
+	if changed {
+		c.Notify()
+	}
+	return changed
+}
+
+// MarkSynced records that every repository has been listed at least once.
+func (c *RefCache) MarkSynced() {
+	c.mu.Lock()
+	c.synced = true
+	c.mu.Unlock()
+}
+
+// HasSynced implements Informer.
+func (c *RefCache) HasSynced() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.synced
+}
+
+// ListRefs implements controller.RefLister. Refs are sorted by key.
+func (c *RefCache) ListRefs(context.Context) ([]controller.Ref, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	var refs []controller.Ref
+	for _, repo := range c.repos {
+		for _, ref := range repo {
+			refs = append(refs, ref)
+		}
+	}
+	sort.Slice(refs, func(i, j int) bool { return refs[i].Key() < refs[j].Key() })
+	return refs, nil
+}
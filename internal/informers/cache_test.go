@@ -0,0 +1,49 @@
+package informers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ephlabs/eph/internal/controller"
+)
+
+func TestRefCache(t *testing.T) {
+	c := NewRefCache()
+	changes := 0
+	c.OnChange(func() { changes++ })
+
+	a := controller.Ref{SourceRef: controller.PRRef("org/a", 1, "x", "sha1"), Labels: []string{"preview"}}
+	b := controller.Ref{SourceRef: controller.PRRef("org/b", 2, "y", "sha2")}
+
+	assert.True(t, c.Replace("org/b", []controller.Ref{b}))
+	assert.True(t, c.Replace("org/a", []controller.Ref{a}))
+	assert.False(t, c.Replace("org/a", []controller.Ref{a}), "identical refs are not a change")
+	assert.Equal(t, 2, changes)
+
+	refs, err := c.ListRefs(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []controller.Ref{a, b}, refs)
+
+	a.Labels = nil
+	assert.True(t, c.Replace("org/a", []controller.Ref{a}))
+	assert.Equal(t, 3, changes)
+}
+
+type cacheInformer struct{ *RefCache }
+
+func (cacheInformer) Name() string                  { return "cache" }
+func (cacheInformer) Run(ctx context.Context) error { <-ctx.Done(); return nil }
+
+func TestWaitForSync(t *testing.T) {
+	c := cacheInformer{NewRefCache()}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.Error(t, WaitForSync(ctx, c))
+
+	c.MarkSynced()
+	assert.NoError(t, WaitForSync(context.Background(), c))
+}
@@ -0,0 +1,232 @@
+package informers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RateLimitError is returned when a forge refuses a request because a rate
+// limit was hit. No requests should be made before Until.
+type RateLimitError struct {
+	Forge string
+	Until time.Time
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("%s: rate limited until %s", e.Forge, e.Until.Format(time.RFC3339))
+}
+
+// RateLimit is a forge's view of the token's rate limit, taken from the last
+// response.
+type RateLimit struct {
+	Remaining int
+	Reset     time.Time
+}
+
+type cachedResponse struct {
+	etag string
+	body []byte
+	next string
+}
+
+// Client is a minimal JSON REST client for polling forge APIs. It makes
+// conditional requests: it remembers the ETag and body of every URL it
+// fetched and sends If-None-Match, so that an unchanged resource comes back
+// as 304 Not Modified, which forges do not count against the rate limit. It
+// also tracks the rate limit headers of every response.
+type Client struct {
+	// Forge prefixes error messages, e.g. "github".
+	Forge   string
+	BaseURL string
+
+	// Header is added to every request, e.g. for authentication.
+	Header http.Header
+
+	// RemainingHeader and ResetHeader name the response headers carrying
+	// the number of requests left and the Unix time the window resets,
+	// e.g. "X-RateLimit-Remaining" and "X-RateLimit-Reset".
+	RemainingHeader string
+	ResetHeader     string
+
+	// HTTP defaults to a client with a 30 second timeout; Now to time.Now.
+	HTTP *http.Client
+	Now  func() time.Time
+
+	mu    sync.Mutex
+	cache map[string]cachedResponse
+	used  map[string]bool
+	limit RateLimit
+	known bool
+}
+
+// GetAll fetches path, relative to c.BaseURL, and every following page,
+// decoding each page's JSON array into a slice of T. Pages are followed
+// through the rel="next" Link header.
+func GetAll[T any](ctx context.Context, c *Client, path string) ([]T, error) {
+	var all []T
+	url := strings.TrimRight(c.BaseURL, "/") + path
+	for url != "" {
+		resp, err := c.get(ctx, url)
+		if err != nil {
+			return nil, err
+		}
+		var page []T
+		if err := json.Unmarshal(resp.body, &page); err != nil {
+			return nil, fmt.Errorf("%s: decoding %s: %w", c.Forge, path, err)
+		}
+		all = append(all, page...)
+		url = resp.next
+	}
+	return all, nil
+}
+
+// GetOne fetches path and decodes its JSON object into a T.
+func GetOne[T any](ctx context.Context, c *Client, path string) (T, error) {
+	var v T
+	resp, err := c.get(ctx, strings.TrimRight(c.BaseURL, "/")+path)
+	if err != nil {
+		return v, err
+	}
+	if err := json.Unmarshal(resp.body, &v); err != nil {
+		return v, fmt.Errorf("%s: decoding %s: %w", c.Forge, path, err)
+	}
+	return v, nil
+}
+
+// Prune forgets every cached response that was not requested since the last
+// Prune, such as the checks of commits that are no longer a PR head. Call it
+// after a complete, successful poll.
+func (c *Client) Prune() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for url := range c.cache {
+		if !c.used[url] {
+			delete(c.cache, url)
+		}
+	}
+	c.used = map[string]bool{}
+}
+
+// RateLimit returns the last rate limit the forge reported, if any.
+func (c *Client) RateLimit() (RateLimit, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.limit, c.known
+}
+
+func (c *Client) now() time.Time {
+	if c.Now != nil {
+		return c.Now()
+	}
+	return time.Now()
+}
+
+func (c *Client) get(ctx context.Context, url string) (cachedResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return cachedResponse{}, err
+	}
+	for k, v := range c.Header {
+		req.Header[k] = v
+	}
+
+	c.mu.Lock()
+	if c.cache == nil {
+		c.cache, c.used = map[string]cachedResponse{}, map[string]bool{}
+	}
+	cached, hasCached := c.cache[url]
+	c.used[url] = true
+	c.mu.Unlock()
+	if hasCached && cached.etag != "" {
+		req.Header.Set("If-None-Match", cached.etag)
+	}
+
+	httpClient := c.HTTP
+	if httpClient == nil {
+		httpClient = defaultHTTPClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return cachedResponse{}, fmt.Errorf("%s: %w", c.Forge, err)
+	}
+	defer resp.Body.Close()
+	c.recordRateLimit(resp.Header)
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && hasCached:
+		return cached, nil
+	case resp.StatusCode == http.StatusOK:
+	case resp.StatusCode == http.StatusTooManyRequests, resp.StatusCode == http.StatusForbidden:
+		if until, limited := c.rateLimitedUntil(resp.Header); limited {
+			return cachedResponse{}, &RateLimitError{Forge: c.Forge, Until: until}
+		}
+		fallthrough
+	default:
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return cachedResponse{}, fmt.Errorf("%s: GET %s: %s: %s", c.Forge, req.URL.Path, resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return cachedResponse{}, fmt.Errorf("%s: reading %s: %w", c.Forge, req.URL.Path, err)
+	}
+	fresh := cachedResponse{etag: resp.Header.Get("ETag"), body: body, next: nextLink(resp.Header.Get("Link"))}
+	c.mu.Lock()
+	c.cache[url] = fresh
+	c.mu.Unlock()
+	return fresh, nil
+}
+
+var defaultHTTPClient = &http.Client{Timeout: 30 * time.Second}
+
+func (c *Client) recordRateLimit(h http.Header) {
+	if c.RemainingHeader == "" {
+		return
+	}
+	remaining, err := strconv.Atoi(h.Get(c.RemainingHeader))
+	if err != nil {
+		return
+	}
+	reset, _ := strconv.ParseInt(h.Get(c.ResetHeader), 10, 64)
+	c.mu.Lock()
+	c.limit = RateLimit{Remaining: remaining, Reset: time.Unix(reset, 0)}
+	c.known = true
+	c.mu.Unlock()
+}
+
+// rateLimitedUntil interprets a 403 or 429 response. Secondary rate limits
+// come with Retry-After; an exhausted primary limit with no requests
+// remaining and the reset time. A 403 with neither is a permission error.
+func (c *Client) rateLimitedUntil(h http.Header) (time.Time, bool) {
+	if secs, err := strconv.Atoi(h.Get("Retry-After")); err == nil {
+		return c.now().Add(time.Duration(secs) * time.Second), true
+	}
+	if c.RemainingHeader != "" && h.Get(c.RemainingHeader) == "0" {
+		if limit, ok := c.RateLimit(); ok && limit.Reset.After(c.now()) {
+			return limit.Reset, true
+		}
+		// Forges recommend waiting at least a minute when the reset time
+		// is unknown.
+		return c.now().Add(time.Minute), true
+	}
+	return time.Time{}, false
+}
+
+// nextLink extracts the rel="next" URL from a Link header.
+func nextLink(header string) string {
+	for part := range strings.SplitSeq(header, ",") {
+		url, params, ok := strings.Cut(strings.TrimSpace(part), ";")
+		if !ok || !strings.Contains(params, `rel="next"`) {
+			continue
+		}
+		return strings.Trim(strings.TrimSpace(url), "<>")
+	}
+	return ""
+}
@@ -0,0 +1,168 @@
+// Package gitea implements a forge informer for Gitea and Forgejo.
+package gitea
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ephlabs/eph/internal/controller"
+	"github.com/ephlabs/eph/internal/informers"
+)
+
+const DefaultInterval = 30 * time.Second
+
+func init() {
+	factory := func(opts informers.RefOptions) (informers.RefInformer, error) {
+		return New(Options{
+			BaseURL:      opts.BaseURL,
+			Repositories: informers.SplitRepositories(opts.Repository),
+			Token:        opts.Token,
+			Interval:     opts.Interval,
+		})
+	}
+	informers.RegisterRef("gitea", factory)
+	informers.RegisterRef("forgejo", factory)
+}
+
+// Options configures an Informer.
+type Options struct {
+	// BaseURL is the instance URL, e.g. "https://git.example.com"; the
+	// "/api/v1" suffix is optional. Gitea is always self-hosted, so there
+	// is no default.
+	BaseURL string
+
+	// Repositories are "owner/name" pairs.
+	Repositories []string
+	Token        string
+
+	Interval   time.Duration
+	HTTPClient *http.Client
+}
+
+// Informer polls a Gitea-compatible API for the open pull requests of a set
+// of repositories, with their labels, head commit, draft state, comments and
+// commit statuses. Commit statuses are reported as checks keyed by their
+// context.
+type Informer struct {
+	*informers.RefPoller
+	client *informers.Client
+}
+
+// New returns an Informer. Call Run to start polling.
+func New(opts Options) (*Informer, error) {
+	if opts.BaseURL == "" {
+		return nil, errors.New("gitea: the instance URL is required (EPH_FORGE_URL)")
+	}
+	if len(opts.Repositories) == 0 {
+		return nil, errors.New("gitea: at least one repository is required")
+	}
+	for _, repo := range opts.Repositories {
+		if owner, name, ok := strings.Cut(repo, "/"); !ok || owner == "" || name == "" || strings.Contains(name, "/") {
+			return nil, fmt.Errorf("gitea: repository %q must have the form owner/name", repo)
+		}
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultInterval
+	}
+	baseURL := strings.TrimRight(opts.BaseURL, "/")
+	if !strings.HasSuffix(baseURL, "/api/v1") {
+		baseURL += "/api/v1"
+	}
+
+	header := http.Header{}
+	header.Set("Accept", "application/json")
+	if opts.Token != "" {
+		header.Set("Authorization", "token "+opts.Token)
+	}
+	// Gitea does not rate limit its API by default, so there are no rate
+	// limit headers to track; Retry-After is still honoured.
+	i := &Informer{client: &informers.Client{
+		Forge:   "gitea",
+		BaseURL: baseURL,
+		Header:  header,
+		HTTP:    opts.HTTPClient,
+	}}
+	i.RefPoller = &informers.RefPoller{
+		RefCache:     informers.NewRefCache(),
+		Forge:        "gitea",
+		Repositories: opts.Repositories,
+		Client:       i.client,
+		List:         i.listRefs,
+		Interval:     opts.Interval,
+	}
+	return i, nil
+}
+
+type pullRequest struct {
+	Number int    `json:"number"`
+	Title  string `json:"title"`
+	Draft  bool   `json:"draft"`
+	Labels []struct {
+		Name string `json:"name"`
+	} `json:"labels"`
+	Head struct {
+		Ref string `json:"ref"`
+		SHA string `json:"sha"`
+	} `json:"head"`
+}
+
+// draft reports whether pr is a draft. Older Gitea versions have no draft
+// flag and mark work in progress with a title prefix instead.
+func (pr pullRequest) draft() bool {
+	title := strings.ToUpper(pr.Title)
+	return pr.Draft || strings.HasPrefix(title, "WIP:") || strings.HasPrefix(title, "[WIP]")
+}
+
+type comment struct {
+	Body string `json:"body"`
+}
+
+type combinedStatus struct {
+	Statuses []struct {
+		Context string `json:"context"`
+		Status  string `json:"status"`
+	} `json:"statuses"`
+}
+
+func (i *Informer) listRefs(ctx context.Context, repo string) ([]controller.Ref, error) {
+	pulls, err := informers.GetAll[pullRequest](ctx, i.client, "/repos/"+repo+"/pulls?state=open&limit=50")
+	if err != nil {
+		return nil, err
+	}
+	refs := make([]controller.Ref, 0, len(pulls))
+	for _, pr := range pulls {
+		ref := controller.Ref{
+			SourceRef: controller.PRRef(repo, pr.Number, pr.Head.Ref, pr.Head.SHA),
+			Draft:     pr.draft(),
+		}
+		for _, l := range pr.Labels {
+			ref.Labels = append(ref.Labels, l.Name)
+		}
+
+		comments, err := informers.GetAll[comment](ctx, i.client, fmt.Sprintf("/repos/%s/issues/%d/comments", repo, pr.Number))
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range comments {
+			ref.Comments = append(ref.Comments, c.Body)
+		}
+
+		status, err := informers.GetOne[combinedStatus](ctx, i.client, "/repos/"+repo+"/commits/"+url.PathEscape(pr.Head.SHA)+"/status")
+		if err != nil {
+			return nil, err
+		}
+		if len(status.Statuses) > 0 {
+			ref.Checks = make(map[string]string, len(status.Statuses))
+			for _, s := range status.Statuses {
+				ref.Checks[s.Context] = s.Status
+			}
+		}
+		refs = append(refs, ref)
+	}
+	return refs, nil
+}
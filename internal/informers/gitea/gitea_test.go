@@ -0,0 +1,71 @@
+package gitea
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ephlabs/eph/internal/informers"
+)
+
+func TestInformerListsPullRequests(t *testing.T) {
+	responses := map[string]string{
+		"/api/v1/repos/tools/runner/pulls": `[
+			{"number": 3, "title": "Add retries", "labels": [{"name": "preview"}], "head": {"ref": "retries", "sha": "aaa"}},
+			{"number": 4, "title": "WIP: rewrite", "labels": [], "head": {"ref": "rewrite", "sha": "bbb"}}
+		]`,
+		"/api/v1/repos/tools/runner/issues/3/comments": `[{"body": "/deploy"}]`,
+		"/api/v1/repos/tools/runner/commits/aaa/status": `{"state": "success", "statuses": [
+			{"context": "ci/woodpecker/pr/build", "status": "success"}
+		]}`,
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token tok" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		body, ok := responses[r.URL.Path]
+		if !ok {
+			body = "[]"
+			if strings.HasSuffix(r.URL.Path, "/status") {
+				body = `{"statuses": []}`
+			}
+		}
+		_, _ = w.Write([]byte(body))
+	}))
+	defer srv.Close()
+
+	inf, err := New(Options{BaseURL: srv.URL + "/", Repositories: []string{"tools/runner"}, Token: "tok"})
+	require.NoError(t, err)
+
+	assert.Equal(t, DefaultInterval, inf.Poll(context.Background()))
+	refs, err := inf.ListRefs(context.Background())
+	require.NoError(t, err)
+	require.Len(t, refs, 2)
+
+	pr := refs[0]
+	assert.Equal(t, "tools/runner/pr/3", pr.Key())
+	assert.Equal(t, "retries", pr.Branch)
+	assert.Equal(t, []string{"preview"}, pr.Labels)
+	assert.Equal(t, []string{"/deploy"}, pr.Comments)
+	assert.Equal(t, map[string]string{"ci/woodpecker/pr/build": "success"}, pr.Checks)
+	assert.False(t, pr.Draft)
+	assert.True(t, refs[1].Draft, "WIP title prefix marks a draft")
+}
+
+func TestRegistered(t *testing.T) {
+	for _, forge := range []string{"gitea", "forgejo"} {
+		inf, err := informers.NewRef(forge, informers.RefOptions{BaseURL: "https://codeberg.org/api/v1", Repository: "o/r"})
+		require.NoError(t, err)
+		_, ok := inf.(informers.Resyncer)
+		assert.True(t, ok, "webhooks need to be able to resync %s", forge)
+	}
+
+	_, err := informers.NewRef("gitea", informers.RefOptions{Repository: "o/r"})
+	assert.ErrorContains(t, err, "EPH_FORGE_URL")
+}
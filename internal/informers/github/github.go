@@ -0,0 +1,172 @@
+// Package github implements a forge informer for GitHub and GitHub Enterprise.
+package github
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ephlabs/eph/internal/controller"
+	"github.com/ephlabs/eph/internal/informers"
+)
+
+const (
+	DefaultBaseURL          = "https://api.github.com"
+	DefaultInterval         = 30 * time.Second
+	DefaultRateLimitReserve = 100
+)
+
+func init() {
+	informers.RegisterRef("github", func(opts informers.RefOptions) (informers.RefInformer, error) {
+		return New(Options{
+			BaseURL:      opts.BaseURL,
+			Repositories: informers.SplitRepositories(opts.Repository),
+			Token:        opts.Token,
+			Interval:     opts.Interval,
+		})
+	})
+}
+
+// Options configures an Informer.
+type Options struct {
+	// BaseURL is the REST API root; for GitHub Enterprise Server it is
+	// "https://HOST/api/v3". Defaults to DefaultBaseURL.
+	BaseURL string
+
+	// Repositories are "owner/name" pairs.
+	Repositories []string
+	Token        string
+
+	Interval         time.Duration
+	RateLimitReserve int
+	HTTPClient       *http.Client
+}
+
+// Informer polls GitHub for the open pull requests of a set of repositories,
+// with their labels, head commit, draft state, comments and check runs.
+//
+// Every request is conditional, so a poll in which nothing changed costs no
+// rate limit. The informer backs off when GitHub reports the rate limit is
+// nearly exhausted or answers with Retry-After.
+type Informer struct {
+	*informers.RefPoller
+	client *informers.Client
+}
+
+// New returns an Informer. Call Run to start polling.
+func New(opts Options) (*Informer, error) {
+	if len(opts.Repositories) == 0 {
+		return nil, errors.New("github: at least one repository is required")
+	}
+	for _, repo := range opts.Repositories {
+		if owner, name, ok := strings.Cut(repo, "/"); !ok || owner == "" || name == "" || strings.Contains(name, "/") {
+			return nil, fmt.Errorf("github: repository %q must have the form owner/name", repo)
+		}
+	}
+	if opts.BaseURL == "" {
+		opts.BaseURL = DefaultBaseURL
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultInterval
+	}
+	if opts.RateLimitReserve <= 0 {
+		opts.RateLimitReserve = DefaultRateLimitReserve
+	}
+
+	header := http.Header{}
+	header.Set("Accept", "application/vnd.github+json")
+	header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if opts.Token != "" {
+		header.Set("Authorization", "Bearer "+opts.Token)
+	}
+	i := &Informer{client: &informers.Client{
+		Forge:           "github",
+		BaseURL:         opts.BaseURL,
+		Header:          header,
+		RemainingHeader: "X-RateLimit-Remaining",
+		ResetHeader:     "X-RateLimit-Reset",
+		HTTP:            opts.HTTPClient,
+	}}
+	i.RefPoller = &informers.RefPoller{
+		RefCache:         informers.NewRefCache(),
+		Forge:            "github",
+		Repositories:     opts.Repositories,
+		Client:           i.client,
+		List:             i.listRefs,
+		Interval:         opts.Interval,
+		RateLimitReserve: opts.RateLimitReserve,
+	}
+	return i, nil
+}
+
+type pullRequest struct {
+	Number int  `json:"number"`
+	Draft  bool `json:"draft"`
+	Labels []struct {
+		Name string `json:"name"`
+	} `json:"labels"`
+	Head struct {
+		Ref string `json:"ref"`
+		SHA string `json:"sha"`
+	} `json:"head"`
+}
+
+type issueComment struct {
+	Body string `json:"body"`
+}
+
+type checkRuns struct {
+	CheckRuns []struct {
+		Name       string `json:"name"`
+		Status     string `json:"status"`
+		Conclusion string `json:"conclusion"`
+	} `json:"check_runs"`
+}
+
+func (i *Informer) listRefs(ctx context.Context, repo string) ([]controller.Ref, error) {
+	pulls, err := informers.GetAll[pullRequest](ctx, i.client, "/repos/"+repo+"/pulls?state=open&per_page=100")
+	if err != nil {
+		return nil, err
+	}
+	refs := make([]controller.Ref, 0, len(pulls))
+	for _, pr := range pulls {
+		ref := controller.Ref{
+			SourceRef: controller.PRRef(repo, pr.Number, pr.Head.Ref, pr.Head.SHA),
+			Draft:     pr.Draft,
+		}
+		for _, l := range pr.Labels {
+			ref.Labels = append(ref.Labels, l.Name)
+		}
+
+		comments, err := informers.GetAll[issueComment](ctx, i.client, fmt.Sprintf("/repos/%s/issues/%d/comments?per_page=100", repo, pr.Number))
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range comments {
+			ref.Comments = append(ref.Comments, c.Body)
+		}
+
+		checks, err := informers.GetOne[checkRuns](ctx, i.client, "/repos/"+repo+"/commits/"+url.PathEscape(pr.Head.SHA)+"/check-runs?per_page=100")
+		if err != nil {
+			return nil, err
+		}
+		if len(checks.CheckRuns) > 0 {
+			ref.Checks = make(map[string]string, len(checks.CheckRuns))
+			for _, run := range checks.CheckRuns {
+				// Until a run completes its conclusion is empty; report
+				// its status ("queued", "in_progress") instead.
+				state := run.Conclusion
+				if run.Status != "completed" || state == "" {
+					state = run.Status
+				}
+				ref.Checks[run.Name] = state
+			}
+		}
+		refs = append(refs, ref)
+	}
+	return refs, nil
+}
@@ -0,0 +1,232 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ephlabs/eph/internal/informers"
+)
+
+// fakeGitHub serves canned JSON for a few REST endpoints, honouring
+// If-None-Match like GitHub does.
+type fakeGitHub struct {
+	mu          sync.Mutex
+	responses   map[string]string
+	requests    int
+	notModified int
+	remaining   int
+	reset       time.Time
+	retryAfter  int
+}
+
+func newFakeGitHub() *fakeGitHub {
+	return &fakeGitHub{
+		responses: map[string]string{},
+		remaining: 5000,
+		reset:     time.Unix(1_900_000_000, 0),
+	}
+}
+
+func (f *fakeGitHub) set(path, body string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.responses[path] = body
+}
+
+func (f *fakeGitHub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests++
+
+	if f.retryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(f.retryAfter))
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	body, ok := f.responses[r.URL.Path]
+	if !ok {
+		body = "[]"
+		if strings.HasSuffix(r.URL.Path, "/check-runs") {
+			body = `{"total_count":0,"check_runs":[]}`
+		}
+	}
+	etag := fmt.Sprintf(`"%x"`, body)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(f.reset.Unix(), 10))
+	if r.Header.Get("If-None-Match") == etag {
+		f.notModified++
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(f.remaining))
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	f.remaining--
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(f.remaining))
+	_, _ = w.Write([]byte(body))
+}
+
+func newTestInformer(t *testing.T, gh *fakeGitHub) *Informer {
+	t.Helper()
+	srv := httptest.NewServer(gh)
+	t.Cleanup(srv.Close)
+	inf, err := New(Options{BaseURL: srv.URL, Repositories: []string{"org/app"}, Token: "t"})
+	require.NoError(t, err)
+	inf.client.Now = func() time.Time { return time.Unix(1_800_000_000, 0) }
+	return inf
+}
+
+const pulls = `[
+  {"number": 12, "draft": false, "labels": [{"name": "preview"}],
+   "head": {"ref": "feature/login", "sha": "abc123"}},
+  {"number": 13, "draft": true, "labels": [],
+   "head": {"ref": "wip", "sha": "def456"}}
+]`
+
+func TestInformerListsPullRequests(t *testing.T) {
+	gh := newFakeGitHub()
+	gh.set("/repos/org/app/pulls", pulls)
+	gh.set("/repos/org/app/issues/12/comments", `[{"body": "/deploy"}]`)
+	gh.set("/repos/org/app/commits/abc123/check-runs",
+		`{"check_runs": [{"name": "build", "status": "completed", "conclusion": "success"},
+		                 {"name": "e2e", "status": "in_progress", "conclusion": null}]}`)
+	inf := newTestInformer(t, gh)
+
+	assert.False(t, inf.HasSynced())
+	assert.Equal(t, DefaultInterval, inf.Poll(context.Background()))
+	assert.True(t, inf.HasSynced())
+
+	refs, err := inf.ListRefs(context.Background())
+	require.NoError(t, err)
+	require.Len(t, refs, 2)
+
+	pr := refs[0]
+	assert.Equal(t, "org/app/pr/12", pr.Key())
+	assert.Equal(t, "feature/login", pr.Branch)
+	assert.Equal(t, "abc123", pr.CommitSHA)
+	assert.Equal(t, []string{"preview"}, pr.Labels)
+	assert.Equal(t, []string{"/deploy"}, pr.Comments)
+	assert.Equal(t, map[string]string{"build": "success", "e2e": "in_progress"}, pr.Checks)
+	assert.True(t, refs[1].Draft)
+}
+
+func TestInformerConditionalRequests(t *testing.T) {
+	gh := newFakeGitHub()
+	gh.set("/repos/org/app/pulls", pulls)
+	inf := newTestInformer(t, gh)
+
+	changes := 0
+	inf.OnChange(func() { changes++ })
+
+	inf.Poll(context.Background())
+	assert.Equal(t, 1, changes)
+	first := gh.requests
+
+	// Nothing changed: every request is answered with 304 and costs no
+	// rate limit.
+	inf.Poll(context.Background())
+	assert.Equal(t, 1, changes, "unchanged poll must not notify")
+	assert.Equal(t, first, gh.notModified)
+	assert.Equal(t, 5000-first, gh.remaining)
+
+	gh.set("/repos/org/app/pulls", `[{"number": 12, "labels": [{"name": "preview"}], "head": {"ref": "feature/login", "sha": "fff999"}}]`)
+	inf.Poll(context.Background())
+	assert.Equal(t, 2, changes)
+	refs, _ := inf.ListRefs(context.Background())
+	require.Len(t, refs, 1)
+	assert.Equal(t, "fff999", refs[0].CommitSHA)
+}
+
+func TestInformerPagination(t *testing.T) {
+	gh := newFakeGitHub()
+	gh.set("/repos/org/app/pulls/page2", `[{"number": 2, "head": {"ref": "b", "sha": "2"}}]`)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/repos/org/app/pulls" {
+			w.Header().Set("Link", `<http://`+r.Host+`/repos/org/app/pulls/page2>; rel="next", <http://`+r.Host+`/repos/org/app/pulls/page2>; rel="last"`)
+			_, _ = w.Write([]byte(`[{"number": 1, "head": {"ref": "a", "sha": "1"}}]`))
+			return
+		}
+		gh.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+	inf, err := New(Options{BaseURL: srv.URL, Repositories: []string{"org/app"}})
+	require.NoError(t, err)
+
+	inf.Poll(context.Background())
+	refs, _ := inf.ListRefs(context.Background())
+	require.Len(t, refs, 2)
+	assert.Equal(t, "org/app/pr/1", refs[0].Key())
+	assert.Equal(t, "org/app/pr/2", refs[1].Key())
+}
+
+func TestInformerRateLimits(t *testing.T) {
+	t.Run("retry after", func(t *testing.T) {
+		gh := newFakeGitHub()
+		gh.set("/repos/org/app/pulls", pulls)
+		inf := newTestInformer(t, gh)
+		inf.Poll(context.Background())
+
+		gh.retryAfter = 120
+		assert.Equal(t, 120*time.Second, inf.Poll(context.Background()))
+
+		// The cache survives a rate-limited poll.
+		refs, _ := inf.ListRefs(context.Background())
+		assert.Len(t, refs, 2)
+	})
+
+	t.Run("reserve", func(t *testing.T) {
+		gh := newFakeGitHub()
+		gh.remaining = 50
+		gh.reset = time.Unix(1_800_000_600, 0)
+		inf := newTestInformer(t, gh)
+		assert.Equal(t, 10*time.Minute, inf.Poll(context.Background()))
+	})
+
+	t.Run("exhausted", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.Header().Set("X-RateLimit-Reset", "1800000300")
+			w.WriteHeader(http.StatusForbidden)
+		}))
+		defer srv.Close()
+		inf, err := New(Options{BaseURL: srv.URL, Repositories: []string{"org/app"}})
+		require.NoError(t, err)
+		inf.client.Now = func() time.Time { return time.Unix(1_800_000_000, 0) }
+		assert.Equal(t, 5*time.Minute, inf.Poll(context.Background()))
+		assert.False(t, inf.HasSynced())
+	})
+}
+
+func TestInformerErrorKeepsCache(t *testing.T) {
+	gh := newFakeGitHub()
+	gh.set("/repos/org/app/pulls", pulls)
+	inf := newTestInformer(t, gh)
+	inf.Poll(context.Background())
+
+	srvDown := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srvDown.Close()
+	inf.client.BaseURL = srvDown.URL
+
+	assert.Equal(t, DefaultInterval, inf.Poll(context.Background()))
+	refs, _ := inf.ListRefs(context.Background())
+	assert.Len(t, refs, 2)
+}
+
+func TestRegistered(t *testing.T) {
+	inf, err := informers.NewRef("github", informers.RefOptions{Repository: "org/a, org/b"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"org/a", "org/b"}, inf.(*Informer).Repositories)
+
+	_, err = informers.NewRef("github", informers.RefOptions{Repository: "not-a-repo"})
+	assert.ErrorContains(t, err, "owner/name")
+}
@@ -0,0 +1,188 @@
+// Package gitlab implements a forge informer for GitLab.com and self-hosted
+// GitLab.
+package gitlab
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ephlabs/eph/internal/controller"
+	"github.com/ephlabs/eph/internal/informers"
+)
+
+const (
+	DefaultBaseURL          = "https://gitlab.com/api/v4"
+	DefaultInterval         = 30 * time.Second
+	DefaultRateLimitReserve = 100
+)
+
+func init() {
+	informers.RegisterRef("gitlab", func(opts informers.RefOptions) (informers.RefInformer, error) {
+		return New(Options{
+			BaseURL:  opts.BaseURL,
+			Projects: informers.SplitRepositories(opts.Repository),
+			Token:    opts.Token,
+			Interval: opts.Interval,
+		})
+	})
+}
+
+// Options configures an Informer.
+type Options struct {
+	// BaseURL is the REST API root, e.g. "https://gitlab.example.com/api/v4".
+	// Defaults to DefaultBaseURL.
+	BaseURL string
+
+	// Projects are full project paths such as "group/subgroup/name".
+	Projects []string
+
+	// Token is a personal, group or project access token with read_api
+	// scope.
+	Token string
+
+	Interval         time.Duration
+	RateLimitReserve int
+	HTTPClient       *http.Client
+}
+
+// Informer polls GitLab for the open merge requests of a set of projects,
+// with their labels, source branch, head commit, draft state, comments and
+// the jobs of the head commit's latest pipeline.
+//
+// Merge requests are reported as controller.RefPR refs, the same as GitHub
+// pull requests, so eph.yaml triggers apply unchanged. Pipeline jobs are
+// reported as checks by job name, plus a "pipeline" check for the pipeline as
+// a whole; GitLab's "success" status matches wait_for_checks.
+type Informer struct {
+	*informers.RefPoller
+	client *informers.Client
+}
+
+// New returns an Informer. Call Run to start polling.
+func New(opts Options) (*Informer, error) {
+	if len(opts.Projects) == 0 {
+		return nil, errors.New("gitlab: at least one project is required")
+	}
+	for _, p := range opts.Projects {
+		if namespace, name, ok := strings.Cut(p, "/"); !ok || namespace == "" || name == "" || strings.HasSuffix(p, "/") {
+			return nil, fmt.Errorf("gitlab: project %q must be a full path such as group/name", p)
+		}
+	}
+	if opts.BaseURL == "" {
+		opts.BaseURL = DefaultBaseURL
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultInterval
+	}
+	if opts.RateLimitReserve <= 0 {
+		opts.RateLimitReserve = DefaultRateLimitReserve
+	}
+
+	header := http.Header{}
+	header.Set("Accept", "application/json")
+	if opts.Token != "" {
+		header.Set("PRIVATE-TOKEN", opts.Token)
+	}
+	i := &Informer{client: &informers.Client{
+		Forge:           "gitlab",
+		BaseURL:         opts.BaseURL,
+		Header:          header,
+		RemainingHeader: "RateLimit-Remaining",
+		ResetHeader:     "RateLimit-Reset",
+		HTTP:            opts.HTTPClient,
+	}}
+	i.RefPoller = &informers.RefPoller{
+		RefCache:         informers.NewRefCache(),
+		Forge:            "gitlab",
+		Repositories:     opts.Projects,
+		Client:           i.client,
+		List:             i.listRefs,
+		Interval:         opts.Interval,
+		RateLimitReserve: opts.RateLimitReserve,
+	}
+	return i, nil
+}
+
+type mergeRequest struct {
+	IID          int      `json:"iid"`
+	Draft        bool     `json:"draft"`
+	Labels       []string `json:"labels"`
+	SourceBranch string   `json:"source_branch"`
+	SHA          string   `json:"sha"`
+}
+
+type note struct {
+	Body   string `json:"body"`
+	System bool   `json:"system"`
+}
+
+type pipeline struct {
+	ID     int    `json:"id"`
+	Status string `json:"status"`
+}
+
+type job struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+}
+
+func (i *Informer) listRefs(ctx context.Context, project string) ([]controller.Ref, error) {
+	base := "/projects/" + url.PathEscape(project)
+	mrs, err := informers.GetAll[mergeRequest](ctx, i.client, base+"/merge_requests?state=opened&per_page=100")
+	if err != nil {
+		return nil, err
+	}
+	refs := make([]controller.Ref, 0, len(mrs))
+	for _, mr := range mrs {
+		ref := controller.Ref{
+			SourceRef: controller.PRRef(project, mr.IID, mr.SourceBranch, mr.SHA),
+			Labels:    mr.Labels,
+			Draft:     mr.Draft,
+		}
+
+		notes, err := informers.GetAll[note](ctx, i.client, fmt.Sprintf("%s/merge_requests/%d/notes?sort=asc&per_page=100", base, mr.IID))
+		if err != nil {
+			return nil, err
+		}
+		for _, n := range notes {
+			// System notes record events such as "added 1 commit"; only
+			// people's comments can trigger deployments.
+			if !n.System {
+				ref.Comments = append(ref.Comments, n.Body)
+			}
+		}
+
+		if ref.Checks, err = i.checks(ctx, base, mr.SHA); err != nil {
+			return nil, err
+		}
+		refs = append(refs, ref)
+	}
+	return refs, nil
+}
+
+// checks returns the job statuses of the latest pipeline for sha, or nil if
+// none has run.
+func (i *Informer) checks(ctx context.Context, base, sha string) (map[string]string, error) {
+	if sha == "" {
+		return nil, nil
+	}
+	pipelines, err := informers.GetOne[[]pipeline](ctx, i.client, base+"/pipelines?sha="+url.QueryEscape(sha)+"&order_by=id&sort=desc&per_page=1")
+	if err != nil || len(pipelines) == 0 {
+		return nil, err
+	}
+	latest := pipelines[0]
+	jobs, err := informers.GetAll[job](ctx, i.client, fmt.Sprintf("%s/pipelines/%d/jobs?per_page=100", base, latest.ID))
+	if err != nil {
+		return nil, err
+	}
+	checks := map[string]string{"pipeline": latest.Status}
+	for _, j := range jobs {
+		checks[j.Name] = j.Status
+	}
+	return checks, nil
+}
@@ -0,0 +1,132 @@
+package gitlab
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ephlabs/eph/internal/informers"
+)
+
+// fakeGitLab serves canned JSON keyed by escaped path, honouring
+// If-None-Match.
+type fakeGitLab struct {
+	mu          sync.Mutex
+	responses   map[string]string
+	token       string
+	notModified int
+	retryAfter  string
+}
+
+func (f *fakeGitLab) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.Header.Get("PRIVATE-TOKEN") != f.token {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if f.retryAfter != "" {
+		w.Header().Set("Retry-After", f.retryAfter)
+		w.WriteHeader(http.StatusTooManyRequests)
+		return
+	}
+	body, ok := f.responses[r.URL.EscapedPath()]
+	if !ok {
+		body = "[]"
+	}
+	etag := fmt.Sprintf(`W/"%x"`, body)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("RateLimit-Remaining", "1000")
+	w.Header().Set("RateLimit-Reset", "1900000000")
+	if r.Header.Get("If-None-Match") == etag {
+		f.notModified++
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	_, _ = w.Write([]byte(body))
+}
+
+func newTestInformer(t *testing.T, gl *fakeGitLab) *Informer {
+	t.Helper()
+	srv := httptest.NewServer(gl)
+	t.Cleanup(srv.Close)
+	inf, err := New(Options{BaseURL: srv.URL, Projects: []string{"platform/web/app"}, Token: gl.token})
+	require.NoError(t, err)
+	inf.client.Now = func() time.Time { return time.Unix(1_800_000_000, 0) }
+	return inf
+}
+
+func TestInformerListsMergeRequests(t *testing.T) {
+	const project = "/projects/platform%2Fweb%2Fapp"
+	gl := &fakeGitLab{token: "glpat", responses: map[string]string{
+		project + "/merge_requests": `[
+			{"iid": 7, "draft": false, "labels": ["preview", "backend"], "source_branch": "feature/cart", "sha": "c0ffee"},
+			{"iid": 8, "draft": true, "labels": [], "source_branch": "wip", "sha": "beef"}
+		]`,
+		project + "/merge_requests/7/notes": `[
+			{"body": "added 1 commit", "system": true},
+			{"body": "/deploy", "system": false}
+		]`,
+		project + "/pipelines":         `[{"id": 99, "status": "running"}]`,
+		project + "/pipelines/99/jobs": `[{"name": "build", "status": "success"}, {"name": "test", "status": "running"}]`,
+	}}
+	inf := newTestInformer(t, gl)
+
+	assert.Equal(t, DefaultInterval, inf.Poll(context.Background()))
+	assert.True(t, inf.HasSynced())
+
+	refs, err := inf.ListRefs(context.Background())
+	require.NoError(t, err)
+	require.Len(t, refs, 2)
+
+	mr := refs[0]
+	assert.Equal(t, "platform/web/app/pr/7", mr.Key())
+	assert.Equal(t, "feature/cart", mr.Branch)
+	assert.Equal(t, "c0ffee", mr.CommitSHA)
+	assert.Equal(t, []string{"preview", "backend"}, mr.Labels)
+	assert.Equal(t, []string{"/deploy"}, mr.Comments)
+	assert.Equal(t, map[string]string{"pipeline": "running", "build": "success", "test": "running"}, mr.Checks)
+	assert.True(t, refs[1].Draft)
+
+	// A second poll is answered entirely from the ETag cache.
+	before := gl.notModified
+	inf.Poll(context.Background())
+	assert.Greater(t, gl.notModified, before)
+	again, _ := inf.ListRefs(context.Background())
+	assert.Equal(t, refs, again)
+}
+
+func TestInformerRetryAfter(t *testing.T) {
+	gl := &fakeGitLab{token: "glpat", retryAfter: "90"}
+	inf := newTestInformer(t, gl)
+	assert.Equal(t, 90*time.Second, inf.Poll(context.Background()))
+	assert.False(t, inf.HasSynced())
+}
+
+func TestInformerUnauthorized(t *testing.T) {
+	gl := &fakeGitLab{token: "right"}
+	srv := httptest.NewServer(gl)
+	defer srv.Close()
+	inf, err := New(Options{BaseURL: srv.URL, Projects: []string{"group/app"}, Token: "wrong"})
+	require.NoError(t, err)
+
+	_, err = inf.listRefs(context.Background(), "group/app")
+	assert.ErrorContains(t, err, "401")
+}
+
+func TestRegistered(t *testing.T) {
+	inf, err := informers.NewRef("gitlab", informers.RefOptions{Repository: "group/sub/app,other/app"})
+	require.NoError(t, err)
+	assert.Equal(t, "gitlab", inf.Name())
+	assert.Equal(t, []string{"group/sub/app", "other/app"}, inf.(*Informer).Repositories)
+
+	_, err = informers.NewRef("gitlab", informers.RefOptions{Repository: "app"})
+	assert.ErrorContains(t, err, "full path")
+}
@@ -0,0 +1,156 @@
+package informers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ephlabs/eph/internal/controller"
+)
+
+// Informer keeps an in-memory cache of some external state up to date.
+type Informer interface {
+	// Name identifies the informer in logs.
+	Name() string
+
+	// Run fills and refreshes the cache until ctx is cancelled.
+	Run(ctx context.Context) error
+
+	// HasSynced reports whether the cache has been filled at least once.
+	// Until then its contents must not be trusted: an empty cache would
+	// make the reconciler delete every environment.
+	HasSynced() bool
+
+	// OnChange registers fn to be called after the cache changes. fn must
+	// not block; the reconciler's Poke is the typical callback.
+	OnChange(fn func())
+}
+
+// Resyncer is implemented by informers that can refresh their cache on
+// demand, e.g. when a webhook reports a change.
+type Resyncer interface {
+	Resync()
+}
+
+// RefInformer is an Informer over a forge's pull requests and branches.
+type RefInformer interface {
+	Informer
+	controller.RefLister
+}
+
+// RefOptions configures a RefInformer.
+type RefOptions struct {
+	// BaseURL overrides the forge API endpoint, e.g. for GitHub Enterprise
+	// or a self-hosted GitLab.
+	BaseURL string
+
+	// Repository is "owner/name", or a comma-separated list of them for
+	// informers that watch several repositories.
+	Repository string
+	Token      string
+
+	// Interval between polls; zero means the informer's default.
+	Interval time.Duration
+}
+
+// RefFactory creates a RefInformer.
+type RefFactory func(opts RefOptions) (RefInformer, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]RefFactory{}
+)
+
+// RegisterRef makes a forge informer available under name, e.g. "github".
+func RegisterRef(name string, factory RefFactory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, dup := registry[name]; dup {
+		panic("informers: RegisterRef called twice for " + name)
+	}
+	registry[name] = factory
+}
+
+// NewRef creates the forge informer registered under name.
+func NewRef(name string, opts RefOptions) (RefInformer, error) {
+	registryMu.RLock()
+	factory, ok := registry[name]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("forge %q is not supported (supported: %v)", name, RegisteredRefs())
+	}
+	return factory(opts)
+}
+
+// RegisteredRefs returns the names of all registered forge informers, sorted.
+func RegisteredRefs() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// WaitForSync blocks until every informer has synced or ctx is done.
+func WaitForSync(ctx context.Context, informers ...Informer) error {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		synced := true
+		for _, inf := range informers {
+			if !inf.HasSynced() {
+				synced = false
+				break
+			}
+		}
+		if synced {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for informers to sync: %w", ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// Notifier implements OnChange for informers to embed.
+type Notifier struct {
+	mu        sync.Mutex
+	callbacks []func()
+}
+
+// OnChange registers fn.
+func (n *Notifier) OnChange(fn func()) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.callbacks = append(n.callbacks, fn)
+}
+
+// Notify calls every registered callback.
+func (n *Notifier) Notify() {
+	n.mu.Lock()
+	callbacks := append([]func(){}, n.callbacks...)
+	n.mu.Unlock()
+	for _, fn := range callbacks {
+		fn()
+	}
+}
+
+// SplitRepositories splits a comma-separated list of repositories, as in
+// EPH_REPOSITORY, dropping empty entries.
+func SplitRepositories(s string) []string {
+	var repos []string
+	for repo := range strings.SplitSeq(s, ",") {
+		if repo = strings.TrimSpace(repo); repo != "" {
+			repos = append(repos, repo)
+		}
+	}
+	return repos
+}
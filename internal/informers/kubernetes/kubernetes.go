@@ -0,0 +1,377 @@
+// Package kubernetes implements an informer over the namespaces, deployments
+// and ingresses the Kubernetes provider creates. It is how ephd rebuilds its
+// view of existing environments after a restart: everything it needs is
+// recorded in labels and annotations on the cluster.
+package kubernetes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	kinformers "k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	appslisters "k8s.io/client-go/listers/apps/v1"
+	networkinglisters "k8s.io/client-go/listers/networking/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/ephlabs/eph/internal/informers"
+	"github.com/ephlabs/eph/internal/providers"
+)
+
+// Labels and annotations on Eph-managed objects. Label values are restricted
+// to 63 characters without slashes, so the ref key and commit, which may not
+// fit, are annotations.
+const (
+	// LabelManaged marks every object Eph creates; the informer only sees
+	// objects with LabelManaged=true.
+	LabelManaged     = "eph.io/managed"
+	LabelProject     = "eph.io/project"
+	LabelEnvironment = "eph.io/environment"
+
+	// AnnotationKey holds the environment's SourceRef.Key, e.g.
+	// "org/app/pr/123".
+	AnnotationKey    = "eph.io/key"
+	AnnotationCommit = "eph.io/commit"
+
+	// AnnotationURL, if set, overrides the URL derived from ingresses.
+	AnnotationURL = "eph.io/url"
+
+	// AnnotationHeader is a header, as "Name: value", that requests to the
+	// URL need to reach the environment.
+	AnnotationHeader = "eph.io/header"
+)
+
+// ManagedSelector selects Eph-managed objects.
+var ManagedSelector = labels.SelectorFromSet(labels.Set{LabelManaged: "true"})
+
+// DefaultResync is how often cached objects are redelivered to event
+// handlers, as a safety net for missed watch events.
+const DefaultResync = 10 * time.Minute
+
+const keyIndex = "eph.io/key"
+
+// Environment is an environment as found on the cluster.
+type Environment struct {
+	// Key is the SourceRef.Key the environment was created for.
+	Key       string
+	Name      string
+	Namespace string
+	Project   string
+	CommitSHA string
+	URL       string
+	Headers   map[string]string
+
+	// Ready is true once every deployment has rolled out. Message explains
+	// why an environment is not ready.
+	Ready   bool
+	Message string
+
+	// Terminating is true while the namespace is being deleted.
+	Terminating bool
+}
+
+// Instance converts e for the reconciler's actual state.
+func (e Environment) Instance() providers.Instance {
+	return providers.Instance{
+		Key:       e.Key,
+		Name:      e.Name,
+		CommitSHA: e.CommitSHA,
+		URL:       e.URL,
+		Headers:   e.Headers,
+		Ready:     e.Ready,
+		Message:   e.Message,
+	}
+}
+
+// Options configures an Informer.
+type Options struct {
+	// Resync defaults to DefaultResync.
+	Resync time.Duration
+}
+
+// Informer watches Eph-managed namespaces, deployments and ingresses with
+// shared informers and lists the environments they make up.
+type Informer struct {
+	informers.Notifier
+
+	client kubernetes.Interface
+	opts   Options
+
+	// caches holds the shared informers of the current or last run.
+	// Stopped informers cannot be started again, so each run after the
+	// first replaces them.
+	caches  atomic.Pointer[caches]
+	running atomic.Bool
+}
+
+// caches are the shared informers of one run.
+type caches struct {
+	factory     kinformers.SharedInformerFactory
+	namespaces  cache.SharedIndexInformer
+	deployments appslisters.DeploymentLister
+	ingresses   networkinglisters.IngressLister
+	synced      []cache.InformerSynced
+
+	// stopped is set when the run ends; the caches then keep the last
+	// known state but no longer count as synced.
+	stopped atomic.Bool
+}
+
+// New returns an Informer over client's cluster. Call Run to start watching.
+func New(client kubernetes.Interface, opts Options) (*Informer, error) {
+	if opts.Resync <= 0 {
+		opts.Resync = DefaultResync
+	}
+	i := &Informer{client: client, opts: opts}
+	c, err := i.newCaches()
+	if err != nil {
+		return nil, err
+	}
+	i.caches.Store(c)
+	return i, nil
+}
+
+func (i *Informer) newCaches() (*caches, error) {
+	factory := kinformers.NewSharedInformerFactoryWithOptions(i.client, i.opts.Resync,
+		kinformers.WithTweakListOptions(func(o *metav1.ListOptions) {
+			o.LabelSelector = ManagedSelector.String()
+		}))
+
+	c := &caches{factory: factory}
+	ns := factory.Core().V1().Namespaces()
+	deploy := factory.Apps().V1().Deployments()
+	ing := factory.Networking().V1().Ingresses()
+
+	c.namespaces = ns.Informer()
+	if err := c.namespaces.AddIndexers(cache.Indexers{keyIndex: indexByKey}); err != nil {
+		return nil, fmt.Errorf("kubernetes: adding namespace index: %w", err)
+	}
+	c.deployments = deploy.Lister()
+	c.ingresses = ing.Lister()
+
+	for _, inf := range []cache.SharedIndexInformer{c.namespaces, deploy.Informer(), ing.Informer()} {
+		if _, err := inf.AddEventHandler(i.handler(c)); err != nil {
+			return nil, fmt.Errorf("kubernetes: adding event handler: %w", err)
+		}
+		c.synced = append(c.synced, inf.HasSynced)
+	}
+	return c, nil
+}
+
+// Name implements informers.Informer.
+func (i *Informer) Name() string { return "kubernetes" }
+
+// Run starts the shared informers and blocks until ctx is cancelled. Run
+// may be called again once it has returned, as when ephd restarts a crashed
+// informer; the caches are then rebuilt from a fresh list.
+func (i *Informer) Run(ctx context.Context) error {
+	if !i.running.CompareAndSwap(false, true) {
+		return errors.New("kubernetes: informer already running")
+	}
+	defer i.running.Store(false)
+	c := i.caches.Load()
+	if c.stopped.Load() {
+		var err error
+		if c, err = i.newCaches(); err != nil {
+			return err
+		}
+		i.caches.Store(c)
+	}
+	c.factory.Start(ctx.Done())
+	defer c.factory.Shutdown()
+	defer c.stopped.Store(true)
+
+	if !cache.WaitForCacheSync(ctx.Done(), c.synced...) {
+		return nil
+	}
+	i.Notify()
+	<-ctx.Done()
+	return nil
+}
+
+// HasSynced implements informers.Informer.
+func (i *Informer) HasSynced() bool {
+	return i.caches.Load().hasSynced()
+}
+
+func (c *caches) hasSynced() bool {
+	if c.stopped.Load() {
+		return false
+	}
+	for _, synced := range c.synced {
+		if !synced() {
+			return false
+		}
+	}
+	return true
+}
+
+// List returns every Eph-managed environment on the cluster, sorted by key.
+// Namespaces without an eph.io/key annotation are skipped.
+func (i *Informer) List() []Environment {
+	c := i.caches.Load()
+	var envs []Environment
+	for _, obj := range c.namespaces.GetStore().List() {
+		if env, ok := c.environment(obj.(*corev1.Namespace)); ok {
+			envs = append(envs, env)
+		}
+	}
+	sort.Slice(envs, func(a, b int) bool { return envs[a].Key < envs[b].Key })
+	return envs
+}
+
+// Get returns the environment created for key, a SourceRef.Key.
+func (i *Informer) Get(key string) (Environment, bool) {
+	c := i.caches.Load()
+	objs, err := c.namespaces.GetIndexer().ByIndex(keyIndex, key)
+	if err != nil || len(objs) == 0 {
+		return Environment{}, false
+	}
+	return c.environment(objs[0].(*corev1.Namespace))
+}
+
+func (c *caches) environment(ns *corev1.Namespace) (Environment, bool) {
+	key := ns.Annotations[AnnotationKey]
+	if key == "" {
+		return Environment{}, false
+	}
+	env := Environment{
+		Key:         key,
+		Name:        ns.Labels[LabelEnvironment],
+		Namespace:   ns.Name,
+		Project:     ns.Labels[LabelProject],
+		CommitSHA:   ns.Annotations[AnnotationCommit],
+		URL:         ns.Annotations[AnnotationURL],
+		Terminating: ns.DeletionTimestamp != nil || ns.Status.Phase == corev1.NamespaceTerminating,
+	}
+	if env.Name == "" {
+		env.Name = ns.Name
+	}
+
+	if name, value, ok := strings.Cut(ns.Annotations[AnnotationHeader], ":"); ok {
+		env.Headers = map[string]string{strings.TrimSpace(name): strings.TrimSpace(value)}
+	}
+	if env.URL == "" {
+		ingresses, _ := c.ingresses.Ingresses(ns.Name).List(labels.Everything())
+		env.URL = IngressURL(ingresses)
+	}
+
+	deployments, _ := c.deployments.Deployments(ns.Name).List(labels.Everything())
+	env.Ready, env.Message = RolledOut(deployments)
+	if env.Terminating {
+		env.Ready, env.Message = false, "namespace is terminating"
+	}
+	return env, true
+}
+
+// RolledOut reports whether every deployment has finished rolling out, with
+// the same conditions as kubectl rollout status.
+func RolledOut(deployments []*appsv1.Deployment) (bool, string) {
+	if len(deployments) == 0 {
+		return false, "no deployments"
+	}
+	sort.Slice(deployments, func(a, b int) bool { return deployments[a].Name < deployments[b].Name })
+	var pending []string
+	for _, d := range deployments {
+		if msg := deploymentPending(d); msg != "" {
+			pending = append(pending, d.Name+": "+msg)
+		}
+	}
+	if len(pending) > 0 {
+		return false, strings.Join(pending, "; ")
+	}
+	return true, ""
+}
+
+func deploymentPending(d *appsv1.Deployment) string {
+	if d.Generation > d.Status.ObservedGeneration {
+		return "waiting for rollout to start"
+	}
+	for _, c := range d.Status.Conditions {
+		if c.Type == appsv1.DeploymentProgressing && c.Reason == "ProgressDeadlineExceeded" {
+			return "progress deadline exceeded"
+		}
+	}
+	want := int32(1)
+	if d.Spec.Replicas != nil {
+		want = *d.Spec.Replicas
+	}
+	switch {
+	case d.Status.UpdatedReplicas < want:
+		return fmt.Sprintf("%d of %d replicas updated", d.Status.UpdatedReplicas, want)
+	case d.Status.Replicas > d.Status.UpdatedReplicas:
+		return fmt.Sprintf("%d old replicas pending termination", d.Status.Replicas-d.Status.UpdatedReplicas)
+	case d.Status.AvailableReplicas < d.Status.UpdatedReplicas:
+		return fmt.Sprintf("%d of %d updated replicas available", d.Status.AvailableReplicas, d.Status.UpdatedReplicas)
+	}
+	return ""
+}
+
+// IngressURL returns the URL of the first ingress host, preferring hosts
+// covered by TLS.
+func IngressURL(ingresses []*networkingv1.Ingress) string {
+	sort.Slice(ingresses, func(a, b int) bool { return ingresses[a].Name < ingresses[b].Name })
+	for _, ing := range ingresses {
+		for _, tls := range ing.Spec.TLS {
+			for _, host := range tls.Hosts {
+				if host != "" {
+					return "https://" + host
+				}
+			}
+		}
+	}
+	for _, ing := range ingresses {
+		for _, rule := range ing.Spec.Rules {
+			if rule.Host != "" {
+				return "http://" + rule.Host
+			}
+		}
+	}
+	return ""
+}
+
+func indexByKey(obj any) ([]string, error) {
+	ns, ok := obj.(*corev1.Namespace)
+	if !ok {
+		return nil, nil
+	}
+	if key := ns.Annotations[AnnotationKey]; key != "" {
+		return []string{key}, nil
+	}
+	return nil, nil
+}
+
+// handler notifies OnChange listeners of every change to c. Periodic
+// resyncs redeliver unchanged objects, which are ignored.
+func (i *Informer) handler(c *caches) cache.ResourceEventHandler {
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: func(any) { i.notifyIfSynced(c) },
+		UpdateFunc: func(oldObj, newObj any) {
+			oldMeta, ok1 := oldObj.(metav1.Object)
+			newMeta, ok2 := newObj.(metav1.Object)
+			if ok1 && ok2 && oldMeta.GetResourceVersion() == newMeta.GetResourceVersion() {
+				return
+			}
+			i.notifyIfSynced(c)
+		},
+		DeleteFunc: func(any) { i.notifyIfSynced(c) },
+	}
+}
+
+// notifyIfSynced suppresses most of the burst of add events during the
+// initial list; Run notifies once when the caches have synced.
+func (i *Informer) notifyIfSynced(c *caches) {
+	if c.hasSynced() {
+		i.Notify()
+	}
+}
@@ -0,0 +1,196 @@
+package kubernetes
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/utils/ptr"
+
+	"github.com/ephlabs/eph/internal/informers"
+)
+
+var managed = map[string]string{LabelManaged: "true"}
+
+func namespace(name, key, commit string) *corev1.Namespace {
+	return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name: name,
+		Labels: map[string]string{
+			LabelManaged:     "true",
+			LabelProject:     "myapp",
+			LabelEnvironment: name,
+		},
+		Annotations: map[string]string{AnnotationKey: key, AnnotationCommit: commit},
+	}}
+}
+
+func headerRouted(name, key, commit string) *corev1.Namespace {
+	ns := namespace(name, key, commit)
+	ns.Annotations[AnnotationURL] = "https://preview.example.com"
+	ns.Annotations[AnnotationHeader] = "X-Eph-Environment: " + name
+	return ns
+}
+
+func deployment(ns, name string, replicas, ready int32) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: name, Labels: managed, Generation: 1},
+		Spec:       appsv1.DeploymentSpec{Replicas: ptr.To(replicas)},
+		Status: appsv1.DeploymentStatus{
+			ObservedGeneration: 1,
+			Replicas:           replicas,
+			UpdatedReplicas:    replicas,
+			AvailableReplicas:  ready,
+		},
+	}
+}
+
+func startInformer(t *testing.T, inf *Informer) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- inf.Run(ctx) }()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	syncCtx, syncCancel := context.WithTimeout(ctx, 5*time.Second)
+	defer syncCancel()
+	require.NoError(t, informers.WaitForSync(syncCtx, inf))
+}
+
+func TestInformerListsEnvironments(t *testing.T) {
+	client := fake.NewClientset(
+		namespace("myapp-calm-river-7", "org/myapp/pr/12", "abc123"),
+		deployment("myapp-calm-river-7", "api", 2, 2),
+		deployment("myapp-calm-river-7", "worker", 1, 1),
+		&networkingv1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "myapp-calm-river-7", Name: "web", Labels: managed},
+			Spec: networkingv1.IngressSpec{
+				TLS: []networkingv1.IngressTLS{{Hosts: []string{"myapp-calm-river-7.preview.example.com"}}},
+			},
+		},
+		headerRouted("myapp-bold-hill-3", "org/myapp/pr/9", "def456"),
+		deployment("myapp-bold-hill-3", "api", 2, 1),
+		// Not managed by Eph.
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "kube-system"}},
+	)
+	inf, err := New(client, Options{})
+	require.NoError(t, err)
+	startInformer(t, inf)
+
+	envs := inf.List()
+	require.Len(t, envs, 2)
+
+	ready := envs[0]
+	assert.Equal(t, "org/myapp/pr/12", ready.Key)
+	assert.Equal(t, "myapp-calm-river-7", ready.Name)
+	assert.Equal(t, "myapp", ready.Project)
+	assert.Equal(t, "abc123", ready.CommitSHA)
+	assert.Equal(t, "https://myapp-calm-river-7.preview.example.com", ready.URL)
+	assert.True(t, ready.Ready, ready.Message)
+
+	pending := envs[1]
+	assert.Equal(t, "org/myapp/pr/9", pending.Key)
+	assert.False(t, pending.Ready)
+	assert.Equal(t, "api: 1 of 2 updated replicas available", pending.Message)
+	assert.Equal(t, "https://preview.example.com", pending.URL)
+	assert.Equal(t, map[string]string{"X-Eph-Environment": "myapp-bold-hill-3"}, pending.Headers)
+	assert.Equal(t, pending.Headers, pending.Instance().Headers)
+
+	got, ok := inf.Get("org/myapp/pr/9")
+	require.True(t, ok)
+	assert.Equal(t, "myapp-bold-hill-3", got.Namespace)
+	assert.Equal(t, "org/myapp/pr/9", got.Instance().Key)
+
+	_, ok = inf.Get("org/myapp/pr/404")
+	assert.False(t, ok)
+}
+
+func TestInformerNotifiesOnChange(t *testing.T) {
+	client := fake.NewClientset(
+		namespace("myapp-calm-river-7", "org/myapp/pr/12", "abc123"),
+		deployment("myapp-calm-river-7", "api", 1, 0),
+	)
+	inf, err := New(client, Options{})
+	require.NoError(t, err)
+
+	var changes atomic.Int32
+	inf.OnChange(func() { changes.Add(1) })
+	startInformer(t, inf)
+	assert.Eventually(t, func() bool { return changes.Load() >= 1 }, time.Second, time.Millisecond,
+		"the initial sync is reported")
+
+	env, _ := inf.Get("org/myapp/pr/12")
+	assert.False(t, env.Ready)
+	before := changes.Load()
+
+	d := deployment("myapp-calm-river-7", "api", 1, 1)
+	d.ResourceVersion = "2"
+	_, err = client.AppsV1().Deployments("myapp-calm-river-7").UpdateStatus(context.Background(), d, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	assert.Eventually(t, func() bool { return changes.Load() > before }, 5*time.Second, 10*time.Millisecond)
+	assert.Eventually(t, func() bool {
+		env, _ := inf.Get("org/myapp/pr/12")
+		return env.Ready
+	}, 5*time.Second, 10*time.Millisecond)
+
+	now := metav1.Now()
+	ns := namespace("myapp-calm-river-7", "org/myapp/pr/12", "abc123")
+	ns.DeletionTimestamp = &now
+	ns.ResourceVersion = "3"
+	_, err = client.CoreV1().Namespaces().Update(context.Background(), ns, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		env, _ := inf.Get("org/myapp/pr/12")
+		return env.Terminating && !env.Ready
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestInformerRestarts(t *testing.T) {
+	client := fake.NewClientset(namespace("myapp-calm-river-7", "org/myapp/pr/12", "abc123"))
+	inf, err := New(client, Options{})
+	require.NoError(t, err)
+
+	// ephd restarts an informer that crashed.
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- inf.Run(ctx) }()
+	syncCtx, syncCancel := context.WithTimeout(ctx, 5*time.Second)
+	defer syncCancel()
+	require.NoError(t, informers.WaitForSync(syncCtx, inf))
+	cancel()
+	require.NoError(t, <-done)
+
+	_, err = client.CoreV1().Namespaces().Create(context.Background(),
+		namespace("myapp-bold-hill-3", "org/myapp/pr/9", "def456"), metav1.CreateOptions{})
+	require.NoError(t, err)
+	startInformer(t, inf)
+
+	envs := inf.List()
+	require.Len(t, envs, 2, "the restarted informer lists afresh")
+	assert.Equal(t, "org/myapp/pr/9", envs[1].Key)
+}
+
+func TestRolledOut(t *testing.T) {
+	stale := deployment("ns", "api", 1, 1)
+	stale.Generation = 2
+	assert.Equal(t, "waiting for rollout to start", deploymentPending(stale))
+
+	surge := deployment("ns", "api", 2, 2)
+	surge.Status.Replicas = 3
+	assert.Equal(t, "1 old replicas pending termination", deploymentPending(surge))
+
+	ok, msg := RolledOut(nil)
+	assert.False(t, ok)
+	assert.Equal(t, "no deployments", msg)
+}
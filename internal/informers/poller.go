@@ -0,0 +1,113 @@
+package informers
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ephlabs/eph/internal/controller"
+	"github.com/ephlabs/eph/internal/log"
+)
+
+// RefPoller implements the polling loop shared by forge informers: it lists
+// the refs of every repository with List, stores them in a RefCache, and
+// paces itself by the rate limit Client reports.
+type RefPoller struct {
+	*RefCache
+
+	// Forge names the informer, e.g. "github".
+	Forge        string
+	Repositories []string
+	Client       *Client
+	List         func(ctx context.Context, repo string) ([]controller.Ref, error)
+
+	Interval time.Duration
+
+	// RateLimitReserve is the number of requests left in the rate limit
+	// window below which polling pauses until the window resets, leaving
+	// room for other users of the token.
+	RateLimitReserve int
+
+	resyncOnce sync.Once
+	resync     chan struct{}
+}
+
+// Name implements Informer.
+func (p *RefPoller) Name() string { return p.Forge }
+
+// Run polls until ctx is cancelled.
+func (p *RefPoller) Run(ctx context.Context) error {
+	for {
+		delay := p.Poll(ctx)
+		timer := time.NewTimer(delay)
+		// A resync request cuts the wait short, unless the forge asked
+		// us to back off.
+		resync := p.resyncChan()
+		if delay > p.Interval {
+			resync = nil
+		}
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		case <-resync:
+			timer.Stop()
+		}
+	}
+}
+
+// Resync implements Resyncer. Requests made while a poll is running are
+// coalesced into one more poll.
+func (p *RefPoller) Resync() {
+	select {
+	case p.resyncChan() <- struct{}{}:
+	default:
+	}
+}
+
+func (p *RefPoller) resyncChan() chan struct{} {
+	p.resyncOnce.Do(func() { p.resync = make(chan struct{}, 1) })
+	return p.resync
+}
+
+// Poll refreshes every repository once and returns how long to wait before
+// the next poll. A repository that fails to refresh keeps its cached refs.
+func (p *RefPoller) Poll(ctx context.Context) time.Duration {
+	failed := false
+	for _, repo := range p.Repositories {
+		refs, err := p.List(ctx, repo)
+		if err != nil {
+			if ctx.Err() != nil {
+				return 0
+			}
+			var limited *RateLimitError
+			if errors.As(err, &limited) {
+				wait := max(limited.Until.Sub(p.Client.now()), p.Interval)
+				log.Warn(ctx, "Forge rate limit hit, pausing polls", "forge", p.Forge, "repository", repo, "retry_in", wait)
+				return wait
+			}
+			log.Warn(ctx, "Failed to poll forge", "forge", p.Forge, "repository", repo, "error", err)
+			failed = true
+			continue
+		}
+		if p.Replace(repo, refs) {
+			log.Debug(ctx, "Forge refs changed", "forge", p.Forge, "repository", repo, "refs", len(refs))
+		}
+	}
+	if failed {
+		return p.Interval
+	}
+	p.Client.Prune()
+	p.MarkSynced()
+
+	if limit, ok := p.Client.RateLimit(); ok && limit.Remaining < p.RateLimitReserve {
+		if wait := limit.Reset.Sub(p.Client.now()); wait > p.Interval {
+			log.Warn(ctx, "Forge rate limit nearly exhausted, pausing polls",
+				"forge", p.Forge, "remaining", limit.Remaining, "retry_in", wait)
+			return wait
+		}
+	}
+	return p.Interval
+}
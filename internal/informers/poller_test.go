@@ -0,0 +1,40 @@
+package informers
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ephlabs/eph/internal/controller"
+)
+
+func TestRefPollerResync(t *testing.T) {
+	var polls atomic.Int32
+	p := &RefPoller{
+		RefCache:     NewRefCache(),
+		Forge:        "test",
+		Repositories: []string{"o/r"},
+		Client:       &Client{},
+		Interval:     time.Hour,
+		List: func(context.Context, string) ([]controller.Ref, error) {
+			polls.Add(1)
+			return nil, nil
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- p.Run(ctx) }()
+
+	assert.Eventually(t, func() bool { return polls.Load() == 1 }, time.Second, time.Millisecond)
+	assert.True(t, p.HasSynced())
+
+	p.Resync()
+	assert.Eventually(t, func() bool { return polls.Load() == 2 }, time.Second, time.Millisecond)
+
+	cancel()
+	assert.NoError(t, <-done)
+}
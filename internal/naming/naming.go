@@ -0,0 +1,230 @@
+package naming
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"embed"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/ephlabs/eph/internal/template"
+)
+
+const (
+	// DefaultWords is the number of words in a name: one adjective and one
+	// noun, as in "serene-ocean".
+	DefaultWords = 2
+
+	// DefaultMaxNumber is the largest numeric suffix, as in "serene-ocean-42".
+	DefaultMaxNumber = 99
+
+	// MaxWords bounds Generator.Words so names stay readable and well inside
+	// the DNS label limit.
+	MaxWords = 4
+)
+
+//go:embed words/*.txt
+var wordFiles embed.FS
+
+var (
+	adjectives = loadWords("words/adjectives.txt")
+	nouns      = loadWords("words/nouns.txt")
+)
+
+func loadWords(name string) []string {
+	data, err := wordFiles.ReadFile(name)
+	if err != nil {
+		panic(err)
+	}
+	return strings.Fields(string(data))
+}
+
+// Name is a generated environment name such as "serene-ocean-42".
+type Name struct {
+	Words  []string
+	Number int
+}
+
+// String returns the name without a project prefix.
+func (n Name) String() string {
+	return strings.Join(n.Words, "-") + "-" + strconv.Itoa(n.Number)
+}
+
+// Vars returns the {words} and {number} variables for rendering a
+// name_template.
+func (n Name) Vars() template.Vars {
+	return template.Vars{
+		template.VarWords:  strings.Join(n.Words, "-"),
+		template.VarNumber: strconv.Itoa(n.Number),
+	}
+}
+
+// Label returns the name prefixed with project, e.g. "myapp-serene-ocean-42".
+// The result is always a valid DNS-1123 label. If it would be too long the
+// project part is shortened, never the random part, so truncation cannot
+// reduce the name's entropy.
+func (n Name) Label(project string) string {
+	name := n.String()
+	prefix := template.SanitizeLabel(project)
+	if prefix == "" {
+		return name
+	}
+	room := template.MaxLabelLength - len(name) - 1
+	if room <= 0 {
+		return name
+	}
+	return template.Truncate(prefix, room) + "-" + name
+}
+
+// Generator produces readable, non-guessable names from embedded adjective
+// and noun lists. The zero value uses DefaultWords and DefaultMaxNumber.
+type Generator struct {
+	// Words is the number of words per name. The last word is a noun and
+	// the others are adjectives. Each additional word adds roughly eight
+	// bits of entropy.
+	Words int
+
+	// MaxNumber is the largest numeric suffix; numbers range from 1 to
+	// MaxNumber inclusive.
+	MaxNumber int
+}
+
+func (g Generator) words() int {
+	if g.Words == 0 {
+		return DefaultWords
+	}
+	return g.Words
+}
+
+func (g Generator) maxNumber() int {
+	if g.MaxNumber == 0 {
+		return DefaultMaxNumber
+	}
+	return g.MaxNumber
+}
+
+func (g Generator) validate() error {
+	if w := g.words(); w < 1 || w > MaxWords {
+		return fmt.Errorf("words must be between 1 and %d, got %d", MaxWords, w)
+	}
+	if g.maxNumber() < 1 {
+		return fmt.Errorf("max number must be positive, got %d", g.MaxNumber)
+	}
+	return nil
+}
+
+// Entropy returns the number of bits of entropy in a generated name, i.e.
+// log2 of the number of distinct names the generator can produce.
+func (g Generator) Entropy() float64 {
+	bits := float64(g.words()-1)*math.Log2(float64(len(adjectives))) + math.Log2(float64(len(nouns)))
+	return bits + math.Log2(float64(g.maxNumber()))
+}
+
+// Generate returns a random name drawn from crypto/rand.
+func (g Generator) Generate() (Name, error) {
+	return g.generate(rand.Reader)
+}
+
+// Derive returns the name for seed under key. The same key and seed always
+// produce the same name, which lets the reconciler re-derive an environment's
+// name after a restart without storing it anywhere.
+//
+// Names stay non-guessable only as long as key is secret: anyone who knows it
+// can compute the name for any PR. An empty key is therefore rejected.
+func (g Generator) Derive(key []byte, seed string) (Name, error) {
+	if len(key) == 0 {
+		return Name{}, errors.New("deriving a name requires a secret key")
+	}
+	return g.generate(newKeyedStream(key, seed))
+}
+
+// PRSeed returns the Derive seed for pull request number of repo, where repo
+// is "owner/name". It is case-insensitive, like the forges' own repository
+// names.
+func PRSeed(repo string, number int) string {
+	return "pr:" + strings.ToLower(repo) + "#" + strconv.Itoa(number)
+}
+
+// RefSeed returns the Derive seed for a non-PR ref of repo, e.g. a branch.
+func RefSeed(repo, refType, refName string) string {
+	return refType + ":" + strings.ToLower(repo) + "#" + refName
+}
+
+func (g Generator) generate(r io.Reader) (Name, error) {
+	if err := g.validate(); err != nil {
+		return Name{}, err
+	}
+	n := Name{Words: make([]string, g.words())}
+	for i := range n.Words {
+		list := adjectives
+		if i == len(n.Words)-1 {
+			list = nouns
+		}
+		idx, err := uniform(r, len(list))
+		if err != nil {
+			return Name{}, err
+		}
+		n.Words[i] = list[idx]
+	}
+	num, err := uniform(r, g.maxNumber())
+	if err != nil {
+		return Name{}, err
+	}
+	n.Number = num + 1
+	return n, nil
+}
+
+// uniform returns an unbiased integer in [0, n) read from r, using rejection
+// sampling to avoid the modulo bias of a plain remainder.
+func uniform(r io.Reader, n int) (int, error) {
+	limit := math.MaxUint32 - math.MaxUint32%uint32(n)
+	var buf [4]byte
+	for {
+		if _, err := io.ReadFull(r, buf[:]); err != nil {
+			return 0, fmt.Errorf("reading random bytes: %w", err)
+		}
+		if v := binary.BigEndian.Uint32(buf[:]); v < limit {
+			return int(v % uint32(n)), nil
+		}
+	}
+}
+
+// keyedStream is an endless deterministic byte stream: HMAC-SHA256(key,
+// seed || counter) for counter = 0, 1, ...
+type keyedStream struct {
+	mac     hash.Hash
+	seed    []byte
+	counter uint64
+	buf     []byte
+}
+
+func newKeyedStream(key []byte, seed string) *keyedStream {
+	return &keyedStream{mac: hmac.New(sha256.New, key), seed: []byte(seed)}
+}
+
+func (s *keyedStream) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		if len(s.buf) == 0 {
+			var ctr [8]byte
+			binary.BigEndian.PutUint64(ctr[:], s.counter)
+			s.counter++
+			s.mac.Reset()
+			s.mac.Write(s.seed)
+			s.mac.Write([]byte{0})
+			s.mac.Write(ctr[:])
+			s.buf = s.mac.Sum(nil)
+		}
+		c := copy(p[n:], s.buf)
+		s.buf = s.buf[c:]
+		n += c
+	}
+	return n, nil
+}
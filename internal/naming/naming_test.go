@@ -0,0 +1,97 @@
+package naming
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ephlabs/eph/internal/template"
+)
+
+func TestWordLists(t *testing.T) {
+	for name, list := range map[string][]string{"adjectives": adjectives, "nouns": nouns} {
+		require.GreaterOrEqual(t, len(list), 128, name)
+		seen := map[string]bool{}
+		for _, w := range list {
+			assert.False(t, seen[w], "%s: duplicate %q", name, w)
+			seen[w] = true
+			assert.True(t, template.IsLabel(w) && !strings.Contains(w, "-"), "%s: %q is not a plain lowercase word", name, w)
+			assert.LessOrEqual(t, len(w), 10, "%s: %q is too long", name, w)
+		}
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	seen := map[string]bool{}
+	for i := 0; i < 50; i++ {
+		n, err := Generator{}.Generate()
+		require.NoError(t, err)
+		require.Len(t, n.Words, DefaultWords)
+		assert.Contains(t, adjectives, n.Words[0])
+		assert.Contains(t, nouns, n.Words[1])
+		assert.True(t, n.Number >= 1 && n.Number <= DefaultMaxNumber, n.Number)
+		assert.True(t, template.IsLabel(n.String()), n.String())
+		seen[n.String()] = true
+	}
+	// ~22 bits of entropy: 50 draws colliding more than a couple of times
+	// would mean the source isn't random.
+	assert.Greater(t, len(seen), 45)
+}
+
+func TestGeneratorOptions(t *testing.T) {
+	g := Generator{Words: 4, MaxNumber: 9999}
+	n, err := g.Generate()
+	require.NoError(t, err)
+	assert.Len(t, n.Words, 4)
+	assert.LessOrEqual(t, n.Number, 9999)
+	assert.Greater(t, g.Entropy(), Generator{}.Entropy()+16)
+
+	_, err = Generator{Words: MaxWords + 1}.Generate()
+	assert.Error(t, err)
+	_, err = Generator{MaxNumber: -1}.Generate()
+	assert.Error(t, err)
+}
+
+func TestDeriveIsDeterministic(t *testing.T) {
+	key := []byte("daemon-secret")
+	a, err := Generator{}.Derive(key, PRSeed("ephlabs/eph", 42))
+	require.NoError(t, err)
+	b, err := Generator{}.Derive(key, PRSeed("EphLabs/eph", 42))
+	require.NoError(t, err)
+	assert.Equal(t, a, b)
+
+	other, err := Generator{}.Derive(key, PRSeed("ephlabs/eph", 43))
+	require.NoError(t, err)
+	assert.NotEqual(t, a, other)
+
+	rekeyed, err := Generator{}.Derive([]byte("another-secret"), PRSeed("ephlabs/eph", 42))
+	require.NoError(t, err)
+	assert.NotEqual(t, a, rekeyed)
+
+	_, err = Generator{}.Derive(nil, PRSeed("ephlabs/eph", 42))
+	assert.Error(t, err)
+}
+
+func TestLabel(t *testing.T) {
+	n := Name{Words: []string{"serene", "ocean"}, Number: 42}
+	assert.Equal(t, "serene-ocean-42", n.String())
+	assert.Equal(t, "myapp-serene-ocean-42", n.Label("myapp"))
+	assert.Equal(t, "my-app-serene-ocean-42", n.Label("My_App"))
+	assert.Equal(t, "serene-ocean-42", n.Label(""))
+
+	// Long project names are shortened; the random part is kept intact.
+	long := n.Label(strings.Repeat("enormous-project-", 5))
+	assert.LessOrEqual(t, len(long), template.MaxLabelLength)
+	assert.True(t, template.IsLabel(long), long)
+	assert.True(t, strings.HasSuffix(long, "-serene-ocean-42"), long)
+}
+
+func TestVarsRenderNameTemplate(t *testing.T) {
+	n := Name{Words: []string{"serene", "ocean"}, Number: 42}
+	vars := n.Vars().Merge(template.Vars{template.VarProject: "myapp"})
+	got, err := template.RenderLabel("{project}-{words}-{number}", vars)
+	require.NoError(t, err)
+	assert.Equal(t, "myapp-serene-ocean-42", got)
+}
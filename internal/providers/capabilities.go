@@ -0,0 +1,49 @@
+package providers
+
+import (
+	"context"
+	"slices"
+
+	"github.com/ephlabs/eph/internal/config"
+)
+
+// CapabilityReporter is implemented by providers that can tell what their
+// backend supports, such as plugins answering GetCapabilities. Providers
+// that do not implement it are assumed to support whatever eph.yaml asks
+// for.
+type CapabilityReporter interface {
+	Capabilities(ctx context.Context) (Capabilities, error)
+}
+
+// Capabilities declares what a provider's backend supports.
+type Capabilities struct {
+	ScaleToZero          bool
+	CustomDomains        bool
+	PersistentStorage    bool
+	DatabaseProvisioning bool
+
+	// Databases are the database types that can be provisioned, e.g.
+	// "postgres".
+	Databases []string
+}
+
+// Missing describes the capabilities cfg requires that c lacks, e.g.
+// "persistent storage", in a stable order.
+func (c Capabilities) Missing(cfg *config.Config) []string {
+	var missing []string
+	if cfg.Database.Enabled && len(cfg.Database.Instances) > 0 {
+		if !c.DatabaseProvisioning {
+			missing = append(missing, "database provisioning")
+		} else {
+			for _, db := range cfg.Database.Instances {
+				if !slices.Contains(c.Databases, db.Type) && !slices.Contains(missing, db.Type+" databases") {
+					missing = append(missing, db.Type+" databases")
+				}
+			}
+		}
+	}
+	if !c.PersistentStorage && slices.ContainsFunc(cfg.Services, func(s config.Service) bool { return s.Persistent }) {
+		missing = append(missing, "persistent storage")
+	}
+	return missing
+}
@@ -0,0 +1,28 @@
+package providers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ephlabs/eph/internal/config"
+)
+
+func TestCapabilitiesMissing(t *testing.T) {
+	cfg := &config.Config{
+		Database: config.DatabaseConfig{Enabled: true, Instances: []config.DatabaseInstance{
+			{Name: "main", Type: "postgres"},
+			{Name: "cache", Type: "redis"},
+			{Name: "replica", Type: "postgres"},
+		}},
+		Services: []config.Service{{Name: "minio", Persistent: true}},
+	}
+
+	assert.Equal(t, []string{"database provisioning", "persistent storage"}, Capabilities{}.Missing(cfg))
+	assert.Equal(t, []string{"redis databases"},
+		Capabilities{DatabaseProvisioning: true, Databases: []string{"postgres"}, PersistentStorage: true}.Missing(cfg))
+	assert.Empty(t, Capabilities{}.Missing(&config.Config{}), "a project without databases or persistent services requires nothing")
+
+	cfg.Database.Enabled = false
+	assert.Equal(t, []string{"persistent storage"}, Capabilities{}.Missing(cfg))
+}
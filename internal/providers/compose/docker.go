@@ -0,0 +1,373 @@
+package compose
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultHost is the Docker Engine address used when DOCKER_HOST is unset.
+const DefaultHost = "unix:///var/run/docker.sock"
+
+// apiVersion is the Engine API version requests are made with. 1.41 is
+// served by Docker 20.10 and later.
+const apiVersion = "v1.41"
+
+// docker is a client for the parts of the Docker Engine API the provider
+// uses.
+type docker struct {
+	http *http.Client
+	base string
+}
+
+// newDocker returns a client for host, a unix:// or tcp:// address as in
+// DOCKER_HOST.
+func newDocker(host string) (*docker, error) {
+	if host == "" {
+		host = DefaultHost
+	}
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, fmt.Errorf("docker host %q: %w", host, err)
+	}
+	switch u.Scheme {
+	case "unix":
+		socket := u.Path
+		transport := &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socket)
+			},
+		}
+		return &docker{http: &http.Client{Transport: transport}, base: "http://docker/" + apiVersion}, nil
+	case "tcp", "http":
+		return &docker{http: &http.Client{}, base: "http://" + u.Host + "/" + apiVersion}, nil
+	default:
+		return nil, fmt.Errorf("docker host %q: unsupported scheme %q", host, u.Scheme)
+	}
+}
+
+// apiError is an error response from the Engine.
+type apiError struct {
+	Status  int
+	Message string
+}
+
+func (e *apiError) Error() string { return e.Message }
+
+func isNotFound(err error) bool {
+	var apiErr *apiError
+	return errors.As(err, &apiErr) && apiErr.Status == http.StatusNotFound
+}
+
+// request sends a request with body encoded as JSON, if not nil, and returns
+// the response if it succeeded.
+func (d *docker) request(ctx context.Context, method, path string, query url.Values, body any) (*http.Response, error) {
+	var r io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		r = bytes.NewReader(data)
+	}
+	u := d.base + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, r)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := d.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		defer resp.Body.Close()
+		var msg struct {
+			Message string `json:"message"`
+		}
+		data, _ := io.ReadAll(resp.Body)
+		if json.Unmarshal(data, &msg) != nil || msg.Message == "" {
+			msg.Message = strings.TrimSpace(string(data))
+		}
+		return nil, &apiError{Status: resp.StatusCode, Message: fmt.Sprintf("%s %s: %s", method, path, msg.Message)}
+	}
+	return resp, nil
+}
+
+// do sends a request and decodes the response into out, if not nil.
+func (d *docker) do(ctx context.Context, method, path string, query url.Values, body, out any) error {
+	resp, err := d.request(ctx, method, path, query, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		_, err = io.Copy(io.Discard, resp.Body)
+		return err
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// labelFilter returns the filters query parameter selecting objects with
+// all of labels.
+func labelFilter(labels map[string]string) url.Values {
+	var selectors []string
+	for k, v := range labels {
+		selectors = append(selectors, k+"="+v)
+	}
+	data, _ := json.Marshal(map[string][]string{"label": selectors})
+	return url.Values{"filters": {string(data)}}
+}
+
+func (d *docker) ping(ctx context.Context) error {
+	return d.do(ctx, http.MethodGet, "/_ping", nil, nil, nil)
+}
+
+// container is the subset of a container inspection the provider reads.
+type container struct {
+	ID     string `json:"Id"`
+	Name   string `json:"Name"`
+	Config struct {
+		Image  string            `json:"Image"`
+		Labels map[string]string `json:"Labels"`
+	} `json:"Config"`
+	State struct {
+		Status     string `json:"Status"`
+		Running    bool   `json:"Running"`
+		Restarting bool   `json:"Restarting"`
+		ExitCode   int    `json:"ExitCode"`
+		Health     *struct {
+			Status string `json:"Status"`
+		} `json:"Health"`
+	} `json:"State"`
+	NetworkSettings struct {
+		Ports map[string][]portBinding `json:"Ports"`
+	} `json:"NetworkSettings"`
+}
+
+type portBinding struct {
+	HostIP   string `json:"HostIp"`
+	HostPort string `json:"HostPort"`
+}
+
+// health is the container's health check status, or "" if it has none.
+func (c *container) health() string {
+	if c.State.Health == nil {
+		return ""
+	}
+	return c.State.Health.Status
+}
+
+// listContainers inspects every container, running or not, with labels.
+func (d *docker) listContainers(ctx context.Context, labels map[string]string) ([]*container, error) {
+	query := labelFilter(labels)
+	query.Set("all", "1")
+	var summaries []struct {
+		ID string `json:"Id"`
+	}
+	if err := d.do(ctx, http.MethodGet, "/containers/json", query, nil, &summaries); err != nil {
+		return nil, err
+	}
+	out := make([]*container, 0, len(summaries))
+	for _, s := range summaries {
+		c, err := d.inspectContainer(ctx, s.ID)
+		if isNotFound(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, nil
+}
+
+func (d *docker) inspectContainer(ctx context.Context, id string) (*container, error) {
+	var c container
+	if err := d.do(ctx, http.MethodGet, "/containers/"+id+"/json", nil, nil, &c); err != nil {
+		return nil, err
+	}
+	c.Name = strings.TrimPrefix(c.Name, "/")
+	return &c, nil
+}
+
+func (d *docker) createContainer(ctx context.Context, name string, body *containerSpec) (string, error) {
+	var created struct {
+		ID string `json:"Id"`
+	}
+	err := d.do(ctx, http.MethodPost, "/containers/create", url.Values{"name": {name}}, body, &created)
+	return created.ID, err
+}
+
+func (d *docker) startContainer(ctx context.Context, id string) error {
+	return d.do(ctx, http.MethodPost, "/containers/"+id+"/start", nil, nil, nil)
+}
+
+// removeContainer stops and removes a container with its anonymous volumes.
+func (d *docker) removeContainer(ctx context.Context, id string) error {
+	err := d.do(ctx, http.MethodDelete, "/containers/"+id, url.Values{"force": {"1"}, "v": {"1"}}, nil, nil)
+	if isNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// pullImage pulls ref unless it is already present. Pull failures are
+// reported in the progress stream rather than by the status code.
+func (d *docker) pullImage(ctx context.Context, ref string) error {
+	err := d.do(ctx, http.MethodGet, "/images/"+ref+"/json", nil, nil, nil)
+	if !isNotFound(err) {
+		return err
+	}
+	resp, err := d.request(ctx, http.MethodPost, "/images/create", url.Values{"fromImage": {ref}}, nil)
+	if err != nil {
+		return fmt.Errorf("pulling %s: %w", ref, err)
+	}
+	defer resp.Body.Close()
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var msg struct {
+			Error string `json:"error"`
+		}
+		if err := dec.Decode(&msg); errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("pulling %s: %w", ref, err)
+		}
+		if msg.Error != "" {
+			return fmt.Errorf("pulling %s: %s", ref, msg.Error)
+		}
+	}
+}
+
+// object is a network or volume.
+type object struct {
+	ID     string            `json:"Id"`
+	Name   string            `json:"Name"`
+	Labels map[string]string `json:"Labels"`
+}
+
+func (d *docker) listNetworks(ctx context.Context, labels map[string]string) ([]object, error) {
+	var out []object
+	err := d.do(ctx, http.MethodGet, "/networks", labelFilter(labels), nil, &out)
+	return out, err
+}
+
+func (d *docker) createNetwork(ctx context.Context, name string, labels map[string]string) error {
+	body := map[string]any{"Name": name, "Driver": "bridge", "Labels": labels, "CheckDuplicate": true}
+	return d.do(ctx, http.MethodPost, "/networks/create", nil, body, nil)
+}
+
+func (d *docker) connectNetwork(ctx context.Context, network, containerID string, aliases []string) error {
+	body := map[string]any{"Container": containerID, "EndpointConfig": map[string]any{"Aliases": aliases}}
+	return d.do(ctx, http.MethodPost, "/networks/"+network+"/connect", nil, body, nil)
+}
+
+func (d *docker) removeNetwork(ctx context.Context, id string) error {
+	err := d.do(ctx, http.MethodDelete, "/networks/"+id, nil, nil, nil)
+	if isNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+func (d *docker) listVolumes(ctx context.Context, labels map[string]string) ([]object, error) {
+	var out struct {
+		Volumes []object `json:"Volumes"`
+	}
+	err := d.do(ctx, http.MethodGet, "/volumes", labelFilter(labels), nil, &out)
+	return out.Volumes, err
+}
+
+func (d *docker) createVolume(ctx context.Context, name string, labels map[string]string) error {
+	return d.do(ctx, http.MethodPost, "/volumes/create", nil, map[string]any{"Name": name, "Labels": labels}, nil)
+}
+
+func (d *docker) removeVolume(ctx context.Context, name string) error {
+	err := d.do(ctx, http.MethodDelete, "/volumes/"+name, nil, nil, nil)
+	if isNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// logLine is a line of container output.
+type logLine struct {
+	Time   time.Time
+	Stream string
+	Line   string
+}
+
+// logs streams the output of a container created without a TTY, which the
+// Engine multiplexes into frames, each with an 8-byte header naming the
+// stream and giving the payload's length.
+func (d *docker) logs(ctx context.Context, id string, query url.Values, fn func(logLine) error) error {
+	query.Set("stdout", "1")
+	query.Set("stderr", "1")
+	query.Set("timestamps", "1")
+	resp, err := d.request(ctx, http.MethodGet, "/containers/"+id+"/logs", query, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	r := bufio.NewReader(resp.Body)
+	partial := map[string]string{}
+	header := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			if errors.Is(err, io.EOF) || ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		stream := "stdout"
+		if header[0] == 2 {
+			stream = "stderr"
+		}
+		size := int(header[4])<<24 | int(header[5])<<16 | int(header[6])<<8 | int(header[7])
+		payload := make([]byte, size)
+		if _, err := io.ReadFull(r, payload); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		text := partial[stream] + string(payload)
+		for {
+			line, rest, ok := strings.Cut(text, "\n")
+			if !ok {
+				break
+			}
+			text = rest
+			if err := fn(parseLogLine(stream, line)); err != nil {
+				return err
+			}
+		}
+		partial[stream] = text
+	}
+}
+
+// parseLogLine splits the RFC 3339 timestamp the Engine prefixes lines with.
+func parseLogLine(stream, line string) logLine {
+	ts, text, _ := strings.Cut(line, " ")
+	t, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		return logLine{Stream: stream, Line: line}
+	}
+	return logLine{Time: t, Stream: stream, Line: text}
+}
@@ -0,0 +1,352 @@
+package compose
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeEngine serves the parts of the Docker Engine API the provider uses,
+// from memory.
+type fakeEngine struct {
+	mu         sync.Mutex
+	containers map[string]*fakeContainer
+	networks   map[string]object
+	volumes    map[string]object
+	images     map[string]bool
+	missing    map[string]bool
+	created    int
+	nextPort   int
+
+	// onStart, if set, changes the state of a container as it starts.
+	onStart func(c *fakeContainer)
+}
+
+type fakeContainer struct {
+	container
+	spec     containerSpec
+	networks []string
+	logs     []logLine
+}
+
+func (c *fakeContainer) service() string { return c.Config.Labels[labelComposeService] }
+
+func (c *fakeContainer) setHealth(status string) {
+	c.State.Health = &struct {
+		Status string `json:"Status"`
+	}{status}
+}
+
+func (c *fakeContainer) exit(code int) {
+	c.State.Status, c.State.Running, c.State.ExitCode = "exited", false, code
+}
+
+func newFakeEngine(t *testing.T) (*fakeEngine, string) {
+	e := &fakeEngine{
+		containers: map[string]*fakeContainer{},
+		networks:   map[string]object{},
+		volumes:    map[string]object{},
+		images:     map[string]bool{},
+		missing:    map[string]bool{},
+		nextPort:   49153,
+	}
+	mux := http.NewServeMux()
+	prefix := "/" + apiVersion
+	mux.HandleFunc("GET "+prefix+"/_ping", func(w http.ResponseWriter, _ *http.Request) { fmt.Fprint(w, "OK") })
+	mux.HandleFunc("GET "+prefix+"/containers/json", e.listContainers)
+	mux.HandleFunc("POST "+prefix+"/containers/create", e.createContainer)
+	mux.HandleFunc("GET "+prefix+"/containers/{id}/json", e.inspectContainer)
+	mux.HandleFunc("POST "+prefix+"/containers/{id}/start", e.startContainer)
+	mux.HandleFunc("DELETE "+prefix+"/containers/{id}", e.removeContainer)
+	mux.HandleFunc("GET "+prefix+"/containers/{id}/logs", e.logs)
+	mux.HandleFunc("GET "+prefix+"/images/{ref...}", e.inspectImage)
+	mux.HandleFunc("POST "+prefix+"/images/create", e.pullImage)
+	mux.HandleFunc("GET "+prefix+"/networks", e.listObjects(func() map[string]object { return e.networks }, false))
+	mux.HandleFunc("POST "+prefix+"/networks/create", e.createObject(func() map[string]object { return e.networks }))
+	mux.HandleFunc("DELETE "+prefix+"/networks/{id}", e.removeObject(func() map[string]object { return e.networks }))
+	mux.HandleFunc("POST "+prefix+"/networks/{id}/connect", e.connectNetwork)
+	mux.HandleFunc("GET "+prefix+"/volumes", e.listObjects(func() map[string]object { return e.volumes }, true))
+	mux.HandleFunc("POST "+prefix+"/volumes/create", e.createObject(func() map[string]object { return e.volumes }))
+	mux.HandleFunc("DELETE "+prefix+"/volumes/{id}", e.removeObject(func() map[string]object { return e.volumes }))
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return e, "tcp://" + srv.Listener.Addr().String()
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func notFound(w http.ResponseWriter, what string) {
+	writeJSON(w, http.StatusNotFound, map[string]string{"message": "No such " + what})
+}
+
+// matches reports whether labels have every label=value of the filters
+// parameter.
+func matches(r *http.Request, labels map[string]string) bool {
+	var filters map[string][]string
+	json.Unmarshal([]byte(r.URL.Query().Get("filters")), &filters)
+	for _, selector := range filters["label"] {
+		k, v, _ := strings.Cut(selector, "=")
+		if labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// container finds a container by ID or name. e.mu must be held.
+func (e *fakeEngine) container(id string) *fakeContainer {
+	if c, ok := e.containers[id]; ok {
+		return c
+	}
+	for _, c := range e.containers {
+		if c.Name == "/"+id {
+			return c
+		}
+	}
+	return nil
+}
+
+// byName returns the container named name, or nil.
+func (e *fakeEngine) byName(name string) *fakeContainer {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.container(name)
+}
+
+// byService returns the names of the containers of service.
+func (e *fakeEngine) byService(service string) []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var out []string
+	for _, c := range e.containers {
+		if c.service() == service {
+			out = append(out, strings.TrimPrefix(c.Name, "/"))
+		}
+	}
+	return out
+}
+
+func (e *fakeEngine) update(name string, fn func(c *fakeContainer)) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	fn(e.container(name))
+}
+
+func (e *fakeEngine) listContainers(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	out := []map[string]string{}
+	for id, c := range e.containers {
+		if matches(r, c.Config.Labels) {
+			out = append(out, map[string]string{"Id": id})
+		}
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (e *fakeEngine) createContainer(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	name := r.URL.Query().Get("name")
+	if e.container(name) != nil {
+		writeJSON(w, http.StatusConflict, map[string]string{"message": "Conflict. The container name is already in use"})
+		return
+	}
+	var spec containerSpec
+	if err := json.NewDecoder(r.Body).Decode(&spec); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"message": err.Error()})
+		return
+	}
+	if !e.images[spec.Image] {
+		writeJSON(w, http.StatusNotFound, map[string]string{"message": "No such image: " + spec.Image})
+		return
+	}
+	e.created++
+	c := &fakeContainer{spec: spec, networks: []string{spec.HostConfig.NetworkMode}}
+	c.ID = fmt.Sprintf("c%d", e.created)
+	c.Name = "/" + name
+	c.Config.Image = spec.Image
+	c.Config.Labels = spec.Labels
+	c.State.Status = "created"
+	e.containers[c.ID] = c
+	writeJSON(w, http.StatusCreated, map[string]string{"Id": c.ID})
+}
+
+func (e *fakeEngine) inspectContainer(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	c := e.container(r.PathValue("id"))
+	if c == nil {
+		notFound(w, "container")
+		return
+	}
+	writeJSON(w, http.StatusOK, c.container)
+}
+
+func (e *fakeEngine) startContainer(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	c := e.container(r.PathValue("id"))
+	if c == nil {
+		notFound(w, "container")
+		return
+	}
+	c.State.Status, c.State.Running = "running", true
+	if c.spec.Healthcheck != nil && c.spec.Healthcheck.Test[0] != "NONE" {
+		c.setHealth("starting")
+	}
+	c.NetworkSettings.Ports = map[string][]portBinding{}
+	for port := range c.spec.HostConfig.PortBindings {
+		c.NetworkSettings.Ports[port] = []portBinding{{HostIP: "0.0.0.0", HostPort: fmt.Sprint(e.nextPort)}}
+		e.nextPort++
+	}
+	if e.onStart != nil {
+		e.onStart(c)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (e *fakeEngine) removeContainer(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	c := e.container(r.PathValue("id"))
+	if c == nil {
+		notFound(w, "container")
+		return
+	}
+	delete(e.containers, c.ID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// logs writes the container's lines as multiplexed frames and, when
+// following, holds the stream open until the client goes away.
+func (e *fakeEngine) logs(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	c := e.container(r.PathValue("id"))
+	if c == nil {
+		e.mu.Unlock()
+		notFound(w, "container")
+		return
+	}
+	lines := c.logs
+	e.mu.Unlock()
+
+	q := r.URL.Query()
+	if tail := q.Get("tail"); tail != "all" {
+		var n int
+		fmt.Sscan(tail, &n)
+		if n < len(lines) {
+			lines = lines[len(lines)-n:]
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+	for _, l := range lines {
+		stream := byte(1)
+		if l.Stream == "stderr" {
+			stream = 2
+		}
+		payload := l.Time.Format(time.RFC3339Nano) + " " + l.Line + "\n"
+		header := make([]byte, 8)
+		header[0] = stream
+		binary.BigEndian.PutUint32(header[4:], uint32(len(payload)))
+		w.Write(header)
+		w.Write([]byte(payload))
+	}
+	w.(http.Flusher).Flush()
+	if q.Get("follow") == "1" {
+		<-r.Context().Done()
+	}
+}
+
+func (e *fakeEngine) inspectImage(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	ref := strings.TrimSuffix(r.PathValue("ref"), "/json")
+	if !e.images[ref] {
+		notFound(w, "image")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"Id": ref})
+}
+
+func (e *fakeEngine) pullImage(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	ref := r.URL.Query().Get("fromImage")
+	if e.missing[ref] {
+		writeJSON(w, http.StatusOK, map[string]string{"error": "manifest unknown"})
+		return
+	}
+	e.images[ref] = true
+	writeJSON(w, http.StatusOK, map[string]string{"status": "Downloaded newer image for " + ref})
+}
+
+func (e *fakeEngine) listObjects(objects func() map[string]object, wrapped bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		out := []object{}
+		for _, o := range objects() {
+			if matches(r, o.Labels) {
+				out = append(out, o)
+			}
+		}
+		if wrapped {
+			writeJSON(w, http.StatusOK, map[string]any{"Volumes": out})
+			return
+		}
+		writeJSON(w, http.StatusOK, out)
+	}
+}
+
+func (e *fakeEngine) createObject(objects func() map[string]object) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		var o object
+		json.NewDecoder(r.Body).Decode(&o)
+		o.ID = o.Name
+		objects()[o.Name] = o
+		writeJSON(w, http.StatusCreated, map[string]string{"Id": o.ID})
+	}
+}
+
+func (e *fakeEngine) removeObject(objects func() map[string]object) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		id := r.PathValue("id")
+		if _, ok := objects()[id]; !ok {
+			notFound(w, "object")
+			return
+		}
+		delete(objects(), id)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (e *fakeEngine) connectNetwork(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var body struct {
+		Container string
+	}
+	json.NewDecoder(r.Body).Decode(&body)
+	c := e.container(body.Container)
+	if _, ok := e.networks[r.PathValue("id")]; !ok || c == nil {
+		notFound(w, "network")
+		return
+	}
+	c.networks = append(c.networks, r.PathValue("id"))
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,126 @@
+package compose
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ephlabs/eph/internal/providers"
+)
+
+// Logs implements providers.LogStreamer, merging the output of the
+// environment's containers. Without Follow, the lines are sent in time
+// order and TailLines applies to the merged output; with Follow, lines are
+// sent as containers write them until ctx is done or every container has
+// stopped.
+func (p *Provider) Logs(ctx context.Context, inst providers.Instance, opts providers.LogOptions, send func(providers.LogEntry) error) error {
+	selector := map[string]string{LabelManaged: "true", LabelKey: inst.Key}
+	containers, err := p.docker.listContainers(ctx, selector)
+	if err != nil {
+		return err
+	}
+	if len(containers) == 0 {
+		return fmt.Errorf("environment %s not found", inst.Name)
+	}
+	if opts.Service != "" {
+		containers = slices.DeleteFunc(containers, func(c *container) bool {
+			return c.Config.Labels[labelComposeService] != opts.Service
+		})
+		if len(containers) == 0 {
+			return fmt.Errorf("environment %s has no service %q", inst.Name, opts.Service)
+		}
+	}
+	slices.SortFunc(containers, func(a, b *container) int { return strings.Compare(a.Name, b.Name) })
+
+	query := func() url.Values {
+		q := url.Values{"tail": {"all"}}
+		if opts.TailLines > 0 {
+			q.Set("tail", strconv.Itoa(opts.TailLines))
+		}
+		if !opts.Since.IsZero() {
+			q.Set("since", strconv.FormatInt(opts.Since.Unix(), 10))
+		}
+		if opts.Follow {
+			q.Set("follow", "1")
+		}
+		return q
+	}
+	entry := func(c *container, l logLine) providers.LogEntry {
+		return providers.LogEntry{Time: l.Time, Service: c.Config.Labels[labelComposeService], Stream: l.Stream, Line: l.Line}
+	}
+
+	if !opts.Follow {
+		var entries []providers.LogEntry
+		for _, c := range containers {
+			err := p.docker.logs(ctx, c.ID, query(), func(l logLine) error {
+				entries = append(entries, entry(c, l))
+				return nil
+			})
+			if err != nil {
+				return fmt.Errorf("reading logs of %s: %w", c.Name, err)
+			}
+		}
+		slices.SortStableFunc(entries, func(a, b providers.LogEntry) int { return a.Time.Compare(b.Time) })
+		if opts.TailLines > 0 && len(entries) > opts.TailLines {
+			entries = entries[len(entries)-opts.TailLines:]
+		}
+		for _, e := range entries {
+			if err := send(e); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	lines := make(chan providers.LogEntry)
+	errs := make(chan error, len(containers))
+	var wg sync.WaitGroup
+	for _, c := range containers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := p.docker.logs(ctx, c.ID, query(), func(l logLine) error {
+				select {
+				case lines <- entry(c, l):
+					return nil
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			})
+			if err != nil && ctx.Err() == nil {
+				errs <- fmt.Errorf("following logs of %s: %w", c.Name, err)
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(lines)
+	}()
+
+	for {
+		select {
+		case e, ok := <-lines:
+			if !ok {
+				select {
+				case err := <-errs:
+					return err
+				default:
+					return nil
+				}
+			}
+			if err := send(e); err != nil {
+				return err
+			}
+		case err := <-errs:
+			return err
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
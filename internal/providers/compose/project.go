@@ -0,0 +1,760 @@
+package compose
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/ephlabs/eph/internal/config"
+)
+
+// Dependency conditions of depends_on.
+const (
+	ConditionStarted   = "service_started"
+	ConditionHealthy   = "service_healthy"
+	ConditionCompleted = "service_completed_successfully"
+)
+
+// defaultNetwork is the network services join when they list none.
+const defaultNetwork = "default"
+
+// Project is the merge of the compose files in docker-compose.compose_files,
+// reduced to what the provider deploys.
+type Project struct {
+	// Services are in start order: every service comes after the services
+	// it depends on, and otherwise in the order the compose files list them.
+	Services []*Service
+
+	Networks map[string]Resource
+	Volumes  map[string]Resource
+
+	// Exposed is the service environment URLs point at: the first one, in
+	// file order, that has ports and is not scaled to zero.
+	Exposed string
+}
+
+// Resource is a top-level network or volume. External resources are used as
+// they are, under Name; the others are created for each environment.
+type Resource struct {
+	External bool
+	Name     string
+}
+
+// Service is a compose service.
+type Service struct {
+	Name  string
+	Image string
+
+	// Command and Entrypoint are nil to keep the image's.
+	Command    []string
+	Entrypoint []string
+
+	Environment map[string]string
+	Labels      map[string]string
+	User        string
+	WorkingDir  string
+	Restart     string
+
+	Ports       []Port
+	Mounts      []Mount
+	Networks    map[string][]string // network name to aliases
+	DependsOn   map[string]string   // service name to condition
+	Healthcheck *Healthcheck
+
+	// Replicas is deploy.replicas, overridden by docker-compose.scale.
+	Replicas int
+}
+
+// Port is a container port. Host ports in the compose files are ignored:
+// each environment gets ports the Engine allocates, so that environments on
+// one host do not collide.
+type Port struct {
+	Target   int
+	Protocol string
+}
+
+func (p Port) String() string { return strconv.Itoa(p.Target) + "/" + p.Protocol }
+
+// Mount is a volume, bind or tmpfs mount. Source is a top-level volume name
+// or, for binds, an absolute path on the Docker host.
+type Mount struct {
+	Type     string
+	Source   string
+	Target   string
+	ReadOnly bool
+}
+
+// Healthcheck overrides the image's health check.
+type Healthcheck struct {
+	Test        []string
+	Interval    time.Duration
+	Timeout     time.Duration
+	StartPeriod time.Duration
+	Retries     int
+}
+
+// Load reads, interpolates and merges the compose files of cfg. Variables
+// are looked up in the process environment, then in
+// docker-compose.env_file, as with docker compose --env-file. Services must
+// name an image: build sections are not supported.
+func Load(cfg *config.Config) (*Project, error) {
+	if cfg.DockerCompose == nil {
+		return nil, errors.New("eph.yaml has no docker-compose section")
+	}
+	dir := projectDir(cfg)
+	vars := map[string]string{}
+	if f := cfg.DockerCompose.EnvFile; f != "" {
+		var err error
+		if vars, err = readEnvFile(resolve(dir, f)); err != nil {
+			return nil, fmt.Errorf("docker-compose.env_file: %w", err)
+		}
+	}
+	lookup := func(name string) (string, bool) {
+		if v, ok := os.LookupEnv(name); ok {
+			return v, true
+		}
+		v, ok := vars[name]
+		return v, ok
+	}
+
+	merged := map[string]any{}
+	var order []string
+	for _, file := range cfg.DockerCompose.ComposeFiles {
+		doc, names, err := readComposeFile(resolve(dir, file), lookup)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			if !slices.Contains(order, name) {
+				order = append(order, name)
+			}
+		}
+		merge(merged, doc)
+	}
+	return build(merged, order, dir, lookup, cfg.DockerCompose.Scale)
+}
+
+// projectDir is the directory compose files are relative to: the one
+// containing the base eph.yaml.
+func projectDir(cfg *config.Config) string {
+	if files := cfg.Files(); len(files) > 0 {
+		return filepath.Dir(files[0])
+	}
+	return ""
+}
+
+func resolve(dir, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
+
+// readEnvFile reads KEY=VALUE lines, skipping blank lines and comments.
+func readEnvFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	vars := map[string]string{}
+	s := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, value, ok := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected NAME=value", path, n)
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		vars[strings.TrimSpace(name)] = value
+	}
+	return vars, s.Err()
+}
+
+// readComposeFile decodes a compose file with its values interpolated, and
+// returns the names of its services in order.
+func readComposeFile(path string, lookup func(string) (string, bool)) (map[string]any, []string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := interpolate(&root, lookup); err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
+	}
+	doc := map[string]any{}
+	if err := root.Decode(&doc); err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	var names []string
+	if len(root.Content) > 0 {
+		top := root.Content[0]
+		for i := 0; i+1 < len(top.Content); i += 2 {
+			if top.Content[i].Value == "services" {
+				services := top.Content[i+1]
+				for j := 0; j+1 < len(services.Content); j += 2 {
+					names = append(names, services.Content[j].Value)
+				}
+			}
+		}
+	}
+
+	services, _ := doc["services"].(map[string]any)
+	for name, s := range services {
+		svc, ok := s.(map[string]any)
+		if !ok {
+			return nil, nil, fmt.Errorf("%s: service %s is not a mapping", path, name)
+		}
+		normalize(svc)
+	}
+	return doc, names, nil
+}
+
+// interpolate expands variables in scalar values, but not in keys. Plain
+// scalars are re-resolved afterwards, so that "${REPLICAS}" can be a number.
+func interpolate(n *yaml.Node, lookup func(string) (string, bool)) error {
+	switch n.Kind {
+	case yaml.ScalarNode:
+		if !strings.Contains(n.Value, "$") {
+			return nil
+		}
+		v, err := config.ExpandEnv(n.Value, config.InterpolateOptions{Lookup: lookup})
+		if err != nil {
+			return fmt.Errorf("line %d: %w", n.Line, err)
+		}
+		n.Value = v
+		if n.Style == 0 {
+			n.Tag = ""
+		}
+	case yaml.MappingNode:
+		for i := 1; i < len(n.Content); i += 2 {
+			if err := interpolate(n.Content[i], lookup); err != nil {
+				return err
+			}
+		}
+	default:
+		for _, c := range n.Content {
+			if err := interpolate(c, lookup); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// normalize converts the list forms of a service's fields to their mapping
+// forms, so that files can be merged key by key.
+func normalize(svc map[string]any) {
+	for _, field := range []string{"environment", "labels"} {
+		if list, ok := svc[field].([]any); ok {
+			m := map[string]any{}
+			for _, item := range list {
+				name, value, ok := strings.Cut(fmt.Sprint(item), "=")
+				if ok {
+					m[name] = value
+				} else {
+					m[name] = nil
+				}
+			}
+			svc[field] = m
+		}
+	}
+	if list, ok := svc["depends_on"].([]any); ok {
+		m := map[string]any{}
+		for _, item := range list {
+			m[fmt.Sprint(item)] = map[string]any{"condition": ConditionStarted}
+		}
+		svc["depends_on"] = m
+	}
+	if list, ok := svc["networks"].([]any); ok {
+		m := map[string]any{}
+		for _, item := range list {
+			m[fmt.Sprint(item)] = nil
+		}
+		svc["networks"] = m
+	}
+}
+
+// appended are the service fields whose lists later files add to rather
+// than replace.
+var appended = []string{"ports", "volumes"}
+
+// merge merges src into dst: mappings are merged recursively, the lists of
+// appended fields are concatenated and other values are replaced.
+func merge(dst, src map[string]any) {
+	for k, v := range src {
+		if sm, ok := v.(map[string]any); ok {
+			if dm, ok := dst[k].(map[string]any); ok {
+				merge(dm, sm)
+				continue
+			}
+		}
+		if sl, ok := v.([]any); ok && slices.Contains(appended, k) {
+			if dl, ok := dst[k].([]any); ok {
+				dst[k] = append(dl, sl...)
+				continue
+			}
+		}
+		dst[k] = v
+	}
+}
+
+type rawProject struct {
+	Services map[string]rawService  `yaml:"services"`
+	Networks map[string]rawResource `yaml:"networks"`
+	Volumes  map[string]rawResource `yaml:"volumes"`
+}
+
+type rawResource struct {
+	External bool   `yaml:"external"`
+	Name     string `yaml:"name"`
+}
+
+type rawService struct {
+	Image       string                    `yaml:"image"`
+	Build       any                       `yaml:"build"`
+	Command     any                       `yaml:"command"`
+	Entrypoint  any                       `yaml:"entrypoint"`
+	Environment map[string]any            `yaml:"environment"`
+	Labels      map[string]any            `yaml:"labels"`
+	User        string                    `yaml:"user"`
+	WorkingDir  string                    `yaml:"working_dir"`
+	Restart     string                    `yaml:"restart"`
+	Ports       []any                     `yaml:"ports"`
+	Volumes     []any                     `yaml:"volumes"`
+	Networks    map[string]*rawNetworkRef `yaml:"networks"`
+	DependsOn   map[string]rawDependency  `yaml:"depends_on"`
+	Healthcheck *rawHealthcheck           `yaml:"healthcheck"`
+	Deploy      struct {
+		Replicas *int `yaml:"replicas"`
+	} `yaml:"deploy"`
+}
+
+type rawNetworkRef struct {
+	Aliases []string `yaml:"aliases"`
+}
+
+type rawDependency struct {
+	Condition string `yaml:"condition"`
+}
+
+type rawHealthcheck struct {
+	Test        any    `yaml:"test"`
+	Interval    string `yaml:"interval"`
+	Timeout     string `yaml:"timeout"`
+	StartPeriod string `yaml:"start_period"`
+	Retries     int    `yaml:"retries"`
+	Disable     bool   `yaml:"disable"`
+}
+
+// build converts the merged document. It is re-encoded so that the typed
+// decoding of yaml.v3 does the conversions.
+func build(doc map[string]any, order []string, dir string, lookup func(string) (string, bool), scale map[string]int) (*Project, error) {
+	data, err := yaml.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	var raw rawProject
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("compose files: %w", err)
+	}
+	if len(raw.Services) == 0 {
+		return nil, errors.New("compose files define no services")
+	}
+
+	p := &Project{Networks: map[string]Resource{}, Volumes: map[string]Resource{}}
+	for name, r := range raw.Networks {
+		p.Networks[name] = Resource(r)
+	}
+	for name, r := range raw.Volumes {
+		p.Volumes[name] = Resource(r)
+	}
+
+	var errs []error
+	services := map[string]*Service{}
+	for _, name := range order {
+		svc, err := buildService(name, raw.Services[name], p, dir, lookup)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("service %s: %w", name, err))
+			continue
+		}
+		if n, ok := scale[name]; ok {
+			svc.Replicas = n
+		}
+		services[name] = svc
+		if p.Exposed == "" && len(svc.Ports) > 0 && svc.Replicas > 0 {
+			p.Exposed = name
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(scale)) {
+		if _, ok := raw.Services[name]; !ok {
+			errs = append(errs, fmt.Errorf("docker-compose.scale: no service %s in the compose files", name))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	if _, ok := p.Networks[defaultNetwork]; !ok {
+		p.Networks[defaultNetwork] = Resource{}
+	}
+
+	if p.Services, err = startOrder(order, services); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func buildService(name string, raw rawService, p *Project, dir string, lookup func(string) (string, bool)) (*Service, error) {
+	if raw.Build != nil {
+		return nil, errors.New("build is not supported: Eph deploys the pre-built image named by image")
+	}
+	if raw.Image == "" {
+		return nil, errors.New("image is required")
+	}
+	svc := &Service{
+		Name:        name,
+		Image:       raw.Image,
+		Environment: map[string]string{},
+		Labels:      map[string]string{},
+		User:        raw.User,
+		WorkingDir:  raw.WorkingDir,
+		Restart:     raw.Restart,
+		Networks:    map[string][]string{},
+		DependsOn:   map[string]string{},
+		Replicas:    1,
+	}
+	if raw.Deploy.Replicas != nil {
+		svc.Replicas = *raw.Deploy.Replicas
+	}
+
+	var err error
+	if svc.Command, err = commandLine(raw.Command); err != nil {
+		return nil, fmt.Errorf("command: %w", err)
+	}
+	if svc.Entrypoint, err = commandLine(raw.Entrypoint); err != nil {
+		return nil, fmt.Errorf("entrypoint: %w", err)
+	}
+	for k, v := range raw.Environment {
+		if v == nil {
+			// A bare name passes the variable through, if it is set.
+			if value, ok := lookup(k); ok {
+				svc.Environment[k] = value
+			}
+			continue
+		}
+		svc.Environment[k] = fmt.Sprint(v)
+	}
+	for k, v := range raw.Labels {
+		if v != nil {
+			svc.Labels[k] = fmt.Sprint(v)
+		} else {
+			svc.Labels[k] = ""
+		}
+	}
+
+	for _, item := range raw.Ports {
+		port, err := parsePort(item)
+		if err != nil {
+			return nil, fmt.Errorf("ports: %w", err)
+		}
+		if !slices.Contains(svc.Ports, port) {
+			svc.Ports = append(svc.Ports, port)
+		}
+	}
+	for _, item := range raw.Volumes {
+		m, err := parseMount(item, dir)
+		if err != nil {
+			return nil, fmt.Errorf("volumes: %w", err)
+		}
+		if m.Type == "volume" && m.Source != "" {
+			if _, ok := p.Volumes[m.Source]; !ok {
+				return nil, fmt.Errorf("volumes: volume %s is not declared in the top-level volumes", m.Source)
+			}
+		}
+		// A later mount of the same target replaces an earlier one.
+		svc.Mounts = slices.DeleteFunc(svc.Mounts, func(existing Mount) bool { return existing.Target == m.Target })
+		svc.Mounts = append(svc.Mounts, m)
+	}
+
+	for net, ref := range raw.Networks {
+		if _, ok := p.Networks[net]; !ok && net != defaultNetwork {
+			return nil, fmt.Errorf("networks: network %s is not declared in the top-level networks", net)
+		}
+		if ref != nil {
+			svc.Networks[net] = ref.Aliases
+		} else {
+			svc.Networks[net] = nil
+		}
+	}
+	if len(svc.Networks) == 0 {
+		svc.Networks[defaultNetwork] = nil
+	}
+
+	for dep, d := range raw.DependsOn {
+		switch d.Condition {
+		case "":
+			d.Condition = ConditionStarted
+		case ConditionStarted, ConditionHealthy, ConditionCompleted:
+		default:
+			return nil, fmt.Errorf("depends_on.%s: unknown condition %q", dep, d.Condition)
+		}
+		svc.DependsOn[dep] = d.Condition
+	}
+
+	if hc := raw.Healthcheck; hc != nil && !hc.Disable {
+		if svc.Healthcheck, err = healthcheck(hc); err != nil {
+			return nil, fmt.Errorf("healthcheck: %w", err)
+		}
+	} else if hc != nil {
+		svc.Healthcheck = &Healthcheck{Test: []string{"NONE"}}
+	}
+	return svc, nil
+}
+
+// commandLine converts a command given as a list, or as a string split as
+// a shell would.
+func commandLine(v any) ([]string, error) {
+	switch v := v.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return splitShell(v)
+	case []any:
+		out := make([]string, len(v))
+		for i, arg := range v {
+			out[i] = fmt.Sprint(arg)
+		}
+		return out, nil
+	default:
+		return nil, fmt.Errorf("expected a string or a list, got %T", v)
+	}
+}
+
+// splitShell splits s into words, honouring quotes and backslashes.
+func splitShell(s string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	var quote rune
+	escaped := false
+	for _, r := range s {
+		switch {
+		case escaped:
+			word.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped, inWord = true, true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote, inWord = r, true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 || escaped {
+		return nil, fmt.Errorf("unterminated quote in %q", s)
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+// parsePort reads the short syntax, [[ip:]host:]container[/protocol], or
+// the long syntax, keeping the container port.
+func parsePort(v any) (Port, error) {
+	port := Port{Protocol: "tcp"}
+	var spec string
+	switch v := v.(type) {
+	case int:
+		port.Target = v
+		return port, nil
+	case string:
+		spec = v
+	case map[string]any:
+		target, err := strconv.Atoi(fmt.Sprint(v["target"]))
+		if err != nil {
+			return port, fmt.Errorf("invalid target %v", v["target"])
+		}
+		port.Target = target
+		if proto, ok := v["protocol"].(string); ok {
+			port.Protocol = proto
+		}
+		return port, nil
+	default:
+		return port, fmt.Errorf("unsupported port %v", v)
+	}
+	spec, proto, ok := strings.Cut(spec, "/")
+	if ok {
+		port.Protocol = proto
+	}
+	if i := strings.LastIndex(spec, ":"); i >= 0 {
+		spec = spec[i+1:]
+	}
+	if strings.Contains(spec, "-") {
+		return port, fmt.Errorf("port ranges are not supported: %v", v)
+	}
+	target, err := strconv.Atoi(spec)
+	if err != nil {
+		return port, fmt.Errorf("invalid port %v", v)
+	}
+	port.Target = target
+	return port, nil
+}
+
+// parseMount reads the short syntax, [source:]target[:ro], or the long
+// syntax. Relative bind sources are resolved against dir.
+func parseMount(v any, dir string) (Mount, error) {
+	var m Mount
+	switch v := v.(type) {
+	case string:
+		parts := strings.Split(v, ":")
+		switch len(parts) {
+		case 1:
+			m.Target = parts[0]
+		case 2, 3:
+			m.Source, m.Target = parts[0], parts[1]
+			if len(parts) == 3 {
+				m.ReadOnly = slices.Contains(strings.Split(parts[2], ","), "ro")
+			}
+		default:
+			return m, fmt.Errorf("invalid volume %q", v)
+		}
+		m.Type = "volume"
+		if strings.HasPrefix(m.Source, ".") || strings.HasPrefix(m.Source, "/") || strings.HasPrefix(m.Source, "~") {
+			m.Type = "bind"
+		}
+	case map[string]any:
+		m.Type, _ = v["type"].(string)
+		m.Source, _ = v["source"].(string)
+		m.Target, _ = v["target"].(string)
+		m.ReadOnly, _ = v["read_only"].(bool)
+		if m.Type == "" {
+			m.Type = "volume"
+		}
+	default:
+		return m, fmt.Errorf("unsupported volume %v", v)
+	}
+	if m.Target == "" {
+		return m, fmt.Errorf("volume %v has no target", v)
+	}
+	switch m.Type {
+	case "bind":
+		if strings.HasPrefix(m.Source, "~") {
+			return m, fmt.Errorf("bind source %s: home directories are not supported", m.Source)
+		}
+		m.Source = resolve(dir, m.Source)
+	case "volume", "tmpfs":
+	default:
+		return m, fmt.Errorf("unsupported volume type %q", m.Type)
+	}
+	return m, nil
+}
+
+func healthcheck(raw *rawHealthcheck) (*Healthcheck, error) {
+	hc := &Healthcheck{Retries: raw.Retries}
+	switch test := raw.Test.(type) {
+	case nil:
+	case string:
+		hc.Test = []string{"CMD-SHELL", test}
+	case []any:
+		for _, arg := range test {
+			hc.Test = append(hc.Test, fmt.Sprint(arg))
+		}
+	default:
+		return nil, fmt.Errorf("test: expected a string or a list, got %T", test)
+	}
+	for _, d := range []struct {
+		field string
+		value string
+		dst   *time.Duration
+	}{
+		{"interval", raw.Interval, &hc.Interval},
+		{"timeout", raw.Timeout, &hc.Timeout},
+		{"start_period", raw.StartPeriod, &hc.StartPeriod},
+	} {
+		if d.value == "" {
+			continue
+		}
+		v, err := time.ParseDuration(d.value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", d.field, err)
+		}
+		*d.dst = v
+	}
+	return hc, nil
+}
+
+// startOrder sorts services so that each comes after its dependencies,
+// keeping the files' order otherwise.
+func startOrder(order []string, services map[string]*Service) ([]*Service, error) {
+	for _, name := range order {
+		for dep := range services[name].DependsOn {
+			if _, ok := services[dep]; !ok {
+				return nil, fmt.Errorf("service %s depends on undefined service %s", name, dep)
+			}
+		}
+	}
+	var out []*Service
+	done := map[string]bool{}
+	for len(out) < len(order) {
+		progressed := false
+		for _, name := range order {
+			if done[name] {
+				continue
+			}
+			ready := true
+			for dep := range services[name].DependsOn {
+				ready = ready && done[dep]
+			}
+			if ready {
+				out = append(out, services[name])
+				done[name] = true
+				progressed = true
+			}
+		}
+		if !progressed {
+			var cycle []string
+			for _, name := range order {
+				if !done[name] {
+					cycle = append(cycle, name)
+				}
+			}
+			sort.Strings(cycle)
+			return nil, fmt.Errorf("services %s depend on each other", strings.Join(cycle, ", "))
+		}
+	}
+	return out, nil
+}
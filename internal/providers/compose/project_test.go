@@ -0,0 +1,204 @@
+package compose
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ephlabs/eph/internal/config"
+)
+
+const baseCompose = `services:
+  web:
+    image: ghcr.io/org/web:latest
+    command: ./server --port 80 "--name=preview env"
+    environment:
+      - LOG_LEVEL=info
+      - PASSED_THROUGH
+    ports: ["8080:80"]
+    depends_on:
+      migrate:
+        condition: service_completed_successfully
+  migrate:
+    image: ghcr.io/org/web:latest
+    command: [./migrate, up]
+    depends_on:
+      db:
+        condition: service_healthy
+  db:
+    image: postgres:${POSTGRES_VERSION:-16}
+    volumes:
+      - data:/var/lib/postgresql/data
+      - ./init:/docker-entrypoint-initdb.d:ro
+    healthcheck:
+      test: pg_isready -U postgres
+      interval: 2s
+      retries: 5
+volumes:
+  data:
+`
+
+const previewCompose = `services:
+  web:
+    environment:
+      LOG_LEVEL: debug
+    ports:
+      - target: 9090
+    deploy:
+      replicas: ${WEB_REPLICAS}
+  db:
+    image: postgres:${POSTGRES_VERSION}-alpine
+`
+
+// project writes eph.yaml and files into a temporary directory and parses
+// eph.yaml from there.
+func project(t *testing.T, ephYAML string, files map[string]string) *config.Config {
+	t.Helper()
+	dir := t.TempDir()
+	for name, data := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(data), 0o600))
+	}
+	cfg, err := config.Parse(filepath.Join(dir, "eph.yaml"), []byte(ephYAML))
+	require.NoError(t, err)
+	return cfg
+}
+
+func TestLoad(t *testing.T) {
+	t.Setenv("PASSED_THROUGH", "yes")
+	cfg := project(t, `version: "1.0"
+name: myapp
+docker-compose:
+  compose_files: [docker-compose.yml, docker-compose.preview.yml]
+  env_file: .env.preview
+`, map[string]string{
+		"docker-compose.yml":         baseCompose,
+		"docker-compose.preview.yml": previewCompose,
+		".env.preview":               "# preview settings\nPOSTGRES_VERSION=15\nexport WEB_REPLICAS='2'\n",
+	})
+
+	p, err := Load(cfg)
+	require.NoError(t, err)
+	var order []string
+	for _, svc := range p.Services {
+		order = append(order, svc.Name)
+	}
+	assert.Equal(t, []string{"db", "migrate", "web"}, order, "dependencies start first")
+	assert.Equal(t, "web", p.Exposed)
+
+	db := p.service("db")
+	assert.Equal(t, "postgres:15-alpine", db.Image, "later files override, with variables from the env file")
+	assert.Equal(t, []Mount{
+		{Type: "volume", Source: "data", Target: "/var/lib/postgresql/data"},
+		{Type: "bind", Source: filepath.Join(filepath.Dir(cfg.Files()[0]), "init"), Target: "/docker-entrypoint-initdb.d", ReadOnly: true},
+	}, db.Mounts)
+	assert.Equal(t, &Healthcheck{Test: []string{"CMD-SHELL", "pg_isready -U postgres"}, Interval: 2 * time.Second, Retries: 5}, db.Healthcheck)
+	assert.Equal(t, map[string][]string{"default": nil}, db.Networks)
+
+	web := p.service("web")
+	assert.Equal(t, []string{"./server", "--port", "80", "--name=preview env"}, web.Command)
+	assert.Equal(t, map[string]string{"LOG_LEVEL": "debug", "PASSED_THROUGH": "yes"}, web.Environment)
+	assert.Equal(t, []Port{{Target: 80, Protocol: "tcp"}, {Target: 9090, Protocol: "tcp"}}, web.Ports, "ports are appended")
+	assert.Equal(t, 2, web.Replicas)
+	assert.Equal(t, map[string]string{"migrate": ConditionCompleted}, web.DependsOn)
+
+	assert.Equal(t, []string{"./migrate", "up"}, p.service("migrate").Command)
+	assert.Equal(t, map[string]Resource{"data": {}}, p.Volumes)
+}
+
+func TestLoadScale(t *testing.T) {
+	cfg := project(t, `version: "1.0"
+name: myapp
+docker-compose:
+  compose_files: [docker-compose.yml]
+  scale:
+    web: 3
+`, map[string]string{"docker-compose.yml": baseCompose})
+	p, err := Load(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, 3, p.service("web").Replicas)
+	assert.Equal(t, 1, p.service("db").Replicas)
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		compose string
+		scale   string
+		wantErr string
+	}{
+		{
+			name:    "build only",
+			compose: "services:\n  web:\n    build: .\n",
+			wantErr: "service web: build is not supported",
+		},
+		{
+			name:    "build with image",
+			compose: "services:\n  web:\n    build: .\n    image: ghcr.io/org/web:latest\n",
+			wantErr: "service web: build is not supported",
+		},
+		{
+			name:    "no image",
+			compose: "services:\n  web:\n    command: [serve]\n",
+			wantErr: "service web: image is required",
+		},
+		{
+			name:    "undefined dependency",
+			compose: "services:\n  web:\n    image: nginx\n    depends_on: [db]\n",
+			wantErr: "service web depends on undefined service db",
+		},
+		{
+			name:    "cycle",
+			compose: "services:\n  a:\n    image: nginx\n    depends_on: [b]\n  b:\n    image: nginx\n    depends_on: [a]\n  c:\n    image: nginx\n",
+			wantErr: "services a, b depend on each other",
+		},
+		{
+			name:    "unknown condition",
+			compose: "services:\n  web:\n    image: nginx\n    depends_on:\n      db:\n        condition: service_ready\n  db:\n    image: postgres\n",
+			wantErr: `depends_on.db: unknown condition "service_ready"`,
+		},
+		{
+			name:    "undeclared volume",
+			compose: "services:\n  db:\n    image: postgres\n    volumes: [data:/data]\n",
+			wantErr: "volume data is not declared",
+		},
+		{
+			name:    "port range",
+			compose: "services:\n  web:\n    image: nginx\n    ports: [\"8000-8005:8000-8005\"]\n",
+			wantErr: "port ranges are not supported",
+		},
+		{
+			name:    "unterminated quote",
+			compose: "services:\n  web:\n    image: nginx\n    command: echo \"hello\n",
+			wantErr: "command: unterminated quote",
+		},
+		{
+			name:    "scaled service missing",
+			compose: "services:\n  web:\n    image: nginx\n",
+			scale:   "  scale:\n    api: 2\n",
+			wantErr: "docker-compose.scale: no service api",
+		},
+		{
+			name:    "scaled services missing",
+			compose: "services:\n  web:\n    image: nginx\n",
+			scale:   "  scale:\n    worker: 2\n    api: 2\n    cron: 1\n",
+			wantErr: "no service api in the compose files\ndocker-compose.scale: no service cron in the compose files\ndocker-compose.scale: no service worker",
+		},
+		{
+			name:    "no services",
+			compose: "volumes:\n  data:\n",
+			wantErr: "compose files define no services",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := project(t, "version: \"1.0\"\nname: myapp\ndocker-compose:\n  compose_files: [docker-compose.yml]\n"+tt.scale,
+				map[string]string{"docker-compose.yml": tt.compose})
+			_, err := Load(cfg)
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
@@ -0,0 +1,743 @@
+// Package compose implements the Docker Compose provider. Every environment
+// is a compose project of its own on a Docker host, deployed from the
+// project's compose files through the Docker Engine API with the
+// environment's images.
+package compose
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/url"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ephlabs/eph/internal/config"
+	"github.com/ephlabs/eph/internal/providers"
+)
+
+// Name is the provider's name in eph.yaml.
+const Name = "docker-compose"
+
+// Labels on Eph-managed containers, networks and volumes.
+const (
+	LabelManaged     = "eph.io/managed"
+	LabelProject     = "eph.io/project"
+	LabelEnvironment = "eph.io/environment"
+	LabelKey         = "eph.io/key"
+	LabelCommit      = "eph.io/commit"
+
+	// LabelConfigHash identifies the configuration a container was created
+	// with; a container whose configuration changed is recreated.
+	LabelConfigHash = "eph.io/config-hash"
+
+	// LabelContainers is the number of containers the environment has once
+	// every service is up.
+	LabelContainers = "eph.io/containers"
+
+	// LabelURLPort marks the containers of the service the environment's
+	// URL points at, with the container port to use, e.g. "80/tcp".
+	LabelURLPort = "eph.io/url-port"
+)
+
+// The labels docker compose uses, so that `docker compose -p <environment>`
+// works on environments too.
+const (
+	labelComposeProject = "com.docker.compose.project"
+	labelComposeService = "com.docker.compose.service"
+	labelComposeNumber  = "com.docker.compose.container-number"
+	labelComposeOneoff  = "com.docker.compose.oneoff"
+)
+
+func init() {
+	providers.Register(Name, func(cfg *config.Config) (providers.Provider, error) {
+		return New(cfg, Options{
+			Host:       os.Getenv("DOCKER_HOST"),
+			PublicHost: os.Getenv("EPH_DOCKER_PUBLIC_HOST"),
+		})
+	})
+}
+
+// Options configures a Provider.
+type Options struct {
+	// Host is the Docker Engine address, as in DOCKER_HOST. It defaults to
+	// DefaultHost.
+	Host string
+
+	// PublicHost is the host name environment URLs use to reach published
+	// ports. It defaults to the host of a tcp:// Host, or localhost.
+	PublicHost string
+}
+
+// Provider deploys environments as compose projects. It is stateless: the
+// environment's key and commit are labels on its containers and networks.
+type Provider struct {
+	cfg    *config.Config
+	opts   Options
+	docker *docker
+}
+
+// New returns a Provider for the Docker Engine at opts.Host.
+func New(cfg *config.Config, opts Options) (*Provider, error) {
+	if cfg.DockerCompose == nil {
+		return nil, errors.New("eph.yaml has no docker-compose section")
+	}
+	d, err := newDocker(opts.Host)
+	if err != nil {
+		return nil, err
+	}
+	if opts.PublicHost == "" {
+		opts.PublicHost = "localhost"
+		if u, err := url.Parse(opts.Host); err == nil && u.Scheme == "tcp" && u.Hostname() != "" {
+			opts.PublicHost = u.Hostname()
+		}
+	}
+	return &Provider{cfg: cfg, opts: opts, docker: d}, nil
+}
+
+// Name implements providers.Provider.
+func (p *Provider) Name() string { return Name }
+
+// CheckAccess verifies that the Engine is reachable and that the compose
+// files can be deployed.
+func (p *Provider) CheckAccess(ctx context.Context) error {
+	if err := p.docker.ping(ctx); err != nil {
+		return fmt.Errorf("contacting the Docker Engine: %w", err)
+	}
+	_, err := Load(p.cfg)
+	return err
+}
+
+// List implements providers.Provider. An environment exists as long as any
+// of its containers or networks do.
+func (p *Provider) List(ctx context.Context) ([]providers.Instance, error) {
+	selector := map[string]string{LabelManaged: "true", LabelProject: p.cfg.Name}
+	containers, err := p.docker.listContainers(ctx, selector)
+	if err != nil {
+		return nil, err
+	}
+	networks, err := p.docker.listNetworks(ctx, selector)
+	if err != nil {
+		return nil, err
+	}
+
+	names := map[string]string{}
+	byKey := map[string][]*container{}
+	for _, n := range networks {
+		if key := n.Labels[LabelKey]; key != "" {
+			names[key] = n.Labels[LabelEnvironment]
+		}
+	}
+	for _, c := range containers {
+		if key := c.Config.Labels[LabelKey]; key != "" {
+			names[key] = c.Config.Labels[LabelEnvironment]
+			byKey[key] = append(byKey[key], c)
+		}
+	}
+
+	keys := slices.Sorted(maps.Keys(names))
+	instances := make([]providers.Instance, 0, len(keys))
+	for _, key := range keys {
+		inst := p.instance(key, names[key], byKey[key])
+		instances = append(instances, inst)
+	}
+	return instances, nil
+}
+
+// instance reports an environment from its containers: ready once all of
+// them are, and running the commit they all carry.
+func (p *Provider) instance(key, name string, containers []*container) providers.Instance {
+	inst := providers.Instance{Key: key, Name: name}
+	commits := map[string]bool{}
+	for _, c := range containers {
+		commits[c.Config.Labels[LabelCommit]] = true
+	}
+	if len(commits) == 1 {
+		for commit := range commits {
+			inst.CommitSHA = commit
+		}
+	}
+	inst.URL = p.url(containers)
+	st := assess(containers)
+	switch {
+	case st.failed != "":
+		inst.Message = st.failed
+	case len(st.pending) > 0:
+		inst.Message = strings.Join(st.pending, "; ")
+	case len(commits) > 1:
+		inst.Message = "updating"
+	default:
+		inst.Ready = true
+	}
+	return inst
+}
+
+// url points at the published port of the first container labelled with
+// LabelURLPort.
+func (p *Provider) url(containers []*container) string {
+	sorted := slices.Clone(containers)
+	slices.SortFunc(sorted, func(a, b *container) int { return strings.Compare(a.Name, b.Name) })
+	for _, c := range sorted {
+		port := c.Config.Labels[LabelURLPort]
+		if port == "" {
+			continue
+		}
+		for _, b := range c.NetworkSettings.Ports[port] {
+			if b.HostPort != "" {
+				return "http://" + p.opts.PublicHost + ":" + b.HostPort
+			}
+		}
+	}
+	return ""
+}
+
+// status summarises the containers of an environment.
+type status struct {
+	// pending lists why the environment is not ready yet, and failed why it
+	// will not become ready.
+	pending []string
+	failed  string
+}
+
+func assess(containers []*container) status {
+	var st status
+	expected := 0
+	sorted := slices.Clone(containers)
+	slices.SortFunc(sorted, func(a, b *container) int { return strings.Compare(a.Name, b.Name) })
+	for _, c := range sorted {
+		if n, err := strconv.Atoi(c.Config.Labels[LabelContainers]); err == nil {
+			expected = max(expected, n)
+		}
+		switch {
+		case c.State.Restarting:
+			st.pending = append(st.pending, c.Name+" is restarting")
+		case c.State.Running:
+			switch c.health() {
+			case "starting":
+				st.pending = append(st.pending, c.Name+" is starting")
+			case "unhealthy":
+				st.pending = append(st.pending, c.Name+" is unhealthy")
+			}
+		case c.State.Status == "exited" && c.State.ExitCode == 0:
+			// A one-off task that completed.
+		case c.State.Status == "exited" || c.State.Status == "dead":
+			if st.failed == "" {
+				st.failed = fmt.Sprintf("%s exited with code %d", c.Name, c.State.ExitCode)
+			}
+		default:
+			st.pending = append(st.pending, c.Name+" is "+c.State.Status)
+		}
+	}
+	if len(containers) < expected {
+		st.pending = append(st.pending, fmt.Sprintf("%d of %d containers created", len(containers), expected))
+	} else if len(containers) == 0 {
+		st.pending = append(st.pending, "no containers")
+	}
+	return st
+}
+
+// Apply converges the environment's compose project towards the compose
+// files: containers whose configuration changed are recreated, and missing
+// ones are created once the services they depend on satisfy their
+// depends_on conditions. Apply does not wait for health checks; ephd
+// re-applies environments until they are ready, which starts the services
+// that were waiting.
+func (p *Provider) Apply(ctx context.Context, spec providers.Spec) (providers.Instance, error) {
+	inst := providers.Instance{Key: spec.Key, Name: spec.Name, CommitSHA: spec.CommitSHA}
+	project, err := Load(p.cfg)
+	if err != nil {
+		return inst, err
+	}
+	d := newDeployment(p.cfg, project, spec)
+	if err := p.createResources(ctx, d); err != nil {
+		return inst, err
+	}
+
+	existing, err := p.docker.listContainers(ctx, map[string]string{LabelManaged: "true", LabelKey: spec.Key})
+	if err != nil {
+		return inst, err
+	}
+	leftover := map[string]*container{}
+	for _, c := range existing {
+		leftover[c.Name] = c
+	}
+
+	var waiting []string
+	current := map[string][]*container{}
+	waitingServices := map[string]bool{}
+	for _, svc := range project.Services {
+		reason, err := dependenciesMet(svc, project, current)
+		if err != nil {
+			return inst, fmt.Errorf("service %s: %w", svc.Name, err)
+		}
+		if reason != "" {
+			waiting = append(waiting, svc.Name+" is waiting for "+reason)
+			waitingServices[svc.Name] = true
+			continue
+		}
+		for i := 1; i <= svc.Replicas; i++ {
+			want := d.container(svc, i)
+			c, err := p.converge(ctx, leftover[want.name], want)
+			if err != nil {
+				return inst, err
+			}
+			delete(leftover, want.name)
+			current[svc.Name] = append(current[svc.Name], c)
+		}
+	}
+
+	// Containers of services that were scaled down or removed from the
+	// compose files go; those of waiting services stay until replaced.
+	for _, c := range leftover {
+		if waitingServices[c.Config.Labels[labelComposeService]] {
+			current[c.Config.Labels[labelComposeService]] = append(current[c.Config.Labels[labelComposeService]], c)
+			continue
+		}
+		if err := p.docker.removeContainer(ctx, c.ID); err != nil {
+			return inst, fmt.Errorf("removing container %s: %w", c.Name, err)
+		}
+	}
+
+	var all []*container
+	for _, cs := range current {
+		all = append(all, cs...)
+	}
+	inst.URL = p.url(all)
+	st := assess(all)
+	if st.failed != "" {
+		return inst, errors.New(st.failed)
+	}
+	pending := append(waiting, st.pending...)
+	if len(waiting) > 0 {
+		// The containers not created yet are already explained.
+		pending = slices.DeleteFunc(pending, func(s string) bool { return strings.HasSuffix(s, "containers created") })
+	}
+	inst.Ready = len(pending) == 0
+	inst.Message = strings.Join(pending, "; ")
+	return inst, nil
+}
+
+// converge returns a running container for want, reusing c if it was
+// created with the same configuration.
+func (p *Provider) converge(ctx context.Context, c *container, want desired) (*container, error) {
+	if c != nil && c.Config.Labels[LabelConfigHash] == want.hash {
+		if c.State.Status != "created" {
+			return c, nil
+		}
+		if err := p.docker.startContainer(ctx, c.ID); err != nil {
+			return nil, fmt.Errorf("starting container %s: %w", want.name, err)
+		}
+		return p.docker.inspectContainer(ctx, c.ID)
+	}
+	if c != nil {
+		if err := p.docker.removeContainer(ctx, c.ID); err != nil {
+			return nil, fmt.Errorf("removing container %s: %w", c.Name, err)
+		}
+	}
+	if err := p.docker.pullImage(ctx, want.spec.Image); err != nil {
+		return nil, err
+	}
+	id, err := p.docker.createContainer(ctx, want.name, &want.spec)
+	if err != nil {
+		return nil, fmt.Errorf("creating container %s: %w", want.name, err)
+	}
+	for _, n := range want.networks {
+		if err := p.docker.connectNetwork(ctx, n.name, id, n.aliases); err != nil {
+			return nil, fmt.Errorf("connecting container %s to %s: %w", want.name, n.name, err)
+		}
+	}
+	if err := p.docker.startContainer(ctx, id); err != nil {
+		return nil, fmt.Errorf("starting container %s: %w", want.name, err)
+	}
+	return p.docker.inspectContainer(ctx, id)
+}
+
+// dependenciesMet reports what svc is waiting for, if anything, given the
+// containers of the services started before it. Dependencies that will not
+// become satisfied are errors.
+func dependenciesMet(svc *Service, project *Project, current map[string][]*container) (string, error) {
+	for _, dep := range slices.Sorted(maps.Keys(svc.DependsOn)) {
+		if project.service(dep).Replicas == 0 {
+			continue
+		}
+		condition := svc.DependsOn[dep]
+		containers := current[dep]
+		if len(containers) == 0 {
+			return dep, nil
+		}
+		for _, c := range containers {
+			exited := c.State.Status == "exited" || c.State.Status == "dead"
+			switch condition {
+			case ConditionStarted:
+				if c.State.Status == "created" {
+					return dep + " to start", nil
+				}
+			case ConditionHealthy:
+				switch {
+				case exited:
+					return "", fmt.Errorf("dependency %s exited with code %d", c.Name, c.State.ExitCode)
+				case c.health() == "":
+					return "", fmt.Errorf("depends on %s being healthy, but it has no health check", dep)
+				case c.health() == "unhealthy":
+					return "", fmt.Errorf("dependency %s is unhealthy", c.Name)
+				case c.health() != "healthy":
+					return dep + " to be healthy", nil
+				}
+			case ConditionCompleted:
+				switch {
+				case exited && c.State.ExitCode != 0:
+					return "", fmt.Errorf("dependency %s exited with code %d", c.Name, c.State.ExitCode)
+				case !exited:
+					return dep + " to complete", nil
+				}
+			}
+		}
+	}
+	return "", nil
+}
+
+func (p *Project) service(name string) *Service {
+	for _, svc := range p.Services {
+		if svc.Name == name {
+			return svc
+		}
+	}
+	return nil
+}
+
+// createResources creates the networks and volumes of the environment that
+// do not exist yet.
+func (p *Provider) createResources(ctx context.Context, d *deployment) error {
+	selector := map[string]string{LabelManaged: "true", LabelKey: d.spec.Key}
+	networks, err := p.docker.listNetworks(ctx, selector)
+	if err != nil {
+		return err
+	}
+	for _, name := range d.networks() {
+		if !slices.ContainsFunc(networks, func(o object) bool { return o.Name == name }) {
+			if err := p.docker.createNetwork(ctx, name, d.labels); err != nil {
+				return fmt.Errorf("creating network %s: %w", name, err)
+			}
+		}
+	}
+	volumes, err := p.docker.listVolumes(ctx, selector)
+	if err != nil {
+		return err
+	}
+	for _, name := range d.volumes() {
+		if !slices.ContainsFunc(volumes, func(o object) bool { return o.Name == name }) {
+			if err := p.docker.createVolume(ctx, name, d.labels); err != nil {
+				return fmt.Errorf("creating volume %s: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Destroy removes the environment's containers, networks and volumes.
+func (p *Provider) Destroy(ctx context.Context, inst providers.Instance) error {
+	selector := map[string]string{LabelManaged: "true", LabelKey: inst.Key}
+	containers, err := p.docker.listContainers(ctx, selector)
+	if err != nil {
+		return err
+	}
+	for _, c := range containers {
+		if err := p.docker.removeContainer(ctx, c.ID); err != nil {
+			return fmt.Errorf("removing container %s: %w", c.Name, err)
+		}
+	}
+	networks, err := p.docker.listNetworks(ctx, selector)
+	if err != nil {
+		return err
+	}
+	for _, n := range networks {
+		if err := p.docker.removeNetwork(ctx, n.ID); err != nil {
+			return fmt.Errorf("removing network %s: %w", n.Name, err)
+		}
+	}
+	volumes, err := p.docker.listVolumes(ctx, selector)
+	if err != nil {
+		return err
+	}
+	for _, v := range volumes {
+		if err := p.docker.removeVolume(ctx, v.Name); err != nil {
+			return fmt.Errorf("removing volume %s: %w", v.Name, err)
+		}
+	}
+	return nil
+}
+
+// deployment is a compose project rendered for one environment.
+type deployment struct {
+	cfg     *config.Config
+	project *Project
+	spec    providers.Spec
+
+	// labels go on every container, network and volume.
+	labels map[string]string
+
+	// images maps the repositories of environment.images to the references
+	// resolved for the environment.
+	images map[string]string
+
+	// exposed is the service the environment's URL points at: the first
+	// one with ports, in file order.
+	exposed string
+}
+
+func newDeployment(cfg *config.Config, project *Project, spec providers.Spec) *deployment {
+	d := &deployment{
+		cfg:     cfg,
+		project: project,
+		spec:    spec,
+		labels: map[string]string{
+			LabelManaged:        "true",
+			LabelProject:        cfg.Name,
+			LabelEnvironment:    spec.Name,
+			LabelKey:            spec.Key,
+			labelComposeProject: spec.Name,
+		},
+		images:  map[string]string{},
+		exposed: project.Exposed,
+	}
+	for _, img := range cfg.Environment.Images {
+		if ref, ok := spec.Images[img.Name]; ok && img.Repository != "" {
+			d.images[img.Repository] = ref
+		}
+	}
+	return d
+}
+
+// networkName is the name of a top-level network for the environment.
+func (d *deployment) networkName(name string) string {
+	if r := d.project.Networks[name]; r.External {
+		if r.Name != "" {
+			return r.Name
+		}
+		return name
+	}
+	return d.spec.Name + "_" + name
+}
+
+func (d *deployment) volumeName(name string) string {
+	if r := d.project.Volumes[name]; r.External {
+		if r.Name != "" {
+			return r.Name
+		}
+		return name
+	}
+	return d.spec.Name + "_" + name
+}
+
+// networks are the non-external networks services join.
+func (d *deployment) networks() []string {
+	var out []string
+	for _, svc := range d.project.Services {
+		for name := range svc.Networks {
+			if n := d.networkName(name); !d.project.Networks[name].External && !slices.Contains(out, n) {
+				out = append(out, n)
+			}
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// volumes are the non-external top-level volumes.
+func (d *deployment) volumes() []string {
+	var out []string
+	for name, r := range d.project.Volumes {
+		if !r.External {
+			out = append(out, d.volumeName(name))
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// desired is a container as the environment needs it.
+type desired struct {
+	name string
+	spec containerSpec
+	hash string
+
+	// networks are joined after creation: the Engine only accepts one
+	// network when creating a container.
+	networks []networkRef
+}
+
+type networkRef struct {
+	name    string
+	aliases []string
+}
+
+// containerSpec is the body of a container creation request.
+type containerSpec struct {
+	Image            string              `json:"Image"`
+	Cmd              []string            `json:"Cmd,omitempty"`
+	Entrypoint       []string            `json:"Entrypoint,omitempty"`
+	Env              []string            `json:"Env,omitempty"`
+	Labels           map[string]string   `json:"Labels"`
+	User             string              `json:"User,omitempty"`
+	WorkingDir       string              `json:"WorkingDir,omitempty"`
+	ExposedPorts     map[string]struct{} `json:"ExposedPorts,omitempty"`
+	Healthcheck      *healthConfig       `json:"Healthcheck,omitempty"`
+	HostConfig       hostConfig          `json:"HostConfig"`
+	NetworkingConfig networkingConfig    `json:"NetworkingConfig"`
+}
+
+type healthConfig struct {
+	Test        []string `json:"Test"`
+	Interval    int64    `json:"Interval,omitempty"`
+	Timeout     int64    `json:"Timeout,omitempty"`
+	StartPeriod int64    `json:"StartPeriod,omitempty"`
+	Retries     int      `json:"Retries,omitempty"`
+}
+
+type hostConfig struct {
+	NetworkMode   string                   `json:"NetworkMode"`
+	PortBindings  map[string][]portBinding `json:"PortBindings,omitempty"`
+	Mounts        []mountSpec              `json:"Mounts,omitempty"`
+	RestartPolicy restartPolicy            `json:"RestartPolicy"`
+}
+
+type mountSpec struct {
+	Type     string `json:"Type"`
+	Source   string `json:"Source,omitempty"`
+	Target   string `json:"Target"`
+	ReadOnly bool   `json:"ReadOnly,omitempty"`
+}
+
+type restartPolicy struct {
+	Name              string `json:"Name"`
+	MaximumRetryCount int    `json:"MaximumRetryCount,omitempty"`
+}
+
+type networkingConfig struct {
+	EndpointsConfig map[string]endpointSpec `json:"EndpointsConfig"`
+}
+
+type endpointSpec struct {
+	Aliases []string `json:"Aliases,omitempty"`
+}
+
+// total is the number of containers the environment has once every service
+// is up.
+func (d *deployment) total() int {
+	n := 0
+	for _, svc := range d.project.Services {
+		n += svc.Replicas
+	}
+	return n
+}
+
+// container renders replica number i of svc.
+func (d *deployment) container(svc *Service, i int) desired {
+	want := desired{name: fmt.Sprintf("%s-%s-%d", d.spec.Name, svc.Name, i)}
+	s := containerSpec{
+		Image:      d.image(svc.Image),
+		Cmd:        svc.Command,
+		Entrypoint: svc.Entrypoint,
+		User:       svc.User,
+		WorkingDir: svc.WorkingDir,
+		Labels:     maps.Clone(svc.Labels),
+	}
+	maps.Copy(s.Labels, d.labels)
+	s.Labels[LabelCommit] = d.spec.CommitSHA
+	s.Labels[LabelContainers] = strconv.Itoa(d.total())
+	s.Labels[labelComposeService] = svc.Name
+	s.Labels[labelComposeNumber] = strconv.Itoa(i)
+	s.Labels[labelComposeOneoff] = "False"
+	if svc.Name == d.exposed {
+		s.Labels[LabelURLPort] = svc.Ports[0].String()
+	}
+
+	env := maps.Clone(svc.Environment)
+	maps.Copy(env, d.spec.Env)
+	for _, name := range slices.Sorted(maps.Keys(env)) {
+		s.Env = append(s.Env, name+"="+env[name])
+	}
+
+	if len(svc.Ports) > 0 {
+		s.ExposedPorts = map[string]struct{}{}
+		s.HostConfig.PortBindings = map[string][]portBinding{}
+		for _, port := range svc.Ports {
+			s.ExposedPorts[port.String()] = struct{}{}
+			s.HostConfig.PortBindings[port.String()] = []portBinding{{}}
+		}
+	}
+	for _, m := range svc.Mounts {
+		source := m.Source
+		if m.Type == "volume" && source != "" {
+			source = d.volumeName(source)
+		}
+		s.HostConfig.Mounts = append(s.HostConfig.Mounts, mountSpec{Type: m.Type, Source: source, Target: m.Target, ReadOnly: m.ReadOnly})
+	}
+	if hc := svc.Healthcheck; hc != nil {
+		s.Healthcheck = &healthConfig{
+			Test:        hc.Test,
+			Interval:    hc.Interval.Nanoseconds(),
+			Timeout:     hc.Timeout.Nanoseconds(),
+			StartPeriod: hc.StartPeriod.Nanoseconds(),
+			Retries:     hc.Retries,
+		}
+	}
+	s.HostConfig.RestartPolicy = restart(svc.Restart)
+
+	for i, name := range slices.Sorted(maps.Keys(svc.Networks)) {
+		ref := networkRef{name: d.networkName(name), aliases: append([]string{svc.Name}, svc.Networks[name]...)}
+		if i == 0 {
+			s.HostConfig.NetworkMode = ref.name
+			s.NetworkingConfig.EndpointsConfig = map[string]endpointSpec{ref.name: {Aliases: ref.aliases}}
+		} else {
+			want.networks = append(want.networks, ref)
+		}
+	}
+
+	hashed := struct {
+		Spec     containerSpec
+		Networks map[string][]string
+	}{Spec: s, Networks: map[string][]string{}}
+	for _, n := range want.networks {
+		hashed.Networks[n.name] = n.aliases
+	}
+	data, _ := json.Marshal(hashed)
+	sum := sha256.Sum256(data)
+	want.hash = hex.EncodeToString(sum[:8])
+	s.Labels[LabelConfigHash] = want.hash
+	want.spec = s
+	return want
+}
+
+// image replaces images from the repositories of environment.images with
+// the references resolved for the environment.
+func (d *deployment) image(image string) string {
+	name := image
+	if i := strings.Index(name, "@"); i >= 0 {
+		name = name[:i]
+	}
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name = name[:i]
+	}
+	if ref, ok := d.images[name]; ok {
+		return ref
+	}
+	return image
+}
+
+// restart converts a compose restart policy, such as "on-failure:3".
+func restart(policy string) restartPolicy {
+	name, retries, _ := strings.Cut(policy, ":")
+	if name == "" {
+		name = "no"
+	}
+	n, _ := strconv.Atoi(retries)
+	return restartPolicy{Name: name, MaximumRetryCount: n}
+}
@@ -0,0 +1,294 @@
+package compose
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ephlabs/eph/internal/providers"
+)
+
+const ephYAML = `version: "1.0"
+name: myapp
+providers:
+  primary: docker-compose
+environment:
+  env:
+    EPH_PREVIEW: "true"
+  images:
+    - name: web
+      repository: ghcr.io/org/web
+      tag_template: "pr-{pr_number}"
+docker-compose:
+  compose_files: [docker-compose.yml]
+`
+
+const testCompose = `services:
+  web:
+    image: ghcr.io/org/web:latest
+    environment:
+      LOG_LEVEL: info
+    ports: ["8080:80"]
+    networks: [default, backend]
+    depends_on:
+      migrate:
+        condition: service_completed_successfully
+  migrate:
+    image: ghcr.io/org/web:latest
+    command: [./migrate, up]
+    networks: [backend]
+    depends_on:
+      db:
+        condition: service_healthy
+  db:
+    image: postgres:16
+    volumes: [data:/var/lib/postgresql/data]
+    networks:
+      backend:
+        aliases: [database]
+    healthcheck:
+      test: [CMD, pg_isready]
+    restart: unless-stopped
+volumes:
+  data:
+networks:
+  backend:
+`
+
+func testProvider(t *testing.T, compose string) (*Provider, *fakeEngine) {
+	t.Helper()
+	engine, host := newFakeEngine(t)
+	cfg := project(t, ephYAML, map[string]string{"docker-compose.yml": compose})
+	p, err := New(cfg, Options{Host: host})
+	require.NoError(t, err)
+	return p, engine
+}
+
+func testSpec(sha string) providers.Spec {
+	return providers.Spec{
+		Key:       "org/myapp/pr/1",
+		Name:      "myapp-calm-river-1",
+		Project:   "myapp",
+		CommitSHA: sha,
+		Images:    map[string]string{"web": "ghcr.io/org/web:pr-1"},
+		Env:       map[string]string{"EPH_PREVIEW": "true"},
+	}
+}
+
+func TestRegistered(t *testing.T) {
+	_, host := newFakeEngine(t)
+	t.Setenv("DOCKER_HOST", host)
+	cfg := project(t, ephYAML, map[string]string{"docker-compose.yml": testCompose})
+	p, err := providers.New(Name, cfg)
+	require.NoError(t, err)
+	assert.Equal(t, "docker-compose", p.Name())
+	require.NoError(t, p.CheckAccess(context.Background()))
+	assert.Implements(t, (*providers.LogStreamer)(nil), p)
+}
+
+func TestCheckAccessRejectsBuild(t *testing.T) {
+	p, _ := testProvider(t, "services:\n  web:\n    build: .\n")
+	assert.ErrorContains(t, p.CheckAccess(context.Background()), "build is not supported")
+}
+
+func TestApply(t *testing.T) {
+	ctx := context.Background()
+	p, engine := testProvider(t, testCompose)
+	engine.onStart = func(c *fakeContainer) {
+		if c.service() == "migrate" {
+			c.exit(0)
+		}
+	}
+
+	inst, err := p.Apply(ctx, testSpec("aaa"))
+	require.NoError(t, err)
+	assert.False(t, inst.Ready)
+	assert.Equal(t, "migrate is waiting for db to be healthy; web is waiting for migrate; myapp-calm-river-1-db-1 is starting", inst.Message)
+	assert.Empty(t, engine.byService("migrate"), "services wait for their dependencies")
+
+	db := engine.byName("myapp-calm-river-1-db-1")
+	require.NotNil(t, db)
+	assert.Equal(t, "postgres:16", db.spec.Image)
+	assert.Equal(t, []string{"CMD", "pg_isready"}, db.spec.Healthcheck.Test)
+	assert.Equal(t, restartPolicy{Name: "unless-stopped"}, db.spec.HostConfig.RestartPolicy)
+	assert.Equal(t, []mountSpec{{Type: "volume", Source: "myapp-calm-river-1_data", Target: "/var/lib/postgresql/data"}}, db.spec.HostConfig.Mounts)
+	assert.Equal(t, "myapp-calm-river-1_backend", db.spec.HostConfig.NetworkMode)
+	assert.Equal(t, []string{"db", "database"}, db.spec.NetworkingConfig.EndpointsConfig["myapp-calm-river-1_backend"].Aliases)
+	assert.Equal(t, "myapp-calm-river-1", db.Config.Labels["com.docker.compose.project"])
+	assert.Equal(t, "org/myapp/pr/1", db.Config.Labels[LabelKey])
+	assert.Contains(t, engine.volumes, "myapp-calm-river-1_data")
+	assert.Contains(t, engine.networks, "myapp-calm-river-1_backend")
+	assert.Contains(t, engine.networks, "myapp-calm-river-1_default")
+
+	engine.update(db.ID, func(c *fakeContainer) { c.setHealth("healthy") })
+	inst, err = p.Apply(ctx, testSpec("aaa"))
+	require.NoError(t, err)
+	assert.True(t, inst.Ready, inst.Message)
+	assert.Equal(t, "http://127.0.0.1:49153", inst.URL)
+
+	web := engine.byName("myapp-calm-river-1-web-1")
+	require.NotNil(t, web)
+	assert.Equal(t, "ghcr.io/org/web:pr-1", web.spec.Image, "images of environment.images are replaced")
+	assert.Equal(t, []string{"EPH_PREVIEW=true", "LOG_LEVEL=info"}, web.spec.Env)
+	assert.Equal(t, map[string][]portBinding{"80/tcp": {{}}}, web.spec.HostConfig.PortBindings, "host ports are allocated by the Engine")
+	assert.Equal(t, []string{"myapp-calm-river-1_backend", "myapp-calm-river-1_default"}, web.networks)
+	assert.Equal(t, "3", web.Config.Labels[LabelContainers])
+
+	created := engine.created
+	inst, err = p.Apply(ctx, testSpec("aaa"))
+	require.NoError(t, err)
+	assert.True(t, inst.Ready)
+	assert.Equal(t, created, engine.created, "an up-to-date environment is left alone")
+
+	list, err := p.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, providers.Instance{
+		Key:       "org/myapp/pr/1",
+		Name:      "myapp-calm-river-1",
+		CommitSHA: "aaa",
+		URL:       "http://127.0.0.1:49153", // the host of DOCKER_HOST
+		Ready:     true,
+	}, list[0])
+
+	// A new commit recreates the containers, waiting for the health check
+	// of the new database container before migrating again.
+	inst, err = p.Apply(ctx, testSpec("bbb"))
+	require.NoError(t, err)
+	assert.False(t, inst.Ready)
+	assert.NotEqual(t, db.ID, engine.byName("myapp-calm-river-1-db-1").ID)
+	list, _ = p.List(ctx)
+	assert.Empty(t, list[0].CommitSHA, "the environment runs two commits")
+
+	require.NoError(t, p.Destroy(ctx, list[0]))
+	assert.Empty(t, engine.containers)
+	assert.Empty(t, engine.networks)
+	assert.Empty(t, engine.volumes)
+	require.NoError(t, p.Destroy(ctx, list[0]), "destroying a missing environment is not an error")
+	list, err = p.List(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, list)
+}
+
+func TestApplyScaleDown(t *testing.T) {
+	ctx := context.Background()
+	p, engine := testProvider(t, "services:\n  web:\n    image: nginx\n    deploy:\n      replicas: 2\n")
+	_, err := p.Apply(ctx, testSpec("aaa"))
+	require.NoError(t, err)
+	assert.Len(t, engine.byService("web"), 2)
+
+	p.cfg.DockerCompose.Scale = map[string]int{"web": 1}
+	inst, err := p.Apply(ctx, testSpec("aaa"))
+	require.NoError(t, err)
+	assert.True(t, inst.Ready)
+	assert.Equal(t, []string{"myapp-calm-river-1-web-1"}, engine.byService("web"))
+}
+
+func TestApplyFailures(t *testing.T) {
+	ctx := context.Background()
+
+	p, engine := testProvider(t, testCompose)
+	engine.onStart = func(c *fakeContainer) {
+		switch c.service() {
+		case "db":
+			c.setHealth("healthy")
+		case "migrate":
+			c.exit(1)
+		}
+	}
+	_, err := p.Apply(ctx, testSpec("aaa"))
+	assert.EqualError(t, err, "service web: dependency myapp-calm-river-1-migrate-1 exited with code 1")
+
+	p, engine = testProvider(t, testCompose)
+	engine.missing["postgres:16"] = true
+	_, err = p.Apply(ctx, testSpec("aaa"))
+	assert.EqualError(t, err, "pulling postgres:16: manifest unknown")
+
+	p, engine = testProvider(t, "services:\n  web:\n    image: nginx\n")
+	engine.onStart = func(c *fakeContainer) { c.exit(2) }
+	_, err = p.Apply(ctx, testSpec("aaa"))
+	assert.EqualError(t, err, "myapp-calm-river-1-web-1 exited with code 2")
+	list, err := p.List(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "myapp-calm-river-1-web-1 exited with code 2", list[0].Message)
+}
+
+func TestLogs(t *testing.T) {
+	ctx := context.Background()
+	p, engine := testProvider(t, "services:\n  web:\n    image: nginx\n  worker:\n    image: busybox\n")
+	t0 := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	engine.onStart = func(c *fakeContainer) {
+		switch c.service() {
+		case "web":
+			c.logs = []logLine{
+				{Time: t0, Stream: "stdout", Line: "listening on :80"},
+				{Time: t0.Add(2 * time.Second), Stream: "stderr", Line: "GET / 500"},
+			}
+		case "worker":
+			c.logs = []logLine{{Time: t0.Add(time.Second), Stream: "stdout", Line: "picked up job 1"}}
+		}
+	}
+	inst, err := p.Apply(ctx, testSpec("aaa"))
+	require.NoError(t, err)
+
+	var entries []providers.LogEntry
+	collect := func(e providers.LogEntry) error {
+		entries = append(entries, e)
+		return nil
+	}
+	require.NoError(t, p.Logs(ctx, inst, providers.LogOptions{}, collect))
+	assert.Equal(t, []providers.LogEntry{
+		{Time: t0, Service: "web", Stream: "stdout", Line: "listening on :80"},
+		{Time: t0.Add(time.Second), Service: "worker", Stream: "stdout", Line: "picked up job 1"},
+		{Time: t0.Add(2 * time.Second), Service: "web", Stream: "stderr", Line: "GET / 500"},
+	}, entries, "logs of all containers are merged in time order")
+
+	entries = nil
+	require.NoError(t, p.Logs(ctx, inst, providers.LogOptions{TailLines: 2}, collect))
+	require.Len(t, entries, 2)
+	assert.Equal(t, "picked up job 1", entries[0].Line)
+
+	entries = nil
+	require.NoError(t, p.Logs(ctx, inst, providers.LogOptions{Service: "worker"}, collect))
+	require.Len(t, entries, 1)
+
+	assert.ErrorContains(t, p.Logs(ctx, inst, providers.LogOptions{Service: "db"}, collect), `no service "db"`)
+	assert.ErrorContains(t, p.Logs(ctx, providers.Instance{Key: "org/myapp/pr/2", Name: "other"}, providers.LogOptions{}, collect), "not found")
+}
+
+func TestLogsFollow(t *testing.T) {
+	p, engine := testProvider(t, "services:\n  web:\n    image: nginx\n    deploy:\n      replicas: 2\n")
+	engine.onStart = func(c *fakeContainer) {
+		c.logs = []logLine{{Time: time.Now(), Stream: "stdout", Line: "started " + c.Name}}
+	}
+	inst, err := p.Apply(context.Background(), testSpec("aaa"))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	count := 0
+	done := make(chan error, 1)
+	go func() {
+		done <- p.Logs(ctx, inst, providers.LogOptions{Follow: true}, func(providers.LogEntry) error {
+			if count++; count == 2 {
+				cancel()
+			}
+			return nil
+		})
+	}()
+	select {
+	case err := <-done:
+		assert.NoError(t, err, "following ends when cancelled")
+		assert.Equal(t, 2, count)
+	case <-time.After(5 * time.Second):
+		t.Fatal("following did not end after cancellation")
+	}
+
+	errStop := errors.New("stop")
+	err = p.Logs(context.Background(), inst, providers.LogOptions{Follow: true}, func(providers.LogEntry) error { return errStop })
+	assert.ErrorIs(t, err, errStop)
+}
@@ -0,0 +1,258 @@
+// Package fake provides a provider that simulates environments in memory,
+// so that ephd can be run end to end on a laptop or in tests without any
+// infrastructure.
+package fake
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math/rand/v2"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/ephlabs/eph/internal/config"
+	"github.com/ephlabs/eph/internal/providers"
+	"github.com/ephlabs/eph/internal/template"
+)
+
+// Name is the provider's name in eph.yaml.
+const Name = "fake"
+
+// DefaultLogInterval applies when eph.yaml sets no fake.log_interval.
+const DefaultLogInterval = time.Second
+
+// logHistory bounds the log lines kept for an environment.
+const logHistory = 1000
+
+func init() {
+	providers.Register(Name, func(cfg *config.Config) (providers.Provider, error) {
+		return New(cfg, OptionsFromConfig(cfg.Fake)), nil
+	})
+}
+
+// Options configures the simulation.
+type Options struct {
+	CreateLatency  time.Duration
+	DestroyLatency time.Duration
+
+	// ReadyAfter is how long a created or updated environment takes to
+	// become ready.
+	ReadyAfter time.Duration
+
+	// FailureRate is the probability that creating or updating an
+	// environment fails, and FailRefs are glob patterns of ref names, or of
+	// head branches for pull requests, whose environments always fail.
+	FailureRate float64
+	FailRefs    []string
+
+	// LogInterval is the interval between generated log lines.
+	LogInterval time.Duration
+
+	// Now and Rand default to time.Now and rand.Float64.
+	Now  func() time.Time
+	Rand func() float64
+}
+
+// OptionsFromConfig converts the fake section of eph.yaml, which may be nil.
+func OptionsFromConfig(c *config.FakeConfig) Options {
+	if c == nil {
+		return Options{}
+	}
+	return Options{
+		CreateLatency:  c.CreateLatency.Duration,
+		DestroyLatency: c.DestroyLatency.Duration,
+		ReadyAfter:     c.ReadyAfter.Duration,
+		FailureRate:    c.FailureRate,
+		FailRefs:       c.FailRefs,
+		LogInterval:    c.LogInterval.Duration,
+	}
+}
+
+// Provider keeps environments in memory. They are lost when ephd restarts,
+// unlike with a real backend.
+type Provider struct {
+	cfg  *config.Config
+	opts Options
+
+	mu   sync.Mutex
+	envs map[string]*environment
+}
+
+type environment struct {
+	inst     providers.Instance
+	services []string
+	created  time.Time
+	readyAt  time.Time
+}
+
+// New returns a fake provider for cfg.
+func New(cfg *config.Config, opts Options) *Provider {
+	if opts.LogInterval <= 0 {
+		opts.LogInterval = DefaultLogInterval
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	if opts.Rand == nil {
+		opts.Rand = rand.Float64
+	}
+	return &Provider{cfg: cfg, opts: opts, envs: map[string]*environment{}}
+}
+
+func (p *Provider) Name() string { return Name }
+
+// CheckAccess always succeeds.
+func (p *Provider) CheckAccess(context.Context) error { return nil }
+
+func (p *Provider) List(context.Context) ([]providers.Instance, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	out := make([]providers.Instance, 0, len(p.envs))
+	for _, env := range p.envs {
+		out = append(out, p.instance(env))
+	}
+	return out, nil
+}
+
+// Apply simulates creating or updating the environment. Re-applying the
+// commit it already runs neither waits nor fails, as ephd re-applies
+// environments until they are ready.
+func (p *Provider) Apply(ctx context.Context, spec providers.Spec) (providers.Instance, error) {
+	p.mu.Lock()
+	env, exists := p.envs[spec.Key]
+	if exists && env.inst.CommitSHA == spec.CommitSHA {
+		inst := p.instance(env)
+		p.mu.Unlock()
+		return inst, nil
+	}
+	p.mu.Unlock()
+
+	if err := sleep(ctx, p.opts.CreateLatency); err != nil {
+		return providers.Instance{}, err
+	}
+	if err := p.injectFailure(spec); err != nil {
+		return providers.Instance{}, err
+	}
+	url, err := p.url(spec)
+	if err != nil {
+		return providers.Instance{}, err
+	}
+
+	now := p.opts.Now()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	env, exists = p.envs[spec.Key]
+	if !exists {
+		env = &environment{created: now}
+		p.envs[spec.Key] = env
+	}
+	env.inst = providers.Instance{Key: spec.Key, Name: spec.Name, CommitSHA: spec.CommitSHA, URL: url}
+	env.services = services(p.cfg)
+	env.readyAt = now.Add(p.opts.ReadyAfter)
+	return p.instance(env), nil
+}
+
+// Destroy simulates removing the environment.
+func (p *Provider) Destroy(ctx context.Context, inst providers.Instance) error {
+	if err := sleep(ctx, p.opts.DestroyLatency); err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.envs, inst.Key)
+	return nil
+}
+
+func (p *Provider) injectFailure(spec providers.Spec) error {
+	names := []string{spec.RefName}
+	if branch := spec.Vars[template.VarBranchName]; branch != "" && branch != spec.RefName {
+		names = append(names, branch)
+	}
+	for _, pattern := range p.opts.FailRefs {
+		for _, name := range names {
+			if ok, _ := path.Match(pattern, name); ok {
+				return fmt.Errorf("injected failure: ref %q matches fake.fail_refs %q", name, pattern)
+			}
+		}
+	}
+	if p.opts.FailureRate > 0 && p.opts.Rand() < p.opts.FailureRate {
+		return fmt.Errorf("injected failure: fake.failure_rate is %g", p.opts.FailureRate)
+	}
+	return nil
+}
+
+// url is the environment's subdomain of environment.base_domain, or of
+// localhost, which browsers resolve to the loopback address.
+func (p *Provider) url(spec providers.Spec) (string, error) {
+	if p.cfg.Environment.BaseDomain == "" {
+		return "http://" + spec.Name + ".localhost", nil
+	}
+	tmpl := p.cfg.Environment.SubdomainTemplate
+	if tmpl == "" {
+		tmpl = "{name}.{base_domain}"
+	}
+	host, err := template.Render(tmpl, spec.Vars)
+	if err != nil {
+		return "", fmt.Errorf("environment.subdomain_template: %w", err)
+	}
+	return "http://" + host, nil
+}
+
+// instance reports env, ready once ReadyAfter has passed. p.mu must be held.
+func (p *Provider) instance(env *environment) providers.Instance {
+	inst := env.inst
+	if wait := env.readyAt.Sub(p.opts.Now()); wait > 0 {
+		inst.Message = fmt.Sprintf("starting, ready in %s", wait.Round(time.Second))
+	} else {
+		inst.Ready = true
+	}
+	return inst
+}
+
+// get returns a copy of the environment for key, as Apply updates it in
+// place while it may be being read.
+func (p *Provider) get(key string) (environment, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	env, ok := p.envs[key]
+	if !ok {
+		return environment{}, fmt.Errorf("environment %s not found", key)
+	}
+	return *env, nil
+}
+
+// services are the workloads logs are generated for: one per image in
+// environment.images.
+func services(cfg *config.Config) []string {
+	var out []string
+	for _, img := range cfg.Environment.Images {
+		out = append(out, img.Name)
+	}
+	if len(out) == 0 {
+		out = []string{"app"}
+	}
+	return out
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// hash derives stable per-environment values for generated data.
+func hash(s string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(s))
+	return h.Sum32()
+}
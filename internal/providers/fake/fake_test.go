@@ -0,0 +1,292 @@
+package fake
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ephlabs/eph/internal/config"
+	"github.com/ephlabs/eph/internal/controller"
+	"github.com/ephlabs/eph/internal/providers"
+	"github.com/ephlabs/eph/internal/reconciler"
+)
+
+const projectYAML = `version: "1.0"
+name: myapp
+providers:
+  primary: fake
+triggers:
+  - type: pr_label
+    labels: [preview]
+environment:
+  base_domain: preview.example.com
+  images:
+    - name: web
+      repository: ghcr.io/org/web
+      tag_template: "pr-{pr_number}"
+    - name: worker
+      repository: ghcr.io/org/worker
+      tag_template: "pr-{pr_number}"
+fake:
+  ready_after: 30s
+  fail_refs: ["fail-*"]
+  log_interval: 1s
+`
+
+var t0 = time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+// clock is a settable Now.
+type clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *clock) Add(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func parseProject(t *testing.T) *config.Config {
+	t.Helper()
+	cfg, err := config.Parse(filepath.Join(t.TempDir(), "eph.yaml"), []byte(projectYAML))
+	require.NoError(t, err)
+	require.NoError(t, config.Validate(cfg))
+	return cfg
+}
+
+func testProvider(t *testing.T) (*Provider, *clock) {
+	t.Helper()
+	cfg := parseProject(t)
+	c := &clock{now: t0}
+	opts := OptionsFromConfig(cfg.Fake)
+	opts.Now = c.Now
+	return New(cfg, opts), c
+}
+
+func testSpec(ref, sha string) providers.Spec {
+	return providers.Spec{
+		Key:       "org/myapp/branch/" + ref,
+		Name:      "calm-river",
+		RefName:   ref,
+		CommitSHA: sha,
+		Vars:      map[string]string{"name": "calm-river", "base_domain": "preview.example.com"},
+	}
+}
+
+func TestRegistered(t *testing.T) {
+	p, err := providers.New(Name, parseProject(t))
+	require.NoError(t, err)
+	assert.Equal(t, "fake", p.Name())
+	require.NoError(t, p.CheckAccess(context.Background()))
+}
+
+func TestApply(t *testing.T) {
+	ctx := context.Background()
+	p, clock := testProvider(t)
+
+	inst, err := p.Apply(ctx, testSpec("feature", "aaa"))
+	require.NoError(t, err)
+	assert.Equal(t, "http://calm-river.preview.example.com", inst.URL)
+	assert.False(t, inst.Ready)
+	assert.Equal(t, "starting, ready in 30s", inst.Message)
+
+	clock.Add(30 * time.Second)
+	list, err := p.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.True(t, list[0].Ready)
+	assert.Equal(t, "aaa", list[0].CommitSHA)
+
+	inst, err = p.Apply(ctx, testSpec("feature", "aaa"))
+	require.NoError(t, err)
+	assert.True(t, inst.Ready, "re-applying the same commit keeps the environment ready")
+
+	inst, err = p.Apply(ctx, testSpec("feature", "bbb"))
+	require.NoError(t, err)
+	assert.Equal(t, "bbb", inst.CommitSHA)
+	assert.False(t, inst.Ready, "a new commit is rolled out again")
+
+	require.NoError(t, p.Destroy(ctx, inst))
+	require.NoError(t, p.Destroy(ctx, inst), "destroying a missing environment is not an error")
+	list, err = p.List(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, list)
+}
+
+func TestApplyLocalhost(t *testing.T) {
+	p := New(&config.Config{Name: "myapp"}, Options{})
+	inst, err := p.Apply(context.Background(), testSpec("feature", "aaa"))
+	require.NoError(t, err)
+	assert.Equal(t, "http://calm-river.localhost", inst.URL)
+	assert.True(t, inst.Ready)
+}
+
+func TestFailureInjection(t *testing.T) {
+	ctx := context.Background()
+	p, _ := testProvider(t)
+	_, err := p.Apply(ctx, testSpec("fail-migrations", "aaa"))
+	assert.EqualError(t, err, `injected failure: ref "fail-migrations" matches fake.fail_refs "fail-*"`)
+	list, _ := p.List(ctx)
+	assert.Empty(t, list)
+
+	roll := 0.3
+	p = New(&config.Config{Name: "myapp"}, Options{FailureRate: 0.5, Rand: func() float64 { return roll }})
+	_, err = p.Apply(ctx, testSpec("feature", "aaa"))
+	assert.EqualError(t, err, "injected failure: fake.failure_rate is 0.5")
+	roll = 0.7
+	_, err = p.Apply(ctx, testSpec("feature", "aaa"))
+	assert.NoError(t, err)
+}
+
+func TestLatency(t *testing.T) {
+	p := New(&config.Config{Name: "myapp"}, Options{CreateLatency: time.Hour})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := p.Apply(ctx, testSpec("feature", "aaa"))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestLogs(t *testing.T) {
+	ctx := context.Background()
+	p, clock := testProvider(t)
+	inst, err := p.Apply(ctx, testSpec("feature", "aaa"))
+	require.NoError(t, err)
+	clock.Add(9 * time.Second)
+
+	var entries []providers.LogEntry
+	collect := func(e providers.LogEntry) error {
+		entries = append(entries, e)
+		return nil
+	}
+	require.NoError(t, p.Logs(ctx, inst, providers.LogOptions{}, collect))
+	assert.Len(t, entries, 20, "a line per service per second, including the first")
+	assert.Equal(t, "web", entries[0].Service)
+	assert.Equal(t, "worker", entries[1].Service)
+	assert.Equal(t, t0, entries[0].Time)
+
+	entries = nil
+	require.NoError(t, p.Logs(ctx, inst, providers.LogOptions{Service: "worker", TailLines: 3}, collect))
+	require.Len(t, entries, 3)
+	assert.Equal(t, "worker", entries[2].Service)
+	assert.Equal(t, t0.Add(9*time.Second), entries[2].Time)
+
+	err = p.Logs(ctx, inst, providers.LogOptions{Service: "db"}, collect)
+	assert.ErrorContains(t, err, `no service "db"`)
+	err = p.Logs(ctx, providers.Instance{Key: "missing"}, providers.LogOptions{}, collect)
+	assert.ErrorContains(t, err, "not found")
+}
+
+func TestLogsFollow(t *testing.T) {
+	p := New(&config.Config{Name: "myapp"}, Options{LogInterval: 5 * time.Millisecond})
+	inst, err := p.Apply(context.Background(), testSpec("feature", "aaa"))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	count := 0
+	errStop := errors.New("stop")
+	err = p.Logs(ctx, inst, providers.LogOptions{Follow: true}, func(providers.LogEntry) error {
+		if count++; count == 5 {
+			cancel()
+		}
+		return nil
+	})
+	assert.NoError(t, err, "following ends when cancelled")
+	assert.GreaterOrEqual(t, count, 5)
+
+	// The environment may be redeployed while its logs are followed.
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error)
+	go func() {
+		done <- p.Logs(ctx, inst, providers.LogOptions{Follow: true}, func(providers.LogEntry) error { return nil })
+	}()
+	for _, sha := range []string{"bbb", "ccc", "ddd"} {
+		_, err := p.Apply(context.Background(), testSpec("feature", sha))
+		require.NoError(t, err)
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	assert.NoError(t, <-done)
+
+	err = p.Logs(context.Background(), inst, providers.LogOptions{Follow: true}, func(providers.LogEntry) error { return errStop })
+	assert.ErrorIs(t, err, errStop)
+}
+
+func TestMetrics(t *testing.T) {
+	ctx := context.Background()
+	p, _ := testProvider(t)
+	inst, err := p.Apply(ctx, testSpec("feature", "aaa"))
+	require.NoError(t, err)
+
+	m, err := p.Metrics(ctx, inst)
+	require.NoError(t, err)
+	assert.Positive(t, m.CPUMillicores)
+	assert.GreaterOrEqual(t, m.MemoryBytes, int64(2*96<<20), "usage scales with the number of services")
+	again, err := p.Metrics(ctx, inst)
+	require.NoError(t, err)
+	assert.Equal(t, m, again, "figures are stable")
+
+	_, err = p.Metrics(ctx, providers.Instance{Key: "missing"})
+	assert.Error(t, err)
+}
+
+type staticRefs []controller.Ref
+
+func (r staticRefs) ListRefs(context.Context) ([]controller.Ref, error) { return r, nil }
+
+// TestReconcile runs the controller and reconciler against the fake
+// provider, as ephd does.
+func TestReconcile(t *testing.T) {
+	ctx := context.Background()
+	p, clock := testProvider(t)
+	refs := staticRefs{
+		{SourceRef: controller.PRRef("org/myapp", 1, "feature", "aaa"), Labels: []string{"preview"}},
+		{SourceRef: controller.PRRef("org/myapp", 2, "fail-migrations", "bbb"), Labels: []string{"preview"}},
+	}
+	ctrl, err := controller.New(controller.Options{
+		Project:  p.cfg,
+		Provider: p,
+		Refs:     refs,
+		NameKey:  []byte("test-key"),
+		Now:      clock.Now,
+	})
+	require.NoError(t, err)
+	rec := reconciler.New(reconciler.Options[string, controller.Ref, providers.Instance]{
+		Name:    "environments",
+		Desired: reconciler.SourceFunc[string, controller.Ref](ctrl.Desired),
+		Actual:  reconciler.SourceFunc[string, providers.Instance](ctrl.Actual),
+		Handler: ctrl,
+	})
+	pass := func() map[string]controller.Phase {
+		_, err := rec.Pass(ctx)
+		require.NoError(t, err)
+		rec.Wait()
+		phases := map[string]controller.Phase{}
+		for _, env := range ctrl.Environments() {
+			phases[env.Source.Branch] = env.Phase
+		}
+		return phases
+	}
+
+	assert.Equal(t, map[string]controller.Phase{
+		"feature":         controller.PhaseCreating,
+		"fail-migrations": controller.PhaseFailed,
+	}, pass())
+
+	clock.Add(30 * time.Second)
+	assert.Equal(t, controller.PhaseReady, pass()["feature"])
+}
@@ -0,0 +1,103 @@
+package fake
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/ephlabs/eph/internal/providers"
+)
+
+var requests = []string{"GET / 200", "GET /healthz 200", "POST /api/items 201", "GET /api/items?page=2 200", "GET /static/app.js 304"}
+
+// Logs generates a line per service every LogInterval since the environment
+// was created, keeping the last thousand, and keeps generating them when
+// following.
+func (p *Provider) Logs(ctx context.Context, inst providers.Instance, opts providers.LogOptions, send func(providers.LogEntry) error) error {
+	env, err := p.get(inst.Key)
+	if err != nil {
+		return err
+	}
+	svcs := env.services
+	if opts.Service != "" {
+		if !slices.Contains(svcs, opts.Service) {
+			return fmt.Errorf("environment %s has no service %q", inst.Name, opts.Service)
+		}
+		svcs = []string{opts.Service}
+	}
+	interval := p.opts.LogInterval
+
+	n := int(p.opts.Now().Sub(env.created)/interval) + 1
+	var history []providers.LogEntry
+	for i := max(0, n-logHistory); i < n; i++ {
+		at := env.created.Add(time.Duration(i) * interval)
+		if at.Before(opts.Since) {
+			continue
+		}
+		for _, svc := range svcs {
+			history = append(history, logEntry(svc, i, at))
+		}
+	}
+	if opts.TailLines > 0 && len(history) > opts.TailLines {
+		history = history[len(history)-opts.TailLines:]
+	}
+	for _, e := range history {
+		if err := send(e); err != nil {
+			return err
+		}
+	}
+	if !opts.Follow {
+		return nil
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for i := n; ; i++ {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		if _, err := p.get(inst.Key); err != nil {
+			// Destroyed while following.
+			return nil
+		}
+		at := p.opts.Now()
+		for _, svc := range svcs {
+			if err := send(logEntry(svc, i, at)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// logEntry generates the ith line of svc: an access log line, with every
+// tenth a warning on stderr.
+func logEntry(svc string, i int, at time.Time) providers.LogEntry {
+	j := i + int(hash(svc)%uint32(len(requests)))
+	if j%10 == 9 {
+		return providers.LogEntry{Time: at, Service: svc, Stream: "stderr", Line: fmt.Sprintf("warning: slow request took %dms", 800+j%400)}
+	}
+	return providers.LogEntry{Time: at, Service: svc, Stream: "stdout", Line: fmt.Sprintf("%s %dms", requests[j%len(requests)], 2+j*7%40)}
+}
+
+// Metrics generates stable usage figures for the environment, scaled by its
+// number of services.
+func (p *Provider) Metrics(_ context.Context, inst providers.Instance) (providers.Metrics, error) {
+	env, err := p.get(inst.Key)
+	if err != nil {
+		return providers.Metrics{}, err
+	}
+	h := int64(hash(inst.Key))
+	n := int64(len(env.services))
+	uptime := p.opts.Now().Sub(env.created)
+	return providers.Metrics{
+		CPUMillicores: n * (50 + h%100 + int64(uptime.Seconds())%25),
+		MemoryBytes:   n * (96 + h%160) << 20,
+		Custom: map[string]float64{
+			"requests_per_second": float64(n * (1 + h%9)),
+			"uptime_seconds":      uptime.Seconds(),
+		},
+	}, nil
+}
@@ -0,0 +1,141 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ephlabs/eph/internal/config"
+	"github.com/ephlabs/eph/internal/template"
+)
+
+// Provider creates and destroys environments on an infrastructure backend.
+//
+// Providers are stateless: everything they need to answer List must be
+// recorded on the backend itself (labels, annotations, ...), so that ephd can
+// be restarted at any time. Apply and Destroy must be idempotent.
+//
+// A provider that answers List from a cache of the backend also implements
+// informers.Informer; ephd then runs it next to the forge informer and waits
+// for both to sync before reconciling.
+type Provider interface {
+	// Name is the provider's name in eph.yaml, e.g. "kubernetes".
+	Name() string
+
+	// CheckAccess verifies that the backend is reachable with the
+	// configured credentials. ephd calls it on startup and refuses to start
+	// if the primary provider's check fails. With a fallback provider, the
+	// controller calls it periodically to decide where new environments go.
+	CheckAccess(ctx context.Context) error
+
+	// List returns every environment the provider manages for the project
+	// it was created for.
+	List(ctx context.Context) ([]Instance, error)
+
+	// Apply creates the environment described by spec, or updates it in
+	// place if it already exists.
+	Apply(ctx context.Context, spec Spec) (Instance, error)
+
+	// Destroy removes an environment and everything it owns. Destroying an
+	// environment that does not exist is not an error.
+	Destroy(ctx context.Context, instance Instance) error
+}
+
+// Spec describes an environment that should exist.
+type Spec struct {
+	// Key identifies the environment's source ref across restarts, e.g.
+	// "ephlabs/eph/pr/123". Providers must record it so List can return it.
+	Key string
+
+	// Name is the generated environment name, already a valid DNS label.
+	Name    string
+	Project string
+
+	Repository string
+	RefType    string
+	RefName    string
+	CommitSHA  string
+
+	// Images maps eph.yaml image names to the references to deploy.
+	Images map[string]string
+
+	// Env holds environment variables to inject into every workload.
+	Env map[string]string
+
+	// Vars are the template variables for the environment, for rendering
+	// provider templates such as namespace_template.
+	Vars template.Vars
+
+	Config *config.Config
+}
+
+// Instance is an environment as reported by a provider.
+type Instance struct {
+	Key       string
+	Name      string
+	CommitSHA string
+	URL       string
+
+	// Headers must be sent with requests to URL to reach the environment,
+	// e.g. with header-based routing.
+	Headers map[string]string
+
+	// Ready is set once the environment is serving traffic.
+	Ready bool
+
+	// Message explains why an instance is not ready, if known.
+	Message string
+}
+
+// Runner is implemented by providers that run something of their own for
+// as long as ephd does, such as a plugin process. ephd runs it as a
+// supervised component, restarting it when Run returns early.
+type Runner interface {
+	Run(ctx context.Context) error
+}
+
+// ErrNotSupported is returned by providers that implement an optional
+// interface, such as plugins, when their backend does not support it.
+var ErrNotSupported = errors.New("not supported by the provider")
+
+// LogStreamer is implemented by providers that can stream an environment's
+// logs. Logs calls send for every entry until it runs out of entries, or,
+// when following, until ctx is cancelled.
+type LogStreamer interface {
+	Logs(ctx context.Context, instance Instance, opts LogOptions, send func(LogEntry) error) error
+}
+
+// LogOptions selects the logs to stream.
+type LogOptions struct {
+	// Service restricts logs to one service; empty means all.
+	Service string
+	Follow  bool
+
+	// TailLines limits the entries before following to the last ones; 0
+	// means all.
+	TailLines int
+	Since     time.Time
+}
+
+// LogEntry is a line of a service's output.
+type LogEntry struct {
+	Time    time.Time
+	Service string
+
+	// Stream is "stdout" or "stderr".
+	Stream string
+	Line   string
+}
+
+// MetricsReporter is implemented by providers that report an environment's
+// resource usage.
+type MetricsReporter interface {
+	Metrics(ctx context.Context, instance Instance) (Metrics, error)
+}
+
+// Metrics is an environment's current resource usage.
+type Metrics struct {
+	CPUMillicores int64
+	MemoryBytes   int64
+	Custom        map[string]float64
+}
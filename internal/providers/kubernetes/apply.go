@@ -0,0 +1,56 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+)
+
+// FieldManager is the server-side apply field manager Eph applies as. Fields
+// removed from the manifests are pruned from the live objects on the next
+// apply, since Eph owns them.
+const FieldManager = "eph"
+
+// applier server-side applies an object into a namespace and returns the
+// live object.
+type applier interface {
+	Apply(ctx context.Context, namespace string, obj *unstructured.Unstructured) (*unstructured.Unstructured, error)
+}
+
+// dynamicApplier applies objects of any kind, looking up their resource with
+// the cluster's discovery information.
+type dynamicApplier struct {
+	client dynamic.Interface
+	mapper meta.ResettableRESTMapper
+}
+
+func (a *dynamicApplier) Apply(ctx context.Context, namespace string, obj *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	gvk := obj.GroupVersionKind()
+	mapping, err := a.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if meta.IsNoMatchError(err) {
+		// The kind may come from a CRD installed since discovery was
+		// cached.
+		a.mapper.Reset()
+		mapping, err = a.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", gvk.Kind, obj.GetName(), err)
+	}
+	if mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+		return nil, fmt.Errorf("%s %s: cluster-scoped objects cannot be part of an environment", gvk.Kind, obj.GetName())
+	}
+	// As with kustomize's namespace field, any namespace in the manifests
+	// is replaced.
+	obj.SetNamespace(namespace)
+
+	live, err := a.client.Resource(mapping.Resource).Namespace(namespace).Apply(ctx, obj.GetName(), obj,
+		metav1.ApplyOptions{FieldManager: FieldManager, Force: true})
+	if err != nil {
+		return nil, fmt.Errorf("applying %s %s: %w", gvk.Kind, obj.GetName(), err)
+	}
+	return live, nil
+}
@@ -0,0 +1,246 @@
+package kubernetes
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"sort"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/cli/values"
+	"helm.sh/helm/v3/pkg/getter"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"github.com/ephlabs/eph/internal/config"
+	"github.com/ephlabs/eph/internal/log"
+	"github.com/ephlabs/eph/internal/template"
+)
+
+// helmMaxHistory is the number of release revisions Helm keeps per
+// environment. Environments are short-lived, so rollbacks beyond the last
+// few deploys are not worth the secrets.
+const helmMaxHistory = 5
+
+// helmRelease is a kubernetes.manifests[].helm source, loaded for a spec.
+type helmRelease struct {
+	name   string
+	chart  *chart.Chart
+	values map[string]any
+}
+
+// loadHelmReleases loads the charts of the helm sources and merges their
+// values: the chart's defaults, then values files in order, then set, whose
+// values are rendered with vars.
+func loadHelmReleases(dir string, sources []config.ManifestSource, vars template.Vars) ([]helmRelease, error) {
+	var releases []helmRelease
+	for i, src := range sources {
+		if src.Helm == nil {
+			continue
+		}
+		r, err := loadHelmRelease(dir, src.Helm, vars)
+		if err != nil {
+			return nil, fmt.Errorf("kubernetes.manifests[%d].helm: %w", i, err)
+		}
+		releases = append(releases, r)
+	}
+	return releases, nil
+}
+
+func loadHelmRelease(dir string, h *config.HelmSource, vars template.Vars) (helmRelease, error) {
+	c, err := loader.Load(resolve(dir, h.Chart))
+	if err != nil {
+		return helmRelease{}, err
+	}
+	r := helmRelease{name: h.Release, chart: c}
+	if r.name == "" {
+		r.name = c.Metadata.Name
+	}
+
+	opts := values.Options{}
+	for _, f := range h.Values {
+		opts.ValueFiles = append(opts.ValueFiles, resolve(dir, f))
+	}
+	keys := make([]string, 0, len(h.Set))
+	for k := range h.Set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v, err := template.Render(h.Set[k], vars)
+		if err != nil {
+			return helmRelease{}, fmt.Errorf("set.%s: %w", k, err)
+		}
+		// A rendered value may contain the separators of --set syntax.
+		v = strings.NewReplacer(`\`, `\\`, `,`, `\,`).Replace(v)
+		opts.Values = append(opts.Values, k+"="+v)
+	}
+	// Only local values files are supported, which need no getters.
+	if r.values, err = opts.MergeValues(getter.Providers{}); err != nil {
+		return helmRelease{}, err
+	}
+	return r, nil
+}
+
+// postRenderer prepares objects for an environment. It is also a Helm post
+// renderer, so that charts get the same treatment as the other sources.
+type postRenderer struct {
+	images  imageRewriter
+	env     map[string]string
+	labels  map[string]string
+	secrets []config.LocalObjectReference
+}
+
+// Run implements postrender.PostRenderer.
+func (p postRenderer) Run(rendered *bytes.Buffer) (*bytes.Buffer, error) {
+	objs, err := decodeManifests(rendered)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.prepare(objs); err != nil {
+		return nil, err
+	}
+	out := new(bytes.Buffer)
+	if err := WriteYAML(out, objs); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// prepare rewrites images, injects env and adds labels and image pull
+// secrets to every object.
+func (p postRenderer) prepare(objs []*unstructured.Unstructured) error {
+	if err := prepare(objs, p.images, p.env, p.labels); err != nil {
+		return err
+	}
+	for _, obj := range objs {
+		if err := addImagePullSecrets(obj, p.secrets); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Labels on Helm releases recording what was deployed, so that an
+// environment that is still rolling out is not upgraded again on every pass.
+// An upgrade creates a revision and re-runs the chart's hooks, such as
+// migration jobs.
+const (
+	helmLabelCommit = "eph.io/commit"
+	helmLabelDigest = "eph.io/digest"
+)
+
+// digest identifies the chart version, values and injected environment
+// variables of r. The rest of what the post renderer does follows from the
+// commit.
+func (r helmRelease) digest(post postRenderer) (string, error) {
+	data, err := json.Marshal(struct {
+		Chart, Version string
+		Values         map[string]any
+		Env            map[string]string
+	}{r.chart.Metadata.Name, r.chart.Metadata.Version, r.values, post.env})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	// Label values are limited to 63 characters.
+	return hex.EncodeToString(sum[:16]), nil
+}
+
+// installRelease installs r into namespace, or upgrades it if the release
+// already exists, like helm upgrade --install. A release already deployed
+// for commit with the same chart and values is left alone. It returns the
+// objects of the release's manifest.
+func installRelease(ctx context.Context, cfg *action.Configuration, namespace string, r helmRelease, post postRenderer, commit string) ([]*unstructured.Unstructured, error) {
+	digest, err := r.digest(post)
+	if err != nil {
+		return nil, fmt.Errorf("helm release %s: %w", r.name, err)
+	}
+	labels := map[string]string{helmLabelCommit: commit, helmLabelDigest: digest}
+
+	var rel *release.Release
+	last, err := cfg.Releases.Last(r.name)
+	switch {
+	case errors.Is(err, driver.ErrReleaseNotFound):
+		install := action.NewInstall(cfg)
+		install.ReleaseName = r.name
+		install.Namespace = namespace
+		install.PostRenderer = post
+		install.Labels = labels
+		rel, err = install.RunWithContext(ctx, r.chart, r.values)
+	case err != nil:
+	case last.Info.Status == release.StatusDeployed && maps.Equal(last.Labels, labels):
+		log.Debug(ctx, "Helm release is up to date", "release", r.name, "revision", last.Version)
+		rel = last
+	default:
+		upgrade := action.NewUpgrade(cfg)
+		upgrade.Namespace = namespace
+		upgrade.PostRenderer = post
+		upgrade.MaxHistory = helmMaxHistory
+		upgrade.Labels = labels
+		rel, err = upgrade.RunWithContext(ctx, r.name, r.chart, r.values)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("helm release %s: %w", r.name, err)
+	}
+	objs, err := decodeManifests(strings.NewReader(rel.Manifest))
+	if err != nil {
+		return nil, fmt.Errorf("helm release %s: %w", r.name, err)
+	}
+	return objs, nil
+}
+
+// uninstallReleases uninstalls every release in the namespace cfg is bound
+// to, so that chart hooks run before the namespace is deleted.
+func uninstallReleases(cfg *action.Configuration) error {
+	list := action.NewList(cfg)
+	list.All = true
+	list.StateMask = action.ListAll
+	releases, err := list.Run()
+	if err != nil {
+		return err
+	}
+	for _, rel := range releases {
+		if _, err := action.NewUninstall(cfg).Run(rel.Name); err != nil && !errors.Is(err, driver.ErrReleaseNotFound) {
+			return fmt.Errorf("uninstalling helm release %s: %w", rel.Name, err)
+		}
+	}
+	return nil
+}
+
+// helmConfig returns a Helm action configuration bound to namespace, storing
+// releases as secrets like the helm CLI does.
+func helmConfig(kubeContext string) func(ctx context.Context, namespace string) (*action.Configuration, error) {
+	return func(ctx context.Context, namespace string) (*action.Configuration, error) {
+		flags := genericclioptions.NewConfigFlags(false)
+		flags.Context = &kubeContext
+		flags.Namespace = &namespace
+		cfg := new(action.Configuration)
+		err := cfg.Init(flags, namespace, "secret", func(format string, v ...any) {
+			log.Debug(ctx, fmt.Sprintf(format, v...), "component", "helm")
+		})
+		if err != nil {
+			return nil, err
+		}
+		return cfg, nil
+	}
+}
+
+func hasHelmSources(sources []config.ManifestSource) bool {
+	for _, src := range sources {
+		if src.Helm != nil {
+			return true
+		}
+	}
+	return false
+}
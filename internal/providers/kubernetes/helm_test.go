@@ -0,0 +1,158 @@
+package kubernetes
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chartutil"
+	kubefake "helm.sh/helm/v3/pkg/kube/fake"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/ephlabs/eph/internal/config"
+	"github.com/ephlabs/eph/internal/template"
+)
+
+const helmProject = `
+version: "1"
+name: myapp
+environment:
+  base_domain: preview.example.com
+kubernetes:
+  namespace_template: "{project}-{name}"
+  manifests:
+    - helm:
+        chart: charts/api
+        values: [charts/values-preview.yaml]
+        set:
+          image.tag: "pr-{pr_number}"
+          ingress.host: "{name}.{base_domain}"
+`
+
+var chartFiles = map[string]string{
+	"charts/api/Chart.yaml": "apiVersion: v2\nname: api\nversion: 0.1.0\n",
+	"charts/api/values.yaml": `replicas: 3
+image:
+  repository: ghcr.io/org/api
+  tag: latest
+ingress:
+  host: api.example.com
+`,
+	"charts/api/templates/deployment.yaml": `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: {{ .Release.Name }}
+spec:
+  replicas: {{ .Values.replicas }}
+  template:
+    spec:
+      containers:
+        - name: api
+          image: "{{ .Values.image.repository }}:{{ .Values.image.tag }}"
+`,
+	"charts/api/templates/ingress.yaml": `apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: {{ .Release.Name }}
+spec:
+  rules:
+    - host: {{ .Values.ingress.host }}
+`,
+	"charts/values-preview.yaml": "replicas: 1\n",
+}
+
+func helmProvider(t *testing.T) (*Provider, *storage.Storage) {
+	t.Helper()
+	files := map[string]string{}
+	for name, data := range chartFiles {
+		files[name] = data
+	}
+	p := testProviderWith(t, fake.NewClientset(), &recordingApplier{}, helmProject, files)
+	releases := storage.Init(driver.NewMemory())
+	p.helm = func(context.Context, string) (*action.Configuration, error) {
+		return &action.Configuration{
+			Releases:     releases,
+			KubeClient:   &kubefake.PrintingKubeClient{Out: io.Discard},
+			Capabilities: chartutil.DefaultCapabilities,
+			Log:          func(string, ...any) {},
+		}, nil
+	}
+	return p, releases
+}
+
+func TestHelmRelease(t *testing.T) {
+	p, releases := helmProvider(t)
+	spec := testSpec("abc123")
+	spec.Env = map[string]string{"EPH_ENVIRONMENT": "calm-river"}
+
+	inst, err := p.Apply(context.Background(), spec)
+	require.NoError(t, err)
+	assert.Equal(t, "http://calm-river.preview.example.com", inst.URL)
+	assert.False(t, inst.Ready)
+	assert.Equal(t, "api: 0 of 1 replicas updated", inst.Message)
+
+	rel, err := releases.Last("api")
+	require.NoError(t, err)
+	assert.Equal(t, 1, rel.Version)
+	assert.Equal(t, release.StatusDeployed, rel.Info.Status)
+	assert.Equal(t, "myapp-calm-river", rel.Namespace)
+	assert.Contains(t, rel.Manifest, "image: ghcr.io/org/api:pr-12")
+	assert.Contains(t, rel.Manifest, "replicas: 1")
+	assert.Contains(t, rel.Manifest, "eph.io/environment: calm-river")
+	assert.Contains(t, rel.Manifest, "name: EPH_ENVIRONMENT")
+
+	// The environment is still rolling out, so it is applied again on the
+	// next pass; the release, and its hooks, are left alone.
+	_, err = p.Apply(context.Background(), spec)
+	require.NoError(t, err)
+	rel, err = releases.Last("api")
+	require.NoError(t, err)
+	assert.Equal(t, 1, rel.Version, "an up-to-date release is not upgraded")
+	assert.Equal(t, "abc123", rel.Labels["eph.io/commit"])
+
+	_, err = p.Apply(context.Background(), testSpec("def456"))
+	require.NoError(t, err)
+	rel, err = releases.Last("api")
+	require.NoError(t, err)
+	assert.Equal(t, 2, rel.Version, "an existing release is upgraded")
+
+	require.Eventually(t, func() bool {
+		_, ok := p.Get(spec.Key)
+		return ok
+	}, 5*time.Second, 10*time.Millisecond)
+	require.NoError(t, p.Destroy(context.Background(), inst))
+	_, err = releases.Last("api")
+	assert.ErrorIs(t, err, driver.ErrReleaseNotFound, "the release is uninstalled on teardown")
+}
+
+func TestRenderHelm(t *testing.T) {
+	p, _ := helmProvider(t)
+	spec := testSpec("abc123")
+
+	objs, err := Render(p.cfg, spec, Options{})
+	require.NoError(t, err)
+	require.Len(t, objs, 9)
+	assert.Equal(t, "Namespace", objs[0].GetKind())
+	assert.Equal(t, "ResourceQuota", objs[1].GetKind())
+	assert.Equal(t, "Deployment", objs[7].GetKind())
+	assert.Equal(t, "myapp-calm-river", objs[7].GetNamespace())
+	assert.Equal(t, "calm-river", objs[7].GetLabels()["eph.io/environment"])
+}
+
+func TestLoadHelmReleaseEscapesSetValues(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, chartFiles)
+	r, err := loadHelmRelease(dir, &config.HelmSource{Chart: "charts/api", Release: "web", Set: map[string]string{
+		"ingress.host": "{name},{project}",
+	}}, template.Vars{template.VarName: "calm-river", template.VarProject: "myapp"})
+	require.NoError(t, err)
+	assert.Equal(t, "web", r.name)
+	assert.Equal(t, "calm-river,myapp", r.values["ingress"].(map[string]any)["host"])
+}
@@ -0,0 +1,264 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"regexp"
+	"strings"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	corev1ac "k8s.io/client-go/applyconfigurations/core/v1"
+
+	"github.com/ephlabs/eph/internal/config"
+	kinformer "github.com/ephlabs/eph/internal/informers/kubernetes"
+	"github.com/ephlabs/eph/internal/providers"
+	"github.com/ephlabs/eph/internal/template"
+)
+
+// IngressName is the name of the Ingress, or HTTPRoute with header-based
+// routing, generated from kubernetes.ingress.expose.
+const IngressName = "eph"
+
+// defaultSubdomainTemplate applies when eph.yaml sets no
+// environment.subdomain_template.
+const defaultSubdomainTemplate = "{name}.{base_domain}"
+
+const (
+	annotationClusterIssuer = "cert-manager.io/cluster-issuer"
+	annotationIssuer        = "cert-manager.io/issuer"
+
+	// ingress-nginx annotations that strip the path prefix.
+	annotationUseRegex      = "nginx.ingress.kubernetes.io/use-regex"
+	annotationRewriteTarget = "nginx.ingress.kubernetes.io/rewrite-target"
+)
+
+var httpRouteKind = schema.GroupVersionKind{Group: "gateway.networking.k8s.io", Version: "v1", Kind: "HTTPRoute"}
+
+// routing is where an environment is reached, according to
+// networking.routing.
+type routing struct {
+	host string
+
+	// prefix is the rendered path_prefix, without a trailing slash, with
+	// path-based routing.
+	prefix string
+
+	// header and value identify the environment with header-based routing.
+	header, value string
+
+	tls bool
+}
+
+// routingFor resolves networking.routing for spec. With subdomain routing,
+// the default, every environment has its own host; with path and header
+// routing, environments share base_domain, which needs no wildcard DNS.
+func routingFor(cfg *config.Config, spec providers.Spec) (routing, error) {
+	r := routing{tls: cfg.Networking.TLS.Enabled}
+	switch conf := cfg.Networking.Routing; conf.Strategy {
+	case "path":
+		prefix, err := template.Render(conf.PathPrefix, spec.Vars)
+		if err != nil {
+			return r, fmt.Errorf("networking.routing.path_prefix: %w", err)
+		}
+		r.host = cfg.Environment.BaseDomain
+		r.prefix = strings.TrimRight(prefix, "/")
+	case "header":
+		r.host = cfg.Environment.BaseDomain
+		r.header, r.value = conf.Header, spec.Name
+	default:
+		tmpl := cfg.Environment.SubdomainTemplate
+		if tmpl == "" {
+			tmpl = defaultSubdomainTemplate
+		}
+		host, err := template.Render(tmpl, spec.Vars)
+		if err != nil {
+			return r, fmt.Errorf("environment.subdomain_template: %w", err)
+		}
+		r.host = host
+	}
+	return r, nil
+}
+
+// url is the environment's URL.
+func (r routing) url() string {
+	scheme := "http://"
+	if r.tls {
+		scheme = "https://"
+	}
+	return scheme + r.host + r.prefix
+}
+
+// annotations record the URL, and the header requests to it need, on the
+// environment's namespace.
+func (r routing) annotations() map[string]string {
+	a := map[string]string{kinformer.AnnotationURL: r.url()}
+	if r.header != "" {
+		a[kinformer.AnnotationHeader] = r.header + ": " + r.value
+	}
+	return a
+}
+
+// headers returns the headers requests to the URL need.
+func (r routing) headers() map[string]string {
+	if r.header == "" {
+		return nil
+	}
+	return map[string]string{r.header: r.value}
+}
+
+// exposes reports whether Eph routes traffic to the environment's services.
+func exposes(cfg *config.Config) bool {
+	return len(cfg.Kubernetes.Ingress.Expose) > 0
+}
+
+// route generates the object routing traffic to the services of
+// kubernetes.ingress.expose, or returns nil if there are none: an HTTPRoute
+// with header-based routing, an Ingress otherwise.
+func route(cfg *config.Config, spec providers.Spec) (*unstructured.Unstructured, error) {
+	if !exposes(cfg) {
+		return nil, nil
+	}
+	r, err := routingFor(cfg, spec)
+	if err != nil {
+		return nil, err
+	}
+	if r.header != "" {
+		return httpRoute(cfg, r), nil
+	}
+	return ingress(cfg, r)
+}
+
+// ingress generates the Ingress for r. With path-based routing, the prefix
+// is stripped with ingress-nginx's regex rewrites, so that services are
+// served from the root as with subdomains. With TLS enabled, the
+// certificate is either issued by cert-manager into a secret of the
+// environment, or the wildcard secret copied by copyWildcardSecret; the
+// latter is required with path-based routing, where environments share a
+// host.
+func ingress(cfg *config.Config, r routing) (*unstructured.Unstructured, error) {
+	conf := cfg.Kubernetes.Ingress
+	ing := &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Name: IngressName, Annotations: maps.Clone(conf.Annotations)},
+	}
+	if ing.Annotations == nil {
+		ing.Annotations = map[string]string{}
+	}
+	if conf.Class != "" {
+		ing.Spec.IngressClassName = &conf.Class
+	}
+
+	pathType := networkingv1.PathTypePrefix
+	if r.prefix != "" {
+		pathType = networkingv1.PathTypeImplementationSpecific
+		ing.Annotations[annotationUseRegex] = "true"
+		ing.Annotations[annotationRewriteTarget] = "/$2"
+	}
+	var paths []networkingv1.HTTPIngressPath
+	for _, e := range conf.Expose {
+		path := exposedPath(e)
+		if r.prefix != "" {
+			path = regexp.QuoteMeta(r.prefix) + "(/|$)(" + regexp.QuoteMeta(strings.TrimPrefix(path, "/")) + ".*)"
+		}
+		paths = append(paths, networkingv1.HTTPIngressPath{
+			Path:     path,
+			PathType: &pathType,
+			Backend: networkingv1.IngressBackend{Service: &networkingv1.IngressServiceBackend{
+				Name: e.Service,
+				Port: networkingv1.ServiceBackendPort{Number: int32(e.Port)},
+			}},
+		})
+	}
+	ing.Spec.Rules = []networkingv1.IngressRule{{
+		Host:             r.host,
+		IngressRuleValue: networkingv1.IngressRuleValue{HTTP: &networkingv1.HTTPIngressRuleValue{Paths: paths}},
+	}}
+
+	if tls := cfg.Networking.TLS; tls.Enabled {
+		var secret string
+		switch tls.Provider {
+		case "cert-manager":
+			secret = IngressName + "-tls"
+			if tls.ClusterIssuer != "" && ing.Annotations[annotationClusterIssuer] == "" && ing.Annotations[annotationIssuer] == "" {
+				ing.Annotations[annotationClusterIssuer] = tls.ClusterIssuer
+			}
+		case "wildcard":
+			secret = tls.WildcardSecret.Name
+		default:
+			return nil, fmt.Errorf("networking.tls.provider %q is not supported by the kubernetes provider", tls.Provider)
+		}
+		ing.Spec.TLS = []networkingv1.IngressTLS{{Hosts: []string{r.host}, SecretName: secret}}
+	}
+	if len(ing.Annotations) == 0 {
+		ing.Annotations = nil
+	}
+	return toUnstructured(ing, ingressKind)
+}
+
+// httpRoute generates the HTTPRoute for r, attached to
+// kubernetes.ingress.gateway, which matches requests carrying the
+// environment's header. TLS is terminated by the gateway.
+func httpRoute(cfg *config.Config, r routing) *unstructured.Unstructured {
+	conf := cfg.Kubernetes.Ingress
+	parent := map[string]any{"name": conf.Gateway.Name}
+	if conf.Gateway.Namespace != "" {
+		parent["namespace"] = conf.Gateway.Namespace
+	}
+	var rules []any
+	for _, e := range conf.Expose {
+		rules = append(rules, map[string]any{
+			"matches": []any{map[string]any{
+				"path":    map[string]any{"type": "PathPrefix", "value": exposedPath(e)},
+				"headers": []any{map[string]any{"type": "Exact", "name": r.header, "value": r.value}},
+			}},
+			"backendRefs": []any{map[string]any{"name": e.Service, "port": int64(e.Port)}},
+		})
+	}
+	u := &unstructured.Unstructured{Object: map[string]any{
+		"spec": map[string]any{
+			"parentRefs": []any{parent},
+			"hostnames":  []any{r.host},
+			"rules":      rules,
+		},
+	}}
+	u.SetGroupVersionKind(httpRouteKind)
+	u.SetName(IngressName)
+	if len(conf.Annotations) > 0 {
+		u.SetAnnotations(maps.Clone(conf.Annotations))
+	}
+	return u
+}
+
+func exposedPath(e config.ExposedService) string {
+	if e.Path == "" {
+		return "/"
+	}
+	return e.Path
+}
+
+// copyWildcardSecret copies networking.tls.wildcard_secret into namespace,
+// where the generated Ingress can reference it. The copy is reapplied on
+// every deploy, so that renewed certificates reach existing environments.
+func (p *Provider) copyWildcardSecret(ctx context.Context, namespace string, spec providers.Spec) error {
+	tls := p.cfg.Networking.TLS
+	if !exposes(p.cfg) || !tls.Enabled || tls.Provider != "wildcard" || p.cfg.Networking.Routing.Strategy == "header" {
+		return nil
+	}
+	ref := tls.WildcardSecret
+	src, err := p.client.CoreV1().Secrets(ref.Namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("reading TLS secret %s/%s: %w", ref.Namespace, ref.Name, err)
+	}
+	secret := corev1ac.Secret(ref.Name, namespace).
+		WithLabels(labels(p.cfg, spec)).
+		WithType(src.Type).
+		WithData(src.Data)
+	_, err = p.client.CoreV1().Secrets(namespace).Apply(ctx, secret, metav1.ApplyOptions{FieldManager: FieldManager, Force: true})
+	if err != nil {
+		return fmt.Errorf("copying TLS secret %s/%s: %w", ref.Namespace, ref.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,213 @@
+package kubernetes
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/ephlabs/eph/internal/config"
+	kinformer "github.com/ephlabs/eph/internal/informers/kubernetes"
+)
+
+const exposeYAML = `
+version: "1"
+name: myapp
+environment:
+  base_domain: preview.example.com
+kubernetes:
+  namespace_template: "{project}-{name}"
+  manifests:
+    - path: k8s
+  ingress:
+    class: nginx
+    annotations:
+      nginx.ingress.kubernetes.io/proxy-body-size: "10m"
+    expose:
+      - service: web
+        port: 80
+      - service: api
+        port: 8080
+        path: /api
+`
+
+func parseProject(t *testing.T, src string) *config.Config {
+	t.Helper()
+	cfg, err := config.Parse(filepath.Join(t.TempDir(), "eph.yaml"), []byte(src))
+	require.NoError(t, err)
+	return cfg
+}
+
+func typedIngress(t *testing.T, cfg *config.Config) *networkingv1.Ingress {
+	t.Helper()
+	u, err := route(cfg, testSpec("abc123"))
+	require.NoError(t, err)
+	require.NotNil(t, u)
+	assert.Equal(t, "networking.k8s.io/v1", u.GetAPIVersion())
+	assert.Equal(t, "Ingress", u.GetKind())
+	var ing networkingv1.Ingress
+	require.NoError(t, runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, &ing))
+	return &ing
+}
+
+func TestIngress(t *testing.T) {
+	ing := typedIngress(t, parseProject(t, exposeYAML))
+	assert.Equal(t, IngressName, ing.Name)
+	require.NotNil(t, ing.Spec.IngressClassName)
+	assert.Equal(t, "nginx", *ing.Spec.IngressClassName)
+	assert.Equal(t, map[string]string{"nginx.ingress.kubernetes.io/proxy-body-size": "10m"}, ing.Annotations)
+	assert.Empty(t, ing.Spec.TLS)
+
+	require.Len(t, ing.Spec.Rules, 1)
+	rule := ing.Spec.Rules[0]
+	assert.Equal(t, "calm-river.preview.example.com", rule.Host)
+	require.Len(t, rule.HTTP.Paths, 2)
+	assert.Equal(t, "/", rule.HTTP.Paths[0].Path)
+	assert.Equal(t, "web", rule.HTTP.Paths[0].Backend.Service.Name)
+	assert.Equal(t, int32(80), rule.HTTP.Paths[0].Backend.Service.Port.Number)
+	assert.Equal(t, "/api", rule.HTTP.Paths[1].Path)
+	assert.Equal(t, networkingv1.PathTypePrefix, *rule.HTTP.Paths[1].PathType)
+
+	cfg := parseProject(t, exposeYAML)
+	cfg.Environment.SubdomainTemplate = "{project}-{name}.{base_domain}"
+	assert.Equal(t, "myapp-calm-river.preview.example.com", typedIngress(t, cfg).Spec.Rules[0].Host)
+
+	cfg.Kubernetes.Ingress.Expose = nil
+	u, err := route(cfg, testSpec("abc123"))
+	require.NoError(t, err)
+	assert.Nil(t, u, "nothing is generated without exposed services")
+}
+
+func TestIngressCertManager(t *testing.T) {
+	cfg := parseProject(t, exposeYAML)
+	cfg.Networking.TLS = config.TLSConfig{Enabled: true, Provider: "cert-manager", ClusterIssuer: "letsencrypt-prod"}
+	ing := typedIngress(t, cfg)
+	assert.Equal(t, "letsencrypt-prod", ing.Annotations["cert-manager.io/cluster-issuer"])
+	assert.Equal(t, []networkingv1.IngressTLS{{Hosts: []string{"calm-river.preview.example.com"}, SecretName: "eph-tls"}}, ing.Spec.TLS)
+
+	cfg.Kubernetes.Ingress.Annotations = map[string]string{"cert-manager.io/issuer": "local"}
+	ing = typedIngress(t, cfg)
+	assert.Equal(t, map[string]string{"cert-manager.io/issuer": "local"}, ing.Annotations, "an issuer in the annotations wins")
+
+	reach, err := routingFor(cfg, testSpec("abc123"))
+	require.NoError(t, err)
+	assert.Equal(t, "https://calm-river.preview.example.com", reach.url())
+
+	cfg.Networking.TLS.Provider = "self-signed"
+	_, err = route(cfg, testSpec("abc123"))
+	assert.ErrorContains(t, err, `"self-signed" is not supported`)
+}
+
+func TestApplyWildcardTLS(t *testing.T) {
+	client := fake.NewClientset(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "wildcard-tls", Namespace: "cert-manager"},
+		Type:       corev1.SecretTypeTLS,
+		Data:       map[string][]byte{"tls.crt": []byte("cert"), "tls.key": []byte("key")},
+	})
+	a := &recordingApplier{}
+	p := testProviderWith(t, client, a, exposeYAML+`
+networking:
+  tls:
+    enabled: true
+    provider: wildcard
+    wildcard_secret:
+      name: wildcard-tls
+      namespace: cert-manager
+`, map[string]string{"k8s/app.yaml": manifests})
+
+	inst, err := p.Apply(context.Background(), testSpec("abc123"))
+	require.NoError(t, err)
+	assert.Equal(t, "https://calm-river.preview.example.com", inst.URL, "the generated ingress wins over the manifests'")
+
+	secret, err := client.CoreV1().Secrets("myapp-calm-river").Get(context.Background(), "wildcard-tls", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, corev1.SecretTypeTLS, secret.Type)
+	assert.Equal(t, []byte("cert"), secret.Data["tls.crt"])
+	assert.Equal(t, "calm-river", secret.Labels[kinformer.LabelEnvironment])
+
+	ns, err := client.CoreV1().Namespaces().Get(context.Background(), "myapp-calm-river", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "https://calm-river.preview.example.com", ns.Annotations[kinformer.AnnotationURL])
+
+	var generated *networkingv1.Ingress
+	for _, obj := range a.applied {
+		if obj.GetKind() == "Ingress" && obj.GetName() == IngressName {
+			generated = &networkingv1.Ingress{}
+			require.NoError(t, runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, generated))
+		}
+	}
+	require.NotNil(t, generated)
+	assert.Equal(t, "wildcard-tls", generated.Spec.TLS[0].SecretName)
+	assert.Equal(t, "calm-river", generated.Labels[kinformer.LabelEnvironment])
+}
+
+func TestIngressPathRouting(t *testing.T) {
+	cfg := parseProject(t, exposeYAML)
+	cfg.Networking.Routing = config.RoutingConfig{Strategy: "path", PathPrefix: "/preview/{name}/"}
+	ing := typedIngress(t, cfg)
+
+	assert.Equal(t, "true", ing.Annotations["nginx.ingress.kubernetes.io/use-regex"])
+	assert.Equal(t, "/$2", ing.Annotations["nginx.ingress.kubernetes.io/rewrite-target"])
+	rule := ing.Spec.Rules[0]
+	assert.Equal(t, "preview.example.com", rule.Host, "environments share the base domain")
+	require.Len(t, rule.HTTP.Paths, 2)
+	assert.Equal(t, "/preview/calm-river(/|$)(.*)", rule.HTTP.Paths[0].Path)
+	assert.Equal(t, "/preview/calm-river(/|$)(api.*)", rule.HTTP.Paths[1].Path)
+	assert.Equal(t, networkingv1.PathTypeImplementationSpecific, *rule.HTTP.Paths[0].PathType)
+
+	reach, err := routingFor(cfg, testSpec("abc123"))
+	require.NoError(t, err)
+	assert.Equal(t, "http://preview.example.com/preview/calm-river", reach.url())
+	assert.Nil(t, reach.headers())
+}
+
+func TestApplyHeaderRouting(t *testing.T) {
+	client := fake.NewClientset()
+	a := &recordingApplier{}
+	p := testProviderWith(t, client, a, exposeYAML+`    gateway:
+      name: eph
+      namespace: gateway-system
+networking:
+  routing:
+    strategy: header
+    header: X-Eph-Environment
+`, map[string]string{"k8s/app.yaml": manifests})
+
+	inst, err := p.Apply(context.Background(), testSpec("abc123"))
+	require.NoError(t, err)
+	assert.Equal(t, "http://preview.example.com", inst.URL)
+	assert.Equal(t, map[string]string{"X-Eph-Environment": "calm-river"}, inst.Headers)
+
+	ns, err := client.CoreV1().Namespaces().Get(context.Background(), "myapp-calm-river", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "X-Eph-Environment: calm-river", ns.Annotations[kinformer.AnnotationHeader])
+	require.Eventually(t, func() bool {
+		env, ok := p.Get("org/myapp/pr/12")
+		return ok && env.Headers["X-Eph-Environment"] == "calm-river"
+	}, 5*time.Second, 10*time.Millisecond, "the header is listed after a restart")
+
+	hr := a.get("HTTPRoute")
+	require.NotNil(t, hr)
+	assert.Equal(t, "gateway.networking.k8s.io/v1", hr.GetAPIVersion())
+	assert.Nil(t, a.get("Ingress").GetAnnotations(), "the manifests' ingress is applied as is")
+	parents, _, _ := unstructured.NestedSlice(hr.Object, "spec", "parentRefs")
+	assert.Equal(t, []any{map[string]any{"name": "eph", "namespace": "gateway-system"}}, parents)
+	hostnames, _, _ := unstructured.NestedStringSlice(hr.Object, "spec", "hostnames")
+	assert.Equal(t, []string{"preview.example.com"}, hostnames)
+	rules, _, _ := unstructured.NestedSlice(hr.Object, "spec", "rules")
+	require.Len(t, rules, 2)
+	assert.Equal(t, []any{map[string]any{
+		"path":    map[string]any{"type": "PathPrefix", "value": "/api"},
+		"headers": []any{map[string]any{"type": "Exact", "name": "X-Eph-Environment", "value": "calm-river"}},
+	}}, rules[1].(map[string]any)["matches"])
+	assert.Equal(t, []any{map[string]any{"name": "api", "port": int64(8080)}}, rules[1].(map[string]any)["backendRefs"])
+}
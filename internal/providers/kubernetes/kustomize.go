@@ -0,0 +1,103 @@
+package kubernetes
+
+import (
+	"fmt"
+	"regexp"
+
+	jsonpatch "gopkg.in/evanphx/json-patch.v4"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/kustomize/api/krusty"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+	sigsyaml "sigs.k8s.io/yaml"
+
+	"github.com/ephlabs/eph/internal/config"
+)
+
+// buildKustomization runs kustomize build on the kustomization directory at
+// path, in-process and with kustomize's default options: no plugins, and
+// remote bases disallowed by the load restrictor.
+func buildKustomization(path string) ([]*unstructured.Unstructured, error) {
+	k := krusty.MakeKustomizer(krusty.MakeDefaultOptions())
+	resMap, err := k.Run(filesys.MakeFsOnDisk(), path)
+	if err != nil {
+		return nil, err
+	}
+	objs := make([]*unstructured.Unstructured, 0, resMap.Size())
+	for _, res := range resMap.Resources() {
+		m, err := res.Map()
+		if err != nil {
+			return nil, err
+		}
+		objs = append(objs, &unstructured.Unstructured{Object: m})
+	}
+	return objs, nil
+}
+
+// applyPatches applies the RFC 6902 patches of a kustomization source to the
+// objects they target. As in kustomize, the target's name and namespace are
+// anchored regular expressions. A patch that matches nothing is an error, so
+// that a renamed resource does not silently drop its preview overrides.
+func applyPatches(objs []*unstructured.Unstructured, patches []config.Patch) error {
+	for i, p := range patches {
+		data, err := sigsyaml.YAMLToJSON([]byte(p.Patch))
+		if err != nil {
+			return fmt.Errorf("patches[%d]: %w", i, err)
+		}
+		patch, err := jsonpatch.DecodePatch(data)
+		if err != nil {
+			return fmt.Errorf("patches[%d]: %w", i, err)
+		}
+		match, err := targetMatcher(p.Target)
+		if err != nil {
+			return fmt.Errorf("patches[%d].target: %w", i, err)
+		}
+
+		matched := false
+		for _, obj := range objs {
+			if !match(obj) {
+				continue
+			}
+			matched = true
+			doc, err := obj.MarshalJSON()
+			if err != nil {
+				return err
+			}
+			if doc, err = patch.Apply(doc); err != nil {
+				return fmt.Errorf("patches[%d]: %s %s: %w", i, obj.GetKind(), obj.GetName(), err)
+			}
+			if err := obj.UnmarshalJSON(doc); err != nil {
+				return fmt.Errorf("patches[%d]: %s %s: %w", i, obj.GetKind(), obj.GetName(), err)
+			}
+		}
+		if !matched {
+			return fmt.Errorf("patches[%d]: target matches no objects", i)
+		}
+	}
+	return nil
+}
+
+func targetMatcher(t config.PatchTarget) (func(*unstructured.Unstructured) bool, error) {
+	name, err := anchored(t.Name)
+	if err != nil {
+		return nil, err
+	}
+	namespace, err := anchored(t.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	return func(obj *unstructured.Unstructured) bool {
+		gvk := obj.GroupVersionKind()
+		return (t.Group == "" || t.Group == gvk.Group) &&
+			(t.Version == "" || t.Version == gvk.Version) &&
+			(t.Kind == "" || t.Kind == gvk.Kind) &&
+			(name == nil || name.MatchString(obj.GetName())) &&
+			(namespace == nil || namespace.MatchString(obj.GetNamespace()))
+	}, nil
+}
+
+func anchored(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	return regexp.Compile("^(?:" + pattern + ")$")
+}
@@ -0,0 +1,323 @@
+package kubernetes
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+
+	"github.com/ephlabs/eph/internal/config"
+)
+
+// loadManifests reads every kubernetes.manifests source, resolving relative
+// paths against dir. A path source is a file or a directory whose .yaml, .yml
+// and .json files are read in lexical order; subdirectories are not descended
+// into. A kustomization source is built with kustomize, then patched. Helm
+// sources are installed as releases instead, and skipped.
+func loadManifests(dir string, sources []config.ManifestSource) ([]*unstructured.Unstructured, error) {
+	var objs []*unstructured.Unstructured
+	for i, src := range sources {
+		if src.Helm != nil {
+			continue
+		}
+		var srcObjs []*unstructured.Unstructured
+		var err error
+		if src.Kustomization != "" {
+			srcObjs, err = buildKustomization(resolve(dir, src.Kustomization))
+		} else {
+			srcObjs, err = readPath(resolve(dir, src.Path))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("kubernetes.manifests[%d]: %w", i, err)
+		}
+		if err := applyPatches(srcObjs, src.Patches); err != nil {
+			return nil, fmt.Errorf("kubernetes.manifests[%d].%w", i, err)
+		}
+		objs = append(objs, srcObjs...)
+	}
+	return objs, nil
+}
+
+func resolve(dir, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
+
+func readPath(path string) ([]*unstructured.Unstructured, error) {
+	files, err := manifestFiles(path)
+	if err != nil {
+		return nil, err
+	}
+	var objs []*unstructured.Unstructured
+	for _, file := range files {
+		fileObjs, err := decodeFile(file)
+		if err != nil {
+			return nil, err
+		}
+		objs = append(objs, fileObjs...)
+	}
+	return objs, nil
+}
+
+func manifestFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, e := range entries {
+		switch filepath.Ext(e.Name()) {
+		case ".yaml", ".yml", ".json":
+			if !e.IsDir() {
+				files = append(files, filepath.Join(path, e.Name()))
+			}
+		}
+	}
+	sort.Strings(files)
+	if len(files) == 0 {
+		return nil, fmt.Errorf("%s contains no manifests", path)
+	}
+	return files, nil
+}
+
+func decodeFile(file string) ([]*unstructured.Unstructured, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	objs, err := decodeManifests(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	return objs, nil
+}
+
+// decodeManifests decodes a stream of YAML documents or JSON objects. Empty
+// documents are skipped and List kinds are flattened.
+func decodeManifests(r io.Reader) ([]*unstructured.Unstructured, error) {
+	dec := utilyaml.NewYAMLOrJSONDecoder(r, 4096)
+	var objs []*unstructured.Unstructured
+	for n := 1; ; n++ {
+		var doc map[string]any
+		if err := dec.Decode(&doc); errors.Is(err, io.EOF) {
+			return objs, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("document %d: %w", n, err)
+		}
+		if len(doc) == 0 {
+			continue
+		}
+		obj := &unstructured.Unstructured{Object: doc}
+		if obj.IsList() {
+			err := obj.EachListItem(func(item runtime.Object) error {
+				u, ok := item.(*unstructured.Unstructured)
+				if !ok {
+					return fmt.Errorf("unexpected list item %T", item)
+				}
+				objs = append(objs, u)
+				return nil
+			})
+			if err != nil {
+				return nil, fmt.Errorf("document %d: %w", n, err)
+			}
+			continue
+		}
+		if obj.GetAPIVersion() == "" || obj.GetKind() == "" || obj.GetName() == "" {
+			return nil, fmt.Errorf("document %d: apiVersion, kind and metadata.name are required", n)
+		}
+		objs = append(objs, obj)
+	}
+}
+
+// podSpecPaths locates the pod spec of the workload kinds Eph rewrites.
+var podSpecPaths = map[string][]string{
+	"Pod":                   {"spec"},
+	"Deployment":            {"spec", "template", "spec"},
+	"StatefulSet":           {"spec", "template", "spec"},
+	"DaemonSet":             {"spec", "template", "spec"},
+	"ReplicaSet":            {"spec", "template", "spec"},
+	"ReplicationController": {"spec", "template", "spec"},
+	"Job":                   {"spec", "template", "spec"},
+	"CronJob":               {"spec", "jobTemplate", "spec", "template", "spec"},
+}
+
+// eachContainer calls fn for every container and init container of obj, if
+// it is a workload.
+func eachContainer(obj *unstructured.Unstructured, fn func(container map[string]any)) error {
+	path, ok := podSpecPaths[obj.GetKind()]
+	if !ok {
+		return nil
+	}
+	for _, field := range []string{"initContainers", "containers"} {
+		containers, found, err := unstructured.NestedSlice(obj.Object, append(path, field)...)
+		if err != nil {
+			return fmt.Errorf("%s %s: %w", obj.GetKind(), obj.GetName(), err)
+		}
+		if !found {
+			continue
+		}
+		for _, c := range containers {
+			if container, ok := c.(map[string]any); ok {
+				fn(container)
+			}
+		}
+		if err := unstructured.SetNestedSlice(obj.Object, containers, append(path, field)...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// imageOverride is a rendered kubernetes.images entry, with kustomize's
+// semantics: Name matches the image name without tag or digest.
+type imageOverride struct {
+	Name, NewName, NewTag, Digest string
+}
+
+// imageRewriter rewrites container images, first by the kubernetes.images
+// overrides, then by the images resolved from environment.images, which
+// match on repository.
+type imageRewriter struct {
+	overrides []imageOverride
+	resolved  map[string]string
+}
+
+func (r imageRewriter) rewrite(image string) string {
+	name, tag, digest := splitImage(image)
+	for _, o := range r.overrides {
+		if o.Name != name {
+			continue
+		}
+		if o.NewName != "" {
+			name = o.NewName
+		}
+		if o.NewTag != "" {
+			tag, digest = o.NewTag, ""
+		}
+		if o.Digest != "" {
+			tag, digest = "", o.Digest
+		}
+		return joinImage(name, tag, digest)
+	}
+	if ref, ok := r.resolved[name]; ok {
+		return ref
+	}
+	return image
+}
+
+// splitImage splits an image reference into name, tag and digest.
+func splitImage(image string) (name, tag, digest string) {
+	name = image
+	if i := strings.Index(name, "@"); i >= 0 {
+		name, digest = name[:i], name[i+1:]
+	}
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name, tag = name[:i], name[i+1:]
+	}
+	return name, tag, digest
+}
+
+func joinImage(name, tag, digest string) string {
+	switch {
+	case digest != "":
+		return name + "@" + digest
+	case tag != "":
+		return name + ":" + tag
+	}
+	return name
+}
+
+// setEnv sets env on a container, replacing variables of the same name.
+func setEnv(container map[string]any, env map[string]string) {
+	if len(env) == 0 {
+		return
+	}
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	existing, _ := container["env"].([]any)
+	out := make([]any, 0, len(existing)+len(env))
+	for _, e := range existing {
+		if m, ok := e.(map[string]any); ok {
+			if _, override := env[fmt.Sprint(m["name"])]; override {
+				continue
+			}
+		}
+		out = append(out, e)
+	}
+	for _, name := range names {
+		out = append(out, map[string]any{"name": name, "value": env[name]})
+	}
+	container["env"] = out
+}
+
+// prepare rewrites images, injects env and adds labels to every object.
+func prepare(objs []*unstructured.Unstructured, images imageRewriter, env, labels map[string]string) error {
+	for _, obj := range objs {
+		l := obj.GetLabels()
+		if l == nil {
+			l = map[string]string{}
+		}
+		for k, v := range labels {
+			l[k] = v
+		}
+		obj.SetLabels(l)
+
+		err := eachContainer(obj, func(c map[string]any) {
+			if image, ok := c["image"].(string); ok {
+				c["image"] = images.rewrite(image)
+			}
+			setEnv(c, env)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addImagePullSecrets adds kubernetes.imagePullSecrets to obj's pod spec, if
+// it is a workload, skipping secrets it already references.
+func addImagePullSecrets(obj *unstructured.Unstructured, secrets []config.LocalObjectReference) error {
+	path, ok := podSpecPaths[obj.GetKind()]
+	if !ok {
+		return nil
+	}
+	path = append(path[:len(path):len(path)], "imagePullSecrets")
+	existing, _, err := unstructured.NestedSlice(obj.Object, path...)
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", obj.GetKind(), obj.GetName(), err)
+	}
+	seen := map[string]bool{}
+	for _, e := range existing {
+		if m, ok := e.(map[string]any); ok {
+			seen[fmt.Sprint(m["name"])] = true
+		}
+	}
+	for _, s := range secrets {
+		if !seen[s.Name] {
+			existing = append(existing, map[string]any{"name": s.Name})
+		}
+	}
+	return unstructured.SetNestedSlice(obj.Object, existing, path...)
+}
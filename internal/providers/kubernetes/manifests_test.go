@@ -0,0 +1,154 @@
+package kubernetes
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/ephlabs/eph/internal/config"
+)
+
+func TestDecodeManifests(t *testing.T) {
+	objs, err := decodeManifests(strings.NewReader(`
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+---
+# only a comment
+---
+apiVersion: v1
+kind: List
+items:
+  - apiVersion: v1
+    kind: Service
+    metadata:
+      name: api
+  - apiVersion: v1
+    kind: Secret
+    metadata:
+      name: token
+`))
+	require.NoError(t, err)
+	var names []string
+	for _, obj := range objs {
+		names = append(names, obj.GetKind()+"/"+obj.GetName())
+	}
+	assert.Equal(t, []string{"ConfigMap/settings", "Service/api", "Secret/token"}, names)
+
+	_, err = decodeManifests(strings.NewReader("apiVersion: v1\nkind: ConfigMap\n"))
+	assert.ErrorContains(t, err, "document 1: apiVersion, kind and metadata.name are required")
+
+	_, err = decodeManifests(strings.NewReader(`{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "json"}}`))
+	assert.NoError(t, err)
+}
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(data), 0o644))
+	}
+}
+
+func TestLoadManifestsKustomization(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"k8s/base/kustomization.yaml": "resources: [deployment.yaml]\n",
+		"k8s/base/deployment.yaml": `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: api-server
+spec:
+  replicas: 3
+  template:
+    spec:
+      containers:
+        - name: api
+          image: api-server
+`,
+		"k8s/overlays/preview/kustomization.yaml": `resources: [../../base]
+namePrefix: preview-
+labels:
+  - pairs:
+      tier: preview
+`,
+	})
+
+	objs, err := loadManifests(dir, []config.ManifestSource{{
+		Kustomization: "k8s/overlays/preview",
+		Patches: []config.Patch{{
+			Target: config.PatchTarget{Kind: "Deployment", Name: ".*-server"},
+			Patch: `
+- op: replace
+  path: /spec/replicas
+  value: 1
+`,
+		}},
+	}})
+	require.NoError(t, err)
+	require.Len(t, objs, 1)
+	deploy := objs[0]
+	assert.Equal(t, "preview-api-server", deploy.GetName())
+	assert.Equal(t, "preview", deploy.GetLabels()["tier"])
+	replicas, _, _ := unstructured.NestedInt64(deploy.Object, "spec", "replicas")
+	assert.Equal(t, int64(1), replicas)
+
+	_, err = loadManifests(dir, []config.ManifestSource{{
+		Kustomization: "k8s/overlays/preview",
+		Patches: []config.Patch{{
+			Target: config.PatchTarget{Kind: "Deployment", Name: "api-server"},
+			Patch:  `[{"op": "remove", "path": "/spec/replicas"}]`,
+		}},
+	}})
+	assert.EqualError(t, err, "kubernetes.manifests[0].patches[0]: target matches no objects",
+		"names are matched after the overlay's namePrefix, and in full")
+
+	_, err = loadManifests(dir, []config.ManifestSource{{Kustomization: "k8s/missing"}})
+	assert.ErrorContains(t, err, "kubernetes.manifests[0]: ")
+}
+
+func TestApplyPatchesErrors(t *testing.T) {
+	objs, err := decodeManifests(strings.NewReader("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: settings\n"))
+	require.NoError(t, err)
+
+	err = applyPatches(objs, []config.Patch{{
+		Target: config.PatchTarget{Kind: "ConfigMap"},
+		Patch:  `[{"op": "replace", "path": "/data/missing", "value": "x"}]`,
+	}})
+	assert.ErrorContains(t, err, "patches[0]: ConfigMap settings: ")
+
+	err = applyPatches(objs, []config.Patch{{Target: config.PatchTarget{Name: "("}, Patch: "[]"}})
+	assert.ErrorContains(t, err, "patches[0].target: ")
+}
+
+func TestImageRewriter(t *testing.T) {
+	r := imageRewriter{
+		overrides: []imageOverride{
+			{Name: "nginx", NewTag: "1.27"},
+			{Name: "localhost:5000/web", NewName: "registry.example.com/web"},
+			{Name: "redis", Digest: "sha256:abc"},
+		},
+		resolved: map[string]string{"ghcr.io/org/api": "ghcr.io/org/api:pr-12"},
+	}
+	tests := map[string]string{
+		"nginx":                           "nginx:1.27",
+		"nginx:1.25@sha256:def":           "nginx:1.27",
+		"localhost:5000/web:v2":           "registry.example.com/web:v2",
+		"redis:7":                         "redis@sha256:abc",
+		"ghcr.io/org/api":                 "ghcr.io/org/api:pr-12",
+		"ghcr.io/org/api:latest":          "ghcr.io/org/api:pr-12",
+		"ghcr.io/org/worker:latest":       "ghcr.io/org/worker:latest",
+		"docker.io/library/nginx:1.25":    "docker.io/library/nginx:1.25",
+		"localhost:5000/other@sha256:123": "localhost:5000/other@sha256:123",
+	}
+	for image, want := range tests {
+		assert.Equal(t, want, r.rewrite(image), image)
+	}
+}
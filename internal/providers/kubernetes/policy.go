@@ -0,0 +1,232 @@
+package kubernetes
+
+import (
+	"fmt"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"github.com/ephlabs/eph/internal/config"
+)
+
+// Maxima are the organisation's limits on a single environment, set on
+// ephd rather than in eph.yaml so that projects cannot raise them. They
+// become the namespace's ResourceQuota, and cap the container defaults
+// eph.yaml asks for.
+type Maxima struct {
+	CPU    resource.Quantity
+	Memory resource.Quantity
+	Pods   int64
+}
+
+// DefaultMaxima apply where ephd sets no maxima.
+var DefaultMaxima = Maxima{
+	CPU:    resource.MustParse("4"),
+	Memory: resource.MustParse("8Gi"),
+	Pods:   50,
+}
+
+// Container defaults for environment.resources values eph.yaml leaves
+// unset. The quota covers limits, so every container needs them.
+var (
+	defaultCPURequest    = resource.MustParse("100m")
+	defaultCPULimit      = resource.MustParse("1")
+	defaultMemoryRequest = resource.MustParse("128Mi")
+	defaultMemoryLimit   = resource.MustParse("512Mi")
+)
+
+// DefaultIngressNamespace is where the ingress controller is assumed to run.
+const DefaultIngressNamespace = "ingress-nginx"
+
+// MaximaFromEnv reads EPH_MAX_CPU, EPH_MAX_MEMORY and EPH_MAX_PODS, falling
+// back to DefaultMaxima for those that are unset.
+func MaximaFromEnv(getenv func(string) string) (Maxima, error) {
+	m := DefaultMaxima
+	for name, q := range map[string]*resource.Quantity{"EPH_MAX_CPU": &m.CPU, "EPH_MAX_MEMORY": &m.Memory} {
+		if s := getenv(name); s != "" {
+			parsed, err := resource.ParseQuantity(s)
+			if err != nil {
+				return m, fmt.Errorf("%s: %w", name, err)
+			}
+			*q = parsed
+		}
+	}
+	if s := getenv("EPH_MAX_PODS"); s != "" {
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || n <= 0 {
+			return m, fmt.Errorf("EPH_MAX_PODS: must be a positive integer, got %q", s)
+		}
+		m.Pods = n
+	}
+	return m, nil
+}
+
+// policies returns the objects that bound an environment's namespace: a
+// ResourceQuota and LimitRange derived from environment.resources and capped
+// by maxima, and NetworkPolicies that deny all traffic except within the
+// namespace, from the ingress controller and to cluster DNS. With
+// header-based routing, traffic arrives through the gateway instead, so its
+// namespace is admitted too.
+func policies(cfg *config.Config, opts Options) ([]*unstructured.Unstructured, error) {
+	res := cfg.Environment.Resources
+	maxima := opts.Maxima
+	if maxima.CPU.IsZero() {
+		maxima.CPU = DefaultMaxima.CPU
+	}
+	if maxima.Memory.IsZero() {
+		maxima.Memory = DefaultMaxima.Memory
+	}
+	if maxima.Pods <= 0 {
+		maxima.Pods = DefaultMaxima.Pods
+	}
+	ingressNamespace := opts.IngressNamespace
+	if ingressNamespace == "" {
+		ingressNamespace = DefaultIngressNamespace
+	}
+	ingressPeers := []networkingv1.NetworkPolicyPeer{{NamespaceSelector: namespaceSelector(ingressNamespace)}}
+	if gw := gatewayNamespace(cfg); gw != "" && gw != ingressNamespace {
+		ingressPeers = append(ingressPeers, networkingv1.NetworkPolicyPeer{NamespaceSelector: namespaceSelector(gw)})
+	}
+	cpuLimit := capped(res.CPULimit, defaultCPULimit, maxima.CPU)
+	memoryLimit := capped(res.MemoryLimit, defaultMemoryLimit, maxima.Memory)
+	cpuRequest := capped(res.CPURequest, defaultCPURequest, cpuLimit)
+	memoryRequest := capped(res.MemoryRequest, defaultMemoryRequest, memoryLimit)
+
+	quota := &corev1.ResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{Name: "eph-quota"},
+		Spec: corev1.ResourceQuotaSpec{Hard: corev1.ResourceList{
+			corev1.ResourceRequestsCPU:    maxima.CPU,
+			corev1.ResourceLimitsCPU:      maxima.CPU,
+			corev1.ResourceRequestsMemory: maxima.Memory,
+			corev1.ResourceLimitsMemory:   maxima.Memory,
+			corev1.ResourcePods:           *resource.NewQuantity(maxima.Pods, resource.DecimalSI),
+		}},
+	}
+	limits := &corev1.LimitRange{
+		ObjectMeta: metav1.ObjectMeta{Name: "eph-limits"},
+		Spec: corev1.LimitRangeSpec{Limits: []corev1.LimitRangeItem{{
+			Type:           corev1.LimitTypeContainer,
+			Default:        corev1.ResourceList{corev1.ResourceCPU: cpuLimit, corev1.ResourceMemory: memoryLimit},
+			DefaultRequest: corev1.ResourceList{corev1.ResourceCPU: cpuRequest, corev1.ResourceMemory: memoryRequest},
+			Max:            corev1.ResourceList{corev1.ResourceCPU: maxima.CPU, corev1.ResourceMemory: maxima.Memory},
+		}}},
+	}
+
+	all := metav1.LabelSelector{}
+	both := []networkingv1.PolicyType{networkingv1.PolicyTypeIngress, networkingv1.PolicyTypeEgress}
+	udp, tcp := corev1.ProtocolUDP, corev1.ProtocolTCP
+	dnsPort := intstr.FromInt32(53)
+	netpols := []*networkingv1.NetworkPolicy{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "eph-default-deny"},
+			Spec:       networkingv1.NetworkPolicySpec{PodSelector: all, PolicyTypes: both},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "eph-allow-same-namespace"},
+			Spec: networkingv1.NetworkPolicySpec{
+				PodSelector: all,
+				PolicyTypes: both,
+				Ingress:     []networkingv1.NetworkPolicyIngressRule{{From: []networkingv1.NetworkPolicyPeer{{PodSelector: &all}}}},
+				Egress:      []networkingv1.NetworkPolicyEgressRule{{To: []networkingv1.NetworkPolicyPeer{{PodSelector: &all}}}},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "eph-allow-ingress-controller"},
+			Spec: networkingv1.NetworkPolicySpec{
+				PodSelector: all,
+				PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+				Ingress:     []networkingv1.NetworkPolicyIngressRule{{From: ingressPeers}},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "eph-allow-dns"},
+			Spec: networkingv1.NetworkPolicySpec{
+				PodSelector: all,
+				PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeEgress},
+				Egress: []networkingv1.NetworkPolicyEgressRule{{
+					To: []networkingv1.NetworkPolicyPeer{{
+						NamespaceSelector: namespaceSelector("kube-system"),
+						PodSelector:       &metav1.LabelSelector{MatchLabels: map[string]string{"k8s-app": "kube-dns"}},
+					}},
+					Ports: []networkingv1.NetworkPolicyPort{
+						{Protocol: &udp, Port: &dnsPort},
+						{Protocol: &tcp, Port: &dnsPort},
+					},
+				}},
+			},
+		},
+	}
+
+	var out []*unstructured.Unstructured
+	add := func(obj runtime.Object, kind schema.GroupVersionKind) error {
+		u, err := toUnstructured(obj, kind)
+		if err != nil {
+			return err
+		}
+		out = append(out, u)
+		return nil
+	}
+	if err := add(quota, corev1.SchemeGroupVersion.WithKind("ResourceQuota")); err != nil {
+		return nil, err
+	}
+	if err := add(limits, corev1.SchemeGroupVersion.WithKind("LimitRange")); err != nil {
+		return nil, err
+	}
+	for _, np := range netpols {
+		if err := add(np, networkingv1.SchemeGroupVersion.WithKind("NetworkPolicy")); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// toUnstructured converts a typed object built by the provider for the
+// applier.
+func toUnstructured(obj runtime.Object, kind schema.GroupVersionKind) (*unstructured.Unstructured, error) {
+	m, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, err
+	}
+	u := &unstructured.Unstructured{Object: m}
+	u.SetGroupVersionKind(kind)
+	// Server-side apply would otherwise take ownership of the empty
+	// creationTimestamp and status the converter emits.
+	unstructured.RemoveNestedField(u.Object, "metadata", "creationTimestamp")
+	unstructured.RemoveNestedField(u.Object, "status")
+	return u, nil
+}
+
+// gatewayNamespace returns the namespace of the gateway header-based routing
+// attaches to, or "" if there is none or it is the environment's own.
+func gatewayNamespace(cfg *config.Config) string {
+	if cfg.Networking.Routing.Strategy != "header" || cfg.Kubernetes == nil || cfg.Kubernetes.Ingress.Gateway == nil {
+		return ""
+	}
+	return cfg.Kubernetes.Ingress.Gateway.Namespace
+}
+
+func namespaceSelector(name string) *metav1.LabelSelector {
+	return &metav1.LabelSelector{MatchLabels: map[string]string{corev1.LabelMetadataName: name}}
+}
+
+// capped parses s, or uses def when s is empty, and caps it at max. eph.yaml
+// quantities were validated when it was loaded.
+func capped(s string, def, max resource.Quantity) resource.Quantity {
+	q := def
+	if s != "" {
+		if parsed, err := resource.ParseQuantity(s); err == nil {
+			q = parsed
+		}
+	}
+	if q.Cmp(max) > 0 {
+		return max
+	}
+	return q
+}
@@ -0,0 +1,119 @@
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/ephlabs/eph/internal/config"
+)
+
+func TestPolicies(t *testing.T) {
+	objs, err := policies(&config.Config{Environment: config.EnvironmentConfig{Resources: config.ResourcesConfig{
+		CPURequest:    "250m",
+		CPULimit:      "8",
+		MemoryRequest: "4Gi",
+		MemoryLimit:   "2Gi",
+	}}}, Options{
+		Maxima:           Maxima{CPU: resource.MustParse("2")},
+		IngressNamespace: "traefik",
+	})
+	require.NoError(t, err)
+
+	byName := map[string]*unstructured.Unstructured{}
+	for _, obj := range objs {
+		byName[obj.GetName()] = obj
+	}
+	require.Len(t, byName, 6)
+
+	quota := byName["eph-quota"]
+	assert.Equal(t, "ResourceQuota", quota.GetKind())
+	hard, _, _ := unstructured.NestedStringMap(quota.Object, "spec", "hard")
+	assert.Equal(t, map[string]string{
+		"requests.cpu":    "2",
+		"limits.cpu":      "2",
+		"requests.memory": "8Gi",
+		"limits.memory":   "8Gi",
+		"pods":            "50",
+	}, hard, "unset maxima default")
+
+	limits, _, _ := unstructured.NestedSlice(byName["eph-limits"].Object, "spec", "limits")
+	require.Len(t, limits, 1)
+	item := limits[0].(map[string]any)
+	assert.Equal(t, map[string]any{"cpu": "2", "memory": "2Gi"}, item["default"], "limits are capped by the maxima")
+	assert.Equal(t, map[string]any{"cpu": "250m", "memory": "2Gi"}, item["defaultRequest"], "requests are capped by the limits")
+
+	deny := byName["eph-default-deny"]
+	assert.Equal(t, "networking.k8s.io/v1", deny.GetAPIVersion())
+	types, _, _ := unstructured.NestedStringSlice(deny.Object, "spec", "policyTypes")
+	assert.Equal(t, []string{"Ingress", "Egress"}, types)
+	_, hasIngress, _ := unstructured.NestedFieldNoCopy(deny.Object, "spec", "ingress")
+	assert.False(t, hasIngress)
+
+	from, _, _ := unstructured.NestedSlice(byName["eph-allow-ingress-controller"].Object, "spec", "ingress")
+	assert.Equal(t, []any{map[string]any{"from": []any{map[string]any{
+		"namespaceSelector": map[string]any{"matchLabels": map[string]any{"kubernetes.io/metadata.name": "traefik"}},
+	}}}}, from)
+
+	egress, _, _ := unstructured.NestedSlice(byName["eph-allow-dns"].Object, "spec", "egress")
+	require.Len(t, egress, 1)
+	assert.Len(t, egress[0].(map[string]any)["ports"], 2)
+
+	for _, obj := range objs {
+		_, hasStatus := obj.Object["status"]
+		assert.False(t, hasStatus, obj.GetName())
+		assert.NotContains(t, obj.Object["metadata"], "creationTimestamp", obj.GetName())
+	}
+}
+
+func TestPoliciesAdmitGateway(t *testing.T) {
+	cfg := &config.Config{
+		Networking: config.NetworkingConfig{Routing: config.RoutingConfig{Strategy: "header", Header: "X-Eph-Environment"}},
+		Kubernetes: &config.KubernetesConfig{Ingress: config.IngressConfig{
+			Gateway: &config.GatewayReference{Name: "eph", Namespace: "gateway-system"},
+		}},
+	}
+	objs, err := policies(cfg, Options{})
+	require.NoError(t, err)
+
+	var from []any
+	for _, obj := range objs {
+		if obj.GetName() == "eph-allow-ingress-controller" {
+			from, _, _ = unstructured.NestedSlice(obj.Object, "spec", "ingress")
+		}
+	}
+	selector := func(ns string) any {
+		return map[string]any{"namespaceSelector": map[string]any{"matchLabels": map[string]any{"kubernetes.io/metadata.name": ns}}}
+	}
+	assert.Equal(t, []any{map[string]any{"from": []any{selector("ingress-nginx"), selector("gateway-system")}}}, from,
+		"header-routed traffic arrives through the gateway")
+
+	// The gateway's namespace is only admitted with header-based routing.
+	cfg.Networking.Routing.Strategy = "subdomain"
+	objs, err = policies(cfg, Options{})
+	require.NoError(t, err)
+	for _, obj := range objs {
+		if obj.GetName() == "eph-allow-ingress-controller" {
+			from, _, _ = unstructured.NestedSlice(obj.Object, "spec", "ingress")
+		}
+	}
+	assert.Equal(t, []any{map[string]any{"from": []any{selector("ingress-nginx")}}}, from)
+}
+
+func TestMaximaFromEnv(t *testing.T) {
+	env := map[string]string{"EPH_MAX_CPU": "16", "EPH_MAX_PODS": "200"}
+	m, err := MaximaFromEnv(func(k string) string { return env[k] })
+	require.NoError(t, err)
+	assert.Equal(t, "16", m.CPU.String())
+	assert.Equal(t, "8Gi", m.Memory.String())
+	assert.Equal(t, int64(200), m.Pods)
+
+	_, err = MaximaFromEnv(func(k string) string { return map[string]string{"EPH_MAX_MEMORY": "lots"}[k] })
+	assert.ErrorContains(t, err, "EPH_MAX_MEMORY")
+
+	_, err = MaximaFromEnv(func(k string) string { return map[string]string{"EPH_MAX_PODS": "0"}[k] })
+	assert.ErrorContains(t, err, "EPH_MAX_PODS")
+}
@@ -0,0 +1,366 @@
+// Package kubernetes implements the Kubernetes provider. Every environment
+// gets its own namespace, into which the project's manifests are server-side
+// applied with the environment's images and variables.
+package kubernetes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"time"
+
+	"helm.sh/helm/v3/pkg/action"
+	appsv1 "k8s.io/api/apps/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	corev1ac "k8s.io/client-go/applyconfigurations/core/v1"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"github.com/ephlabs/eph/internal/config"
+	kinformer "github.com/ephlabs/eph/internal/informers/kubernetes"
+	"github.com/ephlabs/eph/internal/providers"
+	"github.com/ephlabs/eph/internal/template"
+)
+
+// DefaultDeleteTimeout bounds how long Destroy waits for a namespace's
+// finalizers to run.
+const DefaultDeleteTimeout = 5 * time.Minute
+
+func init() {
+	providers.Register("kubernetes", func(cfg *config.Config) (providers.Provider, error) {
+		maxima, err := MaximaFromEnv(os.Getenv)
+		if err != nil {
+			return nil, err
+		}
+		return NewForConfig(cfg, Options{
+			Registry:         os.Getenv("EPH_REGISTRY"),
+			Maxima:           maxima,
+			IngressNamespace: os.Getenv("EPH_INGRESS_NAMESPACE"),
+		})
+	})
+}
+
+// Options configures a Provider.
+type Options struct {
+	// Registry is the value of the {registry} variable in kubernetes.images.
+	Registry string
+
+	// Maxima cap every environment's resources. Unset fields default to
+	// those of DefaultMaxima.
+	Maxima Maxima
+
+	// IngressNamespace is the namespace of the ingress controller, which
+	// network policies admit traffic from. It defaults to
+	// DefaultIngressNamespace.
+	IngressNamespace string
+
+	// DeleteTimeout defaults to DefaultDeleteTimeout.
+	DeleteTimeout time.Duration
+}
+
+// Provider creates environments as namespaces. It is stateless: the
+// namespace carries the environment's key and commit, and List reads them
+// back through an informer, which ephd runs alongside the reconciler.
+type Provider struct {
+	*kinformer.Informer
+
+	cfg     *config.Config
+	opts    Options
+	client  kubernetes.Interface
+	applier applier
+
+	// helm returns a Helm configuration bound to a namespace.
+	helm func(ctx context.Context, namespace string) (*action.Configuration, error)
+
+	// pollInterval is how often Destroy checks whether the namespace is
+	// gone.
+	pollInterval time.Duration
+}
+
+// NewForConfig connects to the cluster of cfg.Kubernetes.Context, using the
+// usual kubeconfig loading rules, or the in-cluster configuration when there
+// is no kubeconfig.
+func NewForConfig(cfg *config.Config, opts Options) (*Provider, error) {
+	if cfg.Kubernetes == nil {
+		return nil, errors.New("eph.yaml has no kubernetes section")
+	}
+	restConfig, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		clientcmd.NewDefaultClientConfigLoadingRules(),
+		&clientcmd.ConfigOverrides{CurrentContext: cfg.Kubernetes.Context},
+	).ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("loading cluster configuration: %w", err)
+	}
+	client, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, err
+	}
+	dyn, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		return nil, err
+	}
+	mapper := restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(client.Discovery()))
+	p, err := New(cfg, client, &dynamicApplier{client: dyn, mapper: mapper}, opts)
+	if err != nil {
+		return nil, err
+	}
+	p.helm = helmConfig(cfg.Kubernetes.Context)
+	return p, nil
+}
+
+// New returns a Provider using client for namespaces and a for manifests.
+func New(cfg *config.Config, client kubernetes.Interface, a applier, opts Options) (*Provider, error) {
+	if cfg.Kubernetes == nil {
+		return nil, errors.New("eph.yaml has no kubernetes section")
+	}
+	if opts.DeleteTimeout <= 0 {
+		opts.DeleteTimeout = DefaultDeleteTimeout
+	}
+	inf, err := kinformer.New(client, kinformer.Options{})
+	if err != nil {
+		return nil, err
+	}
+	return &Provider{
+		Informer:     inf,
+		cfg:          cfg,
+		opts:         opts,
+		client:       client,
+		applier:      a,
+		pollInterval: 2 * time.Second,
+	}, nil
+}
+
+// Name implements providers.Provider.
+func (p *Provider) Name() string { return "kubernetes" }
+
+// CheckAccess verifies that the API server is reachable and that Eph may
+// create and delete namespaces.
+func (p *Provider) CheckAccess(ctx context.Context) error {
+	if _, err := p.client.Discovery().ServerVersion(); err != nil {
+		return fmt.Errorf("contacting the API server: %w", err)
+	}
+	for _, verb := range []string{"create", "delete"} {
+		review, err := p.client.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, &authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+				ResourceAttributes: &authorizationv1.ResourceAttributes{Verb: verb, Resource: "namespaces"},
+			},
+		}, metav1.CreateOptions{})
+		if err != nil {
+			return fmt.Errorf("checking permissions: %w", err)
+		}
+		if !review.Status.Allowed {
+			return fmt.Errorf("not allowed to %s namespaces", verb)
+		}
+	}
+	return nil
+}
+
+// List implements providers.Provider from the informer's cache.
+func (p *Provider) List(context.Context) ([]providers.Instance, error) {
+	var instances []providers.Instance
+	for _, env := range p.Informer.List() {
+		if env.Project == p.cfg.Name {
+			instances = append(instances, env.Instance())
+		}
+	}
+	return instances, nil
+}
+
+// Apply creates the environment's namespace and applies the manifests into
+// it. The commit annotation is only updated once every object has been
+// applied, so a failed apply is retried rather than mistaken for an
+// environment that is up to date and still rolling out.
+func (p *Provider) Apply(ctx context.Context, spec providers.Spec) (providers.Instance, error) {
+	inst := providers.Instance{Key: spec.Key, Name: spec.Name, CommitSHA: spec.CommitSHA}
+
+	namespace, err := Namespace(p.cfg, spec)
+	if err != nil {
+		return inst, err
+	}
+	objs, err := render(p.cfg, spec, p.opts)
+	if err != nil {
+		return inst, err
+	}
+
+	releases, err := loadHelmReleases(projectDir(p.cfg), p.cfg.Kubernetes.Manifests,
+		spec.Vars.Merge(template.Vars{template.VarRegistry: p.opts.Registry}))
+	if err != nil {
+		return inst, err
+	}
+
+	var reach routing
+	if exposes(p.cfg) {
+		if reach, err = routingFor(p.cfg, spec); err != nil {
+			return inst, err
+		}
+	}
+
+	previous, _ := p.Get(spec.Key)
+	if err := p.applyNamespace(ctx, namespace, spec, previous.CommitSHA, reach); err != nil {
+		return inst, err
+	}
+	if err := p.copyWildcardSecret(ctx, namespace, spec); err != nil {
+		return inst, err
+	}
+
+	var status rollout
+	for _, obj := range objs {
+		live, err := p.applier.Apply(ctx, namespace, obj)
+		if err != nil {
+			return inst, err
+		}
+		if err := status.add(live); err != nil {
+			return inst, err
+		}
+	}
+	if len(releases) > 0 {
+		if err := p.installReleases(ctx, namespace, spec, releases, &status); err != nil {
+			return inst, err
+		}
+	}
+
+	if err := p.applyNamespace(ctx, namespace, spec, spec.CommitSHA, reach); err != nil {
+		return inst, err
+	}
+	if reach.host != "" {
+		inst.URL, inst.Headers = reach.url(), reach.headers()
+	} else {
+		inst.URL = kinformer.IngressURL(status.ingresses)
+	}
+	inst.Ready, inst.Message = kinformer.RolledOut(status.deployments)
+	return inst, nil
+}
+
+// installReleases installs or upgrades the Helm releases of spec. Helm does
+// not return the objects it applies, so deployments are read back from the
+// cluster.
+func (p *Provider) installReleases(ctx context.Context, namespace string, spec providers.Spec, releases []helmRelease, status *rollout) error {
+	if p.helm == nil {
+		return errors.New("helm sources need a cluster connection")
+	}
+	cfg, err := p.helm(ctx, namespace)
+	if err != nil {
+		return fmt.Errorf("helm: %w", err)
+	}
+	post, err := newPostRenderer(p.cfg, spec, p.opts)
+	if err != nil {
+		return err
+	}
+	for _, r := range releases {
+		objs, err := installRelease(ctx, cfg, namespace, r, post, spec.CommitSHA)
+		if err != nil {
+			return err
+		}
+		for _, obj := range objs {
+			if obj.GroupVersionKind() == deploymentKind {
+				d, err := p.client.AppsV1().Deployments(namespace).Get(ctx, obj.GetName(), metav1.GetOptions{})
+				if err == nil {
+					status.deployments = append(status.deployments, d)
+					continue
+				}
+			}
+			if err := status.add(obj); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+var (
+	deploymentKind = appsv1.SchemeGroupVersion.WithKind("Deployment")
+	ingressKind    = networkingv1.SchemeGroupVersion.WithKind("Ingress")
+)
+
+// rollout collects the deployments and ingresses of an environment as they
+// were applied, to report its readiness and URL.
+type rollout struct {
+	deployments []*appsv1.Deployment
+	ingresses   []*networkingv1.Ingress
+}
+
+func (r *rollout) add(obj *unstructured.Unstructured) error {
+	switch obj.GroupVersionKind() {
+	case deploymentKind:
+		var d appsv1.Deployment
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &d); err != nil {
+			return err
+		}
+		r.deployments = append(r.deployments, &d)
+	case ingressKind:
+		var ing networkingv1.Ingress
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &ing); err != nil {
+			return err
+		}
+		r.ingresses = append(r.ingresses, &ing)
+	}
+	return nil
+}
+
+// applyNamespace applies the namespace of spec, annotated with the commit
+// deployed to it and, if Eph routes to the environment, how it is reached.
+func (p *Provider) applyNamespace(ctx context.Context, name string, spec providers.Spec, commit string, reach routing) error {
+	annotations := map[string]string{kinformer.AnnotationKey: spec.Key}
+	if commit != "" {
+		annotations[kinformer.AnnotationCommit] = commit
+	}
+	if reach.host != "" {
+		maps.Copy(annotations, reach.annotations())
+	}
+	ns := corev1ac.Namespace(name).
+		WithLabels(labels(p.cfg, spec)).
+		WithAnnotations(annotations)
+	_, err := p.client.CoreV1().Namespaces().Apply(ctx, ns, metav1.ApplyOptions{FieldManager: FieldManager, Force: true})
+	if err != nil {
+		return fmt.Errorf("applying namespace %s: %w", name, err)
+	}
+	return nil
+}
+
+// Destroy deletes the environment's namespace and waits until Kubernetes has
+// finished deleting everything in it, so that a recreated environment does
+// not collide with objects that are still terminating.
+func (p *Provider) Destroy(ctx context.Context, inst providers.Instance) error {
+	env, ok := p.Get(inst.Key)
+	if !ok {
+		return nil
+	}
+	if p.helm != nil && hasHelmSources(p.cfg.Kubernetes.Manifests) {
+		cfg, err := p.helm(ctx, env.Namespace)
+		if err != nil {
+			return fmt.Errorf("helm: %w", err)
+		}
+		if err := uninstallReleases(cfg); err != nil {
+			return err
+		}
+	}
+	err := p.client.CoreV1().Namespaces().Delete(ctx, env.Namespace, metav1.DeleteOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("deleting namespace %s: %w", env.Namespace, err)
+	}
+
+	err = wait.PollUntilContextTimeout(ctx, p.pollInterval, p.opts.DeleteTimeout, true, func(ctx context.Context) (bool, error) {
+		_, err := p.client.CoreV1().Namespaces().Get(ctx, env.Namespace, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	})
+	if err != nil {
+		return fmt.Errorf("waiting for namespace %s to be deleted: %w", env.Namespace, err)
+	}
+	return nil
+}
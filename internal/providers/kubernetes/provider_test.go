@@ -0,0 +1,291 @@
+package kubernetes
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/ephlabs/eph/internal/config"
+	"github.com/ephlabs/eph/internal/informers"
+	kinformer "github.com/ephlabs/eph/internal/informers/kubernetes"
+	"github.com/ephlabs/eph/internal/providers"
+	"github.com/ephlabs/eph/internal/template"
+)
+
+const manifests = `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: api
+spec:
+  template:
+    spec:
+      initContainers:
+        - name: migrate
+          image: ghcr.io/org/api:latest
+      containers:
+        - name: api
+          image: ghcr.io/org/api:latest
+          env:
+            - name: LOG_LEVEL
+              value: info
+            - name: DATABASE_URL
+              value: postgres://localhost
+        - name: proxy
+          image: envoyproxy/envoy:v1.30
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: api
+spec:
+  ports:
+    - port: 80
+---
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: api
+spec:
+  rules:
+    - host: myapp-calm-river.preview.example.com
+`
+
+const ephYAML = `
+version: "1"
+name: myapp
+providers:
+  primary: kubernetes
+environment:
+  images:
+    - name: api
+      repository: ghcr.io/org/api
+      tag: latest
+kubernetes:
+  namespace_template: "{project}-{name}"
+  manifests:
+    - path: k8s
+  images:
+    - name: envoyproxy/envoy
+      newName: "{registry}/envoy"
+      newTag: "v1.31"
+  imagePullSecrets:
+    - name: registry-credentials
+`
+
+// recordingApplier records applied objects and returns them as the live
+// objects. The fake dynamic client cannot server-side apply objects that do
+// not exist yet.
+type recordingApplier struct {
+	namespace string
+	applied   []*unstructured.Unstructured
+	err       error
+}
+
+func (a *recordingApplier) Apply(_ context.Context, namespace string, obj *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	if a.err != nil {
+		return nil, a.err
+	}
+	a.namespace = namespace
+	obj = obj.DeepCopy()
+	obj.SetNamespace(namespace)
+	a.applied = append(a.applied, obj)
+	return obj, nil
+}
+
+func (a *recordingApplier) get(kind string) *unstructured.Unstructured {
+	for _, obj := range a.applied {
+		if obj.GetKind() == kind {
+			return obj
+		}
+	}
+	return nil
+}
+
+func testProvider(t *testing.T, client *fake.Clientset, a applier) *Provider {
+	t.Helper()
+	return testProviderWith(t, client, a, ephYAML, map[string]string{"k8s/app.yaml": manifests})
+}
+
+// testProviderWith starts a provider for project, an eph.yaml, next to
+// files.
+func testProviderWith(t *testing.T, client *fake.Clientset, a applier, project string, files map[string]string) *Provider {
+	t.Helper()
+	dir := t.TempDir()
+	writeFiles(t, dir, files)
+	cfg, err := config.Parse(filepath.Join(dir, "eph.yaml"), []byte(project))
+	require.NoError(t, err)
+
+	p, err := New(cfg, client, a, Options{Registry: "registry.example.com", DeleteTimeout: 5 * time.Second})
+	require.NoError(t, err)
+	p.pollInterval = 10 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- p.Run(ctx) }()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	syncCtx, syncCancel := context.WithTimeout(ctx, 5*time.Second)
+	defer syncCancel()
+	require.NoError(t, informers.WaitForSync(syncCtx, p))
+	return p
+}
+
+func testSpec(commit string) providers.Spec {
+	return providers.Spec{
+		Key:       "org/myapp/pr/12",
+		Name:      "calm-river",
+		Project:   "myapp",
+		CommitSHA: commit,
+		Images:    map[string]string{"api": "ghcr.io/org/api:pr-12"},
+		Env:       map[string]string{"DATABASE_URL": "postgres://db", "EPH_ENVIRONMENT": "calm-river"},
+		Vars: template.PRVars("myapp", 12, "feature", commit).Merge(template.Vars{
+			template.VarName:            "calm-river",
+			template.VarEnvironmentName: "calm-river",
+			template.VarBaseDomain:      "preview.example.com",
+		}),
+	}
+}
+
+func TestApply(t *testing.T) {
+	client := fake.NewClientset()
+	a := &recordingApplier{}
+	p := testProvider(t, client, a)
+
+	inst, err := p.Apply(context.Background(), testSpec("abc123"))
+	require.NoError(t, err)
+	assert.Equal(t, "org/myapp/pr/12", inst.Key)
+	assert.Equal(t, "calm-river", inst.Name)
+	assert.Equal(t, "http://myapp-calm-river.preview.example.com", inst.URL)
+	assert.False(t, inst.Ready, "a new deployment has not rolled out")
+	assert.Equal(t, "api: 0 of 1 replicas updated", inst.Message)
+
+	ns, err := client.CoreV1().Namespaces().Get(context.Background(), "myapp-calm-river", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		kinformer.LabelManaged:     "true",
+		kinformer.LabelProject:     "myapp",
+		kinformer.LabelEnvironment: "calm-river",
+	}, ns.Labels)
+	assert.Equal(t, "org/myapp/pr/12", ns.Annotations[kinformer.AnnotationKey])
+	assert.Equal(t, "abc123", ns.Annotations[kinformer.AnnotationCommit])
+	require.NotEmpty(t, ns.ManagedFields)
+	assert.Equal(t, FieldManager, ns.ManagedFields[0].Manager)
+
+	assert.Equal(t, "myapp-calm-river", a.namespace)
+	require.Len(t, a.applied, 9, "six policies and three manifests")
+	assert.Equal(t, "ResourceQuota", a.applied[0].GetKind(), "policies are applied before workloads")
+	for _, obj := range a.applied {
+		assert.Equal(t, "calm-river", obj.GetLabels()[kinformer.LabelEnvironment], obj.GetKind())
+	}
+
+	deploy := a.get("Deployment")
+	containers, _, _ := unstructured.NestedSlice(deploy.Object, "spec", "template", "spec", "containers")
+	require.Len(t, containers, 2)
+	api := containers[0].(map[string]any)
+	assert.Equal(t, "ghcr.io/org/api:pr-12", api["image"])
+	assert.Equal(t, []any{
+		map[string]any{"name": "LOG_LEVEL", "value": "info"},
+		map[string]any{"name": "DATABASE_URL", "value": "postgres://db"},
+		map[string]any{"name": "EPH_ENVIRONMENT", "value": "calm-river"},
+	}, api["env"])
+	assert.Equal(t, "registry.example.com/envoy:v1.31", containers[1].(map[string]any)["image"])
+
+	initContainers, _, _ := unstructured.NestedSlice(deploy.Object, "spec", "template", "spec", "initContainers")
+	assert.Equal(t, "ghcr.io/org/api:pr-12", initContainers[0].(map[string]any)["image"])
+
+	secrets, _, _ := unstructured.NestedSlice(deploy.Object, "spec", "template", "spec", "imagePullSecrets")
+	assert.Equal(t, []any{map[string]any{"name": "registry-credentials"}}, secrets)
+}
+
+func TestApplyFailureKeepsPreviousCommit(t *testing.T) {
+	client := fake.NewClientset()
+	a := &recordingApplier{}
+	p := testProvider(t, client, a)
+
+	_, err := p.Apply(context.Background(), testSpec("abc123"))
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		env, ok := p.Get("org/myapp/pr/12")
+		return ok && env.CommitSHA == "abc123"
+	}, 5*time.Second, 10*time.Millisecond)
+
+	a.err = errors.New("admission webhook denied the request")
+	_, err = p.Apply(context.Background(), testSpec("def456"))
+	require.ErrorContains(t, err, "admission webhook")
+
+	ns, err := client.CoreV1().Namespaces().Get(context.Background(), "myapp-calm-river", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "abc123", ns.Annotations[kinformer.AnnotationCommit],
+		"the commit is only recorded once every object is applied")
+}
+
+func TestListAndDestroy(t *testing.T) {
+	namespace := func(name, project, key string) *corev1.Namespace {
+		return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+			Labels: map[string]string{
+				kinformer.LabelManaged:     "true",
+				kinformer.LabelProject:     project,
+				kinformer.LabelEnvironment: name,
+			},
+			Annotations: map[string]string{kinformer.AnnotationKey: key, kinformer.AnnotationCommit: "abc123"},
+		}}
+	}
+	client := fake.NewClientset(
+		namespace("myapp-calm-river", "myapp", "org/myapp/pr/12"),
+		namespace("other-bold-hill", "other", "org/other/pr/3"),
+	)
+	p := testProvider(t, client, &recordingApplier{})
+
+	instances, err := p.List(context.Background())
+	require.NoError(t, err)
+	require.Len(t, instances, 1, "environments of other projects are ignored")
+	assert.Equal(t, "org/myapp/pr/12", instances[0].Key)
+	assert.Equal(t, "abc123", instances[0].CommitSHA)
+
+	require.NoError(t, p.Destroy(context.Background(), instances[0]))
+	_, err = client.CoreV1().Namespaces().Get(context.Background(), "myapp-calm-river", metav1.GetOptions{})
+	assert.True(t, apierrors.IsNotFound(err))
+
+	assert.NoError(t, p.Destroy(context.Background(), providers.Instance{Key: "org/myapp/pr/404"}),
+		"destroying a missing environment is not an error")
+}
+
+func TestCheckAccess(t *testing.T) {
+	client := fake.NewClientset()
+	allowed := map[string]bool{"create": true}
+	client.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+		review.Status.Allowed = allowed[review.Spec.ResourceAttributes.Verb]
+		return true, review, nil
+	})
+	p, err := New(&config.Config{Name: "myapp", Kubernetes: &config.KubernetesConfig{}}, client, &recordingApplier{}, Options{})
+	require.NoError(t, err)
+
+	assert.EqualError(t, p.CheckAccess(context.Background()), "not allowed to delete namespaces")
+	allowed["delete"] = true
+	assert.NoError(t, p.CheckAccess(context.Background()))
+}
+
+func TestRegistered(t *testing.T) {
+	assert.Contains(t, providers.Registered(), "kubernetes")
+
+	_, err := providers.New("kubernetes", &config.Config{Name: "myapp"})
+	assert.ErrorContains(t, err, "no kubernetes section")
+}
@@ -0,0 +1,215 @@
+package kubernetes
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"path/filepath"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/action"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	sigsyaml "sigs.k8s.io/yaml"
+
+	"github.com/ephlabs/eph/internal/config"
+	kinformer "github.com/ephlabs/eph/internal/informers/kubernetes"
+	"github.com/ephlabs/eph/internal/providers"
+	"github.com/ephlabs/eph/internal/template"
+)
+
+// Namespace renders kubernetes.namespace_template for spec. It defaults to
+// the environment name.
+func Namespace(cfg *config.Config, spec providers.Spec) (string, error) {
+	tmpl := cfg.Kubernetes.NamespaceTemplate
+	if tmpl == "" {
+		return spec.Name, nil
+	}
+	ns, err := template.RenderLabel(tmpl, spec.Vars)
+	if err != nil {
+		return "", fmt.Errorf("kubernetes.namespace_template: %w", err)
+	}
+	return ns, nil
+}
+
+// Render returns the objects Apply would apply for spec, without contacting
+// the cluster: the environment's namespace first, then its quota, limits and
+// network policies, then the manifests and the generated route, then the
+// objects of Helm releases. It is meant for debugging eph.yaml; unlike Apply
+// it cannot tell cluster-scoped kinds apart, so every manifest is shown in the
+// environment's namespace, and it does not show the copy of a wildcard TLS
+// secret.
+func Render(cfg *config.Config, spec providers.Spec, opts Options) ([]*unstructured.Unstructured, error) {
+	namespace, err := Namespace(cfg, spec)
+	if err != nil {
+		return nil, err
+	}
+	objs, err := render(cfg, spec, opts)
+	if err != nil {
+		return nil, err
+	}
+	charts, err := renderCharts(cfg, spec, opts, namespace)
+	if err != nil {
+		return nil, err
+	}
+	objs = append(objs, charts...)
+
+	ns := &unstructured.Unstructured{}
+	ns.SetAPIVersion("v1")
+	ns.SetKind("Namespace")
+	ns.SetName(namespace)
+	ns.SetLabels(labels(cfg, spec))
+	annotations := map[string]string{
+		kinformer.AnnotationKey:    spec.Key,
+		kinformer.AnnotationCommit: spec.CommitSHA,
+	}
+	if exposes(cfg) {
+		reach, err := routingFor(cfg, spec)
+		if err != nil {
+			return nil, err
+		}
+		maps.Copy(annotations, reach.annotations())
+	}
+	ns.SetAnnotations(annotations)
+	for _, obj := range objs {
+		obj.SetNamespace(namespace)
+	}
+	return append([]*unstructured.Unstructured{ns}, objs...), nil
+}
+
+// renderCharts renders the Helm sources client-side, as helm template does.
+func renderCharts(cfg *config.Config, spec providers.Spec, opts Options, namespace string) ([]*unstructured.Unstructured, error) {
+	releases, err := loadHelmReleases(projectDir(cfg), cfg.Kubernetes.Manifests,
+		spec.Vars.Merge(template.Vars{template.VarRegistry: opts.Registry}))
+	if err != nil || len(releases) == 0 {
+		return nil, err
+	}
+	post, err := newPostRenderer(cfg, spec, opts)
+	if err != nil {
+		return nil, err
+	}
+	var objs []*unstructured.Unstructured
+	for _, r := range releases {
+		install := action.NewInstall(&action.Configuration{Log: func(string, ...any) {}})
+		install.DryRun = true
+		install.ClientOnly = true
+		install.ReleaseName = r.name
+		install.Namespace = namespace
+		install.PostRenderer = post
+		rel, err := install.Run(r.chart, r.values)
+		if err != nil {
+			return nil, fmt.Errorf("helm release %s: %w", r.name, err)
+		}
+		relObjs, err := decodeManifests(strings.NewReader(rel.Manifest))
+		if err != nil {
+			return nil, fmt.Errorf("helm release %s: %w", r.name, err)
+		}
+		objs = append(objs, relObjs...)
+	}
+	return objs, nil
+}
+
+// WriteYAML writes objs as a multi-document YAML stream.
+func WriteYAML(w io.Writer, objs []*unstructured.Unstructured) error {
+	for i, obj := range objs {
+		data, err := sigsyaml.Marshal(obj.Object)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			if _, err := io.WriteString(w, "---\n"); err != nil {
+				return err
+			}
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func labels(cfg *config.Config, spec providers.Spec) map[string]string {
+	return map[string]string{
+		kinformer.LabelManaged:     "true",
+		kinformer.LabelProject:     cfg.Name,
+		kinformer.LabelEnvironment: spec.Name,
+	}
+}
+
+// projectDir is the directory manifest paths are relative to: the one
+// containing the base eph.yaml.
+func projectDir(cfg *config.Config) string {
+	if files := cfg.Files(); len(files) > 0 {
+		return filepath.Dir(files[0])
+	}
+	return ""
+}
+
+// render loads the path and kustomization sources and prepares them for
+// spec, after the namespace's policies so that quotas are in place before
+// any pod is created, and followed by the generated Ingress or HTTPRoute.
+func render(cfg *config.Config, spec providers.Spec, opts Options) ([]*unstructured.Unstructured, error) {
+	objs, err := policies(cfg, opts)
+	if err != nil {
+		return nil, err
+	}
+	manifests, err := loadManifests(projectDir(cfg), cfg.Kubernetes.Manifests)
+	if err != nil {
+		return nil, err
+	}
+	objs = append(objs, manifests...)
+	rt, err := route(cfg, spec)
+	if err != nil {
+		return nil, err
+	}
+	if rt != nil {
+		objs = append(objs, rt)
+	}
+	post, err := newPostRenderer(cfg, spec, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := post.prepare(objs); err != nil {
+		return nil, err
+	}
+	return objs, nil
+}
+
+// newPostRenderer returns the transformations every object of spec goes
+// through, whichever source it comes from.
+func newPostRenderer(cfg *config.Config, spec providers.Spec, opts Options) (postRenderer, error) {
+	images, err := images(cfg, spec, opts)
+	if err != nil {
+		return postRenderer{}, err
+	}
+	return postRenderer{
+		images:  images,
+		env:     spec.Env,
+		labels:  labels(cfg, spec),
+		secrets: cfg.Kubernetes.ImagePullSecrets,
+	}, nil
+}
+
+// images renders kubernetes.images and maps the repositories of
+// environment.images to the references resolved for spec.
+func images(cfg *config.Config, spec providers.Spec, opts Options) (imageRewriter, error) {
+	vars := spec.Vars.Merge(template.Vars{template.VarRegistry: opts.Registry})
+	var r imageRewriter
+	for i, img := range cfg.Kubernetes.Images {
+		o := imageOverride{Name: img.Name, Digest: img.Digest}
+		var err error
+		if o.NewName, err = template.Render(img.NewName, vars); err != nil {
+			return r, fmt.Errorf("kubernetes.images[%d].newName: %w", i, err)
+		}
+		if o.NewTag, err = template.Render(img.NewTag, vars); err != nil {
+			return r, fmt.Errorf("kubernetes.images[%d].newTag: %w", i, err)
+		}
+		r.overrides = append(r.overrides, o)
+	}
+	r.resolved = map[string]string{}
+	for _, img := range cfg.Environment.Images {
+		if ref, ok := spec.Images[img.Name]; ok && img.Repository != "" {
+			r.resolved[img.Repository] = ref
+		}
+	}
+	return r, nil
+}
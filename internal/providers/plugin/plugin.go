@@ -0,0 +1,458 @@
+// Package plugin runs providers that are not built into ephd as separate
+// processes, speaking the eph.provider.v1 gRPC protocol over a Unix socket.
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	providerv1 "github.com/ephlabs/eph/api/eph/provider/v1"
+	"github.com/ephlabs/eph/internal/config"
+	"github.com/ephlabs/eph/internal/log"
+	"github.com/ephlabs/eph/internal/providers"
+	"github.com/ephlabs/eph/pkg/provider"
+)
+
+const (
+	// ProtocolVersion is the newest version of eph.provider.v1 ephd speaks.
+	ProtocolVersion uint32 = 1
+
+	// The environment of plugin processes, shared with the SDK.
+	EnvSocket      = provider.EnvSocket
+	EnvMagicCookie = provider.EnvMagicCookie
+	MagicCookie    = provider.MagicCookie
+
+	// BinaryPrefix prefixes the names of plugin binaries: the provider
+	// "nomad" is served by eph-provider-nomad.
+	BinaryPrefix = "eph-provider-"
+)
+
+// SupportedVersions are the protocol versions ephd speaks, offered to
+// plugins in the handshake.
+var SupportedVersions = []uint32{ProtocolVersion}
+
+const (
+	DefaultStartTimeout = 10 * time.Second
+	DefaultStopTimeout  = 5 * time.Second
+)
+
+// Options configures how a plugin binary is run.
+type Options struct {
+	Path string
+	Args []string
+
+	// Env is added to the environment ephd passes on to the plugin.
+	Env []string
+
+	// StartTimeout bounds starting the plugin and the handshake.
+	StartTimeout time.Duration
+
+	// StopTimeout is how long a plugin has to exit after SIGTERM before it
+	// is killed.
+	StopTimeout time.Duration
+}
+
+// Provider adapts a plugin to providers.Provider. The plugin is started on
+// first use and restarted by the next call after it crashes; Run restarts
+// it eagerly.
+type Provider struct {
+	name string
+	cfg  *config.Config
+	opts Options
+
+	mu   sync.Mutex
+	proc *process
+}
+
+// New returns the provider name served by the plugin binary at opts.Path,
+// for cfg. The plugin is not started until it is first used.
+func New(name string, cfg *config.Config, opts Options) (*Provider, error) {
+	if opts.Path == "" {
+		return nil, errors.New("plugin path is required")
+	}
+	if opts.StartTimeout <= 0 {
+		opts.StartTimeout = DefaultStartTimeout
+	}
+	if opts.StopTimeout <= 0 {
+		opts.StopTimeout = DefaultStopTimeout
+	}
+	return &Provider{name: name, cfg: cfg, opts: opts}, nil
+}
+
+var (
+	discoveredMu sync.Mutex
+	discovered   = map[string]string{}
+)
+
+// Discover registers every executable named eph-provider-<name> in dir as
+// the provider <name>, and makes <name> acceptable in providers.primary and
+// providers.fallback. It returns the names of the providers found.
+func Discover(dir string) ([]string, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading plugin directory: %w", err)
+	}
+	discoveredMu.Lock()
+	defer discoveredMu.Unlock()
+
+	var names []string
+	for _, e := range entries {
+		name, ok := strings.CutPrefix(e.Name(), BinaryPrefix)
+		if !ok || name == "" {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil || !info.Mode().IsRegular() || info.Mode().Perm()&0o111 == 0 {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		if prev, ok := discovered[name]; ok && prev == path {
+			names = append(names, name)
+			continue
+		}
+		if slices.Contains(providers.Registered(), name) {
+			return names, fmt.Errorf("plugin %s: provider %q is already registered", path, name)
+		}
+		providers.Register(name, func(cfg *config.Config) (providers.Provider, error) {
+			return New(name, cfg, Options{Path: path})
+		})
+		config.AddKnownProvider(name)
+		discovered[name] = path
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// Name is the provider's name in eph.yaml.
+func (p *Provider) Name() string { return p.name }
+
+// Run keeps the plugin running until ctx is cancelled, then stops it. It
+// returns an error when the plugin exits, so that the supervisor restarts
+// it with backoff.
+func (p *Provider) Run(ctx context.Context) error {
+	proc, err := p.process(ctx)
+	if err != nil {
+		return err
+	}
+	select {
+	case <-ctx.Done():
+		p.Close()
+		return nil
+	case <-proc.exited:
+		return fmt.Errorf("plugin %s exited: %v", p.name, proc.err)
+	}
+}
+
+// Close stops the plugin, if it is running.
+func (p *Provider) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.proc != nil {
+		p.proc.stop(p.opts.StopTimeout)
+		p.proc = nil
+	}
+	return nil
+}
+
+// process returns the running plugin, starting it if it is not running.
+func (p *Provider) process(ctx context.Context) (*process, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.proc != nil && p.proc.running() {
+		return p.proc, nil
+	}
+	if p.proc != nil {
+		log.Warn(ctx, "Provider plugin exited, restarting", "provider", p.name, "error", p.proc.err)
+		p.proc.stop(0)
+		p.proc = nil
+	}
+	proc, err := start(ctx, p.name, p.cfg, p.opts)
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s: %w", p.name, err)
+	}
+	p.proc = proc
+	return proc, nil
+}
+
+// Client returns a client for the plugin, starting it if it is not
+// running, for the parts of the protocol providers.Provider does not cover.
+func (p *Provider) Client(ctx context.Context) (providerv1.ProviderClient, error) {
+	proc, err := p.process(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return proc.client, nil
+}
+
+// Kill kills the plugin without letting it shut down, as a crash would.
+// The next call restarts it.
+func (p *Provider) Kill() error {
+	p.mu.Lock()
+	proc := p.proc
+	p.mu.Unlock()
+	if proc == nil || !proc.running() {
+		return nil
+	}
+	if err := proc.cmd.Process.Kill(); err != nil {
+		return err
+	}
+	<-proc.exited
+	return nil
+}
+
+// CheckAccess starts the plugin, has it validate eph.yaml, and asks it to
+// verify access to its backend.
+func (p *Provider) CheckAccess(ctx context.Context) error {
+	c, err := p.Client(ctx)
+	if err != nil {
+		return err
+	}
+	data, err := encodeConfig(p.cfg)
+	if err != nil {
+		return err
+	}
+	res, err := c.ValidateConfiguration(ctx, &providerv1.ValidateConfigurationRequest{Config: data})
+	if err != nil {
+		return fmt.Errorf("validating configuration: %s", message(err))
+	}
+	if !res.Valid {
+		var msgs []string
+		for _, e := range res.Errors {
+			msgs = append(msgs, e.Path+": "+e.Message)
+		}
+		return fmt.Errorf("invalid configuration: %s", strings.Join(msgs, "; "))
+	}
+	if _, err := c.CheckAccess(ctx, &providerv1.Empty{}); err != nil {
+		return errors.New(message(err))
+	}
+	return nil
+}
+
+// List returns the environments the plugin manages.
+func (p *Provider) List(ctx context.Context) ([]providers.Instance, error) {
+	c, err := p.Client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.ListEnvironments(ctx, &providerv1.Empty{})
+	if err != nil {
+		return nil, fmt.Errorf("listing environments: %s", message(err))
+	}
+	out := make([]providers.Instance, 0, len(resp.Environments))
+	for _, env := range resp.Environments {
+		out = append(out, instance(env))
+	}
+	return out, nil
+}
+
+// Apply creates or updates the environment, waiting for the plugin to
+// report the outcome.
+func (p *Provider) Apply(ctx context.Context, spec providers.Spec) (providers.Instance, error) {
+	c, err := p.Client(ctx)
+	if err != nil {
+		return providers.Instance{}, err
+	}
+	stream, err := c.CreateEnvironment(ctx, &providerv1.CreateEnvironmentRequest{Spec: environmentSpec(spec)})
+	if err != nil {
+		return providers.Instance{}, fmt.Errorf("creating environment: %s", message(err))
+	}
+	done, err := p.wait(ctx, "create", stream)
+	if err != nil {
+		return providers.Instance{}, fmt.Errorf("creating environment: %w", err)
+	}
+	inst := instance(done.Environment)
+	if inst.Key == "" {
+		inst.Key, inst.Name, inst.CommitSHA = spec.Key, spec.Name, spec.CommitSHA
+	}
+	return inst, nil
+}
+
+// Destroy removes the environment, waiting for the plugin to report the
+// outcome.
+func (p *Provider) Destroy(ctx context.Context, inst providers.Instance) error {
+	c, err := p.Client(ctx)
+	if err != nil {
+		return err
+	}
+	stream, err := c.DestroyEnvironment(ctx, &providerv1.DestroyEnvironmentRequest{Environment: environment(inst)})
+	if err != nil {
+		return fmt.Errorf("destroying environment: %s", message(err))
+	}
+	if _, err := p.wait(ctx, "destroy", stream); err != nil {
+		return fmt.Errorf("destroying environment: %w", err)
+	}
+	return nil
+}
+
+// Capabilities implements providers.CapabilityReporter with the plugin's
+// answer to GetCapabilities.
+func (p *Provider) Capabilities(ctx context.Context) (providers.Capabilities, error) {
+	c, err := p.Client(ctx)
+	if err != nil {
+		return providers.Capabilities{}, err
+	}
+	caps, err := c.GetCapabilities(ctx, &providerv1.Empty{})
+	if err != nil {
+		return providers.Capabilities{}, fmt.Errorf("getting capabilities: %s", message(err))
+	}
+	return providers.Capabilities{
+		ScaleToZero:          caps.SupportsScaleToZero,
+		CustomDomains:        caps.SupportsCustomDomains,
+		PersistentStorage:    caps.SupportsPersistentStorage,
+		DatabaseProvisioning: caps.SupportsDatabaseProvisioning,
+		Databases:            caps.SupportedDatabases,
+	}, nil
+}
+
+// Logs implements providers.LogStreamer with the plugin's StreamLogs. A
+// plugin that does not stream logs fails with providers.ErrNotSupported.
+func (p *Provider) Logs(ctx context.Context, inst providers.Instance, opts providers.LogOptions, send func(providers.LogEntry) error) error {
+	c, err := p.Client(ctx)
+	if err != nil {
+		return err
+	}
+	req := &providerv1.StreamLogsRequest{
+		Key:       inst.Key,
+		Service:   opts.Service,
+		Follow:    opts.Follow,
+		TailLines: int64(opts.TailLines),
+	}
+	if !opts.Since.IsZero() {
+		req.Since = timestamppb.New(opts.Since)
+	}
+	stream, err := c.StreamLogs(ctx, req)
+	if err != nil {
+		return fmt.Errorf("streaming logs: %w", rpcError(err))
+	}
+	for {
+		e, err := stream.Recv()
+		if errors.Is(err, io.EOF) || ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("streaming logs: %w", rpcError(err))
+		}
+		entry := providers.LogEntry{Service: e.Service, Stream: e.Stream, Line: e.Line}
+		if e.Timestamp != nil {
+			entry.Time = e.Timestamp.AsTime()
+		}
+		if err := send(entry); err != nil {
+			return err
+		}
+	}
+}
+
+// Metrics implements providers.MetricsReporter with the plugin's
+// GetMetrics. A plugin that does not report metrics fails with
+// providers.ErrNotSupported.
+func (p *Provider) Metrics(ctx context.Context, inst providers.Instance) (providers.Metrics, error) {
+	c, err := p.Client(ctx)
+	if err != nil {
+		return providers.Metrics{}, err
+	}
+	m, err := c.GetMetrics(ctx, &providerv1.GetMetricsRequest{Key: inst.Key})
+	if err != nil {
+		return providers.Metrics{}, fmt.Errorf("getting metrics: %w", rpcError(err))
+	}
+	return providers.Metrics{CPUMillicores: m.CpuMillicores, MemoryBytes: m.MemoryBytes, Custom: m.Custom}, nil
+}
+
+// wait consumes an operation's updates until the final one.
+func (p *Provider) wait(ctx context.Context, op string, stream grpc.ServerStreamingClient[providerv1.OperationUpdate]) (*providerv1.OperationUpdate, error) {
+	for {
+		u, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("plugin ended the operation without a result")
+		}
+		if err != nil {
+			return nil, errors.New(message(err))
+		}
+		switch u.Status {
+		case providerv1.OperationStatus_OPERATION_STATUS_SUCCEEDED:
+			return u, nil
+		case providerv1.OperationStatus_OPERATION_STATUS_FAILED:
+			return nil, errors.New(u.Message)
+		default:
+			log.Debug(ctx, "Provider operation in progress",
+				"provider", p.name,
+				"operation", op,
+				"message", u.Message,
+				"progress", u.ProgressPercent)
+		}
+	}
+}
+
+// message extracts the plugin's message from a gRPC error.
+func message(err error) string {
+	if st, ok := status.FromError(err); ok {
+		return st.Message()
+	}
+	return err.Error()
+}
+
+// rpcError is the plugin's message from a gRPC error, which wraps
+// providers.ErrNotSupported if the plugin does not implement the call.
+func rpcError(err error) error {
+	if status.Code(err) == codes.Unimplemented {
+		return fmt.Errorf("%w: %s", providers.ErrNotSupported, message(err))
+	}
+	return errors.New(message(err))
+}
+
+func environmentSpec(spec providers.Spec) *providerv1.EnvironmentSpec {
+	return &providerv1.EnvironmentSpec{
+		Key:        spec.Key,
+		Name:       spec.Name,
+		Project:    spec.Project,
+		Repository: spec.Repository,
+		RefType:    spec.RefType,
+		RefName:    spec.RefName,
+		CommitSha:  spec.CommitSHA,
+		Images:     spec.Images,
+		Env:        spec.Env,
+		Vars:       spec.Vars,
+	}
+}
+
+func environment(inst providers.Instance) *providerv1.Environment {
+	return &providerv1.Environment{
+		Key:       inst.Key,
+		Name:      inst.Name,
+		CommitSha: inst.CommitSHA,
+		Url:       inst.URL,
+		Headers:   inst.Headers,
+		Ready:     inst.Ready,
+		Message:   inst.Message,
+	}
+}
+
+func instance(env *providerv1.Environment) providers.Instance {
+	if env == nil {
+		return providers.Instance{}
+	}
+	return providers.Instance{
+		Key:       env.Key,
+		Name:      env.Name,
+		CommitSHA: env.CommitSha,
+		URL:       env.Url,
+		Headers:   env.Headers,
+		Ready:     env.Ready,
+		Message:   env.Message,
+	}
+}
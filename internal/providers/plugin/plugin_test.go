@@ -0,0 +1,268 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"gopkg.in/yaml.v3"
+
+	providerv1 "github.com/ephlabs/eph/api/eph/provider/v1"
+	"github.com/ephlabs/eph/internal/config"
+	"github.com/ephlabs/eph/internal/providers"
+)
+
+// The test binary doubles as a plugin: started with envTestPlugin set, it
+// serves testPlugin instead of running the tests.
+const (
+	envTestPlugin        = "EPH_TEST_PLUGIN"
+	envTestPluginVersion = "EPH_TEST_PLUGIN_VERSION"
+)
+
+func TestMain(m *testing.M) {
+	if os.Getenv(envTestPlugin) != "" {
+		if err := serveTestPlugin(); err != nil {
+			os.Stderr.WriteString(err.Error() + "\n")
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func serveTestPlugin() error {
+	if os.Getenv(EnvMagicCookie) != MagicCookie {
+		return errors.New("not started by ephd")
+	}
+	l, err := net.Listen("unix", os.Getenv(EnvSocket))
+	if err != nil {
+		return err
+	}
+	srv := grpc.NewServer()
+	providerv1.RegisterProviderServer(srv, &testPlugin{envs: map[string]*providerv1.Environment{}})
+	return srv.Serve(l)
+}
+
+type testPlugin struct {
+	providerv1.UnimplementedProviderServer
+
+	mu      sync.Mutex
+	project string
+	envs    map[string]*providerv1.Environment
+}
+
+func (p *testPlugin) Handshake(_ context.Context, req *providerv1.HandshakeRequest) (*providerv1.HandshakeResponse, error) {
+	var cfg config.Config
+	if err := yaml.Unmarshal(req.Config, &cfg); err != nil {
+		return nil, err
+	}
+	p.project = cfg.Name
+	v := uint32(1)
+	if os.Getenv(envTestPluginVersion) == "99" {
+		v = 99
+	}
+	return &providerv1.HandshakeResponse{ProtocolVersion: v, Name: os.Getenv(envTestPlugin), Version: "0.0.1"}, nil
+}
+
+func (p *testPlugin) ValidateConfiguration(context.Context, *providerv1.ValidateConfigurationRequest) (*providerv1.ValidationResult, error) {
+	return &providerv1.ValidationResult{Valid: true}, nil
+}
+
+func (p *testPlugin) CheckAccess(context.Context, *providerv1.Empty) (*providerv1.Empty, error) {
+	return &providerv1.Empty{}, nil
+}
+
+func (p *testPlugin) GetCapabilities(context.Context, *providerv1.Empty) (*providerv1.ProviderCapabilities, error) {
+	return &providerv1.ProviderCapabilities{SupportsPersistentStorage: true, SupportsDatabaseProvisioning: true, SupportedDatabases: []string{"postgres"}}, nil
+}
+
+func (p *testPlugin) CreateEnvironment(req *providerv1.CreateEnvironmentRequest, stream grpc.ServerStreamingServer[providerv1.OperationUpdate]) error {
+	spec := req.Spec
+	switch spec.Name {
+	case "crash":
+		os.Exit(2)
+	case "broken":
+		return stream.Send(&providerv1.OperationUpdate{Status: providerv1.OperationStatus_OPERATION_STATUS_FAILED, Message: "image not found"})
+	}
+	if err := stream.Send(&providerv1.OperationUpdate{Status: providerv1.OperationStatus_OPERATION_STATUS_RUNNING, Message: "starting", ProgressPercent: 50}); err != nil {
+		return err
+	}
+	env := &providerv1.Environment{
+		Key:       spec.Key,
+		Name:      spec.Name,
+		CommitSha: spec.CommitSha,
+		Url:       "http://" + spec.Name + "." + p.project + ".test",
+		Ready:     true,
+	}
+	p.mu.Lock()
+	p.envs[spec.Key] = env
+	p.mu.Unlock()
+	return stream.Send(&providerv1.OperationUpdate{Status: providerv1.OperationStatus_OPERATION_STATUS_SUCCEEDED, Environment: env})
+}
+
+func (p *testPlugin) DestroyEnvironment(req *providerv1.DestroyEnvironmentRequest, stream grpc.ServerStreamingServer[providerv1.OperationUpdate]) error {
+	p.mu.Lock()
+	delete(p.envs, req.Environment.Key)
+	p.mu.Unlock()
+	return stream.Send(&providerv1.OperationUpdate{Status: providerv1.OperationStatus_OPERATION_STATUS_SUCCEEDED})
+}
+
+func (p *testPlugin) ListEnvironments(context.Context, *providerv1.Empty) (*providerv1.ListEnvironmentsResponse, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	resp := &providerv1.ListEnvironmentsResponse{}
+	for _, env := range p.envs {
+		resp.Environments = append(resp.Environments, env)
+	}
+	return resp, nil
+}
+
+// StreamLogs sends a line per service; GetMetrics is left unimplemented.
+func (p *testPlugin) StreamLogs(req *providerv1.StreamLogsRequest, stream grpc.ServerStreamingServer[providerv1.LogEntry]) error {
+	for _, svc := range []string{"web", "worker"} {
+		if req.Service != "" && req.Service != svc {
+			continue
+		}
+		err := stream.Send(&providerv1.LogEntry{Timestamp: req.Since, Service: svc, Stream: "stdout", Line: "started " + req.Key})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func testProvider(t *testing.T, env ...string) *Provider {
+	t.Helper()
+	p, err := New("testplugin", &config.Config{Name: "myapp"}, Options{
+		Path:         os.Args[0],
+		Args:         []string{"-test.run=^$"},
+		Env:          append([]string{envTestPlugin + "=testplugin"}, env...),
+		StartTimeout: 10 * time.Second,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { p.Close() })
+	return p
+}
+
+func testSpec(name string) providers.Spec {
+	return providers.Spec{Key: "org/myapp/pr/1", Name: name, Project: "myapp", CommitSHA: "abc123"}
+}
+
+func TestPlugin(t *testing.T) {
+	ctx := context.Background()
+	p := testProvider(t)
+	assert.Equal(t, "testplugin", p.Name())
+	require.NoError(t, p.CheckAccess(ctx))
+
+	caps, err := p.Capabilities(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, providers.Capabilities{PersistentStorage: true, DatabaseProvisioning: true, Databases: []string{"postgres"}}, caps)
+
+	inst, err := p.Apply(ctx, testSpec("calm-river"))
+	require.NoError(t, err)
+	assert.Equal(t, providers.Instance{
+		Key:       "org/myapp/pr/1",
+		Name:      "calm-river",
+		CommitSHA: "abc123",
+		URL:       "http://calm-river.myapp.test",
+		Ready:     true,
+	}, inst, "the plugin received eph.yaml in the handshake")
+
+	list, err := p.List(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []providers.Instance{inst}, list)
+
+	_, err = p.Apply(ctx, testSpec("broken"))
+	assert.EqualError(t, err, "creating environment: image not found")
+
+	require.NoError(t, p.Destroy(ctx, inst))
+	list, err = p.List(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, list)
+}
+
+func TestPluginTelemetry(t *testing.T) {
+	ctx := context.Background()
+	p := testProvider(t)
+	inst := providers.Instance{Key: "org/myapp/pr/1", Name: "calm-river"}
+	since := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	var entries []providers.LogEntry
+	err := p.Logs(ctx, inst, providers.LogOptions{Service: "worker", Since: since}, func(e providers.LogEntry) error {
+		entries = append(entries, e)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []providers.LogEntry{{Time: since, Service: "worker", Stream: "stdout", Line: "started org/myapp/pr/1"}}, entries)
+
+	_, err = p.Metrics(ctx, inst)
+	assert.ErrorIs(t, err, providers.ErrNotSupported)
+}
+
+func TestPluginVersionMismatch(t *testing.T) {
+	p := testProvider(t, envTestPluginVersion+"=99")
+	err := p.CheckAccess(context.Background())
+	assert.ErrorContains(t, err, "plugin testplugin: plugin speaks protocol version 99, ephd supports [1]")
+}
+
+func TestPluginRestart(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p := testProvider(t)
+	require.NoError(t, p.CheckAccess(ctx))
+
+	ran := make(chan error, 1)
+	go func() { ran <- p.Run(ctx) }()
+
+	_, err := p.Apply(ctx, testSpec("crash"))
+	require.Error(t, err)
+	select {
+	case err := <-ran:
+		assert.ErrorContains(t, err, "plugin testplugin exited")
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after the plugin crashed")
+	}
+
+	inst, err := p.Apply(ctx, testSpec("calm-river"))
+	require.NoError(t, err, "the next call restarts the plugin")
+	assert.True(t, inst.Ready)
+
+	go func() { ran <- p.Run(ctx) }()
+	cancel()
+	select {
+	case err := <-ran:
+		assert.NoError(t, err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("Run did not return after cancellation")
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "eph-provider-discovertest"), []byte("#!/bin/sh\n"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "eph-provider-notexecutable"), []byte("#!/bin/sh\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), nil, 0o755))
+
+	names, err := Discover(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"discovertest"}, names)
+	assert.Contains(t, providers.Registered(), "discovertest")
+	assert.Contains(t, config.KnownProviders(), "discovertest")
+
+	names, err = Discover(dir)
+	require.NoError(t, err, "discovering the same plugins again is a no-op")
+	assert.Equal(t, []string{"discovertest"}, names)
+
+	other := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(other, "eph-provider-discovertest"), []byte("#!/bin/sh\n"), 0o755))
+	_, err = Discover(other)
+	assert.ErrorContains(t, err, `provider "discovertest" is already registered`)
+}
@@ -0,0 +1,197 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"sync"
+	"syscall"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
+	"google.golang.org/grpc/credentials/insecure"
+	"gopkg.in/yaml.v3"
+
+	providerv1 "github.com/ephlabs/eph/api/eph/provider/v1"
+	"github.com/ephlabs/eph/internal/config"
+	"github.com/ephlabs/eph/internal/log"
+	"github.com/ephlabs/eph/pkg/version"
+)
+
+var connectBackoff = backoff.Config{
+	BaseDelay:  20 * time.Millisecond,
+	Multiplier: 1.6,
+	Jitter:     0.2,
+	MaxDelay:   time.Second,
+}
+
+// process is a running plugin binary and the connection to it.
+type process struct {
+	cmd    *exec.Cmd
+	dir    string
+	conn   *grpc.ClientConn
+	client providerv1.ProviderClient
+
+	// exited is closed once the process has exited, after err is set.
+	exited chan struct{}
+	err    error
+}
+
+// start launches the plugin and performs the handshake. ctx only bounds the
+// handshake: the process runs until stop is called.
+func start(ctx context.Context, name string, cfg *config.Config, opts Options) (*process, error) {
+	dir, err := os.MkdirTemp("", "eph-plugin-")
+	if err != nil {
+		return nil, err
+	}
+	socket := filepath.Join(dir, "plugin.sock")
+
+	out := &lineLogger{ctx: log.WithProvider(context.Background(), name)}
+	cmd := exec.Command(opts.Path, opts.Args...)
+	cmd.Env = append(os.Environ(), opts.Env...)
+	cmd.Env = append(cmd.Env, EnvSocket+"="+socket, EnvMagicCookie+"="+MagicCookie)
+	cmd.Stdout, cmd.Stderr = out, out
+	if err := cmd.Start(); err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("starting %s: %w", opts.Path, err)
+	}
+	p := &process{cmd: cmd, dir: dir, exited: make(chan struct{})}
+	go func() {
+		p.err = cmd.Wait()
+		close(p.exited)
+	}()
+
+	// The socket does not exist until the plugin listens on it, so retry
+	// connecting more eagerly than gRPC's default backoff.
+	p.conn, err = grpc.NewClient("unix://"+socket,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithConnectParams(grpc.ConnectParams{Backoff: connectBackoff}))
+	if err != nil {
+		p.stop(0)
+		return nil, err
+	}
+	p.client = providerv1.NewProviderClient(p.conn)
+	if err := p.handshake(ctx, name, cfg, opts.StartTimeout); err != nil {
+		p.stop(0)
+		return nil, err
+	}
+	return p, nil
+}
+
+// handshake waits for the plugin to serve, negotiates the protocol version
+// and hands it the project's configuration.
+func (p *process) handshake(ctx context.Context, name string, cfg *config.Config, timeout time.Duration) error {
+	data, err := encodeConfig(cfg)
+	if err != nil {
+		return err
+	}
+	var dir string
+	if files := cfg.Files(); len(files) > 0 {
+		dir = filepath.Dir(files[0])
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	go func() {
+		select {
+		case <-p.exited:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	resp, err := p.client.Handshake(ctx, &providerv1.HandshakeRequest{
+		ProtocolVersions: SupportedVersions,
+		EphVersion:       version.Version,
+		Config:           data,
+		ConfigDir:        dir,
+	}, grpc.WaitForReady(true))
+	if err != nil {
+		select {
+		case <-p.exited:
+			return fmt.Errorf("plugin exited before the handshake: %v", p.err)
+		default:
+			return fmt.Errorf("handshake: %s", message(err))
+		}
+	}
+	if !slices.Contains(SupportedVersions, resp.ProtocolVersion) {
+		return fmt.Errorf("plugin speaks protocol version %d, ephd supports %v", resp.ProtocolVersion, SupportedVersions)
+	}
+	if resp.Name != name {
+		return fmt.Errorf("plugin reports name %q, expected %q", resp.Name, name)
+	}
+	log.Info(ctx, "Provider plugin started",
+		"provider", name,
+		"version", resp.Version,
+		"protocol_version", resp.ProtocolVersion,
+		"pid", p.cmd.Process.Pid)
+	return nil
+}
+
+// encodeConfig encodes the project's configuration for the plugin.
+func encodeConfig(cfg *config.Config) ([]byte, error) {
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("encoding eph.yaml: %w", err)
+	}
+	return data, nil
+}
+
+// running reports whether the process has not exited.
+func (p *process) running() bool {
+	select {
+	case <-p.exited:
+		return false
+	default:
+		return true
+	}
+}
+
+// stop closes the connection and terminates the process, killing it if it
+// has not exited after timeout.
+func (p *process) stop(timeout time.Duration) {
+	if p.conn != nil {
+		p.conn.Close()
+	}
+	if p.running() {
+		if timeout > 0 {
+			_ = p.cmd.Process.Signal(syscall.SIGTERM)
+		}
+		select {
+		case <-p.exited:
+		case <-time.After(timeout):
+			_ = p.cmd.Process.Kill()
+			<-p.exited
+		}
+	}
+	os.RemoveAll(p.dir)
+}
+
+// lineLogger logs what a plugin writes to stdout and stderr, one line at a
+// time.
+type lineLogger struct {
+	ctx context.Context
+	mu  sync.Mutex
+	buf []byte
+}
+
+func (l *lineLogger) Write(b []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.buf = append(l.buf, b...)
+	for {
+		i := bytes.IndexByte(l.buf, '\n')
+		if i < 0 {
+			break
+		}
+		if line := bytes.TrimRight(l.buf[:i], "\r"); len(line) > 0 {
+			log.Info(l.ctx, "Plugin output", "line", string(line))
+		}
+		l.buf = l.buf[i+1:]
+	}
+	return len(b), nil
+}
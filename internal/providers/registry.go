@@ -0,0 +1,56 @@
+package providers
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/ephlabs/eph/internal/config"
+)
+
+// Factory creates a provider for a project.
+type Factory func(cfg *config.Config) (Provider, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Factory{}
+)
+
+// Register makes a provider available under name. Provider packages call it
+// from init, and ephd imports the ones it ships with. It panics if name is
+// registered twice.
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, dup := registry[name]; dup {
+		panic("providers: Register called twice for " + name)
+	}
+	registry[name] = factory
+}
+
+// New creates the provider registered under name for cfg.
+func New(name string, cfg *config.Config) (Provider, error) {
+	registryMu.RLock()
+	factory, ok := registry[name]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("provider %q is not available in this build (available: %v)", name, Registered())
+	}
+	p, err := factory(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("provider %s: %w", name, err)
+	}
+	return p, nil
+}
+
+// Registered returns the names of all registered providers, sorted.
+func Registered() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,46 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ephlabs/eph/internal/config"
+)
+
+type stubProvider struct{ name string }
+
+func (p stubProvider) Name() string                           { return p.name }
+func (stubProvider) CheckAccess(context.Context) error        { return nil }
+func (stubProvider) List(context.Context) ([]Instance, error) { return nil, nil }
+func (stubProvider) Apply(_ context.Context, s Spec) (Instance, error) {
+	return Instance{Key: s.Key, Name: s.Name}, nil
+}
+func (stubProvider) Destroy(context.Context, Instance) error { return nil }
+
+func TestRegistry(t *testing.T) {
+	Register("stub-registry-test", func(*config.Config) (Provider, error) {
+		return stubProvider{name: "stub-registry-test"}, nil
+	})
+	Register("broken-registry-test", func(*config.Config) (Provider, error) {
+		return nil, errors.New("missing kubeconfig")
+	})
+
+	p, err := New("stub-registry-test", &config.Config{})
+	require.NoError(t, err)
+	assert.Equal(t, "stub-registry-test", p.Name())
+	assert.Contains(t, Registered(), "stub-registry-test")
+
+	_, err = New("broken-registry-test", &config.Config{})
+	assert.ErrorContains(t, err, "provider broken-registry-test: missing kubeconfig")
+
+	_, err = New("nomad", &config.Config{})
+	assert.ErrorContains(t, err, `provider "nomad" is not available`)
+
+	assert.Panics(t, func() {
+		Register("stub-registry-test", func(*config.Config) (Provider, error) { return nil, nil })
+	})
+}
@@ -0,0 +1,49 @@
+package reconciler
+
+import (
+	"cmp"
+	"slices"
+)
+
+// ActionType is what the reconciler must do for a key.
+type ActionType string
+
+const (
+	ActionCreate ActionType = "create"
+	ActionUpdate ActionType = "update"
+	ActionDelete ActionType = "delete"
+)
+
+// Action is one step towards the desired state. Desired is the zero value
+// for deletes and Actual is the zero value for creates.
+type Action[K cmp.Ordered, D, A any] struct {
+	Type    ActionType
+	Key     K
+	Desired D
+	Actual  A
+}
+
+// Diff compares desired and actual state and returns the actions needed to
+// converge, sorted by key. Keys present in both are updated unless upToDate
+// reports that nothing needs to change.
+func Diff[K cmp.Ordered, D, A any](desired map[K]D, actual map[K]A, upToDate func(D, A) bool) []Action[K, D, A] {
+	var actions []Action[K, D, A]
+	for k, d := range desired {
+		a, ok := actual[k]
+		switch {
+		case !ok:
+			actions = append(actions, Action[K, D, A]{Type: ActionCreate, Key: k, Desired: d})
+		case !upToDate(d, a):
+			actions = append(actions, Action[K, D, A]{Type: ActionUpdate, Key: k, Desired: d, Actual: a})
+		}
+	}
+	for k, a := range actual {
+		if _, ok := desired[k]; !ok {
+			actions = append(actions, Action[K, D, A]{Type: ActionDelete, Key: k, Actual: a})
+		}
+	}
+	slices.SortFunc(actions, func(x, y Action[K, D, A]) int {
+		return cmp.Compare(x.Key, y.Key)
+	})
+	return actions
+}
@@ -0,0 +1,300 @@
+package reconciler
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/ephlabs/eph/internal/log"
+)
+
+const (
+	DefaultInterval    = 30 * time.Second
+	DefaultJitter      = 0.1
+	DefaultBaseBackoff = time.Second
+	DefaultMaxBackoff  = 30 * time.Second
+	DefaultWorkers     = 8
+)
+
+// Source lists the state on one side of the reconciliation: what should
+// exist (e.g. labelled PRs from an informer) or what does exist (e.g.
+// environments reported by a provider).
+type Source[K comparable, V any] interface {
+	List(ctx context.Context) (map[K]V, error)
+}
+
+// SourceFunc adapts a function to a Source.
+type SourceFunc[K comparable, V any] func(ctx context.Context) (map[K]V, error)
+
+func (f SourceFunc[K, V]) List(ctx context.Context) (map[K]V, error) { return f(ctx) }
+
+// Handler executes actions. Every method must be idempotent: an action may
+// be retried after a crash or error, and the reconciler never remembers
+// what it did between passes.
+type Handler[K comparable, D, A any] interface {
+	Create(ctx context.Context, key K, desired D) error
+	Update(ctx context.Context, key K, desired D, actual A) error
+	Delete(ctx context.Context, key K, actual A) error
+
+	// UpToDate reports whether actual already matches desired.
+	UpToDate(desired D, actual A) bool
+}
+
+// Options configures a Reconciler.
+type Options[K cmp.Ordered, D, A any] struct {
+	// Name identifies the reconciler in logs.
+	Name    string
+	Desired Source[K, D]
+	Actual  Source[K, A]
+	Handler Handler[K, D, A]
+
+	// Interval between passes; defaults to DefaultInterval.
+	Interval time.Duration
+
+	// Jitter spreads passes over Interval±Jitter*Interval so that several
+	// daemons, or several reconcilers in one daemon, do not poll external
+	// APIs in lockstep. Zero means DefaultJitter; negative disables jitter.
+	Jitter float64
+
+	// BaseBackoff and MaxBackoff bound the exponential delay before a failed
+	// key, or a failed listing, is retried.
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+
+	// Workers bounds how many actions run at once.
+	Workers int
+
+	Clock Clock
+}
+
+// Reconciler drives actual state towards desired state. Each pass lists
+// both sources, diffs them and dispatches the resulting actions. It is level
+// triggered: a missed poke or a crash only delays convergence until the next
+// pass.
+//
+// Actions for different keys run concurrently; actions for the same key are
+// serialised. A key whose action is still running is skipped by later passes,
+// which re-evaluate it once it has finished.
+type Reconciler[K cmp.Ordered, D, A any] struct {
+	opts Options[K, D, A]
+	poke chan struct{}
+	sem  chan struct{}
+	rand func() float64
+	wg   sync.WaitGroup
+
+	mu       sync.Mutex
+	inflight map[K]bool
+	retries  map[K]retry
+}
+
+type retry struct {
+	failures  int
+	notBefore time.Time
+}
+
+// New returns a Reconciler. Desired, Actual and Handler are required.
+func New[K cmp.Ordered, D, A any](opts Options[K, D, A]) *Reconciler[K, D, A] {
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultInterval
+	}
+	if opts.Jitter == 0 {
+		opts.Jitter = DefaultJitter
+	}
+	if opts.BaseBackoff <= 0 {
+		opts.BaseBackoff = DefaultBaseBackoff
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = DefaultMaxBackoff
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = DefaultWorkers
+	}
+	if opts.Clock == nil {
+		opts.Clock = RealClock{}
+	}
+	return &Reconciler[K, D, A]{
+		opts:     opts,
+		poke:     make(chan struct{}, 1),
+		sem:      make(chan struct{}, opts.Workers),
+		rand:     rand.Float64,
+		inflight: map[K]bool{},
+		retries:  map[K]retry{},
+	}
+}
+
+// Poke requests an immediate pass, e.g. after a webhook. It never blocks;
+// pokes that arrive while one is already pending are coalesced.
+func (r *Reconciler[K, D, A]) Poke() {
+	select {
+	case r.poke <- struct{}{}:
+	default:
+	}
+}
+
+// Run reconciles until ctx is cancelled, then waits for running actions to
+// return and returns ctx.Err().
+func (r *Reconciler[K, D, A]) Run(ctx context.Context) error {
+	ctx = log.WithLogger(ctx, log.FromContext(ctx).With("reconciler", r.opts.Name))
+	log.Info(ctx, "Starting reconciler", "interval", r.opts.Interval)
+
+	listFailures := 0
+	for {
+		wait := r.interval()
+		if _, err := r.Pass(ctx); err != nil {
+			// Keys due for a retry can only be retried by a pass that
+			// lists, so the list backoff applies to them too.
+			listFailures++
+			wait = min(wait, r.backoff(listFailures))
+			log.Warn(ctx, "Reconciliation pass failed", "error", err, "retry_in", wait)
+		} else {
+			listFailures = 0
+			if d, ok := r.nextRetry(); ok && d < wait {
+				wait = d
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			r.Wait()
+			return ctx.Err()
+		case <-r.poke:
+		case <-r.opts.Clock.After(wait):
+		}
+	}
+}
+
+// Pass runs a single reconciliation pass. It dispatches the actions it
+// decides on and returns them without waiting for them to finish; use Wait
+// for that.
+func (r *Reconciler[K, D, A]) Pass(ctx context.Context) ([]Action[K, D, A], error) {
+	desired, err := r.opts.Desired.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("listing desired state: %w", err)
+	}
+	actual, err := r.opts.Actual.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("listing actual state: %w", err)
+	}
+
+	actions := Diff(desired, actual, r.opts.Handler.UpToDate)
+	now := r.opts.Clock.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	pending := make(map[K]bool, len(actions))
+	var dispatched []Action[K, D, A]
+	for _, action := range actions {
+		pending[action.Key] = true
+		if r.inflight[action.Key] {
+			continue
+		}
+		if rt, ok := r.retries[action.Key]; ok && now.Before(rt.notBefore) {
+			continue
+		}
+		r.inflight[action.Key] = true
+		r.wg.Add(1)
+		go r.execute(ctx, action)
+		dispatched = append(dispatched, action)
+	}
+	// A key that converged by other means no longer needs its backoff.
+	for k := range r.retries {
+		if !pending[k] {
+			delete(r.retries, k)
+		}
+	}
+	return dispatched, nil
+}
+
+// Wait blocks until all dispatched actions have finished.
+func (r *Reconciler[K, D, A]) Wait() {
+	r.wg.Wait()
+}
+
+func (r *Reconciler[K, D, A]) execute(ctx context.Context, action Action[K, D, A]) {
+	defer r.wg.Done()
+
+	var err error
+	select {
+	case r.sem <- struct{}{}:
+		err = r.apply(ctx, action)
+		<-r.sem
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.inflight, action.Key)
+	if err == nil {
+		delete(r.retries, action.Key)
+		return
+	}
+	rt := r.retries[action.Key]
+	rt.failures++
+	rt.notBefore = r.opts.Clock.Now().Add(r.backoff(rt.failures))
+	r.retries[action.Key] = rt
+	log.Warn(ctx, "Reconciliation action failed",
+		"action", action.Type,
+		"key", action.Key,
+		"error", err,
+		"failures", rt.failures,
+		"retry_after", rt.notBefore)
+	// Run may already be waiting for the full interval; wake it so that it
+	// schedules the retry.
+	r.Poke()
+}
+
+func (r *Reconciler[K, D, A]) apply(ctx context.Context, action Action[K, D, A]) error {
+	log.Debug(ctx, "Reconciling", "action", action.Type, "key", action.Key)
+	switch action.Type {
+	case ActionCreate:
+		return r.opts.Handler.Create(ctx, action.Key, action.Desired)
+	case ActionUpdate:
+		return r.opts.Handler.Update(ctx, action.Key, action.Desired, action.Actual)
+	case ActionDelete:
+		return r.opts.Handler.Delete(ctx, action.Key, action.Actual)
+	}
+	return fmt.Errorf("unknown action %q", action.Type)
+}
+
+// interval returns the jittered delay until the next regular pass.
+func (r *Reconciler[K, D, A]) interval() time.Duration {
+	if r.opts.Jitter < 0 {
+		return r.opts.Interval
+	}
+	spread := float64(r.opts.Interval) * r.opts.Jitter
+	return r.opts.Interval + time.Duration(spread*(2*r.rand()-1))
+}
+
+// backoff returns the delay after the given number of consecutive failures.
+func (r *Reconciler[K, D, A]) backoff(failures int) time.Duration {
+	d := r.opts.BaseBackoff
+	for i := 1; i < failures && d < r.opts.MaxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, r.opts.MaxBackoff)
+}
+
+// nextRetry returns how long until the earliest backed-off key may be
+// retried, so that a brief backoff does not turn into a full interval.
+func (r *Reconciler[K, D, A]) nextRetry() (time.Duration, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.opts.Clock.Now()
+	var earliest time.Duration
+	found := false
+	for k, rt := range r.retries {
+		if r.inflight[k] {
+			continue
+		}
+		d := max(rt.notBefore.Sub(now), 0)
+		if !found || d < earliest {
+			earliest, found = d, true
+		}
+	}
+	return earliest, found
+}
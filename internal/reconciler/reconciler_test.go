@@ -0,0 +1,402 @@
+package reconciler
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var t0 = time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+// fakeSource is a Source backed by a map that tests mutate.
+type fakeSource struct {
+	mu    sync.Mutex
+	items map[string]string
+	err   error
+	lists int
+}
+
+func newFakeSource(items map[string]string) *fakeSource {
+	if items == nil {
+		items = map[string]string{}
+	}
+	return &fakeSource{items: items}
+}
+
+func (s *fakeSource) List(context.Context) (map[string]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lists++
+	if s.err != nil {
+		return nil, s.err
+	}
+	out := make(map[string]string, len(s.items))
+	for k, v := range s.items {
+		out[k] = v
+	}
+	return out, nil
+}
+
+func (s *fakeSource) setErr(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
+
+func (s *fakeSource) listCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lists
+}
+
+func (s *fakeSource) set(k, v string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.items[k] = v
+}
+
+func (s *fakeSource) delete(k string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.items, k)
+}
+
+// fakeHandler applies actions to an actual-state fakeSource, so that passes
+// converge like a real provider would.
+type fakeHandler struct {
+	actual *fakeSource
+
+	mu     sync.Mutex
+	calls  []string
+	fail   map[string]int // key -> remaining failures
+	block  map[string]chan struct{}
+	active map[string]int
+	maxPar map[string]int
+}
+
+func newFakeHandler(actual *fakeSource) *fakeHandler {
+	return &fakeHandler{
+		actual: actual,
+		fail:   map[string]int{},
+		block:  map[string]chan struct{}{},
+		active: map[string]int{},
+		maxPar: map[string]int{},
+	}
+}
+
+func (h *fakeHandler) record(call, key string) (chan struct{}, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.calls = append(h.calls, call+" "+key)
+	h.active[key]++
+	h.maxPar[key] = max(h.maxPar[key], h.active[key])
+	if h.fail[key] > 0 {
+		h.fail[key]--
+		return h.block[key], errors.New("provider unavailable")
+	}
+	return h.block[key], nil
+}
+
+func (h *fakeHandler) done(key string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.active[key]--
+}
+
+func (h *fakeHandler) Create(_ context.Context, key, desired string) error {
+	block, err := h.record("create", key)
+	defer h.done(key)
+	if block != nil {
+		<-block
+	}
+	if err == nil {
+		h.actual.set(key, desired)
+	}
+	return err
+}
+
+func (h *fakeHandler) Update(_ context.Context, key, desired, _ string) error {
+	_, err := h.record("update", key)
+	defer h.done(key)
+	if err == nil {
+		h.actual.set(key, desired)
+	}
+	return err
+}
+
+func (h *fakeHandler) Delete(_ context.Context, key, _ string) error {
+	_, err := h.record("delete", key)
+	defer h.done(key)
+	if err == nil {
+		h.actual.delete(key)
+	}
+	return err
+}
+
+func (h *fakeHandler) UpToDate(desired, actual string) bool {
+	return desired == actual
+}
+
+func (h *fakeHandler) Calls() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]string(nil), h.calls...)
+}
+
+type fixture struct {
+	desired *fakeSource
+	actual  *fakeSource
+	handler *fakeHandler
+	clock   *FakeClock
+	rec     *Reconciler[string, string, string]
+}
+
+func newFixture(desired, actual map[string]string) *fixture {
+	f := &fixture{
+		desired: newFakeSource(desired),
+		actual:  newFakeSource(actual),
+		clock:   NewFakeClock(t0),
+	}
+	f.handler = newFakeHandler(f.actual)
+	f.rec = New(Options[string, string, string]{
+		Name:     "test",
+		Desired:  f.desired,
+		Actual:   f.actual,
+		Handler:  f.handler,
+		Interval: 30 * time.Second,
+		Jitter:   -1,
+		Clock:    f.clock,
+	})
+	return f
+}
+
+func TestDiff(t *testing.T) {
+	desired := map[string]string{"a": "v1", "b": "v2", "c": "v1"}
+	actual := map[string]string{"b": "v1", "c": "v1", "d": "v1"}
+
+	actions := Diff(desired, actual, func(d, a string) bool { return d == a })
+
+	require.Len(t, actions, 3)
+	assert.Equal(t, Action[string, string, string]{Type: ActionCreate, Key: "a", Desired: "v1"}, actions[0])
+	assert.Equal(t, Action[string, string, string]{Type: ActionUpdate, Key: "b", Desired: "v2", Actual: "v1"}, actions[1])
+	assert.Equal(t, Action[string, string, string]{Type: ActionDelete, Key: "d", Actual: "v1"}, actions[2])
+}
+
+func TestPassConverges(t *testing.T) {
+	f := newFixture(
+		map[string]string{"pr/1": "sha1", "pr/2": "sha2"},
+		map[string]string{"pr/2": "old", "pr/3": "sha3"},
+	)
+	ctx := context.Background()
+
+	actions, err := f.rec.Pass(ctx)
+	require.NoError(t, err)
+	assert.Len(t, actions, 3)
+	f.rec.Wait()
+	assert.ElementsMatch(t, []string{"create pr/1", "update pr/2", "delete pr/3"}, f.handler.Calls())
+
+	// Idempotent: a second pass over converged state does nothing.
+	actions, err = f.rec.Pass(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, actions)
+}
+
+func TestPassSerialisesPerKey(t *testing.T) {
+	f := newFixture(map[string]string{"pr/1": "sha1", "pr/2": "sha2"}, nil)
+	release := make(chan struct{})
+	f.handler.block["pr/1"] = release
+	ctx := context.Background()
+
+	_, err := f.rec.Pass(ctx)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return len(f.handler.Calls()) == 2 }, time.Second, time.Millisecond)
+
+	// pr/1 is still being created, so later passes must leave it alone
+	// while pr/2, already done, needs nothing.
+	for i := 0; i < 3; i++ {
+		actions, err := f.rec.Pass(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, actions)
+	}
+
+	close(release)
+	f.rec.Wait()
+	assert.Equal(t, 1, f.handler.maxPar["pr/1"])
+	assert.Len(t, f.handler.Calls(), 2)
+}
+
+func TestPassBacksOffFailedKeys(t *testing.T) {
+	f := newFixture(map[string]string{"pr/1": "sha1"}, nil)
+	f.handler.fail["pr/1"] = 2
+	ctx := context.Background()
+
+	pass := func() int {
+		actions, err := f.rec.Pass(ctx)
+		require.NoError(t, err)
+		f.rec.Wait()
+		return len(actions)
+	}
+
+	assert.Equal(t, 1, pass()) // fails, backoff 1s
+	assert.Equal(t, 0, pass()) // still backing off
+	f.clock.Advance(time.Second)
+	assert.Equal(t, 1, pass()) // fails again, backoff 2s
+	f.clock.Advance(time.Second)
+	assert.Equal(t, 0, pass())
+	f.clock.Advance(time.Second)
+	assert.Equal(t, 1, pass()) // succeeds
+	assert.Equal(t, 0, pass())
+
+	assert.Equal(t, []string{"create pr/1", "create pr/1", "create pr/1"}, f.handler.Calls())
+}
+
+func TestPassForgetsBackoffWhenKeyGoesAway(t *testing.T) {
+	f := newFixture(map[string]string{"pr/1": "sha1"}, nil)
+	f.handler.fail["pr/1"] = 1
+	ctx := context.Background()
+
+	_, err := f.rec.Pass(ctx)
+	require.NoError(t, err)
+	f.rec.Wait()
+
+	f.desired.delete("pr/1")
+	_, err = f.rec.Pass(ctx)
+	require.NoError(t, err)
+
+	f.desired.set("pr/1", "sha1")
+	actions, err := f.rec.Pass(ctx)
+	require.NoError(t, err)
+	assert.Len(t, actions, 1, "a returning key should not inherit its old backoff")
+	f.rec.Wait()
+}
+
+func TestPassReportsSourceErrors(t *testing.T) {
+	f := newFixture(nil, nil)
+	f.actual.err = errors.New("cluster unreachable")
+
+	_, err := f.rec.Pass(context.Background())
+	assert.ErrorContains(t, err, "listing actual state: cluster unreachable")
+}
+
+// waitIdle waits until Run has started n timers, i.e. until it is blocked on
+// the clock after its n-th pass. Timers abandoned after a poke stay pending,
+// so n counts every pass so far.
+func waitIdle(t *testing.T, f *fixture, n int) {
+	t.Helper()
+	require.Eventually(t, func() bool { return f.clock.Waiters() == n }, time.Second, time.Millisecond)
+}
+
+func TestRunIntervalAndPoke(t *testing.T) {
+	f := newFixture(map[string]string{"pr/1": "sha1"}, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- f.rec.Run(ctx) }()
+
+	waitIdle(t, f, 1)
+	require.Eventually(t, func() bool { return len(f.handler.Calls()) == 1 }, time.Second, time.Millisecond)
+
+	// A poke runs a pass immediately, without the clock moving.
+	f.desired.set("pr/2", "sha2")
+	f.rec.Poke()
+	require.Eventually(t, func() bool { return len(f.handler.Calls()) == 2 }, time.Second, time.Millisecond)
+
+	// Without a poke, changes are picked up on the next interval.
+	waitIdle(t, f, 2)
+	f.desired.delete("pr/1")
+	f.clock.Advance(29 * time.Second)
+	assert.Len(t, f.handler.Calls(), 2)
+	f.clock.Advance(time.Second)
+	require.Eventually(t, func() bool { return len(f.handler.Calls()) == 3 }, time.Second, time.Millisecond)
+	assert.Equal(t, "delete pr/1", f.handler.Calls()[2])
+
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+}
+
+func TestRunRetriesSooner(t *testing.T) {
+	f := newFixture(map[string]string{"pr/1": "sha1"}, nil)
+	f.handler.fail["pr/1"] = 1
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = f.rec.Run(ctx) }()
+
+	require.Eventually(t, func() bool { return len(f.handler.Calls()) == 1 }, time.Second, time.Millisecond)
+	// The failure wakes Run, which schedules the retry.
+	waitIdle(t, f, 2)
+
+	// The failed key is retried after its 1s backoff, not the 30s interval.
+	f.clock.Advance(time.Second)
+	require.Eventually(t, func() bool { return len(f.handler.Calls()) == 2 }, time.Second, time.Millisecond)
+}
+
+func TestRunBacksOffListFailuresWithRetriesDue(t *testing.T) {
+	f := newFixture(map[string]string{"pr/1": "sha1"}, nil)
+	f.handler.fail["pr/1"] = 1
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = f.rec.Run(ctx) }()
+
+	require.Eventually(t, func() bool { return len(f.handler.Calls()) == 1 }, time.Second, time.Millisecond)
+	waitIdle(t, f, 2)
+
+	// pr/1 becomes due for its retry just as listing starts failing. The
+	// retry cannot run without a listing, so it must not cut the list
+	// backoff short.
+	f.desired.setErr(errors.New("forge unreachable"))
+	f.clock.Advance(time.Second)
+	waitIdle(t, f, 2)
+	lists := f.desired.listCount()
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, lists, f.desired.listCount(), "Run waits for the list backoff")
+
+	f.clock.Advance(time.Second)
+	require.Eventually(t, func() bool { return f.desired.listCount() == lists+1 }, time.Second, time.Millisecond)
+
+	// Once listing recovers, the retry runs.
+	f.desired.setErr(nil)
+	waitIdle(t, f, 2)
+	f.clock.Advance(2 * time.Second)
+	require.Eventually(t, func() bool { return len(f.handler.Calls()) == 2 }, time.Second, time.Millisecond)
+}
+
+func TestPokeCoalesces(t *testing.T) {
+	f := newFixture(nil, nil)
+	f.rec.Poke()
+	f.rec.Poke()
+	assert.Len(t, f.rec.poke, 1)
+}
+
+func TestJitterAndBackoff(t *testing.T) {
+	rec := New(Options[string, string, string]{Interval: 30 * time.Second})
+
+	rec.rand = func() float64 { return 0 }
+	assert.Equal(t, 27*time.Second, rec.interval())
+	rec.rand = func() float64 { return 1 }
+	assert.Equal(t, 33*time.Second, rec.interval())
+
+	assert.Equal(t, time.Second, rec.backoff(1))
+	assert.Equal(t, 4*time.Second, rec.backoff(3))
+	assert.Equal(t, DefaultMaxBackoff, rec.backoff(20))
+}
+
+func TestFakeClock(t *testing.T) {
+	c := NewFakeClock(t0)
+	ch := c.After(10 * time.Second)
+	assert.Equal(t, 1, c.Waiters())
+
+	c.Advance(5 * time.Second)
+	select {
+	case <-ch:
+		t.Fatal("timer fired early")
+	default:
+	}
+
+	c.Advance(5 * time.Second)
+	assert.Equal(t, t0.Add(10*time.Second), <-ch)
+	assert.Equal(t, 0, c.Waiters())
+}
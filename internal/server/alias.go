@@ -0,0 +1,126 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// AliasResolver maps an alias such as "myapp-pr-123" to the URL of the
+// environment it currently points at. The reconciler keeps its in-memory view
+// of environments in an AliasResolver, and only registers aliases for
+// projects where config.Config.AliasesEnabled is true.
+type AliasResolver interface {
+	ResolveAlias(alias string) (url string, ok bool)
+}
+
+// AliasTable is a concurrency-safe AliasResolver backed by a map.
+type AliasTable struct {
+	mu      sync.RWMutex
+	targets map[string]string
+}
+
+// NewAliasTable returns an empty AliasTable.
+func NewAliasTable() *AliasTable {
+	return &AliasTable{targets: map[string]string{}}
+}
+
+// Set points alias at url, replacing any previous target.
+func (t *AliasTable) Set(alias, url string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.targets[strings.ToLower(alias)] = url
+}
+
+// Delete removes alias.
+func (t *AliasTable) Delete(alias string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.targets, strings.ToLower(alias))
+}
+
+// ResolveAlias implements AliasResolver.
+func (t *AliasTable) ResolveAlias(alias string) (string, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	url, ok := t.targets[strings.ToLower(alias)]
+	return url, ok
+}
+
+// SetAliasResolver enables alias redirects. Until it is called, and whenever
+// Config.DisableAliases is set, alias requests are answered with 404.
+func (s *Server) SetAliasResolver(r AliasResolver) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.aliases = r
+}
+
+func (s *Server) aliasResolver() AliasResolver {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.config.DisableAliases {
+		return nil
+	}
+	return s.aliases
+}
+
+// aliasPathHandler serves /alias/{alias}/{path...}, redirecting to the same
+// path within the environment.
+func (s *Server) aliasPathHandler(w http.ResponseWriter, r *http.Request) {
+	s.redirectAlias(w, r, r.PathValue("alias"), "/"+r.PathValue("path"))
+}
+
+// aliasHostMiddleware redirects requests whose Host is an alias under
+// Config.AliasDomain, e.g. myapp-pr-123.preview.example.com, and passes
+// everything else through to next.
+func (s *Server) aliasHostMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if alias, ok := s.aliasFromHost(r.Host); ok {
+			s.redirectAlias(w, r, alias, r.URL.Path)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) aliasFromHost(host string) (string, bool) {
+	domain := strings.TrimPrefix(s.config.AliasDomain, ".")
+	if domain == "" {
+		return "", false
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	alias, ok := strings.CutSuffix(strings.ToLower(host), "."+strings.ToLower(domain))
+	if !ok || alias == "" || strings.Contains(alias, ".") {
+		return "", false
+	}
+	return alias, true
+}
+
+func (s *Server) redirectAlias(w http.ResponseWriter, r *http.Request, alias, path string) {
+	resolver := s.aliasResolver()
+	if resolver == nil {
+		s.notFoundHandler(w, r)
+		return
+	}
+	target, ok := resolver.ResolveAlias(alias)
+	if !ok {
+		s.jsonResponse(w, http.StatusNotFound, map[string]string{
+			"error":   "Not found",
+			"message": "No environment is currently using this alias.",
+			"alias":   alias,
+		})
+		return
+	}
+
+	target = strings.TrimRight(target, "/") + path
+	if r.URL.RawQuery != "" {
+		target += "?" + r.URL.RawQuery
+	}
+	// The alias moves whenever the environment is recreated, so clients
+	// must not cache the redirect.
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, target, http.StatusFound)
+}
@@ -0,0 +1,109 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newAliasServer(cfg *Config) *Server {
+	server := New(cfg)
+	aliases := NewAliasTable()
+	aliases.Set("myapp-pr-123", "https://myapp-serene-ocean-42.preview.example.com")
+	server.SetAliasResolver(aliases)
+	return server
+}
+
+func TestAliasPathRedirect(t *testing.T) {
+	server := newAliasServer(nil)
+	handler := server.setupRoutes()
+
+	tests := map[string]string{
+		"/alias/myapp-pr-123":                  "https://myapp-serene-ocean-42.preview.example.com/",
+		"/alias/MyApp-PR-123/login?next=/home": "https://myapp-serene-ocean-42.preview.example.com/login?next=/home",
+	}
+	for path, want := range tests {
+		req := httptest.NewRequest("GET", path, nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if w.Code != http.StatusFound {
+			t.Errorf("%s: expected status %d, got %d", path, http.StatusFound, w.Code)
+		}
+		if got := w.Header().Get("Location"); got != want {
+			t.Errorf("%s: expected Location %q, got %q", path, want, got)
+		}
+		if got := w.Header().Get("Cache-Control"); got != "no-store" {
+			t.Errorf("%s: expected Cache-Control no-store, got %q", path, got)
+		}
+	}
+}
+
+func TestAliasHostRedirect(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.AliasDomain = "preview.example.com"
+	server := newAliasServer(cfg)
+	handler := server.aliasHostMiddleware(server.setupRoutes())
+
+	req := httptest.NewRequest("GET", "/dashboard?tab=2", nil)
+	req.Host = "myapp-pr-123.preview.example.com:443"
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusFound {
+		t.Fatalf("expected status %d, got %d", http.StatusFound, w.Code)
+	}
+	want := "https://myapp-serene-ocean-42.preview.example.com/dashboard?tab=2"
+	if got := w.Header().Get("Location"); got != want {
+		t.Errorf("expected Location %q, got %q", want, got)
+	}
+
+	// Requests for the daemon itself are not treated as aliases.
+	req = httptest.NewRequest("GET", "/health", nil)
+	req.Host = "ephd.internal:8080"
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status %d for non-alias host, got %d", http.StatusOK, w.Code)
+	}
+}
+
+func TestAliasNotFound(t *testing.T) {
+	tests := map[string]*Server{
+		"unknown alias": newAliasServer(nil),
+		"no resolver":   New(nil),
+		"disabled": func() *Server {
+			cfg := DefaultConfig()
+			cfg.DisableAliases = true
+			return newAliasServer(cfg)
+		}(),
+	}
+	for name, server := range tests {
+		path := "/alias/myapp-pr-123"
+		if name == "unknown alias" {
+			path = "/alias/myapp-pr-999"
+		}
+		req := httptest.NewRequest("GET", path, nil)
+		w := httptest.NewRecorder()
+		server.setupRoutes().ServeHTTP(w, req)
+
+		if w.Code != http.StatusNotFound {
+			t.Errorf("%s: expected status %d, got %d", name, http.StatusNotFound, w.Code)
+		}
+	}
+}
+
+func TestAliasTable(t *testing.T) {
+	aliases := NewAliasTable()
+	aliases.Set("app-pr-1", "https://a.example.com")
+	aliases.Set("app-pr-1", "https://b.example.com")
+
+	if got, ok := aliases.ResolveAlias("APP-PR-1"); !ok || got != "https://b.example.com" {
+		t.Errorf("expected latest target, got %q (ok=%v)", got, ok)
+	}
+
+	aliases.Delete("app-pr-1")
+	if _, ok := aliases.ResolveAlias("app-pr-1"); ok {
+		t.Error("expected alias to be deleted")
+	}
+}
@@ -0,0 +1,214 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ephlabs/eph/internal/controller"
+	"github.com/ephlabs/eph/internal/informers"
+	"github.com/ephlabs/eph/internal/log"
+	"github.com/ephlabs/eph/internal/providers"
+	"github.com/ephlabs/eph/internal/providers/plugin"
+	"github.com/ephlabs/eph/internal/reconciler"
+	"github.com/ephlabs/eph/internal/webhook"
+	"github.com/ephlabs/eph/internal/worker"
+)
+
+const shutdownTimeout = 30 * time.Second
+
+// RunContext assembles ephd from cfg and runs it until ctx is cancelled.
+//
+// Startup fails fast: a broken eph.yaml, an unknown provider or forge, or a
+// primary provider whose backend cannot be reached is reported immediately
+// rather than on the first reconciliation. An unreachable fallback provider
+// is only logged, as the controller keeps checking it. Once running,
+// components that crash are restarted; RunContext only returns an error if a
+// critical component keeps failing, or at once if ephd cannot listen on its
+// port.
+func RunContext(ctx context.Context, cfg *Config) error {
+	// Plugins must be registered before eph.yaml is validated against the
+	// known providers.
+	if _, err := plugin.Discover(cfg.PluginDir); err != nil {
+		return err
+	}
+	s := New(cfg)
+	if err := s.LoadProject(); err != nil {
+		return err
+	}
+	components, err := s.components(ctx)
+	if err != nil {
+		return err
+	}
+	return worker.Supervise(ctx, components...)
+}
+
+// components builds the supervised process tree: the HTTP server and, when a
+// project and forge are configured, the forge informer and the environment
+// reconciler.
+func (s *Server) components(ctx context.Context) ([]worker.Component, error) {
+	components := []worker.Component{{Name: "http", Run: s.serve, Critical: true}}
+
+	s.mu.RLock()
+	project := s.project
+	s.mu.RUnlock()
+	if project == nil {
+		return components, nil
+	}
+	if s.config.Forge == "" {
+		log.Warn(ctx, "No forge configured (EPH_FORGE), environments will not be reconciled")
+		return components, nil
+	}
+	if s.config.NameKey == "" {
+		return nil, errors.New("EPH_NAME_KEY must be set to derive environment names")
+	}
+
+	provider, err := providers.New(project.Providers.Primary, project)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.validateProviderAccess(ctx, provider); err != nil {
+		return nil, err
+	}
+	var fallback providers.Provider
+	if name := project.Providers.Fallback; name != "" {
+		if fallback, err = providers.New(name, project); err != nil {
+			return nil, err
+		}
+		if err := s.validateProviderAccess(ctx, fallback); err != nil {
+			log.Warn(ctx, "Fallback provider unavailable", "error", err)
+		}
+	}
+
+	refs, err := informers.NewRef(s.config.Forge, informers.RefOptions{
+		BaseURL:    s.config.ForgeURL,
+		Repository: s.config.Repository,
+		Token:      s.config.ForgeToken,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	aliases := NewAliasTable()
+	s.SetAliasResolver(aliases)
+
+	ctrl, err := controller.New(controller.Options{
+		Project:      project,
+		Provider:     provider,
+		Fallback:     fallback,
+		Refs:         refs,
+		NameKey:      []byte(s.config.NameKey),
+		Aliases:      aliases,
+		CheckTimeout: s.config.ProviderCheckTimeout,
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.SetEnvironments(ctrl)
+	s.SetTelemetry(ctrl)
+
+	rec := reconciler.New(reconciler.Options[string, controller.Ref, providers.Instance]{
+		Name:    "environments",
+		Desired: reconciler.SourceFunc[string, controller.Ref](ctrl.Desired),
+		Actual:  reconciler.SourceFunc[string, providers.Instance](ctrl.Actual),
+		Handler: ctrl,
+	})
+	refs.OnChange(rec.Poke)
+	s.setupWebhooks(ctx, refs)
+
+	synced := []informers.Informer{refs}
+	components = append(components,
+		worker.Component{Name: "informer/" + refs.Name(), Run: refs.Run, Critical: true})
+	for i, p := range []providers.Provider{provider, fallback} {
+		primary := i == 0
+		// Providers that answer List from a cache of the backend run it as
+		// an informer of their own. Only the primary's gates reconciliation:
+		// the controller treats a fallback that has not synced as not yet
+		// listed, and creates no environments until it has.
+		if inf, ok := p.(informers.Informer); ok {
+			inf.OnChange(rec.Poke)
+			if primary {
+				synced = append(synced, inf)
+			}
+			components = append(components,
+				worker.Component{Name: "informer/" + inf.Name(), Run: inf.Run, Critical: primary})
+		} else if r, ok := p.(providers.Runner); ok {
+			// Calls restart a crashed plugin too, so it is not critical.
+			components = append(components,
+				worker.Component{Name: "provider/" + p.Name(), Run: r.Run})
+		}
+	}
+
+	return append(components,
+		worker.Component{Name: "reconciler", Critical: true, Run: func(ctx context.Context) error {
+			// Reconciling against an empty cache would delete every
+			// environment.
+			if err := informers.WaitForSync(ctx, synced...); err != nil {
+				return err
+			}
+			return rec.Run(ctx)
+		}},
+	), nil
+}
+
+// setupWebhooks accepts the forge's webhooks, if ephd can verify them. A
+// delivery only makes the informer refresh ahead of its next poll.
+func (s *Server) setupWebhooks(ctx context.Context, refs informers.RefInformer) {
+	resyncer, ok := refs.(informers.Resyncer)
+	if _, supported := webhook.Lookup(s.config.Forge); !ok || !supported {
+		return
+	}
+	if s.config.WebhookSecret == "" {
+		log.Info(ctx, "EPH_WEBHOOK_SECRET not set, relying on polling only", "forge", s.config.Forge)
+		return
+	}
+	h, err := webhook.Handler(s.config.Forge, []byte(s.config.WebhookSecret), func(context.Context, webhook.Event) {
+		resyncer.Resync()
+	})
+	if err != nil {
+		log.Warn(ctx, "Webhooks disabled", "error", err)
+		return
+	}
+	s.SetWebhookHandler(s.config.Forge, h)
+}
+
+func (s *Server) validateProviderAccess(ctx context.Context, provider providers.Provider) error {
+	ctx, cancel := context.WithTimeout(ctx, s.config.ProviderCheckTimeout)
+	defer cancel()
+	if err := provider.CheckAccess(ctx); err != nil {
+		return fmt.Errorf("provider access check: %s: %w", provider.Name(), err)
+	}
+	log.Info(ctx, "Provider access verified", "provider", provider.Name())
+	return nil
+}
+
+// serve runs the HTTP server until ctx is cancelled, then shuts it down
+// gracefully.
+func (s *Server) serve(ctx context.Context) error {
+	errCh := make(chan error, 1)
+	go func() { errCh <- s.Start() }()
+
+	select {
+	case err := <-errCh:
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		// Restarting cannot free the port.
+		if errors.As(err, new(*ListenError)) {
+			return worker.Fatal(fmt.Errorf("server failed to start: %w", err))
+		}
+		return fmt.Errorf("server failed to start: %w", err)
+	case <-ctx.Done():
+	}
+
+	log.Info(context.Background(), "Shutting down gracefully", "timeout", shutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := s.Shutdown(shutdownCtx); err != nil {
+		log.Error(context.Background(), "Server shutdown error", "error", err)
+	}
+	<-errCh
+	return nil
+}
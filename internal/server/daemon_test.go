@@ -0,0 +1,218 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ephlabs/eph/internal/config"
+	_ "github.com/ephlabs/eph/internal/informers/github"
+	"github.com/ephlabs/eph/internal/providers"
+	"github.com/ephlabs/eph/internal/worker"
+)
+
+type unreachableProvider struct{}
+
+func (unreachableProvider) Name() string { return "unreachable" }
+func (unreachableProvider) CheckAccess(context.Context) error {
+	return errors.New("connection refused")
+}
+func (unreachableProvider) List(context.Context) ([]providers.Instance, error) { return nil, nil }
+func (unreachableProvider) Apply(context.Context, providers.Spec) (providers.Instance, error) {
+	return providers.Instance{}, nil
+}
+func (unreachableProvider) Destroy(context.Context, providers.Instance) error { return nil }
+
+// runnerProvider stands in for a provider plugin.
+type runnerProvider struct{ unreachableProvider }
+
+func (runnerProvider) Name() string                      { return "runner" }
+func (runnerProvider) CheckAccess(context.Context) error { return nil }
+func (runnerProvider) Run(ctx context.Context) error {
+	<-ctx.Done()
+	return nil
+}
+
+func init() {
+	providers.Register("unreachable", func(*config.Config) (providers.Provider, error) {
+		return unreachableProvider{}, nil
+	})
+	providers.Register("runner", func(*config.Config) (providers.Provider, error) {
+		return runnerProvider{}, nil
+	})
+}
+
+func writeProject(t *testing.T, provider string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "eph.yaml")
+	data := `version: "1.0"
+name: myapp
+providers:
+  primary: ` + provider + `
+docker-compose:
+  compose_files: [docker-compose.yml]
+`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func daemonConfig(t *testing.T, provider string) *Config {
+	t.Helper()
+	cfg := DefaultConfig()
+	cfg.Port = "127.0.0.1:0"
+	cfg.ConfigPath = writeProject(t, provider)
+	cfg.Forge = "github"
+	cfg.Repository = "org/myapp"
+	cfg.NameKey = "test-key"
+	cfg.ProviderCheckTimeout = time.Second
+	return cfg
+}
+
+func TestRunContextProviderNotInBuild(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := RunContext(ctx, daemonConfig(t, "docker-compose"))
+	if err == nil || !strings.Contains(err.Error(), "not available in this build") {
+		t.Errorf("expected provider availability error, got %v", err)
+	}
+	if ctx.Err() != nil {
+		t.Error("expected RunContext to fail before the deadline")
+	}
+}
+
+func TestComponentsFailFast(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(*Config)
+		wantErr string
+	}{
+		{
+			name:    "provider access",
+			modify:  func(*Config) {},
+			wantErr: "provider access check: unreachable: connection refused",
+		},
+		{
+			name:    "missing name key",
+			modify:  func(c *Config) { c.NameKey = "" },
+			wantErr: "EPH_NAME_KEY",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := daemonConfig(t, "docker-compose")
+			tt.modify(cfg)
+			s := New(cfg)
+			// Test providers are not in config.KnownProviders(), so the
+			// project is set directly rather than loaded and validated.
+			s.project = &config.Config{Name: "myapp", Providers: config.ProvidersConfig{Primary: "unreachable"}}
+
+			_, err := s.components(context.Background())
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestComponentsRunsProvider(t *testing.T) {
+	s := New(daemonConfig(t, "docker-compose"))
+	s.project = &config.Config{Name: "myapp", Providers: config.ProvidersConfig{Primary: "runner"}}
+
+	components, err := s.components(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range components {
+		if c.Name == "provider/runner" {
+			if c.Critical {
+				t.Error("expected the provider component not to be critical")
+			}
+			return
+		}
+	}
+	t.Error("expected a provider/runner component")
+}
+
+func TestComponentsFallback(t *testing.T) {
+	s := New(daemonConfig(t, "docker-compose"))
+	s.project = &config.Config{Name: "myapp", Providers: config.ProvidersConfig{Primary: "unreachable", Fallback: "runner"}}
+	if _, err := s.components(context.Background()); err == nil {
+		t.Error("expected an unreachable primary to fail startup despite the fallback")
+	}
+
+	// An unreachable fallback is only logged: the controller keeps
+	// checking it.
+	s.project.Providers = config.ProvidersConfig{Primary: "runner", Fallback: "unreachable"}
+	if _, err := s.components(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	s.project.Providers = config.ProvidersConfig{Primary: "runner", Fallback: "docker-compose"}
+	_, err := s.components(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "not available in this build") {
+		t.Errorf("expected fallback availability error, got %v", err)
+	}
+}
+
+func TestRunContextPluginDir(t *testing.T) {
+	cfg := daemonConfig(t, "docker-compose")
+	cfg.PluginDir = filepath.Join(t.TempDir(), "missing")
+
+	err := RunContext(context.Background(), cfg)
+	if err == nil || !strings.Contains(err.Error(), "reading plugin directory") {
+		t.Errorf("expected plugin directory error, got %v", err)
+	}
+}
+
+func TestRunContextWithoutForge(t *testing.T) {
+	cfg := daemonConfig(t, "docker-compose")
+	cfg.Forge = ""
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- RunContext(ctx, cfg) }()
+
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("expected nil error, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("RunContext did not return after cancellation")
+	}
+}
+
+func TestRunContextPortInUse(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	cfg := daemonConfig(t, "docker-compose")
+	cfg.Forge = ""
+	cfg.Port = ln.Addr().String()
+
+	// Restarting cannot free the port, so ephd fails at once.
+	done := make(chan error, 1)
+	go func() { done <- RunContext(context.Background(), cfg) }()
+	select {
+	case err := <-done:
+		if !errors.Is(err, worker.ErrComponentFailed) || !strings.Contains(err.Error(), "address already in use") {
+			t.Errorf("expected address in use error, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("RunContext kept restarting the HTTP server")
+	}
+}
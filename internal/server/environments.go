@@ -0,0 +1,112 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/ephlabs/eph/internal/controller"
+)
+
+// EnvironmentLister reports the environments ephd manages.
+// controller.Controller implements it.
+type EnvironmentLister interface {
+	Environments() []controller.Environment
+}
+
+// SetEnvironments serves the environments l reports. Until it is called,
+// GET /api/v1/environments lists none.
+func (s *Server) SetEnvironments(l EnvironmentLister) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.environments = l
+}
+
+func (s *Server) environmentLister() EnvironmentLister {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.environments
+}
+
+// Environment is an environment as listed by GET /api/v1/environments.
+type Environment struct {
+	ID       string           `json:"id"`
+	Key      string           `json:"key"`
+	Name     string           `json:"name"`
+	Project  string           `json:"project"`
+	Phase    controller.Phase `json:"phase"`
+	Provider string           `json:"provider,omitempty"`
+	URL      string           `json:"url,omitempty"`
+
+	// Headers must be sent with requests to URL, e.g. X-Eph-Environment
+	// with header-based routing.
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// Message explains why the environment is not ready, if known.
+	Message string `json:"message,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at,omitzero"`
+}
+
+// EnvironmentList is the response of GET /api/v1/environments.
+type EnvironmentList struct {
+	Environments []Environment `json:"environments"`
+	Total        int           `json:"total"`
+	Message      string        `json:"message,omitempty"`
+}
+
+func (s *Server) listEnvironments(w http.ResponseWriter, _ *http.Request) {
+	resp := EnvironmentList{Environments: []Environment{}}
+	l := s.environmentLister()
+	if l == nil {
+		resp.Message = "ephd is not reconciling a project, so it manages no environments."
+		s.jsonResponse(w, http.StatusOK, resp)
+		return
+	}
+	for _, env := range l.Environments() {
+		resp.Environments = append(resp.Environments, environment(env))
+	}
+	resp.Total = len(resp.Environments)
+	s.jsonResponse(w, http.StatusOK, resp)
+}
+
+// getEnvironment reports the environment with the given ID or name.
+func (s *Server) getEnvironment(w http.ResponseWriter, r *http.Request) {
+	envID := r.PathValue("id")
+	if l := s.environmentLister(); l != nil {
+		for _, env := range l.Environments() {
+			if env.ID == envID || env.Name == envID {
+				s.jsonResponse(w, http.StatusOK, environment(env))
+				return
+			}
+		}
+	}
+	s.jsonResponse(w, http.StatusNotFound, map[string]string{
+		"error":          "Not found",
+		"message":        envID + ": " + controller.ErrNotFound.Error(),
+		"environment_id": envID,
+	})
+}
+
+func environment(env controller.Environment) Environment {
+	out := Environment{
+		ID:        env.ID,
+		Key:       env.Source.Key(),
+		Name:      env.Name,
+		Project:   env.Project,
+		Phase:     env.Phase,
+		Provider:  env.Provider,
+		URL:       env.URL,
+		Headers:   env.Headers,
+		CreatedAt: env.CreatedAt,
+		ExpiresAt: env.ExpiresAt,
+	}
+	// Explain an environment that is not ready by its failing condition.
+	for _, cond := range env.Conditions {
+		if cond.Status == controller.ConditionFalse && cond.Message != "" {
+			out.Message = cond.Message
+			break
+		}
+	}
+	return out
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ephlabs/eph/internal/controller"
+)
+
+type staticEnvironments []controller.Environment
+
+func (e staticEnvironments) Environments() []controller.Environment { return e }
+
+func TestListEnvironmentsReportsRouting(t *testing.T) {
+	server := New(nil)
+	server.SetEnvironments(staticEnvironments{
+		{
+			ID:       "myapp-calm-river-42",
+			Name:     "myapp-calm-river-42",
+			Project:  "myapp",
+			Source:   controller.PRRef("org/myapp", 1, "feature/x", "aaa"),
+			Phase:    controller.PhaseReady,
+			Provider: "kubernetes",
+			URL:      "https://preview.example.com/myapp-calm-river-42/",
+		},
+		{
+			ID:       "myapp-quiet-lake-7",
+			Name:     "myapp-quiet-lake-7",
+			Project:  "myapp",
+			Source:   controller.PRRef("org/myapp", 2, "feature/y", "bbb"),
+			Phase:    controller.PhaseCreating,
+			Provider: "kubernetes",
+			URL:      "https://preview.example.com",
+			Headers:  map[string]string{"X-Eph-Environment": "myapp-quiet-lake-7"},
+		},
+	})
+	handler := server.setupRoutes()
+
+	req := httptest.NewRequest("GET", "/api/v1/environments", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	var resp EnvironmentList
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Total != 2 || len(resp.Environments) != 2 {
+		t.Fatalf("expected 2 environments, got %+v", resp)
+	}
+
+	path := resp.Environments[0]
+	if path.Key != "org/myapp/pr/1" || path.Phase != controller.PhaseReady || path.Provider != "kubernetes" {
+		t.Errorf("unexpected environment %+v", path)
+	}
+	if path.URL != "https://preview.example.com/myapp-calm-river-42/" {
+		t.Errorf("expected the path-routed URL, got %q", path.URL)
+	}
+	if len(path.Headers) != 0 {
+		t.Errorf("expected no headers with path routing, got %v", path.Headers)
+	}
+
+	header := resp.Environments[1]
+	if header.URL != "https://preview.example.com" {
+		t.Errorf("expected the shared host, got %q", header.URL)
+	}
+	if got := header.Headers["X-Eph-Environment"]; got != "myapp-quiet-lake-7" {
+		t.Errorf("expected X-Eph-Environment header, got %q", got)
+	}
+}
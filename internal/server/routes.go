@@ -2,7 +2,9 @@ package server
 
 import (
 	"net/http"
+	"time"
 
+	"github.com/ephlabs/eph/internal/controller"
 	"github.com/ephlabs/eph/pkg/version"
 )
 
@@ -13,8 +15,13 @@ func (s *Server) setupRoutes() *http.ServeMux {
 	mux.HandleFunc("GET /api/v1/status", s.statusHandler)
 	mux.HandleFunc("GET /api/v1/environments", s.listEnvironments)
 	mux.HandleFunc("POST /api/v1/environments", s.createEnvironment)
+	mux.HandleFunc("GET /api/v1/environments/{id}", s.getEnvironment)
 	mux.HandleFunc("DELETE /api/v1/environments/{id}", s.deleteEnvironment)
 	mux.HandleFunc("GET /api/v1/environments/{id}/logs", s.environmentLogs)
+	mux.HandleFunc("GET /api/v1/environments/{id}/metrics", s.environmentMetrics)
+	mux.HandleFunc("GET /alias/{alias}/{path...}", s.aliasPathHandler)
+	mux.HandleFunc("GET /alias/{alias}", s.aliasPathHandler)
+	mux.HandleFunc("POST /webhooks/{forge}", s.webhookHandler)
 	mux.HandleFunc("/", s.notFoundHandler)
 
 	return mux
@@ -31,24 +38,20 @@ func (s *Server) healthHandler(w http.ResponseWriter, _ *http.Request) {
 }
 
 func (s *Server) statusHandler(w http.ResponseWriter, _ *http.Request) {
-	response := map[string]interface{}{
-		"status":  "healthy",
-		"version": version.GetVersion(),
-		"uptime":  "unknown",
-		"environments": map[string]int{
-			"total":  0,
-			"active": 0,
-		},
+	counts := map[string]int{"total": 0, "active": 0}
+	if l := s.environmentLister(); l != nil {
+		for _, env := range l.Environments() {
+			counts["total"]++
+			if env.Phase == controller.PhaseReady {
+				counts["active"]++
+			}
+		}
 	}
-
-	s.jsonResponse(w, http.StatusOK, response)
-}
-
-func (s *Server) listEnvironments(w http.ResponseWriter, _ *http.Request) {
 	response := map[string]interface{}{
-		"environments": []string{},
-		"total":        0,
-		"message":      "Environment listing coming soon! When ready, this will show all your ephemeral environments.",
+		"status":       "healthy",
+		"version":      version.GetVersion(),
+		"uptime":       time.Since(s.started).Round(time.Second).String(),
+		"environments": counts,
 	}
 
 	s.jsonResponse(w, http.StatusOK, response)
@@ -74,17 +77,6 @@ func (s *Server) deleteEnvironment(w http.ResponseWriter, r *http.Request) {
 	s.jsonResponse(w, http.StatusNotImplemented, response)
 }
 
-func (s *Server) environmentLogs(w http.ResponseWriter, r *http.Request) {
-	envID := r.PathValue("id")
-	response := map[string]interface{}{
-		"message":        "Log streaming coming soon!",
-		"environment_id": envID,
-		"logs":           []string{},
-	}
-
-	s.jsonResponse(w, http.StatusNotImplemented, response)
-}
-
 func (s *Server) notFoundHandler(w http.ResponseWriter, r *http.Request) {
 	response := map[string]string{
 		"error":   "Not found",
@@ -62,8 +62,8 @@ func TestListEnvironments(t *testing.T) {
 		t.Errorf("expected total 0, got %v", response["total"])
 	}
 
-	if !strings.Contains(response["message"].(string), "coming soon") {
-		t.Error("expected message to indicate feature is coming soon")
+	if !strings.Contains(response["message"].(string), "not reconciling") {
+		t.Error("expected message to explain why there are no environments")
 	}
 }
 
@@ -3,7 +3,9 @@ package server
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -11,13 +13,22 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/ephlabs/eph/internal/config"
 	"github.com/ephlabs/eph/internal/log"
 )
 
 type Server struct {
-	httpServer *http.Server
-	config     *Config
-	mu         sync.RWMutex
+	httpServer   *http.Server
+	config       *Config
+	project      *config.Config
+	aliases      AliasResolver
+	telemetry    Telemetry
+	environments EnvironmentLister
+	started      time.Time
+	mu           sync.RWMutex
+
+	webhookForge string
+	webhooks     http.Handler
 }
 
 type Config struct {
@@ -25,14 +36,62 @@ type Config struct {
 	ReadTimeout  time.Duration
 	WriteTimeout time.Duration
 	IdleTimeout  time.Duration
+
+	// ConfigPath is an explicit eph.yaml (or directory containing one). When
+	// empty the project config is discovered from the working directory.
+	ConfigPath string
+
+	// AliasDomain is the domain under which alias hosts are served, e.g.
+	// "preview.example.com" for myapp-pr-123.preview.example.com. When empty
+	// only path-based aliases (/alias/{alias}) are served.
+	AliasDomain string
+
+	// DisableAliases turns alias redirects off entirely.
+	DisableAliases bool
+
+	// Forge selects the informer that watches the project's repository,
+	// e.g. "github". Without one, ephd serves its API but does not
+	// reconcile environments.
+	Forge      string
+	ForgeURL   string
+	Repository string
+	ForgeToken string
+
+	// WebhookSecret verifies forge webhooks. Webhooks only make ephd notice
+	// changes sooner; without a secret they are not accepted.
+	WebhookSecret string
+
+	// NameKey is the secret environment names are derived from. It must
+	// not change across restarts.
+	NameKey string
+
+	// ProviderCheckTimeout bounds the startup provider access check, and
+	// the health and capability checks that choose between the primary
+	// and fallback providers.
+	ProviderCheckTimeout time.Duration
+
+	// PluginDir holds provider plugins, executables named
+	// eph-provider-<name>, which become available as the provider <name>.
+	PluginDir string
 }
 
 func DefaultConfig() *Config {
 	return &Config{
-		Port:         ":8080",
-		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 15 * time.Second,
-		IdleTimeout:  60 * time.Second,
+		Port:          ":8080",
+		ReadTimeout:   15 * time.Second,
+		WriteTimeout:  15 * time.Second,
+		IdleTimeout:   60 * time.Second,
+		ConfigPath:    os.Getenv("EPH_CONFIG"),
+		AliasDomain:   os.Getenv("EPH_ALIAS_DOMAIN"),
+		Forge:         os.Getenv("EPH_FORGE"),
+		ForgeURL:      os.Getenv("EPH_FORGE_URL"),
+		Repository:    os.Getenv("EPH_REPOSITORY"),
+		ForgeToken:    os.Getenv("EPH_FORGE_TOKEN"),
+		WebhookSecret: os.Getenv("EPH_WEBHOOK_SECRET"),
+		NameKey:       os.Getenv("EPH_NAME_KEY"),
+		PluginDir:     os.Getenv("EPH_PLUGIN_DIR"),
+
+		ProviderCheckTimeout: 30 * time.Second,
 	}
 }
 
@@ -40,15 +99,18 @@ func New(cfg *Config) *Server {
 	if cfg == nil {
 		cfg = DefaultConfig()
 	}
-	return &Server{config: cfg}
+	return &Server{config: cfg, started: time.Now()}
 }
 
-func (s *Server) Start() error {
-	mux := s.setupRoutes()
+// Handler returns ephd's HTTP handler: its routes behind the middleware.
+func (s *Server) Handler() http.Handler {
+	return s.applyMiddleware(s.aliasHostMiddleware(s.setupRoutes()))
+}
 
+func (s *Server) Start() error {
 	server := &http.Server{
 		Addr:         s.config.Port,
-		Handler:      s.applyMiddleware(mux),
+		Handler:      s.Handler(),
 		ReadTimeout:  s.config.ReadTimeout,
 		WriteTimeout: s.config.WriteTimeout,
 		IdleTimeout:  s.config.IdleTimeout,
@@ -58,14 +120,31 @@ func (s *Server) Start() error {
 	s.httpServer = server
 	s.mu.Unlock()
 
+	addr := server.Addr
+	if addr == "" {
+		addr = ":http"
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return &ListenError{Err: err}
+	}
 	log.Info(context.Background(), "Starting Eph daemon",
 		"port", s.config.Port,
 		"read_timeout", s.config.ReadTimeout,
 		"write_timeout", s.config.WriteTimeout,
 		"idle_timeout", s.config.IdleTimeout)
-	return server.ListenAndServe()
+	return server.Serve(ln)
 }
 
+// ListenError is returned by Start when ephd cannot listen on its port,
+// e.g. because the address is already in use.
+type ListenError struct {
+	Err error
+}
+
+func (e *ListenError) Error() string { return e.Err.Error() }
+func (e *ListenError) Unwrap() error { return e.Err }
+
 func (s *Server) Shutdown(ctx context.Context) error {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -76,30 +155,43 @@ func (s *Server) Shutdown(ctx context.Context) error {
 	return s.httpServer.Shutdown(ctx)
 }
 
-func Run() error {
-	server := New(nil)
-
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
-
-	go func() {
-		<-c
-		log.Info(context.Background(), "Shutting down gracefully", "timeout", 30*time.Second)
-		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-		defer cancel()
-
-		if err := server.Shutdown(ctx); err != nil {
-			log.Error(context.Background(), "Server shutdown error", "error", err)
-		}
-	}()
-
-	if err := server.Start(); err != nil && err != http.ErrServerClosed {
-		return fmt.Errorf("server failed to start: %w", err)
+// LoadProject loads and validates the project config. A missing eph.yaml is
+// not an error for the daemon, which can run without a default project; any
+// other problem is, so that a broken config fails at startup rather than on
+// the first webhook.
+func (s *Server) LoadProject() error {
+	project, err := config.Load(config.LoadOptions{
+		Path:        s.config.ConfigPath,
+		Interpolate: config.InterpolateOptions{Lookup: os.LookupEnv},
+	})
+	if errors.Is(err, config.ErrNotFound) {
+		log.Warn(context.Background(), "No project config found", "error", err)
+		return nil
 	}
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+	if err := config.Validate(project); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+
+	log.Info(context.Background(), "Loaded project config",
+		"project", project.Name,
+		"files", project.Files())
 
+	s.mu.Lock()
+	s.project = project
+	s.mu.Unlock()
 	return nil
 }
 
+// Run starts ephd and blocks until it receives SIGINT or SIGTERM.
+func Run() error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return RunContext(ctx, DefaultConfig())
+}
+
 func (s *Server) jsonResponse(w http.ResponseWriter, status int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
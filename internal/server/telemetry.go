@@ -0,0 +1,162 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ephlabs/eph/internal/controller"
+	"github.com/ephlabs/eph/internal/log"
+	"github.com/ephlabs/eph/internal/providers"
+)
+
+// Telemetry streams the logs and reports the metrics of environments, by
+// ID or name, from the providers hosting them. controller.Controller
+// implements it.
+type Telemetry interface {
+	Logs(ctx context.Context, id string, opts providers.LogOptions, send func(providers.LogEntry) error) error
+	Metrics(ctx context.Context, id string) (providers.Metrics, error)
+}
+
+// SetTelemetry serves environment logs and metrics. Until it is called,
+// they are answered with 501.
+func (s *Server) SetTelemetry(t Telemetry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.telemetry = t
+}
+
+func (s *Server) telemetrySource() Telemetry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.telemetry
+}
+
+// LogEntry is a line of GET /api/v1/environments/{id}/logs, which streams
+// one JSON object per line.
+type LogEntry struct {
+	Time    time.Time `json:"time,omitzero"`
+	Service string    `json:"service"`
+	Stream  string    `json:"stream"`
+	Line    string    `json:"line"`
+}
+
+// Metrics is the response of GET /api/v1/environments/{id}/metrics.
+type Metrics struct {
+	CPUMillicores int64              `json:"cpu_millicores"`
+	MemoryBytes   int64              `json:"memory_bytes"`
+	Custom        map[string]float64 `json:"custom,omitempty"`
+}
+
+// environmentLogs streams an environment's logs as newline-delimited JSON.
+// The query parameters service, follow, tail and since (a duration such as
+// 10m, or an RFC 3339 time) select the entries.
+func (s *Server) environmentLogs(w http.ResponseWriter, r *http.Request) {
+	envID := r.PathValue("id")
+	t := s.telemetrySource()
+	if t == nil {
+		s.jsonResponse(w, http.StatusNotImplemented, map[string]interface{}{
+			"message":        "Log streaming coming soon!",
+			"environment_id": envID,
+			"logs":           []string{},
+		})
+		return
+	}
+	opts, err := logOptions(r, time.Now())
+	if err != nil {
+		s.jsonResponse(w, http.StatusBadRequest, map[string]string{
+			"error":   "Bad request",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	if opts.Follow {
+		// Following outlives the server's write timeout.
+		_ = rc.SetWriteDeadline(time.Time{})
+	}
+	enc := json.NewEncoder(w)
+	started := false
+	err = t.Logs(r.Context(), envID, opts, func(e providers.LogEntry) error {
+		if !started {
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			w.WriteHeader(http.StatusOK)
+			started = true
+		}
+		if err := enc.Encode(LogEntry{Time: e.Time, Service: e.Service, Stream: e.Stream, Line: e.Line}); err != nil {
+			return err
+		}
+		return rc.Flush()
+	})
+	switch {
+	case err == nil && !started:
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.WriteHeader(http.StatusOK)
+	case err != nil && !started:
+		s.telemetryError(w, envID, err)
+	case err != nil && r.Context().Err() == nil:
+		log.Warn(r.Context(), "Log stream ended", "environment_id", envID, "error", err)
+	}
+}
+
+// environmentMetrics reports an environment's resource usage.
+func (s *Server) environmentMetrics(w http.ResponseWriter, r *http.Request) {
+	envID := r.PathValue("id")
+	t := s.telemetrySource()
+	if t == nil {
+		s.jsonResponse(w, http.StatusNotImplemented, map[string]interface{}{
+			"message":        "Metrics coming soon!",
+			"environment_id": envID,
+		})
+		return
+	}
+	m, err := t.Metrics(r.Context(), envID)
+	if err != nil {
+		s.telemetryError(w, envID, err)
+		return
+	}
+	s.jsonResponse(w, http.StatusOK, Metrics{CPUMillicores: m.CPUMillicores, MemoryBytes: m.MemoryBytes, Custom: m.Custom})
+}
+
+func (s *Server) telemetryError(w http.ResponseWriter, envID string, err error) {
+	status, title := http.StatusBadGateway, "Provider error"
+	switch {
+	case errors.Is(err, controller.ErrNotFound):
+		status, title = http.StatusNotFound, "Not found"
+	case errors.Is(err, providers.ErrNotSupported):
+		status, title = http.StatusNotImplemented, "Not supported"
+	}
+	s.jsonResponse(w, status, map[string]string{
+		"error":          title,
+		"message":        err.Error(),
+		"environment_id": envID,
+	})
+}
+
+func logOptions(r *http.Request, now time.Time) (providers.LogOptions, error) {
+	q := r.URL.Query()
+	opts := providers.LogOptions{Service: q.Get("service")}
+	var err error
+	if v := q.Get("follow"); v != "" {
+		if opts.Follow, err = strconv.ParseBool(v); err != nil {
+			return opts, errors.New("follow must be true or false")
+		}
+	}
+	if v := q.Get("tail"); v != "" {
+		if opts.TailLines, err = strconv.Atoi(v); err != nil || opts.TailLines < 0 {
+			return opts, errors.New("tail must be a number of lines")
+		}
+	}
+	if v := q.Get("since"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			opts.Since = now.Add(-d)
+		} else if opts.Since, err = time.Parse(time.RFC3339, v); err != nil {
+			return opts, errors.New("since must be a duration or an RFC 3339 time")
+		}
+	}
+	return opts, nil
+}
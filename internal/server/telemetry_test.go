@@ -0,0 +1,106 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ephlabs/eph/internal/controller"
+	"github.com/ephlabs/eph/internal/providers"
+)
+
+// fakeTelemetry knows the environment calm-river, which has logs but no
+// metrics.
+type fakeTelemetry struct {
+	opts providers.LogOptions
+}
+
+func (f *fakeTelemetry) Logs(_ context.Context, id string, opts providers.LogOptions, send func(providers.LogEntry) error) error {
+	if id != "calm-river" {
+		return fmt.Errorf("%s: %w", id, controller.ErrNotFound)
+	}
+	f.opts = opts
+	for _, line := range []string{"GET / 200", "GET /healthz 200"} {
+		if err := send(providers.LogEntry{Service: "web", Stream: "stdout", Line: line}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *fakeTelemetry) Metrics(context.Context, string) (providers.Metrics, error) {
+	return providers.Metrics{}, fmt.Errorf("metrics are %w", providers.ErrNotSupported)
+}
+
+func TestEnvironmentLogsStream(t *testing.T) {
+	server := New(nil)
+	telemetry := &fakeTelemetry{}
+	server.SetTelemetry(telemetry)
+	handler := server.setupRoutes()
+
+	req := httptest.NewRequest("GET", "/api/v1/environments/calm-river/logs?service=web&tail=10&since=2025-06-01T12:00:00Z", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body)
+	}
+	if got := w.Header().Get("Content-Type"); got != "application/x-ndjson" {
+		t.Errorf("expected Content-Type application/x-ndjson, got %q", got)
+	}
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %q", lines)
+	}
+	var entry LogEntry
+	if err := json.Unmarshal([]byte(lines[1]), &entry); err != nil {
+		t.Fatalf("failed to decode line: %v", err)
+	}
+	if entry.Line != "GET /healthz 200" || entry.Service != "web" {
+		t.Errorf("unexpected entry %+v", entry)
+	}
+
+	want := providers.LogOptions{Service: "web", TailLines: 10, Since: time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)}
+	if telemetry.opts != want {
+		t.Errorf("expected options %+v, got %+v", want, telemetry.opts)
+	}
+}
+
+func TestEnvironmentTelemetryErrors(t *testing.T) {
+	server := New(nil)
+	server.SetTelemetry(&fakeTelemetry{})
+	handler := server.setupRoutes()
+
+	tests := map[string]int{
+		"/api/v1/environments/missing/logs":               http.StatusNotFound,
+		"/api/v1/environments/calm-river/logs?tail=-1":    http.StatusBadRequest,
+		"/api/v1/environments/calm-river/logs?since=soon": http.StatusBadRequest,
+		"/api/v1/environments/calm-river/metrics":         http.StatusNotImplemented,
+	}
+	for path, want := range tests {
+		req := httptest.NewRequest("GET", path, nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if w.Code != want {
+			t.Errorf("%s: expected status %d, got %d", path, want, w.Code)
+		}
+	}
+}
+
+func TestLogOptionsSinceDuration(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	req := httptest.NewRequest("GET", "/?since=10m&follow=true", nil)
+	opts, err := logOptions(req, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !opts.Follow || !opts.Since.Equal(now.Add(-10*time.Minute)) {
+		t.Errorf("unexpected options %+v", opts)
+	}
+}
@@ -0,0 +1,25 @@
+package server
+
+import (
+	"net/http"
+)
+
+// SetWebhookHandler serves forge webhooks at POST /webhooks/{forge}. Until it
+// is called, webhook deliveries are answered with 404.
+func (s *Server) SetWebhookHandler(forge string, h http.Handler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.webhookForge = forge
+	s.webhooks = h
+}
+
+func (s *Server) webhookHandler(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	forge, h := s.webhookForge, s.webhooks
+	s.mu.RUnlock()
+	if h == nil || r.PathValue("forge") != forge {
+		s.notFoundHandler(w, r)
+		return
+	}
+	h.ServeHTTP(w, r)
+}
@@ -0,0 +1,39 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWebhookRoute(t *testing.T) {
+	server := New(nil)
+	handler := server.setupRoutes()
+
+	post := func(path string) int {
+		req := httptest.NewRequest("POST", path, nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := post("/webhooks/gitea"); code != http.StatusNotFound {
+		t.Errorf("expected %d before webhooks are set up, got %d", http.StatusNotFound, code)
+	}
+
+	deliveries := 0
+	server.SetWebhookHandler("gitea", http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		deliveries++
+		w.WriteHeader(http.StatusAccepted)
+	}))
+
+	if code := post("/webhooks/gitea"); code != http.StatusAccepted {
+		t.Errorf("expected %d, got %d", http.StatusAccepted, code)
+	}
+	if code := post("/webhooks/github"); code != http.StatusNotFound {
+		t.Errorf("expected %d for another forge, got %d", http.StatusNotFound, code)
+	}
+	if deliveries != 1 {
+		t.Errorf("expected 1 delivery, got %d", deliveries)
+	}
+}
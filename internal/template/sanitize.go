@@ -0,0 +1,85 @@
+package template
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+const (
+	// MaxLabelLength is the DNS-1123 label limit, which also bounds
+	// Kubernetes namespace names.
+	MaxLabelLength = 63
+
+	// MaxTagLength is the maximum length of an OCI image tag.
+	MaxTagLength = 128
+
+	hashSuffixLength = 6
+)
+
+// SanitizeLabel coerces s into a DNS-1123 label: lowercase alphanumerics and
+// '-', starting and ending with an alphanumeric, at most 63 characters.
+// Runs of other characters (such as the '/' in "feature/login") collapse to a
+// single '-'. Over-long results are shortened with Truncate.
+func SanitizeLabel(s string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(s) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+			dash = false
+			continue
+		}
+		if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	return Truncate(strings.TrimRight(b.String(), "-"), MaxLabelLength)
+}
+
+// SanitizeTag coerces s into a valid image tag component: [A-Za-z0-9_.-],
+// not starting with '.' or '-', at most 128 characters.
+func SanitizeTag(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '.', r == '-':
+			b.WriteRune(r)
+		default:
+			b.WriteByte('-')
+		}
+	}
+	return Truncate(strings.TrimLeft(b.String(), ".-"), MaxTagLength)
+}
+
+// Truncate shortens s to at most max characters. Rather than cutting blindly,
+// which could make two long names collide, it keeps a prefix and appends a
+// short hash of the full value: "very-long-branch-name" -> "very-lo-1a2b3c".
+func Truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	sum := sha256.Sum256([]byte(s))
+	suffix := hex.EncodeToString(sum[:])[:hashSuffixLength]
+	if max <= hashSuffixLength+1 {
+		return suffix[:max]
+	}
+	prefix := strings.TrimRight(s[:max-hashSuffixLength-1], "-.")
+	return prefix + "-" + suffix
+}
+
+// IsLabel reports whether s is already a valid DNS-1123 label.
+func IsLabel(s string) bool {
+	if s == "" || len(s) > MaxLabelLength {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		alnum := (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9')
+		if !alnum && (c != '-' || i == 0 || i == len(s)-1) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,60 @@
+package template
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSanitizeLabel(t *testing.T) {
+	tests := map[string]string{
+		"main":                    "main",
+		"feature/login":           "feature-login",
+		"Fix/JIRA-123_Some Thing": "fix-jira-123-some-thing",
+		"--leading/trailing--":    "leading-trailing",
+		"dependabot/npm/@types/x": "dependabot-npm-types-x",
+		"ümlaut":                  "mlaut",
+	}
+	for in, want := range tests {
+		got := SanitizeLabel(in)
+		assert.Equal(t, want, got, in)
+		assert.True(t, IsLabel(got), got)
+	}
+}
+
+func TestSanitizeLabelTruncatesWithHash(t *testing.T) {
+	long := "feature/" + strings.Repeat("very-long-branch-name-", 5)
+	got := SanitizeLabel(long)
+	assert.LessOrEqual(t, len(got), MaxLabelLength)
+	assert.True(t, IsLabel(got), got)
+
+	// Names that only differ past the cut-off must not collide.
+	other := SanitizeLabel(long + "x")
+	assert.NotEqual(t, got, other)
+	assert.Equal(t, got[:40], other[:40])
+}
+
+func TestTruncate(t *testing.T) {
+	assert.Equal(t, "short", Truncate("short", 10))
+	got := Truncate("abcdefghijklmnop", 10)
+	assert.Len(t, got, 10)
+	assert.Equal(t, "abc-", got[:4])
+	assert.Equal(t, got, Truncate("abcdefghijklmnop", 10), "truncation is deterministic")
+	assert.Len(t, Truncate("abcdefghijklmnop", 4), 4)
+}
+
+func TestSanitizeTag(t *testing.T) {
+	assert.Equal(t, "feature-Login_v1.2", SanitizeTag("feature/Login_v1.2"))
+	assert.Equal(t, "v1", SanitizeTag(".-v1"))
+	assert.LessOrEqual(t, len(SanitizeTag(strings.Repeat("a", 200))), MaxTagLength)
+}
+
+func TestIsLabel(t *testing.T) {
+	assert.True(t, IsLabel("myapp-serene-ocean-42"))
+	assert.False(t, IsLabel(""))
+	assert.False(t, IsLabel("-x"))
+	assert.False(t, IsLabel("x-"))
+	assert.False(t, IsLabel("Upper"))
+	assert.False(t, IsLabel(strings.Repeat("a", 64)))
+}
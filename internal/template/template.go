@@ -0,0 +1,187 @@
+package template
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Vars maps template variable names to their values.
+type Vars map[string]string
+
+// Template is a parsed eph.yaml template such as
+// "{ref_type}-{ref_name}-{commit_sha:0:7}".
+type Template struct {
+	raw   string
+	parts []part
+}
+
+type part struct {
+	literal  string
+	variable string
+	start    int
+	end      int // -1 means "to the end of the value"
+	sliced   bool
+}
+
+// Parse parses a template. Variables are written as {name}; {name:start:end}
+// and {name:start} take a substring of the value, clamped to its length.
+// Literal braces are written as {{ and }}.
+func Parse(s string) (*Template, error) {
+	t := &Template{raw: s}
+	var lit strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '{' && i+1 < len(s) && s[i+1] == '{':
+			lit.WriteByte('{')
+			i++
+		case c == '}' && i+1 < len(s) && s[i+1] == '}':
+			lit.WriteByte('}')
+			i++
+		case c == '}':
+			return nil, fmt.Errorf("template %q: unexpected '}' at offset %d", s, i)
+		case c == '{':
+			end := strings.IndexByte(s[i:], '}')
+			if end < 0 {
+				return nil, fmt.Errorf("template %q: unterminated '{' at offset %d", s, i)
+			}
+			p, err := parseVariable(s[i+1 : i+end])
+			if err != nil {
+				return nil, fmt.Errorf("template %q: %w", s, err)
+			}
+			if lit.Len() > 0 {
+				t.parts = append(t.parts, part{literal: lit.String()})
+				lit.Reset()
+			}
+			t.parts = append(t.parts, p)
+			i += end
+		default:
+			lit.WriteByte(c)
+		}
+	}
+	if lit.Len() > 0 {
+		t.parts = append(t.parts, part{literal: lit.String()})
+	}
+	return t, nil
+}
+
+func parseVariable(body string) (part, error) {
+	fields := strings.Split(body, ":")
+	p := part{variable: fields[0], end: -1}
+	if !validName(p.variable) {
+		return part{}, fmt.Errorf("invalid variable name %q", p.variable)
+	}
+	if len(fields) == 1 {
+		return p, nil
+	}
+	if len(fields) > 3 {
+		return part{}, fmt.Errorf("variable {%s}: expected {name:start:end}", body)
+	}
+	p.sliced = true
+	start, err := strconv.Atoi(fields[1])
+	if err != nil || start < 0 {
+		return part{}, fmt.Errorf("variable {%s}: invalid start offset %q", body, fields[1])
+	}
+	p.start = start
+	if len(fields) == 3 && fields[2] != "" {
+		end, err := strconv.Atoi(fields[2])
+		if err != nil || end < start {
+			return part{}, fmt.Errorf("variable {%s}: invalid end offset %q", body, fields[2])
+		}
+		p.end = end
+	}
+	return p, nil
+}
+
+func validName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+// String returns the template source.
+func (t *Template) String() string {
+	return t.raw
+}
+
+// Variables returns the distinct variable names referenced by the template,
+// sorted.
+func (t *Template) Variables() []string {
+	seen := map[string]bool{}
+	var names []string
+	for _, p := range t.parts {
+		if p.variable != "" && !seen[p.variable] {
+			seen[p.variable] = true
+			names = append(names, p.variable)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Execute renders the template. Every referenced variable must be present in
+// vars; an empty value is allowed.
+func (t *Template) Execute(vars Vars) (string, error) {
+	var b strings.Builder
+	var missing []string
+	for _, p := range t.parts {
+		if p.variable == "" {
+			b.WriteString(p.literal)
+			continue
+		}
+		v, ok := vars[p.variable]
+		if !ok {
+			missing = append(missing, p.variable)
+			continue
+		}
+		if p.sliced {
+			v = substring(v, p.start, p.end)
+		}
+		b.WriteString(v)
+	}
+	if len(missing) > 0 {
+		return "", fmt.Errorf("template %q: undefined variable(s) %s", t.raw, strings.Join(missing, ", "))
+	}
+	return b.String(), nil
+}
+
+func substring(s string, start, end int) string {
+	if start > len(s) {
+		return ""
+	}
+	if end < 0 || end > len(s) {
+		end = len(s)
+	}
+	return s[start:end]
+}
+
+// Render parses and executes s in one step.
+func Render(s string, vars Vars) (string, error) {
+	t, err := Parse(s)
+	if err != nil {
+		return "", err
+	}
+	return t.Execute(vars)
+}
+
+// RenderLabel renders s and coerces the result into a valid DNS-1123 label,
+// for templates whose output names a namespace or hostname component.
+func RenderLabel(s string, vars Vars) (string, error) {
+	out, err := Render(s, vars)
+	if err != nil {
+		return "", err
+	}
+	label := SanitizeLabel(out)
+	if label == "" {
+		return "", fmt.Errorf("template %q rendered to %q, which has no valid DNS label characters", s, out)
+	}
+	return label, nil
+}
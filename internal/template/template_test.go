@@ -0,0 +1,78 @@
+package template
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRender(t *testing.T) {
+	vars := Vars{
+		VarProject:   "myapp",
+		VarWords:     "serene-ocean",
+		VarNumber:    "42",
+		VarRefType:   "pr",
+		VarRefName:   "123",
+		VarPRNumber:  "123",
+		VarCommitSHA: "abc1234def5678",
+	}
+
+	tests := []struct {
+		tmpl string
+		want string
+	}{
+		{"{project}-{words}-{number}", "myapp-serene-ocean-42"},
+		{"{ref_type}-{ref_name}-{commit_sha:0:7}", "pr-123-abc1234"},
+		{"pr-{pr_number}", "pr-123"},
+		{"{commit_sha:7}", "def5678"},
+		{"{commit_sha:0:100}", "abc1234def5678"},
+		{"{commit_sha:50:60}", ""},
+		{"no variables", "no variables"},
+		{"{{literal}}-{project}", "{literal}-myapp"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tmpl, func(t *testing.T) {
+			got, err := Render(tt.tmpl, vars)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRenderMissingVariable(t *testing.T) {
+	_, err := Render("{project}-pr-{pr_number}", Vars{VarProject: "app"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "pr_number")
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, tmpl := range []string{
+		"{project",
+		"project}",
+		"{}",
+		"{Project}",
+		"{commit_sha:x}",
+		"{commit_sha:5:2}",
+		"{commit_sha:0:7:9}",
+	} {
+		_, err := Parse(tmpl)
+		assert.Error(t, err, tmpl)
+	}
+}
+
+func TestTemplateVariables(t *testing.T) {
+	tmpl, err := Parse("{ref_type}-{ref_name}-{commit_sha:0:7}-{ref_type}")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"commit_sha", "ref_name", "ref_type"}, tmpl.Variables())
+	assert.Equal(t, "{ref_type}-{ref_name}-{commit_sha:0:7}-{ref_type}", tmpl.String())
+}
+
+func TestRenderLabel(t *testing.T) {
+	got, err := RenderLabel("{project}-{branch_name}", Vars{VarProject: "MyApp", VarBranchName: "Feature/Login_Page"})
+	require.NoError(t, err)
+	assert.Equal(t, "myapp-feature-login-page", got)
+
+	_, err = RenderLabel("{project}", Vars{VarProject: "___"})
+	assert.Error(t, err)
+}
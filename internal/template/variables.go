@@ -0,0 +1,141 @@
+package template
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Variable names understood by eph.yaml templates.
+const (
+	VarProject         = "project"
+	VarWords           = "words"
+	VarNumber          = "number"
+	VarName            = "name"
+	VarEnvironmentName = "environment_name"
+	VarBaseDomain      = "base_domain"
+	VarRefType         = "ref_type"
+	VarRefName         = "ref_name"
+	VarPRNumber        = "pr_number"
+	VarCommitSHA       = "commit_sha"
+	VarBranchName      = "branch_name"
+	VarRegistry        = "registry"
+)
+
+// Ref types used for the {ref_type} variable.
+const (
+	RefTypePR     = "pr"
+	RefTypeBranch = "branch"
+	RefTypeTag    = "tag"
+)
+
+// Context identifies where in eph.yaml a template is used, which determines
+// the variables it may reference.
+type Context string
+
+const (
+	ContextName         Context = "name_template"
+	ContextSubdomain    Context = "subdomain_template"
+	ContextAlias        Context = "alias_template"
+	ContextImageTag     Context = "tag_template"
+	ContextNamespace    Context = "namespace_template"
+	ContextImageOverlay Context = "kubernetes.images"
+	ContextHelmSet      Context = "helm.set"
+	ContextDatabase     Context = "database"
+	ContextPathPrefix   Context = "path_prefix"
+)
+
+var refVariables = []string{VarProject, VarRefType, VarRefName, VarPRNumber, VarCommitSHA, VarBranchName}
+
+var contextVariables = map[Context][]string{
+	// Names must stay stable for the lifetime of an environment, so unlike
+	// tags they cannot depend on the commit being deployed.
+	ContextName:         {VarProject, VarWords, VarNumber, VarRefType, VarRefName, VarPRNumber, VarBranchName},
+	ContextSubdomain:    {VarName, VarEnvironmentName, VarBaseDomain, VarProject},
+	ContextAlias:        {VarProject, VarPRNumber, VarRefType, VarRefName, VarBranchName},
+	ContextImageTag:     refVariables,
+	ContextNamespace:    append([]string{VarName, VarEnvironmentName}, refVariables...),
+	ContextImageOverlay: append([]string{VarRegistry}, refVariables...),
+	ContextHelmSet:      append([]string{VarName, VarEnvironmentName, VarBaseDomain, VarRegistry}, refVariables...),
+	ContextDatabase:     append([]string{VarName, VarEnvironmentName}, refVariables...),
+	ContextPathPrefix:   {VarName, VarEnvironmentName, VarProject},
+}
+
+// Available returns the variables a template may reference in ctx.
+func Available(ctx Context) []string {
+	return contextVariables[ctx]
+}
+
+// Check parses s and verifies that it only references variables available in
+// ctx. It is used by config validation to catch e.g. {commit_sha} in a
+// name_template before anything is rendered.
+func Check(s string, ctx Context) error {
+	t, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	allowed, ok := contextVariables[ctx]
+	if !ok {
+		return fmt.Errorf("unknown template context %q", ctx)
+	}
+	var unknown []string
+	for _, name := range t.Variables() {
+		if !slices.Contains(allowed, name) {
+			unknown = append(unknown, "{"+name+"}")
+		}
+	}
+	if len(unknown) > 0 {
+		return fmt.Errorf("%s not available in %s (available: %s)",
+			strings.Join(unknown, ", "), ctx, braced(allowed))
+	}
+	return nil
+}
+
+// RefVars returns the variables describing a Git ref. For branches, ref_name
+// and branch_name are sanitised into DNS labels so "feature/Login" renders
+// as "feature-login"; tag names are passed through unchanged.
+func RefVars(project, refType, refName, commitSHA string) Vars {
+	v := Vars{
+		VarProject:   project,
+		VarRefType:   refType,
+		VarRefName:   refName,
+		VarCommitSHA: commitSHA,
+	}
+	switch refType {
+	case RefTypePR:
+		v[VarPRNumber] = refName
+	case RefTypeBranch:
+		v[VarRefName] = SanitizeLabel(refName)
+		v[VarBranchName] = SanitizeLabel(refName)
+	}
+	return v
+}
+
+// PRVars returns RefVars for a pull request whose head is branch.
+func PRVars(project string, number int, branch, commitSHA string) Vars {
+	v := RefVars(project, RefTypePR, strconv.Itoa(number), commitSHA)
+	v[VarBranchName] = SanitizeLabel(branch)
+	return v
+}
+
+// Merge returns a copy of v with the entries of other added, overriding
+// duplicates.
+func (v Vars) Merge(other Vars) Vars {
+	out := make(Vars, len(v)+len(other))
+	for k, val := range v {
+		out[k] = val
+	}
+	for k, val := range other {
+		out[k] = val
+	}
+	return out
+}
+
+func braced(names []string) string {
+	out := make([]string, len(names))
+	for i, n := range names {
+		out[i] = "{" + n + "}"
+	}
+	return strings.Join(out, ", ")
+}
@@ -0,0 +1,48 @@
+package template
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheck(t *testing.T) {
+	assert.NoError(t, Check("{project}-{words}-{number}", ContextName))
+	assert.NoError(t, Check("{ref_type}-{ref_name}-{commit_sha:0:7}", ContextImageTag))
+	assert.NoError(t, Check("{project}-pr-{pr_number}", ContextNamespace))
+	assert.NoError(t, Check("{registry}/{project}/api", ContextImageOverlay))
+	assert.NoError(t, Check("/preview/{name}", ContextPathPrefix))
+	assert.NoError(t, Check("{name}.{base_domain}", ContextHelmSet))
+
+	err := Check("{project}-{words}-{number}", ContextImageTag)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "{number}, {words} not available in tag_template")
+
+	assert.Error(t, Check("{name}", ContextName))
+	assert.Error(t, Check("{project", ContextName))
+	assert.Error(t, Check("{project}", Context("bogus")))
+}
+
+func TestRefVars(t *testing.T) {
+	v := RefVars("app", RefTypeBranch, "feature/Login", "abc")
+	assert.Equal(t, "feature-login", v[VarRefName])
+	assert.Equal(t, "feature-login", v[VarBranchName])
+	_, hasPR := v[VarPRNumber]
+	assert.False(t, hasPR)
+
+	v = RefVars("app", RefTypeTag, "v1.0.0", "abc")
+	assert.Equal(t, "v1.0.0", v[VarRefName])
+
+	v = PRVars("app", 123, "fix/bug", "abc1234")
+	got, err := Render("{ref_type}-{ref_name}-{branch_name}", v)
+	require.NoError(t, err)
+	assert.Equal(t, "pr-123-fix-bug", got)
+}
+
+func TestVarsMerge(t *testing.T) {
+	base := Vars{"a": "1", "b": "2"}
+	merged := base.Merge(Vars{"b": "3", "c": "4"})
+	assert.Equal(t, Vars{"a": "1", "b": "3", "c": "4"}, merged)
+	assert.Equal(t, "2", base["b"], "Merge must not modify the receiver")
+}
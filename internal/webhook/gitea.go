@@ -0,0 +1,50 @@
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+func init() {
+	Register("gitea", Gitea{})
+	Register("forgejo", Gitea{})
+}
+
+// Gitea parses webhooks from Gitea and Forgejo, which sign the raw body with
+// HMAC-SHA256 and send the hex digest in X-Gitea-Signature or
+// X-Forgejo-Signature.
+type Gitea struct{}
+
+// Parse implements Parser.
+func (Gitea) Parse(header http.Header, body, secret []byte) (Event, error) {
+	signature := firstHeader(header, "X-Forgejo-Signature", "X-Gitea-Signature")
+	if signature == "" || !ValidHMACSHA256(body, secret, signature) {
+		return Event{}, ErrInvalidSignature
+	}
+	event := Event{Type: firstHeader(header, "X-Forgejo-Event", "X-Gitea-Event")}
+	if event.Type == "" {
+		return Event{}, errors.New("missing X-Gitea-Event header")
+	}
+
+	var payload struct {
+		Repository struct {
+			FullName string `json:"full_name"`
+		} `json:"repository"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return Event{}, fmt.Errorf("decoding payload: %w", err)
+	}
+	event.Repository = payload.Repository.FullName
+	return event, nil
+}
+
+func firstHeader(h http.Header, names ...string) string {
+	for _, name := range names {
+		if v := h.Get(name); v != "" {
+			return v
+		}
+	}
+	return ""
+}
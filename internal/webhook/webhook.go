@@ -0,0 +1,126 @@
+// Package webhook receives forge webhooks. Webhooks are hints, not a source of
+// truth: a verified event only asks the forge informer to refresh now rather
+// than at its next poll, and a missed or forged one costs nothing but latency.
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/ephlabs/eph/internal/log"
+)
+
+// MaxBodyBytes bounds the size of a webhook payload.
+const MaxBodyBytes = 5 << 20
+
+// ErrInvalidSignature is returned by a Parser when a request is not signed
+// with the configured secret.
+var ErrInvalidSignature = errors.New("webhook: invalid signature")
+
+// Event is a verified webhook delivery.
+type Event struct {
+	Forge string
+
+	// Type is the forge's event name, e.g. "pull_request".
+	Type string
+
+	// Repository is the repository the event is about, e.g. "owner/name".
+	Repository string
+}
+
+// Parser verifies and decodes the webhooks of one forge.
+type Parser interface {
+	// Parse checks that body is signed with secret and extracts the event.
+	// It returns ErrInvalidSignature if the signature is missing or wrong.
+	Parse(header http.Header, body, secret []byte) (Event, error)
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Parser{}
+)
+
+// Register makes a webhook parser available under a forge name, e.g.
+// "gitea". It panics if the name is taken.
+func Register(forge string, p Parser) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, dup := registry[forge]; dup {
+		panic("webhook: Register called twice for " + forge)
+	}
+	registry[forge] = p
+}
+
+// Lookup returns the parser registered for forge.
+func Lookup(forge string) (Parser, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	p, ok := registry[forge]
+	return p, ok
+}
+
+// Registered returns the names of all forges with a webhook parser, sorted.
+func Registered() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Handler returns an http.Handler that verifies deliveries with forge's
+// parser and secret and calls onEvent for each valid one. onEvent must not
+// block.
+func Handler(forge string, secret []byte, onEvent func(context.Context, Event)) (http.Handler, error) {
+	parser, ok := Lookup(forge)
+	if !ok {
+		return nil, fmt.Errorf("webhook: no parser for forge %q (supported: %v)", forge, Registered())
+	}
+	if len(secret) == 0 {
+		return nil, errors.New("webhook: a secret is required")
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MaxBodyBytes))
+		if err != nil {
+			http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		event, err := parser.Parse(r.Header, body, secret)
+		switch {
+		case errors.Is(err, ErrInvalidSignature):
+			log.Warn(r.Context(), "Rejected webhook with invalid signature", "forge", forge, "remote_addr", r.RemoteAddr)
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		event.Forge = forge
+		log.Debug(r.Context(), "Webhook received", "forge", forge, "event", event.Type, "repository", event.Repository)
+		onEvent(r.Context(), event)
+		w.WriteHeader(http.StatusAccepted)
+	}), nil
+}
+
+// ValidHMACSHA256 reports whether signature is the hex-encoded HMAC-SHA256 of
+// body under secret. The comparison is constant-time.
+func ValidHMACSHA256(body, secret []byte, signature string) bool {
+	got, err := hex.DecodeString(signature)
+	if err != nil || len(got) != sha256.Size {
+		return false
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
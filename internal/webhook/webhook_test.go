@@ -0,0 +1,71 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func sign(body, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+const payload = `{"action": "labeled", "number": 3, "repository": {"full_name": "tools/runner"}}`
+
+func TestGiteaHandler(t *testing.T) {
+	var events []Event
+	h, err := Handler("forgejo", []byte("s3cret"), func(_ context.Context, e Event) { events = append(events, e) })
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		header map[string]string
+		want   int
+	}{
+		{"forgejo", map[string]string{"X-Forgejo-Event": "pull_request_label", "X-Forgejo-Signature": sign(payload, "s3cret")}, http.StatusAccepted},
+		{"gitea", map[string]string{"X-Gitea-Event": "pull_request", "X-Gitea-Signature": sign(payload, "s3cret")}, http.StatusAccepted},
+		{"wrong secret", map[string]string{"X-Gitea-Event": "pull_request", "X-Gitea-Signature": sign(payload, "other")}, http.StatusUnauthorized},
+		{"unsigned", map[string]string{"X-Gitea-Event": "pull_request"}, http.StatusUnauthorized},
+		{"no event", map[string]string{"X-Gitea-Signature": sign(payload, "s3cret")}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/webhooks/forgejo", strings.NewReader(payload))
+			for k, v := range tt.header {
+				req.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+			assert.Equal(t, tt.want, w.Code)
+		})
+	}
+
+	require.Len(t, events, 2)
+	assert.Equal(t, Event{Forge: "forgejo", Type: "pull_request_label", Repository: "tools/runner"}, events[0])
+	assert.Equal(t, "pull_request", events[1].Type)
+}
+
+func TestHandlerRequiresParserAndSecret(t *testing.T) {
+	_, err := Handler("bitbucket", []byte("x"), func(context.Context, Event) {})
+	assert.ErrorContains(t, err, "bitbucket")
+
+	_, err = Handler("gitea", nil, func(context.Context, Event) {})
+	assert.ErrorContains(t, err, "secret")
+}
+
+func TestValidHMACSHA256(t *testing.T) {
+	assert.True(t, ValidHMACSHA256([]byte("body"), []byte("k"), sign("body", "k")))
+	assert.False(t, ValidHMACSHA256([]byte("body!"), []byte("k"), sign("body", "k")))
+	assert.False(t, ValidHMACSHA256([]byte("body"), []byte("k"), "not-hex"))
+	assert.False(t, ValidHMACSHA256([]byte("body"), []byte("k"), "abcd"))
+}
@@ -0,0 +1,169 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/ephlabs/eph/internal/log"
+)
+
+const (
+	DefaultMaxRestarts    = 5
+	DefaultRestartWindow  = 5 * time.Minute
+	DefaultRestartBackoff = time.Second
+	maxRestartBackoff     = 30 * time.Second
+)
+
+// Component is a long-running part of ephd, such as the HTTP server, an
+// informer or the reconciler.
+type Component struct {
+	Name string
+
+	// Run must block until ctx is cancelled. Returning earlier, with or
+	// without an error, or panicking counts as a crash.
+	Run func(ctx context.Context) error
+
+	// Critical components take the whole process down once they exhaust
+	// their restart budget. Non-critical components are restarted forever.
+	Critical bool
+}
+
+// Supervisor runs components with a shared context and restarts them when
+// they crash. Restarts back off exponentially; if a critical component
+// crashes more than MaxRestarts times within RestartWindow, the supervisor
+// cancels every component and returns an error.
+type Supervisor struct {
+	MaxRestarts    int
+	RestartWindow  time.Duration
+	RestartBackoff time.Duration
+}
+
+// ErrComponentFailed wraps the error returned by Run when a critical component
+// could not be kept running.
+var ErrComponentFailed = errors.New("critical component failed")
+
+// Fatal marks err as one that restarting the component cannot fix, such as
+// an address already in use. The supervisor does not restart a component
+// that returns it: a critical one fails at once, any other stays stopped.
+func Fatal(err error) error {
+	return fatalError{err}
+}
+
+type fatalError struct{ err error }
+
+func (e fatalError) Error() string { return e.err.Error() }
+func (e fatalError) Unwrap() error { return e.err }
+
+// Supervise runs components under a Supervisor with default settings.
+func Supervise(ctx context.Context, components ...Component) error {
+	return (&Supervisor{}).Run(ctx, components...)
+}
+
+// Run starts every component and blocks until ctx is cancelled, in which case
+// it returns nil once all components have stopped, or until a critical
+// component fails for good.
+func (s *Supervisor) Run(ctx context.Context, components ...Component) error {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	var wg sync.WaitGroup
+	for _, c := range components {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := s.supervise(ctx, c); err != nil {
+				cancel(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := context.Cause(ctx); err != nil && errors.Is(err, ErrComponentFailed) {
+		return err
+	}
+	return nil
+}
+
+// supervise keeps c running until ctx is done. It returns an error only when
+// a critical component has exhausted its restart budget.
+func (s *Supervisor) supervise(ctx context.Context, c Component) error {
+	ctx = log.WithLogger(ctx, log.FromContext(ctx).With("component", c.Name))
+	var crashes []time.Time
+	for {
+		err := runSafely(ctx, c)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err == nil {
+			err = errors.New("exited unexpectedly")
+		}
+		if errors.As(err, new(fatalError)) {
+			if c.Critical {
+				log.Error(ctx, "Component failed, giving up", "error", err)
+				return fmt.Errorf("%w: %s: %w", ErrComponentFailed, c.Name, err)
+			}
+			log.Error(ctx, "Component failed, not restarting it", "error", err)
+			return nil
+		}
+
+		now := time.Now()
+		crashes = append(crashes, now)
+		for len(crashes) > 0 && now.Sub(crashes[0]) > s.restartWindow() {
+			crashes = crashes[1:]
+		}
+		if c.Critical && len(crashes) > s.maxRestarts() {
+			log.Error(ctx, "Component failed too often, giving up",
+				"error", err,
+				"crashes", len(crashes),
+				"window", s.restartWindow())
+			return fmt.Errorf("%w: %s: %w", ErrComponentFailed, c.Name, err)
+		}
+
+		delay := s.backoff(len(crashes))
+		log.Warn(ctx, "Component crashed, restarting", "error", err, "restart_in", delay)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(delay):
+		}
+	}
+}
+
+func runSafely(ctx context.Context, c Component) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+			log.Error(ctx, "Component panicked", "panic", r, "stack", string(debug.Stack()))
+		}
+	}()
+	return c.Run(ctx)
+}
+
+func (s *Supervisor) maxRestarts() int {
+	if s.MaxRestarts > 0 {
+		return s.MaxRestarts
+	}
+	return DefaultMaxRestarts
+}
+
+func (s *Supervisor) restartWindow() time.Duration {
+	if s.RestartWindow > 0 {
+		return s.RestartWindow
+	}
+	return DefaultRestartWindow
+}
+
+func (s *Supervisor) backoff(crashes int) time.Duration {
+	d := DefaultRestartBackoff
+	if s.RestartBackoff > 0 {
+		d = s.RestartBackoff
+	}
+	for i := 1; i < crashes && d < maxRestartBackoff; i++ {
+		d *= 2
+	}
+	return min(d, maxRestartBackoff)
+}
@@ -0,0 +1,127 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func fastSupervisor() *Supervisor {
+	return &Supervisor{MaxRestarts: 2, RestartWindow: time.Minute, RestartBackoff: time.Millisecond}
+}
+
+func TestSupervisorStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var stopped atomic.Int32
+	blocking := func(ctx context.Context) error {
+		<-ctx.Done()
+		stopped.Add(1)
+		return ctx.Err()
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- fastSupervisor().Run(ctx,
+			Component{Name: "a", Run: blocking, Critical: true},
+			Component{Name: "b", Run: blocking},
+		)
+	}()
+
+	cancel()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("supervisor did not stop")
+	}
+	assert.Equal(t, int32(2), stopped.Load())
+}
+
+func TestSupervisorRestartsCrashedComponents(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var runs atomic.Int32
+	flaky := func(ctx context.Context) error {
+		switch runs.Add(1) {
+		case 1:
+			return errors.New("boom")
+		case 2:
+			panic("kaboom")
+		}
+		<-ctx.Done()
+		return nil
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- fastSupervisor().Run(ctx, Component{Name: "flaky", Run: flaky, Critical: true}) }()
+
+	require.Eventually(t, func() bool { return runs.Load() == 3 }, time.Second, time.Millisecond)
+	cancel()
+	assert.NoError(t, <-done)
+}
+
+func TestSupervisorGivesUpOnCriticalComponent(t *testing.T) {
+	var peerStopped atomic.Bool
+	broken := func(context.Context) error { return errors.New("cannot bind :8080") }
+	peer := func(ctx context.Context) error {
+		<-ctx.Done()
+		peerStopped.Store(true)
+		return nil
+	}
+
+	err := fastSupervisor().Run(context.Background(),
+		Component{Name: "http", Run: broken, Critical: true},
+		Component{Name: "informer", Run: peer},
+	)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrComponentFailed))
+	assert.Contains(t, err.Error(), "http")
+	assert.Contains(t, err.Error(), "cannot bind :8080")
+	assert.True(t, peerStopped.Load(), "other components must be stopped")
+}
+
+func TestSupervisorKeepsRestartingNonCritical(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var runs atomic.Int32
+	broken := func(context.Context) error {
+		runs.Add(1)
+		return errors.New("rate limited")
+	}
+
+	done := make(chan error, 1)
+	s := &Supervisor{MaxRestarts: 1, RestartWindow: time.Minute, RestartBackoff: time.Microsecond}
+	go func() { done <- s.Run(ctx, Component{Name: "informer", Run: broken}) }()
+
+	require.Eventually(t, func() bool { return runs.Load() > 3 }, 5*time.Second, time.Millisecond)
+	cancel()
+	assert.NoError(t, <-done)
+}
+
+func TestSupervisorDoesNotRestartFatalErrors(t *testing.T) {
+	var runs, pluginRuns atomic.Int32
+	inUse := func(context.Context) error {
+		runs.Add(1)
+		return Fatal(errors.New("listen tcp :8080: bind: address already in use"))
+	}
+	plugin := func(context.Context) error {
+		pluginRuns.Add(1)
+		return Fatal(errors.New("plugin binary missing"))
+	}
+
+	err := fastSupervisor().Run(context.Background(),
+		Component{Name: "plugin", Run: plugin},
+		Component{Name: "http", Run: inUse, Critical: true},
+	)
+	require.ErrorIs(t, err, ErrComponentFailed)
+	assert.Contains(t, err.Error(), "http: listen tcp :8080")
+	assert.Equal(t, int32(1), runs.Load())
+	assert.Equal(t, int32(1), pluginRuns.Load(), "non-critical components stay stopped")
+}
@@ -0,0 +1,283 @@
+// Package conformance checks that a provider plugin behaves as ephd expects.
+// A plugin's tests run it against the plugin binary:
+//
+//	func TestConformance(t *testing.T) {
+//		conformance.Run(t, conformance.Options{
+//			Binary: "./bin/eph-provider-nomad",
+//			Name:   "nomad",
+//		})
+//	}
+//
+// The suite talks to the binary through the same host as ephd, so it
+// creates and destroys a real environment on the plugin's backend.
+package conformance
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	providerv1 "github.com/ephlabs/eph/api/eph/provider/v1"
+	"github.com/ephlabs/eph/internal/config"
+	"github.com/ephlabs/eph/internal/providers"
+	"github.com/ephlabs/eph/internal/providers/plugin"
+	"github.com/ephlabs/eph/pkg/provider"
+)
+
+// Options configures a conformance run.
+type Options struct {
+	// Binary is the plugin executable, started with Args and with Env
+	// added to the test's environment.
+	Binary string
+	Args   []string
+	Env    []string
+
+	// Name is the provider's name, which the plugin must report in the
+	// handshake.
+	Name string
+
+	// Config is the eph.yaml handed to the plugin, with relative paths
+	// resolved against Dir. It defaults to a minimal project selecting Name
+	// as the primary provider, and Dir to the working directory.
+	Config string
+	Dir    string
+
+	// Spec is the environment the suite creates and destroys. Key, Name
+	// and Project default to values identifying the suite.
+	Spec provider.Spec
+
+	// Timeout bounds each operation; it defaults to five minutes.
+	Timeout time.Duration
+}
+
+// stopTimeout is how long the plugin has to shut down once asked to. A
+// plugin still serving a cancelled call takes longer.
+const stopTimeout = 5 * time.Second
+
+// Run runs the conformance suite against the plugin. The subtests share one
+// environment and run in order:
+//   - the handshake and access check succeed
+//   - creating an environment twice leaves one environment
+//   - the environment is still reported after the plugin crashes
+//   - cancelling a followed log stream ends the call on the plugin
+//   - destroying the environment removes it
+//   - destroying an environment that does not exist succeeds
+func Run(t *testing.T, opts Options) {
+	t.Helper()
+	s := newSuite(t, opts)
+	steps := []struct {
+		name string
+		run  func(t *testing.T)
+	}{
+		{"Handshake", s.handshake},
+		{"IdempotentCreate", s.idempotentCreate},
+		{"StatusAfterCrash", s.statusAfterCrash},
+		{"LogStreamCancellation", s.logStreamCancellation},
+		{"Destroy", s.destroy},
+		{"DestroyMissing", s.destroyMissing},
+	}
+	for _, step := range steps {
+		if !t.Run(step.name, step.run) {
+			t.Fatalf("%s failed, skipping the remaining steps", step.name)
+		}
+	}
+}
+
+type suite struct {
+	opts Options
+	spec providers.Spec
+	p    *plugin.Provider
+}
+
+func newSuite(t *testing.T, opts Options) *suite {
+	require.NotEmpty(t, opts.Binary, "Options.Binary is required")
+	require.NotEmpty(t, opts.Name, "Options.Name is required")
+	if opts.Config == "" {
+		opts.Config = "version: \"1\"\nname: conformance\nproviders:\n  primary: " + opts.Name + "\n"
+	}
+	if opts.Dir == "" {
+		wd, err := os.Getwd()
+		require.NoError(t, err)
+		opts.Dir = wd
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 5 * time.Minute
+	}
+	cfg, err := config.Parse(filepath.Join(opts.Dir, "eph.yaml"), []byte(opts.Config))
+	require.NoError(t, err, "parsing Options.Config")
+
+	spec := opts.Spec
+	if spec.Project == "" {
+		spec.Project = cfg.Name
+	}
+	if spec.Name == "" {
+		spec.Name = "conformance"
+	}
+	if spec.Key == "" {
+		spec.Key = "conformance/" + spec.Project + "/pr/1"
+	}
+	if spec.CommitSHA == "" {
+		spec.CommitSHA = "0000000000000000000000000000000000000001"
+	}
+
+	p, err := plugin.New(opts.Name, cfg, plugin.Options{
+		Path:         opts.Binary,
+		Args:         opts.Args,
+		Env:          opts.Env,
+		StartTimeout: opts.Timeout,
+		StopTimeout:  stopTimeout,
+	})
+	require.NoError(t, err)
+	s := &suite{opts: opts, p: p, spec: providers.Spec{
+		Key:        spec.Key,
+		Name:       spec.Name,
+		Project:    spec.Project,
+		Repository: spec.Repository,
+		RefType:    spec.RefType,
+		RefName:    spec.RefName,
+		CommitSHA:  spec.CommitSHA,
+		Images:     spec.Images,
+		Env:        spec.Env,
+		Vars:       spec.Vars,
+		Config:     cfg,
+	}}
+	t.Cleanup(func() {
+		// Leave nothing behind on the backend if a step failed.
+		ctx, cancel := s.context()
+		defer cancel()
+		if err := p.Destroy(ctx, providers.Instance{Key: s.spec.Key, Name: s.spec.Name}); err != nil {
+			t.Logf("cleaning up: %v", err)
+		}
+		p.Close()
+	})
+	return s
+}
+
+func (s *suite) context() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), s.opts.Timeout)
+}
+
+func (s *suite) status(t *testing.T) *providerv1.EnvironmentStatus {
+	t.Helper()
+	ctx, cancel := s.context()
+	defer cancel()
+	c, err := s.p.Client(ctx)
+	require.NoError(t, err)
+	st, err := c.GetEnvironmentStatus(ctx, &providerv1.GetEnvironmentStatusRequest{Key: s.spec.Key})
+	require.NoError(t, err)
+	return st
+}
+
+// listed returns the environments with the suite's key.
+func (s *suite) listed(t *testing.T) []providers.Instance {
+	t.Helper()
+	ctx, cancel := s.context()
+	defer cancel()
+	list, err := s.p.List(ctx)
+	require.NoError(t, err)
+	var out []providers.Instance
+	for _, inst := range list {
+		if inst.Key == s.spec.Key {
+			out = append(out, inst)
+		}
+	}
+	return out
+}
+
+func (s *suite) handshake(t *testing.T) {
+	ctx, cancel := s.context()
+	defer cancel()
+	require.NoError(t, s.p.CheckAccess(ctx))
+}
+
+func (s *suite) idempotentCreate(t *testing.T) {
+	ctx, cancel := s.context()
+	defer cancel()
+	first, err := s.p.Apply(ctx, s.spec)
+	require.NoError(t, err)
+	assert.Equal(t, s.spec.Key, first.Key)
+	assert.Equal(t, s.spec.Name, first.Name)
+	assert.Equal(t, s.spec.CommitSHA, first.CommitSHA)
+
+	second, err := s.p.Apply(ctx, s.spec)
+	require.NoError(t, err, "creating an existing environment must update it in place")
+	assert.Equal(t, first.Key, second.Key)
+	assert.Equal(t, first.Name, second.Name)
+	assert.Len(t, s.listed(t), 1, "creating the environment twice must leave one environment")
+}
+
+func (s *suite) statusAfterCrash(t *testing.T) {
+	require.NoError(t, s.p.Kill())
+	listed := s.listed(t)
+	require.Len(t, listed, 1, "environments must be listed from the backend, not the plugin's memory")
+	assert.Equal(t, s.spec.Name, listed[0].Name)
+	assert.Equal(t, s.spec.CommitSHA, listed[0].CommitSHA)
+
+	st := s.status(t)
+	assert.True(t, st.Found, "the environment's status must be available after a restart")
+	assert.Equal(t, s.spec.Key, st.GetEnvironment().GetKey())
+}
+
+func (s *suite) logStreamCancellation(t *testing.T) {
+	ctx, cancel := s.context()
+	defer cancel()
+	c, err := s.p.Client(ctx)
+	require.NoError(t, err)
+	caps, err := c.GetCapabilities(ctx, &providerv1.Empty{})
+	require.NoError(t, err)
+	if !caps.SupportsLogs {
+		t.Skip("the plugin does not support logs")
+	}
+
+	streamCtx, cancelStream := context.WithCancel(ctx)
+	stream, err := c.StreamLogs(streamCtx, &providerv1.StreamLogsRequest{Key: s.spec.Key, Follow: true})
+	require.NoError(t, err)
+	received := make(chan error, 1)
+	go func() {
+		for {
+			if _, err := stream.Recv(); err != nil {
+				received <- err
+				return
+			}
+		}
+	}()
+	// Let the plugin start following before cancelling.
+	time.Sleep(200 * time.Millisecond)
+	cancelStream()
+	select {
+	case err := <-received:
+		assert.Equal(t, codes.Canceled, status.Code(err), "a followed stream must only end when cancelled: %v", err)
+	case <-time.After(stopTimeout):
+		t.Fatal("the log stream did not end after it was cancelled")
+	}
+
+	// Stopping waits for calls in flight: a plugin still following the
+	// cancelled stream is killed instead.
+	start := time.Now()
+	require.NoError(t, s.p.Close())
+	assert.Less(t, time.Since(start), stopTimeout, "the plugin kept serving the cancelled log stream")
+}
+
+func (s *suite) destroy(t *testing.T) {
+	ctx, cancel := s.context()
+	defer cancel()
+	listed := s.listed(t)
+	require.Len(t, listed, 1)
+	require.NoError(t, s.p.Destroy(ctx, listed[0]))
+	assert.Empty(t, s.listed(t), "a destroyed environment must not be listed")
+	assert.False(t, s.status(t).Found, "a destroyed environment must not be found")
+}
+
+func (s *suite) destroyMissing(t *testing.T) {
+	ctx, cancel := s.context()
+	defer cancel()
+	missing := providers.Instance{Key: s.spec.Key + "-missing", Name: s.spec.Name + "-missing"}
+	assert.NoError(t, s.p.Destroy(ctx, missing), "destroying an environment that does not exist must succeed")
+}
@@ -0,0 +1,134 @@
+package conformance_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ephlabs/eph/pkg/provider"
+	"github.com/ephlabs/eph/pkg/provider/conformance"
+)
+
+// The test binary doubles as a plugin built with the SDK, keeping its
+// environments as JSON files in the directory named by envStateDir.
+const envStateDir = "EPH_TEST_STATE_DIR"
+
+func TestMain(m *testing.M) {
+	if dir := os.Getenv(envStateDir); dir != "" {
+		if err := provider.Serve(&fileProvider{dir: dir}); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+type fileProvider struct {
+	dir     string
+	project string
+}
+
+func (p *fileProvider) Info() provider.Info {
+	return provider.Info{Name: "files", Version: "0.0.1"}
+}
+
+func (p *fileProvider) Configure(_ context.Context, cfg provider.Config) error {
+	var doc struct {
+		Name string `yaml:"name"`
+	}
+	if err := cfg.Decode(&doc); err != nil {
+		return err
+	}
+	p.project = doc.Name
+	return nil
+}
+
+func (p *fileProvider) CheckAccess(context.Context) error {
+	_, err := os.Stat(p.dir)
+	return err
+}
+
+func (p *fileProvider) path(key string) string {
+	return filepath.Join(p.dir, strings.ReplaceAll(key, "/", "_")+".json")
+}
+
+func (p *fileProvider) Create(_ context.Context, spec provider.Spec, progress *provider.Progress) (provider.Environment, error) {
+	if err := progress.Report(10, "writing "+spec.Name); err != nil {
+		return provider.Environment{}, err
+	}
+	env := provider.Environment{
+		Key:       spec.Key,
+		Name:      spec.Name,
+		CommitSHA: spec.CommitSHA,
+		URL:       "http://" + spec.Name + "." + p.project + ".test",
+		Ready:     true,
+	}
+	data, err := json.Marshal(env)
+	if err != nil {
+		return provider.Environment{}, err
+	}
+	progress.Created("file", spec.Name, p.path(spec.Key))
+	return env, os.WriteFile(p.path(spec.Key), data, 0o600)
+}
+
+func (p *fileProvider) Destroy(_ context.Context, env provider.Environment, _ *provider.Progress) error {
+	err := os.Remove(p.path(env.Key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (p *fileProvider) List(context.Context) ([]provider.Environment, error) {
+	files, err := filepath.Glob(filepath.Join(p.dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var envs []provider.Environment
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		var env provider.Environment
+		if err := json.Unmarshal(data, &env); err != nil {
+			return nil, err
+		}
+		envs = append(envs, env)
+	}
+	return envs, nil
+}
+
+func (p *fileProvider) Logs(ctx context.Context, req provider.LogRequest, send func(provider.LogEntry) error) error {
+	for i := 0; ; i++ {
+		if err := send(provider.LogEntry{Time: time.Now(), Service: "web", Stream: "stdout", Line: fmt.Sprintf("line %d", i)}); err != nil {
+			return err
+		}
+		if !req.Follow {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+}
+
+func TestConformance(t *testing.T) {
+	conformance.Run(t, conformance.Options{
+		Binary:  os.Args[0],
+		Args:    []string{"-test.run=^$"},
+		Env:     []string{envStateDir + "=" + t.TempDir()},
+		Name:    "files",
+		Timeout: 30 * time.Second,
+	})
+}
@@ -0,0 +1,80 @@
+package provider
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+
+	providerv1 "github.com/ephlabs/eph/api/eph/provider/v1"
+)
+
+// Progress reports the progress of a long-running operation to ephd. It is
+// safe for concurrent use.
+type Progress struct {
+	mu        sync.Mutex
+	id        string
+	send      func(*providerv1.OperationUpdate) error
+	outputs   map[string]string
+	resources []*providerv1.ResourceInfo
+}
+
+func newProgress(send func(*providerv1.OperationUpdate) error) *Progress {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return &Progress{id: hex.EncodeToString(b), send: send, outputs: map[string]string{}}
+}
+
+// Report sends a progress update. percent is clamped to 0-100. An error
+// means ephd is no longer listening, e.g. because the operation was
+// cancelled.
+func (p *Progress) Report(percent int, message string) error {
+	percent = min(max(percent, 0), 100)
+	return p.update(providerv1.OperationStatus_OPERATION_STATUS_RUNNING, message, percent)
+}
+
+// Output records a named result of the operation, e.g. a database URL,
+// sent to ephd with the final update.
+func (p *Progress) Output(key, value string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.outputs[key] = value
+}
+
+// Created records a resource the operation created on the backend, sent to
+// ephd with the final update.
+func (p *Progress) Created(kind, name, id string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.resources = append(p.resources, &providerv1.ResourceInfo{Kind: kind, Name: name, Id: id})
+}
+
+func (p *Progress) update(status providerv1.OperationStatus, message string, percent int) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.send(&providerv1.OperationUpdate{
+		OperationId:     p.id,
+		Status:          status,
+		Message:         message,
+		ProgressPercent: int32(percent),
+	})
+}
+
+// finish sends the final update of the operation.
+func (p *Progress) finish(err error, env *providerv1.Environment) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	u := &providerv1.OperationUpdate{
+		OperationId:      p.id,
+		Status:           providerv1.OperationStatus_OPERATION_STATUS_SUCCEEDED,
+		ProgressPercent:  100,
+		Outputs:          p.outputs,
+		CreatedResources: p.resources,
+		Environment:      env,
+	}
+	if err != nil {
+		u.Status = providerv1.OperationStatus_OPERATION_STATUS_FAILED
+		u.Message = err.Error()
+		u.Environment = nil
+	}
+	return p.send(u)
+}
@@ -0,0 +1,222 @@
+// Package provider is the SDK for writing Eph provider plugins: binaries
+// that ephd starts to create and destroy environments on backends it has no
+// built-in support for.
+//
+// A plugin implements Provider, and any of the optional interfaces its
+// backend supports, and calls Serve from main:
+//
+//	func main() {
+//		if err := provider.Serve(&nomadProvider{}); err != nil {
+//			log.Fatal(err)
+//		}
+//	}
+//
+// ephd discovers plugins named eph-provider-<name> in EPH_PLUGIN_DIR. The
+// package conformance checks that a plugin behaves as ephd expects.
+package provider
+
+import (
+	"context"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Protocol constants shared by ephd and plugins.
+const (
+	// EnvSocket names the Unix socket a plugin must serve on.
+	EnvSocket = "EPH_PLUGIN_SOCKET"
+
+	// EnvMagicCookie is set to MagicCookie for plugins started by ephd, so
+	// that a plugin run by hand can tell it is not meant to be.
+	EnvMagicCookie = "EPH_PLUGIN_MAGIC_COOKIE"
+	MagicCookie    = "9f3c1a2e6b0d4e7a8c5f2b1d0e9a7c6b"
+)
+
+// SupportedVersions are the protocol versions the SDK speaks. The handshake
+// picks the highest one ephd also speaks.
+var SupportedVersions = []uint32{1}
+
+// Provider creates and destroys environments on a backend.
+//
+// Like ephd's built-in providers, plugins must be stateless: everything List
+// returns must be recorded on the backend, as the plugin is restarted
+// whenever it or ephd crashes. Create and Destroy must be idempotent.
+type Provider interface {
+	// Info describes the plugin. Name must match the name ephd knows it by,
+	// the part of the binary's name after eph-provider-.
+	Info() Info
+
+	// Configure hands the plugin the project's configuration. It is called
+	// once per handshake, before any other method.
+	Configure(ctx context.Context, cfg Config) error
+
+	// CheckAccess verifies that the backend is reachable with the
+	// configured credentials.
+	CheckAccess(ctx context.Context) error
+
+	// Create creates the environment described by spec, or updates it in
+	// place if it already exists, reporting its progress to progress.
+	Create(ctx context.Context, spec Spec, progress *Progress) (Environment, error)
+
+	// Destroy removes an environment and everything it owns. Destroying an
+	// environment that does not exist is not an error.
+	Destroy(ctx context.Context, env Environment, progress *Progress) error
+
+	// List returns every environment the plugin manages for the project.
+	List(ctx context.Context) ([]Environment, error)
+}
+
+// Validator is implemented by plugins that check their part of eph.yaml.
+// Without it, every configuration is accepted.
+type Validator interface {
+	Validate(ctx context.Context, cfg Config) []ValidationError
+}
+
+// Scaler is implemented by plugins that can change the number of replicas
+// of an environment's workloads. Replicas of 0 scales it to zero.
+type Scaler interface {
+	Scale(ctx context.Context, key string, replicas int, progress *Progress) error
+}
+
+// LogStreamer is implemented by plugins that can stream an environment's
+// logs. Logs calls send for every entry until it runs out of entries, or,
+// when following, until ctx is cancelled; it must then return promptly.
+type LogStreamer interface {
+	Logs(ctx context.Context, req LogRequest, send func(LogEntry) error) error
+}
+
+// MetricsReporter is implemented by plugins that report an environment's
+// resource usage.
+type MetricsReporter interface {
+	Metrics(ctx context.Context, key string) (Metrics, error)
+}
+
+// Info describes a plugin.
+type Info struct {
+	Name    string
+	Version string
+
+	Capabilities Capabilities
+}
+
+// Capabilities declares what a plugin's backend supports. Support for logs
+// and metrics is declared by implementing LogStreamer and MetricsReporter.
+type Capabilities struct {
+	ScaleToZero          bool
+	CustomDomains        bool
+	PersistentStorage    bool
+	DatabaseProvisioning bool
+	Databases            []string
+
+	// Limits on a single environment, e.g. "4" and "8Gi", and on the
+	// number of environments. Zero values mean no limit.
+	MaxCPU          string
+	MaxMemory       string
+	MaxEnvironments int
+
+	// ConfigSchema documents the plugin's settings, by YAML path.
+	ConfigSchema map[string]ConfigField
+}
+
+// ConfigField documents one of a plugin's settings.
+type ConfigField struct {
+	// Type is a JSON schema type, e.g. "string" or "object".
+	Type        string
+	Description string
+	Required    bool
+}
+
+// Config is the project's eph.yaml, after overlays and interpolation.
+type Config struct {
+	// Data is the YAML document.
+	Data []byte
+
+	// Dir is the directory relative paths in it are resolved against.
+	Dir string
+}
+
+// Decode decodes the document into v, ignoring the fields v does not have.
+func (c Config) Decode(v any) error {
+	return yaml.Unmarshal(c.Data, v)
+}
+
+// ValidationError reports a problem with the value at a YAML path of
+// eph.yaml, e.g. "advanced.nomad.datacenter".
+type ValidationError struct {
+	Path    string
+	Message string
+}
+
+// Spec describes an environment that should exist.
+type Spec struct {
+	// Key identifies the environment's source ref across restarts, e.g.
+	// "ephlabs/eph/pr/123". Plugins must record it so List can return it.
+	Key string
+
+	// Name is the generated environment name, already a valid DNS label.
+	Name    string
+	Project string
+
+	Repository string
+	RefType    string
+	RefName    string
+	CommitSHA  string
+
+	// Images maps eph.yaml image names to the references to deploy.
+	Images map[string]string
+
+	// Env holds environment variables to inject into every workload.
+	Env map[string]string
+
+	// Vars are the template variables for the environment.
+	Vars map[string]string
+}
+
+// Environment is an environment as reported by a plugin.
+type Environment struct {
+	Key       string
+	Name      string
+	CommitSHA string
+	URL       string
+
+	// Headers must be sent with requests to URL to reach the environment.
+	Headers map[string]string
+
+	// Ready is set once the environment is serving traffic.
+	Ready bool
+
+	// Message explains why an environment is not ready, if known.
+	Message string
+}
+
+// LogRequest selects the logs to stream.
+type LogRequest struct {
+	Key string
+
+	// Service restricts logs to one service; empty means all.
+	Service string
+	Follow  bool
+
+	// TailLines limits the entries before following to the last ones; 0
+	// means all.
+	TailLines int64
+	Since     time.Time
+}
+
+// LogEntry is a line of a service's output.
+type LogEntry struct {
+	Time    time.Time
+	Service string
+
+	// Stream is "stdout" or "stderr".
+	Stream string
+	Line   string
+}
+
+// Metrics is an environment's current resource usage.
+type Metrics struct {
+	CPUMillicores int64
+	MemoryBytes   int64
+	Custom        map[string]float64
+}
@@ -0,0 +1,60 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"google.golang.org/grpc"
+
+	providerv1 "github.com/ephlabs/eph/api/eph/provider/v1"
+)
+
+// ErrNotPlugin is returned by Serve when the binary was not started by
+// ephd.
+var ErrNotPlugin = errors.New("this binary is an Eph provider plugin: install it in ephd's EPH_PLUGIN_DIR rather than running it directly")
+
+// stopTimeout is how long in-flight calls get to finish after SIGTERM.
+const stopTimeout = 30 * time.Second
+
+// Serve serves p on the socket ephd named in EPH_PLUGIN_SOCKET until the
+// plugin receives SIGTERM or SIGINT, then lets in-flight calls finish.
+func Serve(p Provider) error {
+	if os.Getenv(EnvMagicCookie) != MagicCookie {
+		return ErrNotPlugin
+	}
+	socket := os.Getenv(EnvSocket)
+	if socket == "" {
+		return fmt.Errorf("%s is not set", EnvSocket)
+	}
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		return err
+	}
+	srv := grpc.NewServer()
+	providerv1.RegisterProviderServer(srv, NewServer(p))
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+	defer signal.Stop(signals)
+	go func() {
+		if _, ok := <-signals; !ok {
+			return
+		}
+		stopped := make(chan struct{})
+		go func() {
+			srv.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-time.After(stopTimeout):
+			srv.Stop()
+		}
+	}()
+	return srv.Serve(l)
+}
@@ -0,0 +1,264 @@
+package provider
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	providerv1 "github.com/ephlabs/eph/api/eph/provider/v1"
+)
+
+// server implements the eph.provider.v1 protocol on top of a Provider.
+type server struct {
+	providerv1.UnimplementedProviderServer
+
+	p Provider
+
+	mu         sync.Mutex
+	configured bool
+}
+
+// NewServer returns the gRPC service for p, for plugins that run their own
+// gRPC server rather than calling Serve.
+func NewServer(p Provider) providerv1.ProviderServer {
+	return &server{p: p}
+}
+
+func (s *server) Handshake(ctx context.Context, req *providerv1.HandshakeRequest) (*providerv1.HandshakeResponse, error) {
+	var version uint32
+	for _, v := range req.ProtocolVersions {
+		if slices.Contains(SupportedVersions, v) && v > version {
+			version = v
+		}
+	}
+	if version == 0 {
+		return nil, status.Errorf(codes.FailedPrecondition,
+			"no common protocol version: ephd speaks %v, the plugin %v", req.ProtocolVersions, SupportedVersions)
+	}
+	if err := s.p.Configure(ctx, Config{Data: req.Config, Dir: req.ConfigDir}); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "configuring: %v", err)
+	}
+	s.mu.Lock()
+	s.configured = true
+	s.mu.Unlock()
+
+	info := s.p.Info()
+	return &providerv1.HandshakeResponse{ProtocolVersion: version, Name: info.Name, Version: info.Version}, nil
+}
+
+// ready rejects calls before the handshake, which would reach a provider
+// that has not been configured.
+func (s *server) ready() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.configured {
+		return status.Error(codes.FailedPrecondition, "handshake required")
+	}
+	return nil
+}
+
+func (s *server) CheckAccess(ctx context.Context, _ *providerv1.Empty) (*providerv1.Empty, error) {
+	if err := s.ready(); err != nil {
+		return nil, err
+	}
+	if err := s.p.CheckAccess(ctx); err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	return &providerv1.Empty{}, nil
+}
+
+func (s *server) CreateEnvironment(req *providerv1.CreateEnvironmentRequest, stream grpc.ServerStreamingServer[providerv1.OperationUpdate]) error {
+	if err := s.ready(); err != nil {
+		return err
+	}
+	if req.Spec == nil {
+		return status.Error(codes.InvalidArgument, "spec is required")
+	}
+	progress := newProgress(stream.Send)
+	env, err := s.p.Create(stream.Context(), specFromProto(req.Spec), progress)
+	if err != nil {
+		return progress.finish(err, nil)
+	}
+	return progress.finish(nil, environmentToProto(env))
+}
+
+func (s *server) DestroyEnvironment(req *providerv1.DestroyEnvironmentRequest, stream grpc.ServerStreamingServer[providerv1.OperationUpdate]) error {
+	if err := s.ready(); err != nil {
+		return err
+	}
+	if req.Environment == nil {
+		return status.Error(codes.InvalidArgument, "environment is required")
+	}
+	progress := newProgress(stream.Send)
+	return progress.finish(s.p.Destroy(stream.Context(), environmentFromProto(req.Environment), progress), nil)
+}
+
+func (s *server) ScaleEnvironment(req *providerv1.ScaleEnvironmentRequest, stream grpc.ServerStreamingServer[providerv1.OperationUpdate]) error {
+	if err := s.ready(); err != nil {
+		return err
+	}
+	scaler, ok := s.p.(Scaler)
+	if !ok {
+		return status.Error(codes.Unimplemented, "scaling is not supported")
+	}
+	progress := newProgress(stream.Send)
+	return progress.finish(scaler.Scale(stream.Context(), req.Key, int(req.Replicas), progress), nil)
+}
+
+func (s *server) ListEnvironments(ctx context.Context, _ *providerv1.Empty) (*providerv1.ListEnvironmentsResponse, error) {
+	if err := s.ready(); err != nil {
+		return nil, err
+	}
+	envs, err := s.p.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resp := &providerv1.ListEnvironmentsResponse{}
+	for _, env := range envs {
+		resp.Environments = append(resp.Environments, environmentToProto(env))
+	}
+	return resp, nil
+}
+
+// GetEnvironmentStatus is answered from List, which the backend is the
+// source of truth for.
+func (s *server) GetEnvironmentStatus(ctx context.Context, req *providerv1.GetEnvironmentStatusRequest) (*providerv1.EnvironmentStatus, error) {
+	if err := s.ready(); err != nil {
+		return nil, err
+	}
+	envs, err := s.p.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, env := range envs {
+		if env.Key == req.Key {
+			return &providerv1.EnvironmentStatus{Found: true, Environment: environmentToProto(env)}, nil
+		}
+	}
+	return &providerv1.EnvironmentStatus{}, nil
+}
+
+func (s *server) StreamLogs(req *providerv1.StreamLogsRequest, stream grpc.ServerStreamingServer[providerv1.LogEntry]) error {
+	if err := s.ready(); err != nil {
+		return err
+	}
+	streamer, ok := s.p.(LogStreamer)
+	if !ok {
+		return status.Error(codes.Unimplemented, "log streaming is not supported")
+	}
+	lr := LogRequest{Key: req.Key, Service: req.Service, Follow: req.Follow, TailLines: req.TailLines}
+	if req.Since != nil {
+		lr.Since = req.Since.AsTime()
+	}
+	err := streamer.Logs(stream.Context(), lr, func(e LogEntry) error {
+		return stream.Send(&providerv1.LogEntry{
+			Timestamp: timestamppb.New(e.Time),
+			Service:   e.Service,
+			Stream:    e.Stream,
+			Line:      e.Line,
+		})
+	})
+	if ctxErr := stream.Context().Err(); ctxErr != nil {
+		return status.FromContextError(ctxErr).Err()
+	}
+	return err
+}
+
+func (s *server) GetMetrics(ctx context.Context, req *providerv1.GetMetricsRequest) (*providerv1.EnvironmentMetrics, error) {
+	if err := s.ready(); err != nil {
+		return nil, err
+	}
+	reporter, ok := s.p.(MetricsReporter)
+	if !ok {
+		return nil, status.Error(codes.Unimplemented, "metrics are not supported")
+	}
+	m, err := reporter.Metrics(ctx, req.Key)
+	if err != nil {
+		return nil, err
+	}
+	return &providerv1.EnvironmentMetrics{CpuMillicores: m.CPUMillicores, MemoryBytes: m.MemoryBytes, Custom: m.Custom}, nil
+}
+
+func (s *server) GetCapabilities(context.Context, *providerv1.Empty) (*providerv1.ProviderCapabilities, error) {
+	caps := s.p.Info().Capabilities
+	_, logs := s.p.(LogStreamer)
+	_, metrics := s.p.(MetricsReporter)
+	resp := &providerv1.ProviderCapabilities{
+		SupportsScaleToZero:          caps.ScaleToZero,
+		SupportsCustomDomains:        caps.CustomDomains,
+		SupportsPersistentStorage:    caps.PersistentStorage,
+		SupportsDatabaseProvisioning: caps.DatabaseProvisioning,
+		SupportedDatabases:           caps.Databases,
+		SupportsLogs:                 logs,
+		SupportsMetrics:              metrics,
+		ResourceLimits: &providerv1.ResourceLimits{
+			MaxCpu:          caps.MaxCPU,
+			MaxMemory:       caps.MaxMemory,
+			MaxEnvironments: int32(caps.MaxEnvironments),
+		},
+	}
+	if len(caps.ConfigSchema) > 0 {
+		resp.ConfigurationSchema = map[string]*providerv1.ConfigSchema{}
+		for path, f := range caps.ConfigSchema {
+			resp.ConfigurationSchema[path] = &providerv1.ConfigSchema{Type: f.Type, Description: f.Description, Required: f.Required}
+		}
+	}
+	return resp, nil
+}
+
+func (s *server) ValidateConfiguration(ctx context.Context, req *providerv1.ValidateConfigurationRequest) (*providerv1.ValidationResult, error) {
+	v, ok := s.p.(Validator)
+	if !ok {
+		return &providerv1.ValidationResult{Valid: true}, nil
+	}
+	res := &providerv1.ValidationResult{Valid: true}
+	for _, e := range v.Validate(ctx, Config{Data: req.Config}) {
+		res.Valid = false
+		res.Errors = append(res.Errors, &providerv1.ValidationError{Path: e.Path, Message: e.Message})
+	}
+	return res, nil
+}
+
+func specFromProto(s *providerv1.EnvironmentSpec) Spec {
+	return Spec{
+		Key:        s.Key,
+		Name:       s.Name,
+		Project:    s.Project,
+		Repository: s.Repository,
+		RefType:    s.RefType,
+		RefName:    s.RefName,
+		CommitSHA:  s.CommitSha,
+		Images:     s.Images,
+		Env:        s.Env,
+		Vars:       s.Vars,
+	}
+}
+
+func environmentToProto(env Environment) *providerv1.Environment {
+	return &providerv1.Environment{
+		Key:       env.Key,
+		Name:      env.Name,
+		CommitSha: env.CommitSHA,
+		Url:       env.URL,
+		Headers:   env.Headers,
+		Ready:     env.Ready,
+		Message:   env.Message,
+	}
+}
+
+func environmentFromProto(env *providerv1.Environment) Environment {
+	return Environment{
+		Key:       env.Key,
+		Name:      env.Name,
+		CommitSHA: env.CommitSha,
+		URL:       env.Url,
+		Headers:   env.Headers,
+		Ready:     env.Ready,
+		Message:   env.Message,
+	}
+}
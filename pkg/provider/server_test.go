@@ -0,0 +1,180 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	providerv1 "github.com/ephlabs/eph/api/eph/provider/v1"
+)
+
+type stubProvider struct {
+	configured Config
+}
+
+func (p *stubProvider) Info() Info {
+	return Info{Name: "stub", Version: "1.2.3", Capabilities: Capabilities{ScaleToZero: true, MaxCPU: "2"}}
+}
+
+func (p *stubProvider) Configure(_ context.Context, cfg Config) error {
+	p.configured = cfg
+	return nil
+}
+
+func (p *stubProvider) CheckAccess(context.Context) error { return nil }
+
+func (p *stubProvider) Create(_ context.Context, spec Spec, progress *Progress) (Environment, error) {
+	if spec.Name == "broken" {
+		return Environment{}, errors.New("image not found")
+	}
+	if err := progress.Report(150, "pulling images"); err != nil {
+		return Environment{}, err
+	}
+	progress.Output("database_url", "postgres://db")
+	progress.Created("container", spec.Name+"-web", "c1")
+	return Environment{Key: spec.Key, Name: spec.Name, Ready: true}, nil
+}
+
+func (p *stubProvider) Destroy(context.Context, Environment, *Progress) error { return nil }
+
+func (p *stubProvider) List(context.Context) ([]Environment, error) {
+	return []Environment{{Key: "org/app/pr/1", Name: "calm-river"}}, nil
+}
+
+func (p *stubProvider) Validate(_ context.Context, cfg Config) []ValidationError {
+	var doc struct {
+		Name string `yaml:"name"`
+	}
+	if err := cfg.Decode(&doc); err != nil || doc.Name == "" {
+		return []ValidationError{{Path: "name", Message: "is required"}}
+	}
+	return nil
+}
+
+func dial(t *testing.T, p Provider) providerv1.ProviderClient {
+	t.Helper()
+	l := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	providerv1.RegisterProviderServer(srv, NewServer(p))
+	go srv.Serve(l)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return l.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return providerv1.NewProviderClient(conn)
+}
+
+func updates(t *testing.T, stream grpc.ServerStreamingClient[providerv1.OperationUpdate]) []*providerv1.OperationUpdate {
+	t.Helper()
+	var out []*providerv1.OperationUpdate
+	for {
+		u, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return out
+		}
+		require.NoError(t, err)
+		out = append(out, u)
+	}
+}
+
+func TestHandshake(t *testing.T) {
+	ctx := context.Background()
+	p := &stubProvider{}
+	c := dial(t, p)
+
+	_, err := c.ListEnvironments(ctx, &providerv1.Empty{})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err), "calls before the handshake are rejected")
+
+	_, err = c.Handshake(ctx, &providerv1.HandshakeRequest{ProtocolVersions: []uint32{2, 3}})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	assert.ErrorContains(t, err, "no common protocol version")
+
+	resp, err := c.Handshake(ctx, &providerv1.HandshakeRequest{
+		ProtocolVersions: []uint32{1, 2},
+		Config:           []byte("name: myapp\n"),
+		ConfigDir:        "/src/myapp",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, uint32(1), resp.ProtocolVersion)
+	assert.Equal(t, "stub", resp.Name)
+	assert.Equal(t, "1.2.3", resp.Version)
+	assert.Equal(t, Config{Data: []byte("name: myapp\n"), Dir: "/src/myapp"}, p.configured)
+
+	list, err := c.ListEnvironments(ctx, &providerv1.Empty{})
+	require.NoError(t, err)
+	require.Len(t, list.Environments, 1)
+	assert.Equal(t, "calm-river", list.Environments[0].Name)
+
+	st, err := c.GetEnvironmentStatus(ctx, &providerv1.GetEnvironmentStatusRequest{Key: "org/app/pr/1"})
+	require.NoError(t, err)
+	assert.True(t, st.Found)
+	st, err = c.GetEnvironmentStatus(ctx, &providerv1.GetEnvironmentStatusRequest{Key: "org/app/pr/2"})
+	require.NoError(t, err)
+	assert.False(t, st.Found)
+}
+
+func TestCreateEnvironment(t *testing.T) {
+	ctx := context.Background()
+	c := dial(t, &stubProvider{})
+	_, err := c.Handshake(ctx, &providerv1.HandshakeRequest{ProtocolVersions: SupportedVersions})
+	require.NoError(t, err)
+
+	stream, err := c.CreateEnvironment(ctx, &providerv1.CreateEnvironmentRequest{Spec: &providerv1.EnvironmentSpec{Key: "org/app/pr/1", Name: "calm-river"}})
+	require.NoError(t, err)
+	us := updates(t, stream)
+	require.Len(t, us, 2)
+	assert.Equal(t, providerv1.OperationStatus_OPERATION_STATUS_RUNNING, us[0].Status)
+	assert.Equal(t, "pulling images", us[0].Message)
+	assert.Equal(t, int32(100), us[0].ProgressPercent, "progress is clamped")
+	assert.Equal(t, providerv1.OperationStatus_OPERATION_STATUS_SUCCEEDED, us[1].Status)
+	assert.Equal(t, us[0].OperationId, us[1].OperationId)
+	assert.Equal(t, map[string]string{"database_url": "postgres://db"}, us[1].Outputs)
+	assert.Equal(t, "calm-river-web", us[1].CreatedResources[0].Name)
+	assert.True(t, us[1].Environment.Ready)
+
+	stream, err = c.CreateEnvironment(ctx, &providerv1.CreateEnvironmentRequest{Spec: &providerv1.EnvironmentSpec{Name: "broken"}})
+	require.NoError(t, err)
+	us = updates(t, stream)
+	require.Len(t, us, 1)
+	assert.Equal(t, providerv1.OperationStatus_OPERATION_STATUS_FAILED, us[0].Status)
+	assert.Equal(t, "image not found", us[0].Message)
+
+	scale, err := c.ScaleEnvironment(ctx, &providerv1.ScaleEnvironmentRequest{Key: "org/app/pr/1"})
+	require.NoError(t, err)
+	_, err = scale.Recv()
+	assert.Equal(t, codes.Unimplemented, status.Code(err))
+}
+
+func TestCapabilitiesAndValidation(t *testing.T) {
+	ctx := context.Background()
+	c := dial(t, &stubProvider{})
+
+	caps, err := c.GetCapabilities(ctx, &providerv1.Empty{})
+	require.NoError(t, err)
+	assert.True(t, caps.SupportsScaleToZero)
+	assert.False(t, caps.SupportsLogs, "stubProvider does not implement LogStreamer")
+	assert.Equal(t, "2", caps.ResourceLimits.MaxCpu)
+
+	res, err := c.ValidateConfiguration(ctx, &providerv1.ValidateConfigurationRequest{Config: []byte("version: \"1\"\n")})
+	require.NoError(t, err)
+	assert.False(t, res.Valid)
+	assert.Equal(t, "name", res.Errors[0].Path)
+}
+
+func TestServeNotPlugin(t *testing.T) {
+	t.Setenv(EnvMagicCookie, "")
+	assert.ErrorIs(t, Serve(&stubProvider{}), ErrNotPlugin)
+}